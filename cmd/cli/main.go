@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	iofs "io/fs"
+	"os"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/messaging"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/migrate"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/migrations"
+	pkg "github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/mail"
+)
+
+const usage = `Usage:
+  cli dlq list [-limit N]     Show messages in the dead-letter queue
+  cli dlq replay [-id ID]     Move dead letters back to the main queue (all, or the message with ID)
+  cli email preview -template NAME [-locale LOCALE] [-body VALUE] [-format html|text]
+                              Render an email template with sample data
+  cli migrate up [-dir DIR]   Apply the pending migrations (embedded ones, or those of DIR)
+  cli migrate down [-steps N] [-dir DIR]
+                              Revert the last N applied migrations (default 1)
+  cli migrate baseline -version N [-dir DIR]
+                              Record the migrations up to N as applied without running them,
+                              for a database created before the migrations were tracked
+  cli migrate status [-dir DIR]
+                              Show which migrations are applied
+  cli migrate create -name NAME [-dir DIR]
+                              Create an empty migration and its down file in DIR (default migrations)
+`
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "dlq":
+		runDLQ()
+	case "email":
+		runEmail()
+	case "migrate":
+		runMigrate()
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+}
+
+// runDLQ inspects or replays the dead-letter queue.
+func runDLQ() {
+	broker := newBroker()
+	defer broker.Close()
+
+	switch os.Args[2] {
+	case "list":
+		fs := flag.NewFlagSet("dlq list", flag.ExitOnError)
+		limit := fs.Int("limit", 20, "maximum number of messages to show")
+		fs.Parse(os.Args[3:])
+
+		deadLetters, err := broker.InspectDeadLetters(*limit)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error inspecting dead letters:", err)
+			os.Exit(1)
+		}
+
+		out, _ := json.MarshalIndent(deadLetters, "", "  ")
+		fmt.Println(string(out))
+	case "replay":
+		fs := flag.NewFlagSet("dlq replay", flag.ExitOnError)
+		id := fs.String("id", "", "message ID to replay (default: all)")
+		fs.Parse(os.Args[3:])
+
+		replayed, err := broker.ReplayDeadLetters(*id)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error replaying dead letters:", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Replayed %d messages\n", replayed)
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+}
+
+// newBroker connects to RabbitMQ, the only dependency of the dlq commands.
+func newBroker() *messaging.Broker {
+	cfg, err := app.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading config:", err)
+		os.Exit(1)
+	}
+
+	a, err := app.New(cfg, app.WithQueue())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error connecting:", err)
+		os.Exit(1)
+	}
+	return messaging.NewBroker(a)
+}
+
+// runEmail renders an email template to stdout.
+func runEmail() {
+	if os.Args[2] != "preview" {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("email preview", flag.ExitOnError)
+	name := fs.String("template", "", "template name, e.g. otp_login")
+	locale := fs.String("locale", constants.DefaultLocale, "locale to render")
+	body := fs.String("body", "123456", "sample value for the template body (link, code or password)")
+	format := fs.String("format", "html", "part to print: html or text")
+	fs.Parse(os.Args[3:])
+
+	rendered, err := pkg.RenderEmail(models.EmailData{
+		Template: *name,
+		Locale:   *locale,
+		Body:     *body,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error rendering email:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Subject:", rendered.Subject)
+	fmt.Println()
+	if *format == "text" {
+		fmt.Println(rendered.Text)
+	} else {
+		fmt.Println(rendered.HTML)
+	}
+}
+
+// runMigrate applies, reverts, lists or creates schema migrations.
+func runMigrate() {
+	command := os.Args[2]
+	fs := flag.NewFlagSet("migrate "+command, flag.ExitOnError)
+	dir := fs.String("dir", "", "directory of the migrations (default: the migrations embedded in the binary)")
+	steps := fs.Int("steps", 1, "number of migrations to revert")
+	name := fs.String("name", "", "name of the new migration, e.g. add_users_bio")
+	version := fs.Int64("version", 0, "last migration already applied to the database")
+
+	switch command {
+	case "create":
+		fs.Parse(os.Args[3:])
+		if *dir == "" {
+			*dir = constants.MigrationsDir
+		}
+
+		paths, err := migrate.Create(*dir, *name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error creating migration:", err)
+			os.Exit(1)
+		}
+		for _, path := range paths {
+			fmt.Println("Created", path)
+		}
+	case "up", "down", "baseline", "status":
+		fs.Parse(os.Args[3:])
+		migrator, closeDB := newMigrator(*dir)
+		ctx := context.Background()
+		var err error
+		switch command {
+		case "up":
+			var applied []migrate.Migration
+			applied, err = migrator.Up(ctx)
+			for _, migration := range applied {
+				fmt.Println("Applied", migration)
+			}
+			if err == nil {
+				fmt.Printf("Applied %d migrations\n", len(applied))
+			}
+		case "down":
+			var reverted []migrate.Migration
+			reverted, err = migrator.Down(ctx, *steps)
+			for _, migration := range reverted {
+				fmt.Println("Reverted", migration)
+			}
+			if err == nil {
+				fmt.Printf("Reverted %d migrations\n", len(reverted))
+			}
+		case "baseline":
+			var recorded []migrate.Migration
+			recorded, err = migrator.Baseline(ctx, *version)
+			for _, migration := range recorded {
+				fmt.Println("Recorded", migration)
+			}
+			if err == nil {
+				fmt.Printf("Recorded %d migrations\n", len(recorded))
+			}
+		case "status":
+			var statuses []migrate.Status
+			statuses, err = migrator.Status(ctx)
+			if err == nil {
+				out, _ := json.MarshalIndent(statuses, "", "  ")
+				fmt.Println(string(out))
+			}
+		}
+		closeDB()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error running migrations:", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+}
+
+// newMigrator connects to PostgreSQL and loads the migrations of dir, or the embedded ones when dir is empty.
+// The returned function closes the connection.
+func newMigrator(dir string) (*migrate.Migrator, func()) {
+	cfg, err := app.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading config:", err)
+		os.Exit(1)
+	}
+
+	a, err := app.New(cfg, app.WithDatabase())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error connecting:", err)
+		os.Exit(1)
+	}
+
+	var files iofs.FS = migrations.FS
+	if dir != "" {
+		files = os.DirFS(dir)
+	}
+
+	migrator, err := migrate.New(a.DB, files, a.MigrationSettings())
+	if err != nil {
+		a.Close()
+		fmt.Fprintln(os.Stderr, "Error loading migrations:", err)
+		os.Exit(1)
+	}
+	return migrator, func() { a.Close() }
+}
@@ -1,54 +1,49 @@
 package main
 
 import (
-	"fmt"
-	"sync"
-	"time"
-
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
-	"github.com/robfig/cron/v3"
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/jobs"
 )
 
 func main() {
-	c := cron.New(cron.WithSeconds())
-
-	_, err := c.AddFunc("@every 1m", func() {
-		fmt.Println("Job running every minute: Tai dev!")
-	})
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
+	cfg, err := app.LoadConfig()
 	if err != nil {
-		fmt.Println("Error adding cron job to run every 1 minute:", err)
-		return
+		slog.Error("Error loading config", "error", err)
+		os.Exit(1)
 	}
 
-	var hourJobDone sync.Once
-
-	_, err = c.AddFunc("0 0 * * * *", func() {
-		hourJobDone.Do(func() {
-			err := repo.UpdateVerificationBulk(global.DB)
-			if err != nil {
-				fmt.Println("Error updating verification records:", err)
-			}
-			fmt.Println("Job running every hour: Update Verification not used!")
-		})
-	})
-
+	a, err := app.New(cfg, app.WithTracing(constants.ServiceNameCron), app.WithDatabase(), app.WithCache(), app.WithFirebase(), app.WithExportStorage())
 	if err != nil {
-		fmt.Println("Error adding cron job to run every hour:", err)
-		return
+		slog.Error("Error starting cron jobs", "error", err)
+		os.Exit(1)
 	}
+	defer a.Close()
 
-	c.Start()
+	runner, err := jobs.NewRunner(a, jobs.DefaultJobs(a))
+	if err != nil {
+		slog.Error("Error registering cron jobs", "error", err)
+		os.Exit(1)
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	runner.Start()
+	slog.Info("Job runner started")
 
-	time.AfterFunc(1*time.Minute, func() {
-		fmt.Println("Program exiting gracefully...")
-		c.Stop()
-		wg.Done()
-	})
+	// Run until SIGINT or SIGTERM, then let running jobs finish their current batch
+	<-ctx.Done()
+	runner.Stop(constants.CronShutdownTimeout)
 
-	wg.Wait()
+	for _, metrics := range runner.Metrics() {
+		slog.Info("Job metrics", "job", metrics.Name, "runs", metrics.Runs, "failures", metrics.Failures,
+			"skipped", metrics.Skipped, "rows", metrics.Rows)
+	}
 }
@@ -1,10 +1,61 @@
 package main
 
 import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/messaging"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/metrics"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := app.LoadConfig()
+	if err != nil {
+		slog.Error("Error loading config", "error", err)
+		os.Exit(1)
+	}
+
+	a, err := app.New(cfg, app.WithTracing(constants.ServiceNameQueue), app.WithDatabase(), app.WithQueue(), app.WithMailer(), app.WithExportStorage())
+	if err != nil {
+		slog.Error("Error starting queue", "error", err)
+		os.Exit(1)
+	}
+	defer a.Close()
+
+	broker := messaging.NewBroker(a)
+	defer broker.Close()
+	broker.RegisterDefaultHandlers(service.New(a))
+
+	// Queue depths and the database pool, when a metrics port is configured
+	if cfg.Metrics.QueuePort != "" {
+		go serveMetrics(a, broker)
+	}
+
+	// Publish outbox rows written by the server
+	go broker.RelayOutbox(ctx, constants.OutboxRelayInterval)
+
 	// Start consuming messages
-	messaging.ConsumerMessages()
+	broker.ConsumerMessages()
+}
+
+// serveMetrics serves /metrics on the metrics port of the consumer, with the depths of its queues and its database pool.
+func serveMetrics(a *app.App, broker *messaging.Broker) {
+	reg := metrics.NewRegistry(metrics.NewDBCollector(a.DB), metrics.NewQueueCollector(broker.QueueDepths))
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(reg, a.Cfg.Metrics.Token))
+
+	if err := http.ListenAndServe(":"+a.Cfg.Metrics.QueuePort, mux); err != nil {
+		slog.Error("Error serving metrics", "error", err)
+	}
 }
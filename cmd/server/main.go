@@ -1,8 +1,18 @@
 package main
 
 import (
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/routers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
 )
 
 // @title 	Server Auth
@@ -11,6 +21,64 @@ import (
 // @host 103.82.195.138:8000
 // @BasePath /v1
 func main() {
-	r := routers.NewRouter()
-	r.Run(":" + global.Cfg.Server.Port)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := app.LoadConfig()
+	if err != nil {
+		slog.Error("Error loading config", "error", err)
+		os.Exit(1)
+	}
+
+	// Emails and events are written to the outbox and sent by the queue binary, so no mailer or RabbitMQ here
+	a, err := app.New(cfg,
+		app.WithTracing(constants.ServiceNameServer),
+		app.WithDatabase(),
+		app.WithMigrations(),
+		app.WithCache(),
+		app.WithFirebase(),
+		app.WithSMS(),
+		app.WithGeoIP(),
+		app.WithIDToken(),
+		app.WithExportStorage(),
+	)
+	if err != nil {
+		slog.Error("Error starting server", "error", err)
+		os.Exit(1)
+	}
+	defer a.Close()
+
+	svc := service.New(a)
+	server := &http.Server{
+		Addr:    ":" + cfg.Server.Port,
+		Handler: routers.NewRouter(a, svc),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server listening", "addr", server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	// Run until SIGINT or SIGTERM, then finish the requests in flight and the jobs triggered manually
+	failed := false
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Error serving", "error", err)
+			failed = true
+		}
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), constants.ServerShutdownTimeout)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Error shutting down server", "error", err)
+		}
+		cancel()
+	}
+	svc.Close(constants.ServerShutdownTimeout)
+
+	if failed {
+		a.Close()
+		os.Exit(1)
+	}
 }
@@ -1,5 +1,25 @@
 package constants
 
+import "time"
+
+const (
+	KeyAuthPro      = "go_auth_pro"
+	KeyAuthProRetry = "go_auth_pro.retry"
+	KeyAuthProDLQ   = "go_auth_pro.dlq"
+)
+
+const (
+	HeaderRetryCount = "x-retry-count"
+	HeaderLastError  = "x-last-error"
+)
+
+const (
+	ConsumerMaxRetries     = 5
+	ConsumerRetryBaseDelay = 5 * time.Second
+)
+
 const (
-	KeyAuthPro = "go_auth_pro"
+	ProducerChannelPoolSize   = 5
+	ProducerConfirmTimeout    = 5 * time.Second
+	ProducerReconnectMaxDelay = 30 * time.Second
 )
@@ -7,13 +7,18 @@ const (
 	SpamKeyLogin            = "spam_user_login"
 	SpamKeyLinkVerification = "spam_user_link_verification"
 	SpamKeyForget           = "spam_user_forget"
+	SpamKeyOtpPhone         = "spam_user_otp_phone_%d"
+	SpamKeyLoginFailed      = "spam_user_login_failed_%d"
 	CacheProfileUser        = "user_profile_%s"
 	BlackListIP             = "blacklist_ips"
+	OtpChallengeKey         = "otp_challenge_%s"
 )
 const (
 	RequestThreshold                 = 5
 	RequestThresholdLinkVerification = 3
 	RequestThresholdForget           = 2
+	RequestThresholdOtpPhone         = 3
+	OtpChallengeMaxAttempts          = 5
 )
 
 const (
@@ -21,6 +26,7 @@ const (
 	ExtendedBlock  = 30 * time.Minute
 	ExpireDuration = 30 * time.Second
 	ExpireSevenDay = 7 * 24 * time.Hour
+	LoginOtpTTL    = 5 * time.Minute
 )
 
 const (
@@ -44,7 +44,8 @@ const (
 )
 
 const (
-	DeviceId = "X-Device-Id"
+	DeviceId      = "X-Device-Id"
+	WebhookSecret = "X-Webhook-Secret"
 )
 
 const (
@@ -63,3 +64,288 @@ const (
 const (
 	MB_1 = 1024 * 1024
 )
+
+const (
+	OtpChannelEmail = 10
+	OtpChannelSMS   = 20
+)
+
+const (
+	SMSDriverLog  = "log"
+	SMSDriverHTTP = "http"
+)
+
+const (
+	MailDriverSMTP   = "smtp"
+	MailDriverFile   = "file"
+	MailDriverMemory = "memory"
+)
+
+const (
+	MailStatusSent       = 10
+	MailStatusFailed     = 20
+	MailStatusSuppressed = 30
+	MailStatusBounced    = 40
+	MailStatusComplained = 50
+)
+
+const (
+	MailEventBounce    = "bounce"
+	MailEventComplaint = "complaint"
+
+	BounceTypeHard = "hard"
+	BounceTypeSoft = "soft"
+)
+
+const (
+	DefaultPhoneRegion = "VN"
+)
+
+const (
+	// Requests per second allowed by the rate limiter, and its burst
+	DefaultRateLimit = 5
+	DefaultRateBurst = 10
+)
+
+const (
+	OutboxStatusPending   = 10
+	OutboxStatusPublished = 20
+	OutboxStatusFailed    = 30
+)
+
+const (
+	OutboxRelayInterval = 2 * time.Second
+	OutboxBatchSize     = 50
+	OutboxMaxAttempts   = 10
+	// How long the secret of an email can still be sent, retries and dead-letter replays included
+	OutboxSecretTTL = 72 * time.Hour
+)
+
+const (
+	AggregateUser = "user"
+)
+
+const (
+	EventEmailSend      = "email.send"
+	EventUserRegistered = "user.registered"
+	EventSessionRevoked = "session.revoked"
+
+	EventDataExportRequested = "data_export.requested"
+)
+
+const (
+	AuditRegister               = "auth.register"
+	AuditAccountVerified        = "auth.account_verified"
+	AuditLoginSucceeded         = "auth.login_succeeded"
+	AuditLoginFailed            = "auth.login_failed"
+	AuditSocialLogin            = "auth.social_login"
+	AuditPasswordResetRequested = "auth.password_reset_requested"
+	AuditPasswordReset          = "auth.password_reset"
+	AuditLogout                 = "auth.logout"
+	AuditOtpSent                = "otp.sent"
+	AuditOtpVerified            = "otp.verified"
+	AuditOtpFailed              = "otp.failed"
+	AuditPasswordChanged        = "user.password_changed"
+	AuditTwoFactorChanged       = "user.two_factor_changed"
+	AuditProfileUpdated         = "user.profile_updated"
+	AuditPhoneVerified          = "user.phone_verified"
+	AuditEmailChangeRequested   = "user.email_change_requested"
+	AuditEmailChanged           = "user.email_changed"
+	AuditAccountDestroyed       = "user.account_destroyed"
+	AuditBlacklistIP            = "blacklist.ip_added"
+	AuditNewSignIn              = "auth.new_sign_in"
+	AuditDeviceRevoked          = "auth.device_revoked"
+	AuditOtpNewDeviceChanged    = "user.otp_new_device_changed"
+	AuditLoginRisk              = "auth.login_risk"
+	AuditDataExportRequested    = "user.data_export_requested"
+	AuditDataExportDownloaded   = "user.data_export_downloaded"
+	AuditDeletionCancelled      = "user.account_deletion_cancelled"
+	AuditAccountDeleted         = "user.account_deleted"
+	AuditJobTriggered           = "admin.job_triggered"
+)
+
+const (
+	RevokeDeviceTokenTTL = 7 * 24 * time.Hour
+	DeviceTypeMaxLength  = 200
+)
+
+const (
+	RiskDecisionAllow  = "allow"
+	RiskDecisionStepUp = "step_up"
+	RiskDecisionBlock  = "block"
+
+	RiskSignalNewDevice            = "new_device"
+	RiskSignalNewNetwork           = "new_network"
+	RiskSignalIPChange             = "ip_change"
+	RiskSignalFailedAttempts       = "failed_attempts"
+	RiskSignalBlacklistedNeighbour = "blacklisted_neighbour"
+	RiskSignalImpossibleTravel     = "impossible_travel"
+)
+
+const (
+	DefaultRiskMediumScore       = 30
+	DefaultRiskHighScore         = 70
+	DefaultRiskFailedAttemptsMin = 3
+	DefaultRiskMaxTravelSpeed    = 900.0
+	// Positions closer than this are within the accuracy of GeoIP and never count as travel
+	RiskMinTravelDistanceKm = 200.0
+)
+
+const (
+	DataExportStatusPending    = 10
+	DataExportStatusProcessing = 20
+	DataExportStatusReady      = 30
+	DataExportStatusFailed     = 40
+	DataExportStatusExpired    = 50
+)
+
+const (
+	DefaultExportDir = "tmp/exports"
+	DefaultExportTTL = 48 * time.Hour
+	ExportDataFile   = "data.json"
+)
+
+const (
+	DefaultDeletionGracePeriod = 30 * 24 * time.Hour
+	AccountDeletionBatchSize   = 100
+	// Placeholder email of an anonymised account, formatted with the user ID
+	AnonymisedEmailFormat = "deleted-%d@deleted.invalid"
+)
+
+const (
+	// Clock difference tolerated when checking the expiry and issue time of social login ID tokens
+	IDTokenLeeway    = time.Minute
+	JWKSFetchTimeout = 10 * time.Second
+)
+
+const (
+	// Number of previous passwords a new password is checked against
+	PasswordHistoryDepth = 10
+)
+
+const (
+	// Lease of a job and the counter its fencing tokens are drawn from, formatted with the job name
+	JobLeaseKey           = "job_lease:%s"
+	JobFenceKey           = "job_fence:%s"
+	JobLeaseTTL           = 2 * time.Minute
+	JobLeaseRenewInterval = 40 * time.Second
+	CronShutdownTimeout   = 30 * time.Second
+	// ServerShutdownTimeout is how long the server waits for the requests in flight and the jobs triggered manually
+	ServerShutdownTimeout = 30 * time.Second
+
+	JobVerificationCleanup = "verification_cleanup"
+	JobOtpCleanup          = "otp_cleanup"
+	JobDeviceCleanup       = "device_cleanup"
+	JobPasswordHistory     = "password_history_cleanup"
+	JobAccountDeletion     = "account_deletion"
+	JobOutboxCleanup       = "outbox_cleanup"
+	JobDataExportExpiry    = "data_export_expiry"
+
+	// Schedules use the seconds field: second minute hour day month weekday
+	JobVerificationCleanupSpec = "0 0 * * * *"
+	JobOtpCleanupSpec          = "0 */15 * * * *"
+	JobDeviceCleanupSpec       = "0 0 3 * * *"
+	JobPasswordHistorySpec     = "0 30 3 * * *"
+	JobAccountDeletionSpec     = "0 30 * * * *"
+	JobOutboxCleanupSpec       = "0 15 * * * *"
+	JobDataExportExpirySpec    = "0 45 * * * *"
+)
+
+const (
+	JobRunStatusRunning   = 10
+	JobRunStatusSucceeded = 20
+	JobRunStatusFailed    = 30
+
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+
+	JobRunsDefaultLimit = 20
+	JobRunsMaxLimit     = 100
+)
+
+const (
+	DefaultRetentionVerificationDays = 7
+	DefaultRetentionOtpDays          = 1
+	DefaultRetentionDeviceDays       = 90
+	DefaultRetentionOutboxDays       = 7
+	DefaultRetentionBatchSize        = 500
+)
+
+const (
+	AuditDefaultLimit = 50
+	AuditMaxLimit     = 200
+)
+
+const (
+	DefaultLocale = "en"
+)
+
+var SupportedLocales = []string{"en", "vi"}
+
+const (
+	EmailTemplateDir = "templates/email"
+
+	EmailTemplateRegister           = "register"
+	EmailTemplateResendVerification = "resend_verification"
+	EmailTemplateVerifySuccess      = "verify_success"
+	EmailTemplateOtpLogin           = "otp_login"
+	EmailTemplateForgetPassword     = "forget_password"
+	EmailTemplateUpdateEmailOtp     = "update_email_otp"
+	EmailTemplateNewSignIn          = "new_sign_in"
+	EmailTemplateSignInBlocked      = "sign_in_blocked"
+	EmailTemplateDataExportReady    = "data_export_ready"
+	EmailTemplateAccountDeletion    = "account_deletion"
+)
+
+const (
+	// Table of the applied migrations, and the key of the advisory lock held while they are applied
+	// so two processes starting together do not apply the same migration twice
+	MigrationTable   = "schema_migrations"
+	MigrationLockKey = 7402115386
+	MigrationsDir    = "migrations"
+	// Setting read by the migrations with current_setting: the region of phone.defaultregion
+	MigrationSettingPhoneRegion = "app.phone_region"
+)
+
+const (
+	// Label values of the metrics
+	MetricResultSuccess   = "success"
+	MetricResultFailure   = "failure"
+	MetricResultTwoFactor = "two_factor"
+	MetricRouteUnmatched  = "unmatched"
+
+	LoginMethodIdentifier = "identifier"
+	LoginMethodSocial     = "social"
+	LoginMethodOtp        = "otp"
+
+	OtpPurposeLogin       = "login"
+	OtpPurposeVerifyPhone = "verify_phone"
+	OtpPurposeUpdateEmail = "update_email"
+
+	SpamActionRegister         = "register"
+	SpamActionLogin            = "login"
+	SpamActionLinkVerification = "link_verification"
+	SpamActionForget           = "forget"
+	SpamActionOtpPhone         = "otp_phone"
+)
+
+const (
+	// Exporters of the traces, and the service name of each binary in them
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+
+	ServiceNameServer = "go-secure-auth-server"
+	ServiceNameQueue  = "go-secure-auth-queue"
+	ServiceNameCron   = "go-secure-auth-cron"
+
+	// How long the spans not exported yet may take to be flushed when a binary stops
+	TracingShutdownTime = 5 * time.Second
+)
+
+const (
+	// The request ID is read from and returned in this header, and kept in the gin context under RequestIDKey
+	HeaderRequestID = "X-Request-ID"
+	RequestIDKey    = "request_id"
+	// A request ID sent by the caller longer than this is replaced
+	RequestIDMaxLength = 128
+)
@@ -1,15 +1,45 @@
 package utils
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/lib/pq"
 )
 
+// ContextErrorCode returns the error code of an operation that failed because it was cancelled or ran out of time.
+// It returns ErrCodeRequestCanceled when ctx, the context of the request, was cancelled, and ErrCodeRequestTimeout
+// when the operation ran out of time: database/sql reports it as context.DeadlineExceeded and Postgres as query_canceled.
+// It returns 0 for any other error.
+func ContextErrorCode(ctx context.Context, err error) int {
+	if err == nil {
+		return 0
+	}
+
+	switch ctx.Err() {
+	case context.Canceled:
+		return response.ErrCodeRequestCanceled
+	case context.DeadlineExceeded:
+		return response.ErrCodeRequestTimeout
+	}
+
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return response.ErrCodeRequestCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return response.ErrCodeRequestTimeout
+	case errors.As(err, &pqErr) && pqErr.Code == response.QueryCanceled:
+		return response.ErrCodeRequestTimeout
+	}
+	return 0
+}
+
 func HandleDBError(err error) string {
 	if pqErr, ok := err.(*pq.Error); ok {
-		log.Printf("PostgreSQL Error Code: %s", pqErr.Code)
+		slog.Debug("PostgreSQL error", "pg_code", string(pqErr.Code))
 
 		switch pqErr.Code {
 		case response.SuccessfulCompletion:
@@ -1,7 +1,7 @@
 package configs
 
 import (
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
@@ -14,7 +14,8 @@ func LoadConfig(path string) (config models.Config, err error) {
 	// Load environment variables from .env file
 	err = godotenv.Load()
 	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+		slog.Error("Error loading .env file", "error", err)
+		os.Exit(1)
 	}
 
 	viper.AddConfigPath(path)
@@ -1317,6 +1317,9 @@ const docTemplate = `{
                 "now": {
                     "type": "integer"
                 },
+                "request_id": {
+                    "type": "string"
+                },
                 "status": {
                     "type": "integer"
                 }
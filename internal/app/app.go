@@ -0,0 +1,274 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/controllers/initialization"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/migrate"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/migrations"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/geoip"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/idtoken"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/logger"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/mailer"
+	pkg "github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/setting"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/sms"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/storage"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/redis/go-redis/v9"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Cache is the Redis client. *redis.Client implements it, and so does a client of miniredis in tests.
+type Cache = redis.UniversalClient
+
+// Queue is the RabbitMQ connection; *amqp.Connection implements it.
+type Queue interface {
+	Channel() (*amqp.Channel, error)
+	IsClosed() bool
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// App holds the configuration and the connections shared by the services, middlewares, jobs and consumers.
+// It is built by New with one option per dependency, so every binary only connects to what it uses;
+// a dependency that was not requested is nil. Tests build an App literal with fakes instead,
+// such as the in-memory repositories of the memory package.
+type App struct {
+	Cfg      models.Config
+	DB       *sql.DB
+	Repos    repo.Repositories
+	Cache    Cache
+	Firebase *helpers.FirebaseAuth
+	Queue    Queue
+	Mailer   mailer.Mailer
+	SMS      sms.SMSSender
+	GeoIP    *geoip.Locator
+	IDToken  *idtoken.Verifier
+	Exports  storage.Storage
+	Tracer   *sdktrace.TracerProvider
+}
+
+// Option connects or creates one dependency of the App.
+type Option func(a *App) error
+
+// LoadConfig reads the configuration from configs/yaml, config.dev or config.prod depending on ENV.
+func LoadConfig() (models.Config, error) {
+	return configs.LoadConfig("configs/yaml")
+}
+
+// New creates an App from the configuration and the given options, applied in order.
+// It first installs the JSON logger of the configuration as the default logger, so the options log with it.
+// The logs go to stderr, where the standard logger wrote them, leaving stdout to the output of the CLI.
+// When an option fails, the dependencies already connected are closed and the error is returned.
+func New(cfg models.Config, options ...Option) (*App, error) {
+	slog.SetDefault(logger.New(cfg.Log, os.Stderr))
+
+	a := &App{Cfg: cfg}
+	for _, option := range options {
+		if err := option(a); err != nil {
+			a.Close()
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// milliseconds converts a timeout of the configuration to a duration.
+func milliseconds(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// WithTracing installs the tracer provider of the configured exporter, with the spans of the binary named serviceName.
+// It comes first, so the connections made by the other options are traced. Without an exporter Tracer stays nil.
+func WithTracing(serviceName string) Option {
+	return func(a *App) error {
+		provider, err := tracing.NewProvider(a.Cfg.Tracing, serviceName)
+		if err != nil {
+			return fmt.Errorf("error starting tracing: %w", err)
+		}
+		a.Tracer = provider
+		return nil
+	}
+}
+
+// WithDatabase connects to PostgreSQL and creates the repositories on top of it.
+func WithDatabase() Option {
+	return func(a *App) error {
+		db, err := initialization.ConnectPG(a.Cfg)
+		if err != nil {
+			return fmt.Errorf("error connecting to database: %w", err)
+		}
+		a.DB = db
+		a.Repos = repo.NewRepositories(db, repo.Timeouts{
+			Query:       milliseconds(a.Cfg.Timeout.Query),
+			Transaction: milliseconds(a.Cfg.Timeout.Transaction),
+		})
+		return nil
+	}
+}
+
+// WithMigrations applies the embedded migrations that are still pending when database.migrateonstart is set.
+// It must come after WithDatabase.
+func WithMigrations() Option {
+	return func(a *App) error {
+		if !a.Cfg.Database.MigrateOnStart {
+			return nil
+		}
+
+		migrator, err := migrate.New(a.DB, migrations.FS, a.MigrationSettings())
+		if err != nil {
+			return fmt.Errorf("error loading migrations: %w", err)
+		}
+		applied, err := migrator.Up(context.Background())
+		for _, migration := range applied {
+			slog.Info("Applied migration", "migration", migration.String())
+		}
+		if err != nil {
+			return fmt.Errorf("error applying migrations: %w", err)
+		}
+		return nil
+	}
+}
+
+// MigrationSettings returns the settings the migrations read from the configuration,
+// such as the phone region assumed by the backfill of phone numbers to E.164.
+func (a *App) MigrationSettings() map[string]string {
+	return map[string]string{
+		constants.MigrationSettingPhoneRegion: helpers.PhoneRegion(a.Cfg.Phone.DefaultRegion),
+	}
+}
+
+// WithCache connects to Redis.
+func WithCache() Option {
+	return func(a *App) error {
+		cache, err := initialization.ConnectRedis(a.Cfg)
+		if err != nil {
+			return fmt.Errorf("error connecting to redis: %w", err)
+		}
+		a.Cache = cache
+		return nil
+	}
+}
+
+// WithFirebase initialises the Firebase Admin SDK, with its Authentication calls bounded by timeout.firebase.
+func WithFirebase() Option {
+	return func(a *App) error {
+		app, err := pkg.InitializeApp()
+		if err != nil {
+			return fmt.Errorf("error connecting to firebase: %w", err)
+		}
+		a.Firebase = &helpers.FirebaseAuth{App: app, Timeout: milliseconds(a.Cfg.Timeout.Firebase)}
+		return nil
+	}
+}
+
+// WithQueue connects to RabbitMQ.
+func WithQueue() Option {
+	return func(a *App) error {
+		conn, err := initialization.ConnectRabbitMQ(a.Cfg.RabbitMQ.URL)
+		if err != nil {
+			return fmt.Errorf("error connecting to rabbitmq: %w", err)
+		}
+		a.Queue = conn
+		return nil
+	}
+}
+
+// WithMailer creates the mailer of the configured driver.
+func WithMailer() Option {
+	return func(a *App) error {
+		a.Mailer = mailer.NewMailer(a.Cfg.Mail, a.Cfg.Gmail)
+		return nil
+	}
+}
+
+// WithSMS creates the SMS sender of the configured driver.
+func WithSMS() Option {
+	return func(a *App) error {
+		a.SMS = sms.NewSender(a.Cfg.SMS)
+		return nil
+	}
+}
+
+// WithGeoIP opens the GeoIP databases. They are optional: when they cannot be opened
+// the error is only printed, and the risk rules that need a location are skipped.
+func WithGeoIP() Option {
+	return func(a *App) error {
+		locator, err := geoip.Open(a.Cfg.GeoIP.CityPath, a.Cfg.GeoIP.ASNPath)
+		if err != nil {
+			slog.Warn("Error opening GeoIP databases", "error", err)
+			return nil
+		}
+		a.GeoIP = locator
+		return nil
+	}
+}
+
+// WithIDToken creates the verifier of social login ID tokens.
+func WithIDToken() Option {
+	return func(a *App) error {
+		verifier, err := idtoken.New(a.Cfg.Social)
+		if err != nil {
+			return fmt.Errorf("error loading id token keys: %w", err)
+		}
+		a.IDToken = verifier
+		return nil
+	}
+}
+
+// WithExportStorage opens the storage of the data export archives, in export.dir.
+// The queue consumer writes the archives, the server sends them and the cron jobs remove them,
+// so the three binaries must see the same directory: in Docker it is the export_data volume.
+func WithExportStorage() Option {
+	return func(a *App) error {
+		dir := a.Cfg.Export.Dir
+		if dir == "" {
+			dir = constants.DefaultExportDir
+		}
+		exports, err := storage.NewDir(dir)
+		if err != nil {
+			return fmt.Errorf("error opening export storage: %w", err)
+		}
+		a.Exports = exports
+		return nil
+	}
+}
+
+// Close closes every dependency the App holds and returns the errors joined.
+func (a *App) Close() error {
+	var errs []error
+	if a.Mailer != nil {
+		errs = append(errs, a.Mailer.Close())
+	}
+	if a.Queue != nil && !a.Queue.IsClosed() {
+		errs = append(errs, a.Queue.Close())
+	}
+	if a.Cache != nil {
+		errs = append(errs, a.Cache.Close())
+	}
+	if a.DB != nil {
+		errs = append(errs, a.DB.Close())
+	}
+	if a.GeoIP != nil {
+		errs = append(errs, a.GeoIP.Close())
+	}
+	// Last, so the spans of the work done before Close are exported
+	if a.Tracer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), constants.TracingShutdownTime)
+		defer cancel()
+		errs = append(errs, a.Tracer.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// GetMyAuditEvents lists the security events of the authenticated user's account.
+// It calls the GetMyAuditEvents function from the service package.
+// If the events are read, it returns a success response with them.
+func (ctl *Controller) GetMyAuditEvents(c *gin.Context) error {
+	result := ctl.svc.GetMyAuditEvents(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Audit events", result)
+	return nil
+}
+
+// GetAuditEvents lists the security events of every account for administrators.
+// It calls the GetAuditEvents function from the service package.
+// If the events are read, it returns a success response with them.
+func (ctl *Controller) GetAuditEvents(c *gin.Context) error {
+	result := ctl.svc.GetAuditEvents(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Audit events", result)
+	return nil
+}
@@ -1,7 +1,8 @@
 package controllers
 
 import (
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 )
@@ -10,8 +11,8 @@ import (
 // It calls the Register function from the service package and returns the result.
 // If the result is nil, it returns nil.
 // Otherwise, it sends a "Created" response with the result.
-func Register(c *gin.Context) error {
-	result := service.Register(c)
+func (ctl *Controller) Register(c *gin.Context) error {
+	result := ctl.svc.Register(c)
 	if result == nil {
 		return nil
 	}
@@ -20,8 +21,8 @@ func Register(c *gin.Context) error {
 }
 
 // ResendVerificationLink resends the verification link to the user.
-func ResendVerificationLink(c *gin.Context) error {
-	result := service.ResendVerificationLink(c)
+func (ctl *Controller) ResendVerificationLink(c *gin.Context) error {
+	result := ctl.svc.ResendVerificationLink(c)
 	if result == nil {
 		return nil
 	}
@@ -30,11 +31,11 @@ func ResendVerificationLink(c *gin.Context) error {
 }
 
 // VerificationAccount handles the verification of user accounts.
-// It calls the service.VerificationAccount function to perform the verification.
+// It calls the ctl.svc.VerificationAccount function to perform the verification.
 // If the verification is successful, it sends a success response using the response.Ok function.
 // If an error occurs during the verification process, it returns the error.
-func VerificationAccount(c *gin.Context) error {
-	result := service.VerificationAccount(c)
+func (ctl *Controller) VerificationAccount(c *gin.Context) error {
+	result := ctl.svc.VerificationAccount(c)
 	if result == nil {
 		return nil
 	}
@@ -46,11 +47,19 @@ func VerificationAccount(c *gin.Context) error {
 // It calls the LoginIdentifier function from the service package to perform the login identifier logic.
 // If the result is not nil, it sends a successful response with the result.
 // Returns an error if there was an issue during the process.
-func LoginIdentifier(c *gin.Context) error {
-	result := service.LoginIdentifier(c)
+func (ctl *Controller) LoginIdentifier(c *gin.Context) error {
+	result := ctl.svc.LoginIdentifier(c)
 	if result == nil {
+		observeLogin(c, constants.LoginMethodIdentifier, constants.MetricResultFailure)
 		return nil
 	}
+
+	// A user with two-factor authentication is only logged in once the OTP is verified
+	if _, twoFactor := result.(models.LoginTwoFactor); twoFactor {
+		observeLogin(c, constants.LoginMethodIdentifier, constants.MetricResultTwoFactor)
+	} else {
+		observeLogin(c, constants.LoginMethodIdentifier, constants.MetricResultSuccess)
+	}
 	response.Ok(c, "Login Identifier", result)
 	return nil
 }
@@ -59,19 +68,27 @@ func LoginIdentifier(c *gin.Context) error {
 // It calls the LoginSocial function from the service package to perform the login operation.
 // If the login is successful, it returns the result as a response using the Ok function from the response package.
 // If the login fails, it returns an error.
-func LoginSocial(c *gin.Context) error {
-	result := service.LoginSocial(c)
+func (ctl *Controller) LoginSocial(c *gin.Context) error {
+	result := ctl.svc.LoginSocial(c)
 	if result == nil {
+		observeLogin(c, constants.LoginMethodSocial, constants.MetricResultFailure)
 		return nil
 	}
+
+	// A login from an unknown device, or with a medium risk score, is only logged in once the OTP is verified
+	if _, twoFactor := result.(models.LoginTwoFactor); twoFactor {
+		observeLogin(c, constants.LoginMethodSocial, constants.MetricResultTwoFactor)
+	} else {
+		observeLogin(c, constants.LoginMethodSocial, constants.MetricResultSuccess)
+	}
 	response.Ok(c, "Login Social", result)
 	return nil
 }
 
 // ForgetPassword handles the forget password functionality.
 // It calls the service to process the forget password request and returns the result.
-func ForgetPassword(c *gin.Context) error {
-	result := service.ForgetPassword(c)
+func (ctl *Controller) ForgetPassword(c *gin.Context) error {
+	result := ctl.svc.ForgetPassword(c)
 	if result == nil {
 		return nil
 	}
@@ -81,8 +98,8 @@ func ForgetPassword(c *gin.Context) error {
 
 // ResetPassword handles the reset password functionality.
 // It calls the service to reset the password and returns the result.
-func ResetPassword(c *gin.Context) error {
-	result := service.ResetPassword(c)
+func (ctl *Controller) ResetPassword(c *gin.Context) error {
+	result := ctl.svc.ResetPassword(c)
 	if result == nil {
 		return nil
 	}
@@ -94,11 +111,35 @@ func ResetPassword(c *gin.Context) error {
 // It calls the RenewToken function from the service package to renew the token.
 // If the token renewal is successful, it sends an "OK" response with the renewed token.
 // If there is an error during the token renewal process, it returns the error.
-func RenewToken(c *gin.Context) error {
-	result := service.RenewToken(c)
+func (ctl *Controller) RenewToken(c *gin.Context) error {
+	result := ctl.svc.RenewToken(c)
 	if result == nil {
 		return nil
 	}
 	response.Ok(c, "Renew Token", result)
 	return nil
 }
+
+// CancelAccountDeletion restores an account whose deletion is in its grace period.
+// It calls the CancelAccountDeletion function from the service package.
+// If the account is restored, it returns a success response with the user ID and email.
+func (ctl *Controller) CancelAccountDeletion(c *gin.Context) error {
+	result := ctl.svc.CancelAccountDeletion(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Cancel account deletion", result)
+	return nil
+}
+
+// RevokeDevice signs out the device reported in a "new sign-in" email.
+// It calls the RevokeDevice function from the service package.
+// If the device is signed out, it returns a success response with the user and device IDs.
+func (ctl *Controller) RevokeDevice(c *gin.Context) error {
+	result := ctl.svc.RevokeDevice(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Revoke device", result)
+	return nil
+}
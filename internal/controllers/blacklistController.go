@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 )
@@ -10,8 +9,8 @@ import (
 // It calls the BlackListIP function from the service package to perform the operation.
 // If the operation is successful, it returns a success response with the result.
 // If the operation fails, it returns an error.
-func BlackListIP(c *gin.Context) error {
-	result := service.BlackListIP(c)
+func (ctl *Controller) BlackListIP(c *gin.Context) error {
+	result := ctl.svc.BlackListIP(c)
 	if result == nil {
 		return nil
 	}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/metrics"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// Controller binds the HTTP routes to the use cases of a Service.
+type Controller struct {
+	svc *service.Service
+}
+
+// New creates a Controller calling the given Service.
+func New(svc *service.Service) *Controller {
+	return &Controller{svc: svc}
+}
+
+// observeLogin counts a login attempt of the given method with its result.
+// A failure is labelled with the error code of its response, e.g. ErrorPasswordNotMatch or ErrUserNotActive.
+func observeLogin(c *gin.Context, method string, result string) {
+	reason := ""
+	if result == constants.MetricResultFailure {
+		code, _ := response.ErrorCode(c)
+		reason = strconv.Itoa(code)
+	}
+	metrics.Logins.WithLabelValues(method, result, reason).Inc()
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestDataExport queues an export of the authenticated user's data.
+// It calls the RequestDataExport function from the service package.
+// If the export is queued, it returns a success response with the export ID.
+func (ctl *Controller) RequestDataExport(c *gin.Context) error {
+	result := ctl.svc.RequestDataExport(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Request data export", result)
+	return nil
+}
+
+// DownloadDataExport sends the zip archive of a data export.
+// It calls the DownloadDataExport function from the service package.
+// If the download link is valid, it responds with the archive from the export storage as an attachment.
+func (ctl *Controller) DownloadDataExport(c *gin.Context) error {
+	result := ctl.svc.DownloadDataExport(c)
+	if result == nil {
+		return nil
+	}
+	defer result.File.Close()
+
+	name := fmt.Sprintf("data-export-%d.zip", result.ID)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	http.ServeContent(c.Writer, c.Request, name, result.ModTime, result.File)
+	return nil
+}
@@ -3,6 +3,7 @@ package initialization
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
@@ -23,21 +24,19 @@ func ConnectPG(cfg models.Config) (*sql.DB, error) {
 	for i := 0; i < maxRetries; i++ {
 		db, err = sql.Open("postgres", connStr)
 		if err != nil {
-			fmt.Printf("Error connecting to database: %v\n", err)
-			fmt.Println("Retrying in 5 seconds...")
+			slog.Warn("Error connecting to database, retrying in 5 seconds", "error", err)
 			time.Sleep(5 * time.Second)
 			continue
 		}
 
 		err = db.Ping()
 		if err != nil {
-			fmt.Printf("Error pinging database: %v\n", err)
-			fmt.Println("Retrying in 5 seconds...")
+			slog.Warn("Error pinging database, retrying in 5 seconds", "error", err)
 			time.Sleep(5 * time.Second)
 			continue
 		}
 
-		fmt.Println("SUCCESSFULLY CONNECTED POSTGRESQL 🐘!")
+		slog.Info("Connected to PostgreSQL")
 		return db, nil
 	}
 
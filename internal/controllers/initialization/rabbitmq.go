@@ -2,7 +2,7 @@ package initialization
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -25,12 +25,11 @@ func ConnectRabbitMQ(dsn string) (*amqp.Connection, error) {
 	for i := 0; i < maxRetries; i++ {
 		conn, err = amqp.Dial(dsn)
 		if err == nil {
-			log.Println("CONNECTED SUCCESS RABBITMQ 🐇!")
+			slog.Info("Connected to RabbitMQ")
 			return conn, nil
 		}
 
-		log.Printf("Failed to connect to RabbitMQ: %s", err)
-		log.Printf("Retrying in %v...", retryDelay)
+		slog.Warn("Failed to connect to RabbitMQ, retrying", "error", err, "delay", retryDelay)
 		time.Sleep(retryDelay)
 	}
 
@@ -3,35 +3,49 @@ package initialization
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
 // ConnectRedis establishes a connection to Redis using the provided configuration.
+// Commands are bounded by timeout.cache and by the deadline of the context they are given,
+// and each one is traced as a child of the span in that context.
 // It returns a Redis client and an error if the connection fails.
 func ConnectRedis(cfg models.Config) (*redis.Client, error) {
 	var rdb *redis.Client
 	var pong string
 	var err error
 
+	commandTimeout := time.Duration(cfg.Timeout.Cache) * time.Millisecond
+
 	maxRetries := 10
 	for i := 0; i < maxRetries; i++ {
 		rdb = redis.NewClient(&redis.Options{
 			Addr:     fmt.Sprintf("%s:%s", cfg.Cache.Host, cfg.Cache.Port),
 			Password: cfg.Cache.Password,
 			DB:       0,
+
+			ReadTimeout:           commandTimeout,
+			WriteTimeout:          commandTimeout,
+			ContextTimeoutEnabled: true,
 		})
 
 		pong, err = rdb.Ping(context.Background()).Result()
 		if err != nil {
-			fmt.Printf("Error connecting to Redis: %v\n", err)
-			fmt.Println("Retrying in 5 seconds...")
+			slog.Warn("Error connecting to Redis, retrying in 5 seconds", "error", err)
 			time.Sleep(5 * time.Second)
 			continue
 		} else {
-			fmt.Println("CONNECTED TO REDIS:", pong, "🥩")
+			slog.Info("Connected to Redis", "ping", pong)
+			// The commands are traced without their arguments, which hold emails, phones and tokens
+			if err := redisotel.InstrumentTracing(rdb, redisotel.WithDBStatement(false)); err != nil {
+				rdb.Close()
+				return nil, fmt.Errorf("error tracing Redis: %w", err)
+			}
 			return rdb, nil
 		}
 	}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// ListJobs lists the background jobs with their latest run for administrators.
+// It calls the ListJobs function from the service package.
+// If the jobs are read, it returns a success response with them.
+func (ctl *Controller) ListJobs(c *gin.Context) error {
+	result := ctl.svc.ListJobs(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Jobs", result)
+	return nil
+}
+
+// GetJobRuns lists the run history of a background job for administrators.
+// It calls the GetJobRuns function from the service package.
+// If the runs are read, it returns a success response with them.
+func (ctl *Controller) GetJobRuns(c *gin.Context) error {
+	result := ctl.svc.GetJobRuns(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Job runs", result)
+	return nil
+}
+
+// TriggerJob runs a background job now for administrators.
+// It calls the TriggerJob function from the service package.
+// If the job is started, it returns a success response with the ID of its run.
+func (ctl *Controller) TriggerJob(c *gin.Context) error {
+	result := ctl.svc.TriggerJob(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Job triggered", result)
+	return nil
+}
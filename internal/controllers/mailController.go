@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// MailWebhook receives bounce and complaint notifications from the mail provider.
+// It calls the MailWebhook function from the service package to apply them.
+// If the events are applied, it returns a success response with the counts.
+// If the request is rejected, the service has already written the error response.
+func (ctl *Controller) MailWebhook(c *gin.Context) error {
+	result := ctl.svc.MailWebhook(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Mail events processed", result)
+	return nil
+}
@@ -1,7 +1,7 @@
 package controllers
 
 import (
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 )
@@ -10,11 +10,13 @@ import (
 // It calls the RenewToken function from the service package to renew the token.
 // If the token renewal is successful, it sends an "OK" response with the renewed token.
 // If there is an error during the token renewal process, it returns the error.
-func VerificationOtp(c *gin.Context) error {
-	result := service.VerificationOtp(c)
+func (ctl *Controller) VerificationOtp(c *gin.Context) error {
+	result := ctl.svc.VerificationOtp(c)
 	if result == nil {
+		observeLogin(c, constants.LoginMethodOtp, constants.MetricResultFailure)
 		return nil
 	}
+	observeLogin(c, constants.LoginMethodOtp, constants.MetricResultSuccess)
 
 	response.Ok(c, "Two factor login", result)
 	return nil
@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 )
@@ -10,8 +9,8 @@ import (
 // It calls the GetProfileUser function from the service package to fetch the user's profile.
 // If the result is nil, it returns nil.
 // Otherwise, it sends a successful response with the user's profile data.
-func GetProfileUser(c *gin.Context) error {
-	result := service.GetProfileUser(c)
+func (ctl *Controller) GetProfileUser(c *gin.Context) error {
+	result := ctl.svc.GetProfileUser(c)
 	if result == nil {
 		return nil
 	}
@@ -20,11 +19,11 @@ func GetProfileUser(c *gin.Context) error {
 }
 
 // UpdateProfile updates the profile of a user.
-// It retrieves the user's profile using the service.GetProfileUser function,
+// It retrieves the user's profile using the ctl.svc.GetProfileUser function,
 // and if the result is not nil, it sends a successful response with the updated profile.
 // If the result is nil, it returns nil.
-func UpdateProfile(c *gin.Context) error {
-	result := service.UpdateProfileUser(c)
+func (ctl *Controller) UpdateProfile(c *gin.Context) error {
+	result := ctl.svc.UpdateProfileUser(c)
 	if result == nil {
 		return nil
 	}
@@ -36,8 +35,8 @@ func UpdateProfile(c *gin.Context) error {
 // It calls the Logout function from the service package to perform the logout operation.
 // If the logout is successful, it returns a success response.
 // Otherwise, it returns an error response.
-func LogoutUser(c *gin.Context) error {
-	result := service.Logout(c)
+func (ctl *Controller) LogoutUser(c *gin.Context) error {
+	result := ctl.svc.Logout(c)
 	if result == nil {
 		return nil
 	}
@@ -46,11 +45,11 @@ func LogoutUser(c *gin.Context) error {
 }
 
 // ChangePassword is a controller function that handles the change password request.
-// It calls the service.ChangePassword function to perform the password change operation.
+// It calls the ctl.svc.ChangePassword function to perform the password change operation.
 // If the operation is successful, it returns a success response with the updated user information.
 // If the operation fails, it returns an error response.
-func ChangePassword(c *gin.Context) error {
-	result := service.ChangePassword(c)
+func (ctl *Controller) ChangePassword(c *gin.Context) error {
+	result := ctl.svc.ChangePassword(c)
 	if result == nil {
 		return nil
 	}
@@ -62,8 +61,8 @@ func ChangePassword(c *gin.Context) error {
 // It calls the ChangePassword function from the service package to change the user's password.
 // If the password change is successful, it returns a success response with the updated user information.
 // Otherwise, it returns an error.
-func EnableTowFactor(c *gin.Context) error {
-	result := service.EnableTowFactor(c)
+func (ctl *Controller) EnableTowFactor(c *gin.Context) error {
+	result := ctl.svc.EnableTowFactor(c)
 	if result == nil {
 		return nil
 	}
@@ -74,8 +73,8 @@ func EnableTowFactor(c *gin.Context) error {
 // SendOtpUpdateEmail sends an OTP (One-Time Password) to update the user's email.
 // It calls the SendOtpUpdateEmail function from the service package and returns the result.
 // If the result is nil, it returns nil. Otherwise, it sends a success response with the result.
-func SendOtpUpdateEmail(c *gin.Context) error {
-	result := service.SendOtpUpdateEmail(c)
+func (ctl *Controller) SendOtpUpdateEmail(c *gin.Context) error {
+	result := ctl.svc.SendOtpUpdateEmail(c)
 	if result == nil {
 		return nil
 	}
@@ -84,11 +83,11 @@ func SendOtpUpdateEmail(c *gin.Context) error {
 }
 
 // UpdateEmailUser updates the email of a user.
-// It calls the service.UpdateEmailUser function to perform the update operation.
+// It calls the ctl.svc.UpdateEmailUser function to perform the update operation.
 // If the update is successful, it returns a success response with the updated user information.
 // If there is an error during the update, it returns the error.
-func UpdateEmailUser(c *gin.Context) error {
-	result := service.UpdateEmailUser(c)
+func (ctl *Controller) UpdateEmailUser(c *gin.Context) error {
+	result := ctl.svc.UpdateEmailUser(c)
 	if result == nil {
 		return nil
 	}
@@ -97,14 +96,50 @@ func UpdateEmailUser(c *gin.Context) error {
 }
 
 // DestroyAccount handles the request to destroy a user account.
-// It calls the service.DestroyAccount function to perform the account deletion.
+// It calls the ctl.svc.DestroyAccount function to perform the account deletion.
 // If the deletion is successful, it returns a success response.
 // Otherwise, it returns an error response.
-func DestroyAccount(c *gin.Context) error {
-	result := service.DestroyAccount(c)
+func (ctl *Controller) DestroyAccount(c *gin.Context) error {
+	result := ctl.svc.DestroyAccount(c)
 	if result == nil {
 		return nil
 	}
 	response.Ok(c, "Destroy Account User", result)
 	return nil
 }
+
+// SendOtpVerifyPhone sends an OTP by SMS to verify the user's phone number.
+// It calls the SendOtpVerifyPhone function from the service package and returns the result.
+// If the result is nil, it returns nil. Otherwise, it sends a success response with the result.
+func (ctl *Controller) SendOtpVerifyPhone(c *gin.Context) error {
+	result := ctl.svc.SendOtpVerifyPhone(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Send Otp Verify Phone", result)
+	return nil
+}
+
+// VerifyPhone verifies the user's phone number with an SMS OTP.
+// It calls the ctl.svc.VerifyPhone function and, if the verification succeeds,
+// returns a success response with the verified phone information.
+func (ctl *Controller) VerifyPhone(c *gin.Context) error {
+	result := ctl.svc.VerifyPhone(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Verify Phone User", result)
+	return nil
+}
+
+// SetOtpNewDevice turns on or off the OTP for sign-ins from unknown devices.
+// It calls the SetOtpNewDevice function from the service package.
+// If the setting is saved, it returns a success response with it.
+func (ctl *Controller) SetOtpNewDevice(c *gin.Context) error {
+	result := ctl.svc.SetOtpNewDevice(c)
+	if result == nil {
+		return nil
+	}
+	response.Ok(c, "Require OTP for new devices", result)
+	return nil
+}
@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+)
+
+// purgeDeletedAccounts erases the accounts whose deletion grace period is over.
+// For each account it deletes the Firebase user and removes the export archives from the export storage,
+// then hard-deletes the rows holding personal data (devices, OTPs, verification links, password history,
+// social logins, sign-ins, data exports, outbox messages and email secrets, and the request context
+// of its audit events) and anonymises the users row and the mail log in one transaction.
+// The audit log is append-only and keeps its events, without their IP, device ID and user agent,
+// now pointing to the anonymised account.
+// It returns the number of accounts erased; an account that fails is logged and retried on the next run.
+func (t *tasks) purgeDeletedAccounts(ctx context.Context) (int64, error) {
+	accounts, err := t.app.Repos.Deletions.ListAccountsDueForDeletion(ctx, constants.AccountDeletionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, account := range accounts {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		if err := t.purgeAccount(ctx, account); err != nil {
+			slog.ErrorContext(ctx, "Failed to erase account", "user_id", account.ID, "error", err)
+			continue
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// purgeAccount erases one account, see purgeDeletedAccounts.
+func (t *tasks) purgeAccount(ctx context.Context, account models.AccountDueForDeletion) error {
+	//* Firebase is done first: its user is found by email, which is gone once the account is anonymised
+	if t.app.Firebase != nil {
+		uid, err := helpers.GetUserUIDByEmail(ctx, t.app.Firebase, account.Email)
+		if err == nil {
+			err = helpers.DeleteUser(ctx, t.app.Firebase, uid)
+		}
+		if err != nil && !helpers.IsUserNotFound(err) {
+			return err
+		}
+	}
+
+	//* The archives are removed before their rows, so an archive that cannot be removed is retried with the account
+	keys, err := t.app.Repos.DataExports.ListUserDataExportFiles(ctx, account.ID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		err := t.app.Exports.Remove(ctx, key)
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.ErrorContext(ctx, "Export archive is missing from the export storage", "user_id", account.ID, "key", key)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to remove export archive %s: %w", key, err)
+		}
+	}
+
+	anonymisedEmail := fmt.Sprintf(constants.AnonymisedEmailFormat, account.ID)
+
+	err = t.app.Repos.WithTx(ctx, func(tx repo.Repositories) error {
+		if err := tx.Deletions.DeleteUserPersonalData(ctx, account.ID); err != nil {
+			return err
+		}
+		if err := tx.Deletions.AnonymiseUserMailLog(ctx, account.ID, anonymisedEmail); err != nil {
+			return err
+		}
+		return tx.Deletions.AnonymiseUser(ctx, account.ID, anonymisedEmail)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := t.app.Repos.Audit.CreateAuditEvent(ctx, models.CreateAuditEventParams{
+		SubjectID: sql.NullInt32{Int32: int32(account.ID), Valid: true},
+		EventType: constants.AuditAccountDeleted,
+	}); err != nil {
+		slog.ErrorContext(ctx, "Failed to record audit event", "event_type", constants.AuditAccountDeleted, "error", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"time"
+)
+
+// expireDataExports removes the archives of the data exports whose download link expired from the export storage,
+// as they hold the user's personal data, and marks the exports as expired.
+// An archive that cannot be removed is logged and its export is retried on the next run.
+// An archive that is not in the storage is logged as an error, as the storage is probably not the one
+// the queue consumer writes to; its export is still expired, but the run fails so it is noticed.
+// It returns the number of exports expired.
+func (t *tasks) expireDataExports(ctx context.Context) (int64, error) {
+	now := time.Now()
+	var missing int
+	expired, err := t.deleteInBatches(ctx, func(limit int) (int64, error) {
+		exports, err := t.app.Repos.DataExports.ListExpiredDataExports(ctx, now, limit)
+		if err != nil {
+			return 0, err
+		}
+
+		var expired int64
+		for _, export := range exports {
+			if export.FilePath.Valid {
+				err := t.app.Exports.Remove(ctx, export.FilePath.String)
+				if errors.Is(err, fs.ErrNotExist) {
+					slog.ErrorContext(ctx, "Export archive is missing from the export storage", "export_id", export.ID, "key", export.FilePath.String)
+					missing++
+				} else if err != nil {
+					slog.ErrorContext(ctx, "Failed to remove export archive", "export_id", export.ID, "key", export.FilePath.String, "error", err)
+					continue
+				}
+			}
+			if err := t.app.Repos.DataExports.ExpireDataExport(ctx, export.ID); err != nil {
+				return expired, err
+			}
+			expired++
+		}
+		return expired, nil
+	})
+	if err == nil && missing > 0 {
+		err = fmt.Errorf("%d export archives were missing from the export storage", missing)
+	}
+	return expired, err
+}
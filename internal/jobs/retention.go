@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+)
+
+// tasks are the functions of the default jobs, run against the database and services of an App.
+type tasks struct {
+	app *app.App
+}
+
+// DefaultJobs returns the jobs run by the cronjob binary: the retention cleanups, the removal of expired
+// data export archives and the erasure of accounts whose deletion grace period is over.
+func DefaultJobs(a *app.App) []Job {
+	t := &tasks{app: a}
+	return []Job{
+		{
+			Name: constants.JobVerificationCleanup,
+			Spec: constants.JobVerificationCleanupSpec,
+			Run:  t.cleanupVerifications,
+		},
+		{
+			Name: constants.JobOtpCleanup,
+			Spec: constants.JobOtpCleanupSpec,
+			Run:  t.cleanupOtps,
+		},
+		{
+			Name: constants.JobDeviceCleanup,
+			Spec: constants.JobDeviceCleanupSpec,
+			Run:  t.cleanupDevices,
+		},
+		{
+			Name: constants.JobPasswordHistory,
+			Spec: constants.JobPasswordHistorySpec,
+			Run:  t.prunePasswordHistory,
+		},
+		{
+			Name: constants.JobOutboxCleanup,
+			Spec: constants.JobOutboxCleanupSpec,
+			Run:  t.cleanupOutbox,
+		},
+		{
+			Name: constants.JobDataExportExpiry,
+			Spec: constants.JobDataExportExpirySpec,
+			Run:  t.expireDataExports,
+		},
+		{
+			Name: constants.JobAccountDeletion,
+			Spec: constants.JobAccountDeletionSpec,
+			Run:  t.purgeDeletedAccounts,
+		},
+	}
+}
+
+// cleanupVerifications deletes verification links that expired more than the retention period ago.
+func (t *tasks) cleanupVerifications(ctx context.Context) (int64, error) {
+	before := retentionCutoff(t.app.Cfg.Retention.VerificationDays, constants.DefaultRetentionVerificationDays)
+	return t.deleteInBatches(ctx, func(limit int) (int64, error) {
+		return repo.DeleteExpiredVerifications(ctx, t.app.Repos.DB, before, limit)
+	})
+}
+
+// cleanupOtps deletes OTPs that expired or were used more than the retention period ago.
+func (t *tasks) cleanupOtps(ctx context.Context) (int64, error) {
+	before := retentionCutoff(t.app.Cfg.Retention.OtpDays, constants.DefaultRetentionOtpDays)
+	return t.deleteInBatches(ctx, func(limit int) (int64, error) {
+		return repo.DeleteExpiredOtps(ctx, t.app.Repos.DB, before, limit)
+	})
+}
+
+// cleanupDevices deletes devices logged out more than the retention period ago.
+func (t *tasks) cleanupDevices(ctx context.Context) (int64, error) {
+	before := retentionCutoff(t.app.Cfg.Retention.DeviceDays, constants.DefaultRetentionDeviceDays)
+	return t.deleteInBatches(ctx, func(limit int) (int64, error) {
+		return repo.DeleteStaleDevices(ctx, t.app.Repos.DB, before, limit)
+	})
+}
+
+// cleanupOutbox deletes outbox messages published, or given up, more than the retention period ago,
+// and the secrets of emails that expired without being sent.
+func (t *tasks) cleanupOutbox(ctx context.Context) (int64, error) {
+	before := retentionCutoff(t.app.Cfg.Retention.OutboxDays, constants.DefaultRetentionOutboxDays)
+	deleted, err := t.deleteInBatches(ctx, func(limit int) (int64, error) {
+		return repo.DeleteOldOutbox(ctx, t.app.Repos.DB, before, limit)
+	})
+	if err != nil {
+		return deleted, err
+	}
+
+	now := time.Now()
+	secrets, err := t.deleteInBatches(ctx, func(limit int) (int64, error) {
+		return repo.DeleteExpiredOutboxSecrets(ctx, t.app.Repos.DB, now, limit)
+	})
+	return deleted + secrets, err
+}
+
+// prunePasswordHistory deletes the old passwords beyond the depth checked when a password is changed.
+func (t *tasks) prunePasswordHistory(ctx context.Context) (int64, error) {
+	return t.deleteInBatches(ctx, func(limit int) (int64, error) {
+		return repo.PrunePasswordHistory(ctx, t.app.Repos.DB, constants.PasswordHistoryDepth, limit)
+	})
+}
+
+// deleteInBatches calls deleteBatch until it deletes fewer rows than the batch size,
+// so a large backlog is deleted in short statements that do not hold locks for long.
+// It stops between batches when ctx is cancelled and returns the number of rows deleted.
+func (t *tasks) deleteInBatches(ctx context.Context, deleteBatch func(limit int) (int64, error)) (int64, error) {
+	batchSize := t.app.Cfg.Retention.BatchSize
+	if batchSize <= 0 {
+		batchSize = constants.DefaultRetentionBatchSize
+	}
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		deleted, err := deleteBatch(batchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// retentionCutoff returns the time before which data is deleted, from a retention in days.
+func retentionCutoff(days int, defaultDays int) time.Time {
+	if days <= 0 {
+		days = defaultDays
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
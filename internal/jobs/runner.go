@@ -0,0 +1,361 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo/redis"
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+	errLeaseLost   = errors.New("lease lost")
+	errStaleFence  = errors.New("fencing token is older than the last run")
+)
+
+// Job is a task run on a cron schedule or triggered manually.
+// Run returns the number of rows it processed; it should return early when ctx is cancelled.
+//
+// Run must be idempotent. The fencing token only keeps a process that lost its lease from recording
+// the start or end of a run: its writes are not fenced, and until it notices that its lease is gone,
+// at the next renewal, it may still write next to the new holder. The default jobs delete or
+// expire rows matched by their age, so a batch run twice changes nothing the second time.
+type Job struct {
+	Name string
+	Spec string
+	Run  func(ctx context.Context) (int64, error)
+}
+
+// Metrics counts the runs of a job since the runner started.
+// Skipped runs are those another replica was already running.
+type Metrics struct {
+	Name         string        `json:"name"`
+	Runs         int64         `json:"runs"`
+	Failures     int64         `json:"failures"`
+	Skipped      int64         `json:"skipped"`
+	Rows         int64         `json:"rows"`
+	LastRunAt    time.Time     `json:"last_run_at"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastRows     int64         `json:"last_rows"`
+	LastError    string        `json:"last_error"`
+}
+
+// Runner runs jobs on their schedules and on demand, and records every run in the job_runs table.
+//
+// Every run holds a Redis lease named after the job, so a job runs on only one replica at a time.
+// The lease is renewed while the job runs; when a renewal fails the job's context is cancelled.
+// Each lease comes with a fencing token that only grows, and a run is only recorded if its token is
+// greater than that of every earlier run, so a process that lost its lease while paused cannot start
+// a run next to the process that took the lease over, nor record the end of a run the new holder closed.
+type Runner struct {
+	app    *app.App
+	cron   *cron.Cron
+	ctx    context.Context
+	cancel context.CancelFunc
+	owner  string
+	jobs   map[string]Job
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	metrics map[string]*Metrics
+}
+
+// lease is a job lease held by the runner and the run recorded under it.
+type lease struct {
+	key   string
+	token int64
+	runID int64
+}
+
+// NewRunner creates a runner of the given jobs, which takes its leases in the cache of a and records runs in its database.
+// Schedules use the seconds field. It returns an error if a job name is used twice or a schedule is invalid.
+func NewRunner(a *app.App, jobs []Job) (*Runner, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	hostname, _ := os.Hostname()
+
+	r := &Runner{
+		app: a,
+		cron: cron.New(
+			cron.WithSeconds(),
+			cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)),
+		),
+		ctx:     ctx,
+		cancel:  cancel,
+		owner:   fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		jobs:    map[string]Job{},
+		metrics: map[string]*Metrics{},
+	}
+
+	for _, job := range jobs {
+		if _, ok := r.jobs[job.Name]; ok {
+			cancel()
+			return nil, fmt.Errorf("job %s: registered twice", job.Name)
+		}
+		job := job
+		if _, err := r.cron.AddFunc(job.Spec, func() { r.runScheduled(job) }); err != nil {
+			cancel()
+			return nil, fmt.Errorf("job %s: invalid schedule %q: %w", job.Name, job.Spec, err)
+		}
+		r.jobs[job.Name] = job
+		r.metrics[job.Name] = &Metrics{Name: job.Name}
+	}
+	return r, nil
+}
+
+// Jobs returns the jobs of the runner, sorted by name.
+func (r *Runner) Jobs() []Job {
+	result := make([]Job, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		result = append(result, job)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Start runs the jobs on their schedules in the background.
+func (r *Runner) Start() {
+	r.cron.Start()
+}
+
+// Stop stops scheduling jobs and cancels the context of running ones, then waits up to timeout
+// for them to return. Jobs stop between batches, so no statement is interrupted.
+func (r *Runner) Stop(timeout time.Duration) {
+	r.cancel()
+	cronDone := r.cron.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		<-cronDone.Done()
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("Job runner stopped")
+	case <-time.After(timeout):
+		slog.Warn("Job runner stopped with jobs still running", "timeout", timeout)
+	}
+}
+
+// Metrics returns the metrics of every job, sorted by job name.
+func (r *Runner) Metrics() []Metrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]Metrics, 0, len(r.metrics))
+	for _, metrics := range r.metrics {
+		result = append(result, *metrics)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Trigger runs a job now, on behalf of the given user, and returns the ID of the run.
+// The lease is taken and the run recorded before it returns; the job itself runs in the background.
+// It returns ErrJobNotFound for an unknown job and ErrJobRunning when the job is already running.
+func (r *Runner) Trigger(name string, actorId int) (int64, error) {
+	job, ok := r.jobs[name]
+	if !ok {
+		return 0, ErrJobNotFound
+	}
+
+	held, err := r.acquire(job, constants.JobTriggerManual, sql.NullInt32{Int32: int32(actorId), Valid: true})
+	if err != nil {
+		return 0, err
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.execute(job, held)
+	}()
+	return held.runID, nil
+}
+
+// runScheduled runs a job on its schedule, unless another replica is running it.
+func (r *Runner) runScheduled(job Job) {
+	if r.ctx.Err() != nil {
+		return
+	}
+
+	held, err := r.acquire(job, constants.JobTriggerSchedule, sql.NullInt32{})
+	if errors.Is(err, ErrJobRunning) {
+		slog.Info("Job skipped, running on another replica", "job", job.Name)
+		r.record(job.Name, func(m *Metrics) { m.Skipped++ })
+		return
+	}
+	if err != nil {
+		slog.Error("Job failed to start", "job", job.Name, "error", err)
+		r.record(job.Name, func(m *Metrics) { m.Failures++; m.LastError = err.Error() })
+		return
+	}
+
+	r.execute(job, held)
+}
+
+// acquire takes the lease of a job and records the start of a run under its fencing token.
+// Runs left unfinished by a process that lost the lease are closed as failed first.
+func (r *Runner) acquire(job Job, trigger string, triggeredBy sql.NullInt32) (*lease, error) {
+	key := fmt.Sprintf(constants.JobLeaseKey, job.Name)
+	fenceKey := fmt.Sprintf(constants.JobFenceKey, job.Name)
+
+	token, err := redis.AcquireLease(r.ctx, r.app.Cache, key, fenceKey, constants.JobLeaseTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take lease: %w", err)
+	}
+	if token == 0 {
+		return nil, ErrJobRunning
+	}
+	held := &lease{key: key, token: token}
+
+	if _, err := r.app.Repos.JobRuns.AbandonJobRuns(r.ctx, models.AbandonJobRunsParams{
+		JobName: job.Name,
+		Status:  constants.JobRunStatusFailed,
+		Error:   errLeaseLost.Error(),
+	}); err != nil {
+		slog.Error("Job failed to close abandoned runs", "job", job.Name, "error", err)
+	}
+
+	runID, err := r.app.Repos.JobRuns.StartJobRun(r.ctx, models.StartJobRunParams{
+		JobName:     job.Name,
+		FenceToken:  token,
+		Trigger:     trigger,
+		Status:      constants.JobRunStatusRunning,
+		Owner:       r.owner,
+		TriggeredBy: triggeredBy,
+	})
+	if err != nil {
+		r.release(job, held)
+		if err == sql.ErrNoRows {
+			return nil, errStaleFence
+		}
+		return nil, fmt.Errorf("failed to record run: %w", err)
+	}
+	held.runID = runID
+	return held, nil
+}
+
+// execute runs a job under its lease, renewing the lease until the job returns,
+// then records the end of the run and releases the lease.
+func (r *Runner) execute(job Job, held *lease) {
+	defer r.release(job, held)
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	lost := make(chan struct{})
+	stopRenew := make(chan struct{})
+	go r.renew(job, held, cancel, lost, stopRenew)
+
+	start := time.Now()
+	rows, err := job.Run(ctx)
+	duration := time.Since(start)
+	close(stopRenew)
+
+	select {
+	case <-lost:
+		err = errLeaseLost
+	default:
+	}
+
+	status := constants.JobRunStatusSucceeded
+	runErr := sql.NullString{}
+	if err != nil {
+		status = constants.JobRunStatusFailed
+		runErr = sql.NullString{String: err.Error(), Valid: true}
+	}
+	//* Recorded without the runner's context, which is cancelled on shutdown
+	finishErr := r.app.Repos.JobRuns.FinishJobRun(context.Background(), models.FinishJobRunParams{
+		ID:         held.runID,
+		FenceToken: held.token,
+		Status:     status,
+		Rows:       rows,
+		Error:      runErr,
+	})
+	if errors.Is(finishErr, sql.ErrNoRows) {
+		//* A newer run of the job closed this one, which is recorded as failed with errLeaseLost
+		slog.Warn("Job run was taken over by a newer run", "job", job.Name, "run_id", held.runID, "fence_token", held.token)
+		err = errStaleFence
+	} else if finishErr != nil {
+		slog.Error("Job failed to record end of run", "job", job.Name, "run_id", held.runID, "error", finishErr)
+	}
+
+	r.record(job.Name, func(m *Metrics) {
+		m.Runs++
+		m.Rows += rows
+		m.LastRunAt = start
+		m.LastDuration = duration
+		m.LastRows = rows
+		m.LastError = ""
+		if err != nil {
+			m.Failures++
+			m.LastError = err.Error()
+		}
+	})
+
+	if err != nil {
+		slog.Error("Job run failed", "job", job.Name, "run_id", held.runID, "duration", duration, "rows", rows, "error", err)
+		return
+	}
+	slog.Info("Job run finished", "job", job.Name, "run_id", held.runID, "duration", duration, "rows", rows)
+}
+
+// renew extends the lease of a running job until stop is closed.
+// When the lease cannot be renewed it closes lost and cancels the job.
+func (r *Runner) renew(job Job, held *lease, cancel context.CancelFunc, lost chan<- struct{}, stop <-chan struct{}) {
+	ticker := time.NewTicker(constants.JobLeaseRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			renewed, err := redis.RenewLease(context.Background(), r.app.Cache, held.key, held.token, constants.JobLeaseTTL)
+			if err != nil {
+				//* A failed renewal is retried on the next tick, the lease outlives several of them
+				slog.Warn("Job failed to renew lease", "job", job.Name, "error", err)
+				continue
+			}
+			if !renewed {
+				slog.Warn("Job lease lost, cancelling run", "job", job.Name, "run_id", held.runID)
+				close(lost)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// release releases the lease of a job, with a fresh context: the runner's one is cancelled on shutdown.
+func (r *Runner) release(job Job, held *lease) {
+	if err := redis.ReleaseLease(context.Background(), r.app.Cache, held.key, held.token); err != nil {
+		slog.Error("Job failed to release lease", "job", job.Name, "error", err)
+	}
+}
+
+// record updates the metrics of a job.
+func (r *Runner) record(name string, update func(m *Metrics)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	metrics, ok := r.metrics[name]
+	if !ok {
+		metrics = &Metrics{Name: name}
+		r.metrics[name] = metrics
+	}
+	update(metrics)
+}
@@ -0,0 +1,31 @@
+package messaging
+
+import (
+	"sync"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+)
+
+// Broker publishes and consumes the messages of the auth queue on the RabbitMQ connection of an App.
+// Handlers are registered on it before ConsumerMessages is called.
+type Broker struct {
+	app      *app.App
+	producer *Producer
+
+	handlersMu sync.RWMutex
+	handlers   map[string]Handler
+}
+
+// NewBroker creates a broker with a producer on top of the App's queue connection.
+func NewBroker(a *app.App) *Broker {
+	return &Broker{
+		app:      a,
+		producer: NewProducer(a.Cfg.RabbitMQ.URL, a.Queue),
+		handlers: map[string]Handler{},
+	}
+}
+
+// Close stops the broker's producer.
+func (b *Broker) Close() error {
+	return b.producer.Close()
+}
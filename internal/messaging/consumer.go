@@ -2,52 +2,53 @@ package messaging
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
-	"time"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/tracing"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-func ConsumerMessages() {
+// ConsumerMessages consumes the auth queue with a pool of workers until SIGINT or SIGTERM.
+// Every message is handed to the handler registered for its type.
+// Successful messages are acked; failed messages are moved to a delay queue and retried with
+// exponential backoff, and poison messages or messages out of retries go to the dead-letter queue.
+func (b *Broker) ConsumerMessages() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	ch, err := global.MessageQueue.Channel()
+	ch, err := b.app.Queue.Channel()
 	if err != nil {
-		log.Fatalf("Failed to open a channel: %s", err)
+		slog.Error("Failed to open a channel", "error", err)
+		os.Exit(1)
 	}
 	defer ch.Close()
 
-	q, err := ch.QueueDeclare(
-		constants.KeyAuthPro, // queue name
-		true,                 // durable: If true, the queue will survive broker restarts.
-		false,                // delete when unused: If true, the queue will be deleted when there are no consumers.
-		false,                // exclusive: If true, the queue can only be used by the connection that declares it and will be deleted when the connection closes.
-		false,                // no-wait: If true, the queue declaration will not wait for a confirmation from the server.
-		nil,                  // arguments: Optional additional arguments (e.g., for message TTL, queue length limits).
-	)
-	if err != nil {
-		log.Fatalf("Failed to declare a queue: %s", err)
+	if err := declareTopology(ch); err != nil {
+		slog.Error("Failed to declare the queues", "error", err)
+		os.Exit(1)
 	}
 
 	msgs, err := ch.Consume(
-		q.Name, // queue
-		"",     // consumer
-		false,  // manual ack
-		false,  // exclusive
-		false,  // no-local
-		false,  // no-wait
-		nil,    // args
+		constants.KeyAuthPro, // queue
+		"",                   // consumer
+		false,                // manual ack
+		false,                // exclusive
+		false,                // no-local
+		false,                // no-wait
+		nil,                  // args
 	)
 	if err != nil {
-		log.Fatalf("Failed to register a consumer: %s", err)
+		slog.Error("Failed to register a consumer", "error", err)
+		os.Exit(1)
 	}
 
 	var wg sync.WaitGroup
@@ -59,9 +60,10 @@ func ConsumerMessages() {
 		go func(workerID int) {
 			defer wg.Done()
 			for d := range messageBuffer {
-				if err := processMessage(ctx, d); err != nil {
-					log.Printf("Worker %d: Error processing message: %s", workerID, err)
-					// Requeue or handle the error
+				if err := b.processMessage(ctx, d); err != nil {
+					slog.Error("Error processing message", "worker", workerID, "message_id", d.MessageId, "type", d.Type, "error", err)
+					b.retryOrDeadLetter(ctx, d, err)
+					continue
 				}
 				d.Ack(false)
 			}
@@ -83,7 +85,7 @@ func ConsumerMessages() {
 				}
 				messageBuffer <- d
 			case sig := <-sigChan:
-				log.Printf("Received signal: %s. Shutting down...", sig)
+				slog.Info("Received signal, shutting down", "signal", sig.String())
 				cancel()
 				close(messageBuffer)
 				return
@@ -92,34 +94,67 @@ func ConsumerMessages() {
 	}()
 
 	wg.Wait() // Wait for all workers to finish
-	log.Println("All workers have finished processing")
+	slog.Info("All workers have finished processing")
 }
 
-func processMessage(ctx context.Context, d amqp.Delivery) error {
-	// Implement your message processing logic here with retry mechanism
-	log.Printf("Received a message: %s", d.Body)
-	// Simulating processing time
-	time.Sleep(2 * time.Second)
-
-	// Example: Implement retry logic
-	for retry := 0; retry < 3; retry++ {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			// Simulate message processing
-			if err := simulateProcessing(d.Body); err != nil {
-				log.Printf("Processing failed, retrying (%d/3): %s", retry+1, err)
-				time.Sleep(1 * time.Second)
-			} else {
-				return nil
-			}
-		}
+// processMessage decodes the message envelope and dispatches it to its handler,
+// in a span that continues the trace carried by the headers of the message.
+func (b *Broker) processMessage(ctx context.Context, d amqp.Delivery) (err error) {
+	ctx, span := startProcessSpan(ctx, constants.KeyAuthPro, d)
+	defer func() { tracing.End(span, err) }()
+
+	var msg models.OutboxMessage
+	if err := json.Unmarshal(d.Body, &msg); err != nil {
+		return fmt.Errorf("%w: invalid message body: %v", ErrPoisonMessage, err)
 	}
-	return errors.New("message processing failed after retries")
+
+	return b.dispatch(ctx, msg)
 }
 
-func simulateProcessing(_ []byte) error {
-	// Replace with actual processing logic
-	return nil
+// retryOrDeadLetter handles a failed delivery.
+// The message is republished to the delay queue of its next attempt, or to the dead-letter queue
+// when it is a poison message or has used all retries, and the original delivery is acked once
+// the copy is confirmed. If the copy cannot be published, or the consumer is shutting down,
+// the delivery is nacked and requeued so it is not lost.
+func (b *Broker) retryOrDeadLetter(ctx context.Context, d amqp.Delivery, procErr error) {
+	if ctx.Err() != nil {
+		d.Nack(false, true)
+		return
+	}
+
+	attempt := retryCount(d.Headers) + 1
+
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[constants.HeaderRetryCount] = int32(attempt)
+	headers[constants.HeaderLastError] = procErr.Error()
+
+	queue := retryQueueName(attempt)
+	if errors.Is(procErr, ErrPoisonMessage) || attempt > constants.ConsumerMaxRetries {
+		queue = constants.KeyAuthProDLQ
+	}
+
+	err := b.publishToQueue(queue, amqp.Publishing{
+		Headers:     headers,
+		ContentType: d.ContentType,
+		MessageId:   d.MessageId,
+		Type:        d.Type,
+		Timestamp:   d.Timestamp,
+		Body:        d.Body,
+	})
+	if err != nil {
+		slog.Error("Failed to move message", "message_id", d.MessageId, "queue", queue, "error", err)
+		d.Nack(false, true)
+		return
+	}
+
+	if queue == constants.KeyAuthProDLQ {
+		slog.Warn("Message moved to the dead-letter queue", "message_id", d.MessageId, "attempts", attempt, "error", procErr)
+	} else {
+		slog.Info("Message scheduled for retry", "message_id", d.MessageId, "attempt", attempt, "delay", retryDelay(attempt))
+	}
+
+	d.Ack(false)
 }
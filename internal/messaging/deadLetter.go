@@ -0,0 +1,123 @@
+package messaging
+
+import (
+	"fmt"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// InspectDeadLetters returns up to limit messages from the dead-letter queue without removing them.
+// The messages are fetched unacked and requeued once they have been read.
+func (b *Broker) InspectDeadLetters(limit int) ([]models.DeadLetter, error) {
+	ch, err := b.app.Queue.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := declareTopology(ch); err != nil {
+		return nil, err
+	}
+
+	deadLetters := []models.DeadLetter{}
+	var lastTag uint64
+
+	for len(deadLetters) < limit {
+		d, ok, err := ch.Get(constants.KeyAuthProDLQ, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		lastTag = d.DeliveryTag
+		deadLetters = append(deadLetters, toDeadLetter(d))
+	}
+
+	if lastTag > 0 {
+		if err := ch.Nack(lastTag, true, true); err != nil {
+			return nil, fmt.Errorf("failed to requeue dead letters: %w", err)
+		}
+	}
+
+	return deadLetters, nil
+}
+
+// ReplayDeadLetters moves messages from the dead-letter queue back to the main queue with a fresh retry count.
+// With an empty messageID every dead letter is replayed, otherwise only the message with that ID.
+// It returns the number of replayed messages.
+func (b *Broker) ReplayDeadLetters(messageID string) (int, error) {
+	ch, err := b.app.Queue.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open a channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := declareTopology(ch); err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	skipped := []amqp.Delivery{}
+
+	// Skipped messages stay unacked until the end, so Get never returns them twice
+	defer func() {
+		for _, d := range skipped {
+			d.Nack(false, true)
+		}
+	}()
+
+	for {
+		d, ok, err := ch.Get(constants.KeyAuthProDLQ, false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to read the dead-letter queue: %w", err)
+		}
+		if !ok {
+			return replayed, nil
+		}
+
+		if messageID != "" && d.MessageId != messageID {
+			skipped = append(skipped, d)
+			continue
+		}
+
+		headers := amqp.Table{}
+		for k, v := range d.Headers {
+			headers[k] = v
+		}
+		delete(headers, constants.HeaderRetryCount)
+
+		err = b.PublishMessage(amqp.Publishing{
+			Headers:     headers,
+			ContentType: d.ContentType,
+			MessageId:   d.MessageId,
+			Type:        d.Type,
+			Timestamp:   d.Timestamp,
+			Body:        d.Body,
+		})
+		if err != nil {
+			d.Nack(false, true)
+			return replayed, fmt.Errorf("failed to replay message %s: %w", d.MessageId, err)
+		}
+
+		d.Ack(false)
+		replayed++
+	}
+}
+
+// toDeadLetter converts a delivery from the dead-letter queue into a models.DeadLetter.
+func toDeadLetter(d amqp.Delivery) models.DeadLetter {
+	lastError, _ := d.Headers[constants.HeaderLastError].(string)
+
+	return models.DeadLetter{
+		MessageID:  d.MessageId,
+		Type:       d.Type,
+		RetryCount: retryCount(d.Headers),
+		LastError:  lastError,
+		Timestamp:  d.Timestamp,
+		Body:       string(d.Body),
+	}
+}
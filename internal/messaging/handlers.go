@@ -0,0 +1,120 @@
+package messaging
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
+	pkg "github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/mail"
+)
+
+// RegisterDefaultHandlers registers the handlers of the messages written to the outbox by the server.
+// Data exports are built by svc.
+func (b *Broker) RegisterDefaultHandlers(svc *service.Service) {
+	Handle(b, constants.EventEmailSend, b.handleEmailSend)
+	Handle(b, constants.EventUserRegistered, handleUserRegistered)
+	Handle(b, constants.EventSessionRevoked, handleSessionRevoked)
+	Handle(b, constants.EventDataExportRequested, func(ctx context.Context, event models.DataExportRequestedEvent) error {
+		return handleDataExportRequested(ctx, svc, event)
+	})
+}
+
+// handleEmailSend sends the email described by an email.send message and records it in mail_log.
+// Emails to addresses marked undeliverable (hard bounce or complaint) are not sent, only logged as suppressed.
+// The body is read from outbox_secrets and deleted once the email is sent, so a redelivered message
+// whose secret is gone, or expired, is dropped instead of sending the email twice.
+// A failed send is logged and returned so the message is retried; a failure to write the log after a
+// successful send is only logged, since retrying would send the email twice.
+func (b *Broker) handleEmailSend(ctx context.Context, email models.EmailMessage) error {
+	undeliverable, err := repo.IsEmailUndeliverable(ctx, b.app.Repos.DB, email.To)
+	if err != nil {
+		return err
+	}
+	if undeliverable {
+		slog.InfoContext(ctx, "Email suppressed: address is undeliverable", "template", email.Template, "email", email.To)
+		b.deleteEmailSecret(ctx, email)
+		return b.logMailSend(ctx, email, constants.MailStatusSuppressed, "", nil)
+	}
+
+	body := email.Body
+	if email.SecretID != 0 {
+		body, err = repo.GetOutboxSecret(ctx, b.app.Repos.DB, email.SecretID)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(ctx, "Email dropped: its secret expired or it was already sent", "template", email.Template, "secret_id", email.SecretID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	messageID, errSend := pkg.SendGoEmail(b.app.Mailer, b.app.Cfg.Gmail.Mail, email.To, models.EmailData{
+		Template: email.Template,
+		Locale:   email.Locale,
+		Body:     body,
+		Details:  email.Details,
+	})
+
+	status := constants.MailStatusSent
+	if errSend != nil {
+		status = constants.MailStatusFailed
+	} else {
+		b.deleteEmailSecret(ctx, email)
+	}
+	if err := b.logMailSend(ctx, email, status, messageID, errSend); err != nil {
+		slog.ErrorContext(ctx, "Failed to write mail log", "email", email.To, "error", err)
+	}
+
+	return errSend
+}
+
+// deleteEmailSecret deletes the secret of an email that will not be sent again.
+// A failure is only logged: the secret expires and the outbox cleanup deletes it.
+func (b *Broker) deleteEmailSecret(ctx context.Context, email models.EmailMessage) {
+	if email.SecretID == 0 {
+		return
+	}
+	if err := repo.DeleteOutboxSecret(ctx, b.app.Repos.DB, email.SecretID); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete email secret", "secret_id", email.SecretID, "error", err)
+	}
+}
+
+// logMailSend writes a mail_log row for an email.send message.
+func (b *Broker) logMailSend(ctx context.Context, email models.EmailMessage, status int, messageID string, errSend error) error {
+	params := models.CreateMailLogParams{
+		UserID:            sql.NullInt32{Int32: int32(email.UserID), Valid: email.UserID != 0},
+		Template:          email.Template,
+		Recipient:         email.To,
+		Status:            status,
+		ProviderMessageID: sql.NullString{String: messageID, Valid: messageID != ""},
+	}
+	if errSend != nil {
+		params.Error = sql.NullString{String: errSend.Error(), Valid: true}
+	}
+
+	_, err := repo.CreateMailLog(ctx, b.app.Repos.DB, params)
+	return err
+}
+
+// handleUserRegistered records a user.registered event.
+func handleUserRegistered(ctx context.Context, event models.UserRegisteredEvent) error {
+	slog.InfoContext(ctx, "User registered", "user_id", event.UserID, "email", event.Email)
+	return nil
+}
+
+// handleSessionRevoked records a session.revoked event.
+func handleSessionRevoked(ctx context.Context, event models.SessionRevokedEvent) error {
+	slog.InfoContext(ctx, "Session revoked", "user_id", event.UserID, "device_id", event.DeviceID)
+	return nil
+}
+
+// handleDataExportRequested builds the archive of a data export and queues the email with its download link.
+func handleDataExportRequested(ctx context.Context, svc *service.Service, event models.DataExportRequestedEvent) error {
+	slog.InfoContext(ctx, "Building data export", "export_id", event.ExportID, "user_id", event.UserID)
+	return svc.BuildDataExport(ctx, event)
+}
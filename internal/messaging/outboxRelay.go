@@ -0,0 +1,106 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// RelayOutbox publishes pending outbox rows to RabbitMQ until ctx is cancelled.
+// Every interval it locks a batch of pending rows, publishes each one with publisher confirms
+// and marks it as published, or records the error so the row is retried on the next tick.
+// Rows are locked with SKIP LOCKED, so several relays can run side by side.
+// Delivery is at-least-once: the outbox ID is sent as the message ID so consumers can detect duplicates.
+func (b *Broker) RelayOutbox(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Outbox relay stopped")
+			return
+		case <-ticker.C:
+			published, err := b.relayOutboxBatch(ctx, constants.OutboxBatchSize)
+			if err != nil {
+				slog.Error("Outbox relay failed", "error", err)
+				continue
+			}
+			if published > 0 {
+				slog.Info("Outbox relay published messages", "count", published)
+			}
+		}
+	}
+}
+
+// relayOutboxBatch publishes one batch of pending outbox rows inside a transaction.
+// It returns the number of rows that were published.
+func (b *Broker) relayOutboxBatch(ctx context.Context, limit int) (int, error) {
+	published := 0
+
+	err := b.app.Repos.WithTx(ctx, func(tx repo.Repositories) error {
+		rows, err := tx.Outbox.GetPendingOutbox(ctx, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := b.publishOutbox(ctx, row); err != nil {
+				slog.ErrorContext(ctx, "Outbox relay failed to publish message", "outbox_id", row.ID, "error", err)
+				if err := tx.Outbox.MarkOutboxFailed(ctx, models.MarkOutboxFailedParams{
+					ID:          row.ID,
+					LastError:   err.Error(),
+					MaxAttempts: constants.OutboxMaxAttempts,
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := tx.Outbox.MarkOutboxPublished(ctx, row.ID); err != nil {
+				return err
+			}
+			published++
+		}
+
+		return nil
+	})
+
+	return published, err
+}
+
+// publishOutbox wraps an outbox row in a models.OutboxMessage and publishes it,
+// in a span that continues the trace of the request that wrote the row.
+// The headers of the message carry that span, so the consumer continues the trace.
+func (b *Broker) publishOutbox(ctx context.Context, row models.Outbox) (err error) {
+	headers := amqp.Table{}
+	_, span := startPublishSpan(tracing.Extract(ctx, row.TraceContext), constants.KeyAuthPro, row.EventType, headers)
+	defer func() { tracing.End(span, err) }()
+
+	body, err := json.Marshal(models.OutboxMessage{
+		ID:        row.ID,
+		Type:      row.EventType,
+		Payload:   row.Payload,
+		CreatedAt: row.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.PublishMessage(amqp.Publishing{
+		Headers:     headers,
+		ContentType: "application/json",
+		MessageId:   strconv.Itoa(row.ID),
+		Type:        row.EventType,
+		Timestamp:   row.CreatedAt,
+		Body:        body,
+	})
+}
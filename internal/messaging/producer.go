@@ -1,69 +1,264 @@
 package messaging
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-// ProducerSendMessage sends a message to the message queue.
-// It opens a channel, enables publisher confirms, declares a queue,
-// and publishes the message to the queue.
-// If the message is successfully published, it logs the message as sent.
-// If there is an error during any step, it logs the error and terminates the program.
-func ProducerSendMessage(message string) {
-	ch, err := global.MessageQueue.Channel()
-	if err != nil {
-		log.Fatalf("Failed to open a channel: %s", err)
+var (
+	ErrProducerClosed = errors.New("producer is closed")
+	ErrNotConnected   = errors.New("rabbitmq is not connected")
+	ErrNotConfirmed   = errors.New("message was not confirmed by the broker")
+)
+
+// Producer is a long-lived RabbitMQ publisher, shared by everything that publishes through a Broker.
+// It keeps a pool of channels in confirm mode and reconnects with backoff when the connection
+// is closed by the broker or the network. Failures are returned to the caller, never fatal:
+// the outbox relay keeps a message that was not confirmed and publishes it again on its next batch.
+type Producer struct {
+	url string
+
+	mu   sync.RWMutex
+	conn app.Queue
+	// owned is set when the producer dialled conn itself, after the connection of the App was closed
+	owned bool
+
+	channels  chan *amqp.Channel
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewProducer creates a Producer on top of an existing connection.
+// When conn is nil or already closed, the producer dials url in the background.
+func NewProducer(url string, conn app.Queue) *Producer {
+	p := &Producer{
+		url:      url,
+		channels: make(chan *amqp.Channel, constants.ProducerChannelPoolSize),
+		done:     make(chan struct{}),
 	}
-	defer ch.Close()
 
-	// Bật Publisher Confirms
-	if err := ch.Confirm(false); err != nil {
-		log.Fatalf("Failed to put channel in confirm mode: %s", err)
+	if conn != nil && !conn.IsClosed() {
+		p.setConnection(conn, false)
+	} else {
+		go p.reconnect()
 	}
 
-	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return p
+}
 
-	q, err := ch.QueueDeclare(
-		constants.KeyAuthPro, // name
-		true,                 // durable: If true, the queue will survive broker restarts.
-		false,                // delete when unused: If true, the queue will be deleted when there are no consumers.
-		false,                // exclusive: If true, the queue can only be used by the connection that declares it and will be deleted when the connection closes.
-		false,                // no-wait: If true, the queue declaration will not wait for a confirmation from the server.
-		nil,                  // arguments: Optional additional arguments (e.g., for message TTL, queue length limits).
+// Publish publishes a persistent message to the given queue through the default exchange
+// and waits for the broker to confirm it.
+// It returns ErrNotConnected while the producer is reconnecting.
+func (p *Producer) Publish(ctx context.Context, queue string, msg amqp.Publishing) error {
+	select {
+	case <-p.done:
+		return ErrProducerClosed
+	default:
+	}
+
+	ch, err := p.getChannel()
+	if err != nil {
+		return err
+	}
+
+	msg.DeliveryMode = amqp.Persistent
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(
+		ctx,
+		"",    // exchange: The name of the exchange to publish to. An empty string indicates the default exchange.
+		queue, // routing key: The routing key for the message. Used to route messages to queues in certain exchange types.
+		false, // mandatory: If true, the server will return an unroutable message with a mandatory flag set.
+		false, // immediate: If true, the server will return an immediate response if no consumer is available.
+		msg,
 	)
 	if err != nil {
-		log.Fatalf("Failed to declare a queue: %s", err)
+		ch.Close()
+		return fmt.Errorf("failed to publish a message: %w", err)
 	}
 
-	err = ch.Publish(
-		"",     // exchange: The name of the exchange to publish to. An empty string indicates the default exchange.
-		q.Name, // routing key: The routing key for the message. Used to route messages to queues in certain exchange types.
-		false,  // mandatory: If true, the server will return an unroutable message with a mandatory flag set.
-		false,  // immediate: If true, the server will return an immediate response if no consumer is available.
+	confirmCtx, cancel := context.WithTimeout(ctx, constants.ProducerConfirmTimeout)
+	defer cancel()
 
-		amqp.Publishing{
-			DeliveryMode: amqp.Persistent,
-			ContentType:  "text/plain",
-			Body:         []byte(message),
-		})
+	acked, err := confirm.WaitContext(confirmCtx)
 	if err != nil {
-		log.Fatalf("Failed to publish a message: %s", err)
+		// The channel may still deliver the late confirmation, so it is not reused
+		ch.Close()
+		return fmt.Errorf("failed to confirm a message: %w", err)
+	}
+
+	p.putChannel(ch)
+
+	if !acked {
+		return ErrNotConfirmed
+	}
+	return nil
+}
+
+// Close stops the producer and closes its channels. The connection of the App it was created with
+// is left open, as the consumers and the App share it; a connection the producer dialled itself is closed.
+func (p *Producer) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+
+		p.drainChannels()
+
+		p.mu.Lock()
+		if p.conn != nil && p.owned {
+			p.conn.Close()
+		}
+		p.conn = nil
+		p.mu.Unlock()
+	})
+	return nil
+}
+
+// getChannel returns an open channel from the pool, or opens a new one in confirm mode.
+func (p *Producer) getChannel() (*amqp.Channel, error) {
+pool:
+	for {
+		select {
+		case ch := <-p.channels:
+			if !ch.IsClosed() {
+				return ch, nil
+			}
+		default:
+			break pool
+		}
+	}
+
+	p.mu.RLock()
+	conn := p.conn
+	p.mu.RUnlock()
+
+	if conn == nil || conn.IsClosed() {
+		return nil, ErrNotConnected
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to put channel in confirm mode: %w", err)
+	}
+
+	if err := declareTopology(ch); err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+// putChannel returns a channel to the pool, or closes it when the pool is full.
+func (p *Producer) putChannel(ch *amqp.Channel) {
+	if ch.IsClosed() {
+		return
 	}
 
 	select {
-	case confirmed := <-confirms:
-		if confirmed.Ack {
-			log.Println("Queue status:", constants.KeyAuthPro)
-			log.Printf("[x] Sent %s", message)
-		} else {
-			log.Printf("Failed to confirm message: %s", message)
+	case p.channels <- ch:
+	default:
+		ch.Close()
+	}
+}
+
+// drainChannels closes every pooled channel.
+func (p *Producer) drainChannels() {
+	for {
+		select {
+		case ch := <-p.channels:
+			ch.Close()
+		default:
+			return
 		}
-	case <-time.After(5 * time.Second): // Timeout after 5 seconds
-		log.Printf("Confirmation timeout for message: %s", message)
 	}
 }
+
+// setConnection installs a new connection and watches it for closure.
+// Owned tells whether the producer dialled it, and so closes it.
+func (p *Producer) setConnection(conn app.Queue, owned bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		//* Closed while reconnecting: the new connection is not used
+		if owned {
+			conn.Close()
+		}
+		return
+	default:
+	}
+
+	p.conn = conn
+	p.owned = owned
+	go p.watch(conn)
+}
+
+// watch waits for the connection to close and starts reconnecting,
+// unless the producer itself is being closed.
+func (p *Producer) watch(conn app.Queue) {
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	select {
+	case <-p.done:
+		return
+	case err := <-closed:
+		slog.Warn("RabbitMQ connection closed, reconnecting", "error", err)
+
+		p.mu.Lock()
+		p.conn = nil
+		p.mu.Unlock()
+
+		p.drainChannels()
+		p.reconnect()
+	}
+}
+
+// reconnect dials the broker with exponential backoff until it succeeds or the producer is closed.
+func (p *Producer) reconnect() {
+	delay := time.Second
+
+	for {
+		conn, err := amqp.Dial(p.url)
+		if err == nil {
+			slog.Info("Reconnected RabbitMQ producer")
+			p.setConnection(conn, true)
+			return
+		}
+
+		slog.Warn("Failed to reconnect to RabbitMQ, retrying", "error", err, "delay", delay)
+
+		select {
+		case <-p.done:
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > constants.ProducerReconnectMaxDelay {
+			delay = constants.ProducerReconnectMaxDelay
+		}
+	}
+}
+
+// PublishMessage publishes a persistent message to the auth queue and waits for the broker to confirm it.
+func (b *Broker) PublishMessage(msg amqp.Publishing) error {
+	return b.publishToQueue(constants.KeyAuthPro, msg)
+}
+
+// publishToQueue publishes a persistent message to the given queue with the broker's producer.
+func (b *Broker) publishToQueue(queue string, msg amqp.Publishing) error {
+	return b.producer.Publish(context.Background(), queue, msg)
+}
@@ -0,0 +1,48 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeQueue is a connection of the App that records whether it was closed.
+type fakeQueue struct {
+	mu     sync.Mutex
+	closed bool
+}
+
+func (q *fakeQueue) Channel() (*amqp.Channel, error) {
+	return nil, amqp.ErrClosed
+}
+
+func (q *fakeQueue) IsClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+func (q *fakeQueue) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	return receiver
+}
+
+func (q *fakeQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	return nil
+}
+
+func TestProducerCloseKeepsSharedConnection(t *testing.T) {
+	conn := &fakeQueue{}
+	producer := NewProducer("", conn)
+
+	assert.NoError(t, producer.Close())
+	assert.NoError(t, producer.Close())
+	assert.False(t, conn.IsClosed(), "the connection of the App is closed by the App")
+
+	assert.ErrorIs(t, producer.Publish(context.Background(), "queue", amqp.Publishing{}), ErrProducerClosed)
+}
@@ -0,0 +1,50 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+// ErrPoisonMessage marks a message that can never be processed, such as a malformed body
+// or a type without handler. Poison messages go straight to the dead-letter queue.
+var ErrPoisonMessage = errors.New("poison message")
+
+// Handler processes one message envelope.
+// Returning an error retries the message with backoff, or dead-letters it when the error wraps ErrPoisonMessage.
+type Handler func(ctx context.Context, msg models.OutboxMessage) error
+
+// RegisterHandler registers the handler for a message type, replacing any previous one.
+func (b *Broker) RegisterHandler(messageType string, handler Handler) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+	b.handlers[messageType] = handler
+}
+
+// Handle registers a typed handler on a broker: the envelope payload is decoded into T before fn is called.
+// A payload that cannot be decoded is a poison message.
+func Handle[T any](b *Broker, messageType string, fn func(ctx context.Context, payload T) error) {
+	b.RegisterHandler(messageType, func(ctx context.Context, msg models.OutboxMessage) error {
+		var payload T
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("%w: invalid %s payload: %v", ErrPoisonMessage, messageType, err)
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// dispatch runs the handler registered for the message type.
+func (b *Broker) dispatch(ctx context.Context, msg models.OutboxMessage) error {
+	b.handlersMu.RLock()
+	handler, ok := b.handlers[msg.Type]
+	b.handlersMu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: no handler for message type %q", ErrPoisonMessage, msg.Type)
+	}
+
+	return handler(ctx, msg)
+}
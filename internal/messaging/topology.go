@@ -0,0 +1,99 @@
+package messaging
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// declareTopology declares every queue used by the auth messaging:
+//   - the main queue the consumer reads from,
+//   - one delay queue per retry attempt, whose messages expire after the attempt's backoff
+//     and are dead-lettered back to the main queue,
+//   - the dead-letter queue for poison messages and messages that ran out of retries.
+//
+// Declaring is idempotent, so producers, consumers and the admin command all call it.
+func declareTopology(ch *amqp.Channel) error {
+	if _, err := ch.QueueDeclare(constants.KeyAuthPro, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", constants.KeyAuthPro, err)
+	}
+
+	if _, err := ch.QueueDeclare(constants.KeyAuthProDLQ, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", constants.KeyAuthProDLQ, err)
+	}
+
+	for attempt := 1; attempt <= constants.ConsumerMaxRetries; attempt++ {
+		_, err := ch.QueueDeclare(
+			retryQueueName(attempt),
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			amqp.Table{
+				"x-message-ttl":             retryDelay(attempt).Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": constants.KeyAuthPro,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare retry queue %s: %w", retryQueueName(attempt), err)
+		}
+	}
+
+	return nil
+}
+
+// retryDelay returns the exponential backoff for a retry attempt (1-based):
+// ConsumerRetryBaseDelay, then twice as long for every following attempt.
+func retryDelay(attempt int) time.Duration {
+	return constants.ConsumerRetryBaseDelay * time.Duration(1<<(attempt-1))
+}
+
+// retryQueueName returns the name of the delay queue for a retry attempt, e.g. go_auth_pro.retry.10000.
+func retryQueueName(attempt int) string {
+	return fmt.Sprintf("%s.%d", constants.KeyAuthProRetry, retryDelay(attempt).Milliseconds())
+}
+
+// retryCount reads the number of retries already made from the message headers.
+func retryCount(headers amqp.Table) int {
+	switch v := headers[constants.HeaderRetryCount].(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// QueueDepths returns the number of messages ready in the main, retry and dead-letter queues, keyed by queue name.
+func (b *Broker) QueueDepths() (map[string]int, error) {
+	ch, err := b.app.Queue.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := declareTopology(ch); err != nil {
+		return nil, err
+	}
+
+	names := []string{constants.KeyAuthPro, constants.KeyAuthProDLQ}
+	for attempt := 1; attempt <= constants.ConsumerMaxRetries; attempt++ {
+		names = append(names, retryQueueName(attempt))
+	}
+
+	depths := make(map[string]int, len(names))
+	for _, name := range names {
+		queue, err := ch.QueueDeclarePassive(name, true, false, false, false, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect queue %s: %w", name, err)
+		}
+		depths[name] = queue.Messages
+	}
+	return depths, nil
+}
@@ -0,0 +1,61 @@
+package messaging
+
+import (
+	"context"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier carries the trace context in the headers of a message, so the consumer
+// continues the trace of the request that wrote the message. It implements propagation.TextMapCarrier.
+type headerCarrier amqp.Table
+
+func (h headerCarrier) Get(key string) string {
+	value, _ := h[key].(string)
+	return value
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h[key] = value
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// startPublishSpan starts the producer span of a message published to queue
+// and writes its trace context to headers.
+func startPublishSpan(ctx context.Context, queue, messageType string, headers amqp.Table) (context.Context, trace.Span) {
+	ctx, span := tracing.Start(ctx, "publish "+messageType,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messagingAttributes(queue, semconv.MessagingOperationPublish)...))
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+	return ctx, span
+}
+
+// startProcessSpan starts the consumer span of a delivery, as a child of the trace context in its headers.
+func startProcessSpan(ctx context.Context, queue string, d amqp.Delivery) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier(d.Headers))
+	attributes := append(messagingAttributes(queue, semconv.MessagingOperationProcess), semconv.MessagingMessageID(d.MessageId))
+	return tracing.Start(ctx, "process "+d.Type,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attributes...))
+}
+
+// messagingAttributes returns the attributes of a span of a message of queue.
+func messagingAttributes(queue string, operation attribute.KeyValue) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String("rabbitmq"),
+		semconv.MessagingDestinationName(queue),
+		operation,
+	}
+}
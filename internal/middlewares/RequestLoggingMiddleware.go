@@ -8,6 +8,9 @@ import (
 	"io/ioutil"
 	"time"
 
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/logger"
 	third_party "github.com/fdhhhdjd/Go_Secure_Auth_Pro/third_party/telegram"
 	"github.com/gin-gonic/gin"
 )
@@ -22,9 +25,10 @@ func (w *bodyWriter) Write(b []byte) (int, error) {
 }
 
 // FormatErrorMessage formats an error message with Markdown for readability.
-func FormatErrorMessage(method, path string, duration time.Duration, status int, errorName string, code string, formattedRequestBody string) string {
+func FormatErrorMessage(requestID, method, path string, duration time.Duration, status int, errorName string, code string, formattedRequestBody string) string {
 	message := fmt.Sprintf(
 		"🚨 *Error!* 🚨\n"+
+			"**Request ID:** `%s`\n"+
 			"**Request:** `%s`\n"+
 			"**Method:** `%s`\n"+
 			"**Code:** `%s`\n"+
@@ -33,7 +37,7 @@ func FormatErrorMessage(method, path string, duration time.Duration, status int,
 			"**Error Message:** `%s`\n"+
 			"**Body:** \n"+
 			"```json\n%s\n```",
-		path, method, code, duration, status, errorName, formattedRequestBody)
+		requestID, path, method, code, duration, status, errorName, formattedRequestBody)
 
 	return message
 }
@@ -41,7 +45,9 @@ func FormatErrorMessage(method, path string, duration time.Duration, status int,
 // RequestLoggingMiddleware is a middleware function that logs information about incoming requests and outgoing responses.
 // It captures the request method, path, duration, status code, error name (if any), and request body.
 // The captured information is formatted as a Markdown message and sent to a third-party service for logging.
-func RequestLoggingMiddleware() gin.HandlerFunc {
+// The path and the request body are redacted first: passwords, OTPs and tokens are removed and emails and phones masked,
+// see redactedPath for the path.
+func RequestLoggingMiddleware(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
 
@@ -52,7 +58,7 @@ func RequestLoggingMiddleware() gin.HandlerFunc {
 		}
 		c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(requestBodyBytes))
 
-		method, path := c.Request.Method, c.Request.URL.Path
+		method, path := c.Request.Method, redactedPath(c)
 
 		// Write again  response body
 		responseBodyBuffer := new(bytes.Buffer)
@@ -83,17 +89,18 @@ func RequestLoggingMiddleware() gin.HandlerFunc {
 
 		}
 
-		// Convert request body to JSON
+		// Convert the redacted request body to JSON
+		redactedBody := logger.RedactJSON(requestBodyBytes)
 		var formattedRequestBody bytes.Buffer
-		if err := json.Indent(&formattedRequestBody, requestBodyBytes, "", "  "); err != nil {
-			formattedRequestBody.Write(requestBodyBytes)
+		if err := json.Indent(&formattedRequestBody, redactedBody, "", "  "); err != nil {
+			formattedRequestBody.Write(redactedBody)
 		}
 
 		// Send message to Telegram
-		message := FormatErrorMessage(method, path, duration, status, errorName, code, formattedRequestBody.String())
+		message := FormatErrorMessage(c.GetString(constants.RequestIDKey), method, path, duration, status, errorName, code, formattedRequestBody.String())
 
 		if status >= 500 {
-			go third_party.SendTelegramMessage(message, "Markdown", true, false)
+			go third_party.SendTelegramMessage(a.Cfg.Telegram, message, "Markdown", true, false)
 		}
 	}
 }
@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/logger"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLogMiddleware logs every request once it is served, in place of the default logger of Gin:
+// method, path, route, status, duration and client IP, and the error code of an error response.
+// Server errors are logged as errors and client errors as warnings. The path is redacted with redactedPath,
+// as some paths hold an email or a token, and the query string is left out as it may hold a token.
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", redactedPath(c)),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if code, failed := response.ErrorCode(c); failed {
+			attrs = append(attrs, slog.Int("error_code", code))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.LogAttrs(c, level, "request", attrs...)
+	}
+}
+
+// redactedPath returns the path of the request to log. A matched route is rebuilt from its template,
+// each parameter redacted by its name with logger.RedactField, so the token of a link such as
+// /v1/exports/:token never reaches the logs; the path of an unmatched request is redacted as text.
+func redactedPath(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		return logger.RedactString(c.Request.URL.Path)
+	}
+
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			key := segment[1:]
+			segments[i] = logger.RedactField(key, strings.TrimPrefix(c.Param(key), "/"))
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Recovery responds with a 500 error to a request whose handler panicked and logs the panic with its stack,
+// in place of the recovery of Gin that writes to stderr.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err interface{}) {
+		slog.ErrorContext(c, "panic recovered", "panic", err, "stack", string(debug.Stack()))
+		response.InternalServerError(c, response.ErrCodeInternalServer)
+	})
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"strings"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware only lets through users whose email is listed in admin.emails.
+// It must run after AuthorizationMiddleware, which stores the authenticated user in the context.
+// Other users receive a ForbiddenError response.
+func AdminMiddleware(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payload, exists := c.Get(constants.InfoAccess)
+		if !exists {
+			response.UnauthorizedError(c, response.ErrCodeAuthTokenInvalid)
+			return
+		}
+
+		userInfo := payload.(models.Payload)
+		if !IsAdmin(a.Cfg.Admin, userInfo.Email) {
+			response.ForbiddenError(c, response.ErrCodePermissionDenied)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// IsAdmin reports whether the email belongs to an administrator.
+func IsAdmin(cfg models.AdminConfig, email string) bool {
+	for _, admin := range cfg.Emails {
+		if strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
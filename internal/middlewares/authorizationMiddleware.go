@@ -1,13 +1,15 @@
 package middlewares
 
 import (
+	"context"
 	"strings"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/logger"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
@@ -18,7 +20,7 @@ import (
 // If the request is not authorized, it aborts the request with a JSON response containing an unauthorized error.
 // It also verifies the access token and checks if the user email and ID match the device information.
 // If all checks pass, it sets the user information in the request context and proceeds to the next middleware or handler.
-func AuthorizationMiddleware() gin.HandlerFunc {
+func AuthorizationMiddleware(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		refetchToken, _ := c.Cookie("user_login")
@@ -36,7 +38,7 @@ func AuthorizationMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		resultDevice, err := repo.GetDeviceId(global.DB, models.GetDeviceIdParams{
+		resultDevice, err := a.Repos.Devices.GetDeviceId(c, models.GetDeviceIdParams{
 			DeviceId: deviceID.(string),
 			IsActive: true,
 		})
@@ -66,7 +68,7 @@ func AuthorizationMiddleware() gin.HandlerFunc {
 		email := userInfo["email"].(string)
 		userId := userInfo["id"].(float64)
 
-		resultCheckUser := CheckUser(email)
+		resultCheckUser := CheckUser(c, a.Repos.Users, email)
 
 		if !resultCheckUser {
 			response.UnauthorizedError(c, response.ErrUserNotExit)
@@ -78,6 +80,7 @@ func AuthorizationMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		logger.SetUserID(c, int(userId))
 		c.Set(constants.InfoAccess, models.Payload{
 			ID:    int(userId),
 			Email: email,
@@ -90,8 +93,8 @@ func AuthorizationMiddleware() gin.HandlerFunc {
 
 // checkUser checks if a user is valid and active based on the provided email.
 // It retrieves the user details from the repository and returns true if the user is valid and active, false otherwise.
-func CheckUser(email string) bool {
-	resultDetailUser, err := repo.GetUserDetail(global.DB, email)
+func CheckUser(ctx context.Context, users repo.UserRepository, email string) bool {
+	resultDetailUser, err := users.GetUserDetail(ctx, email)
 
 	if err != nil {
 		return false
@@ -1,8 +1,6 @@
 package middlewares
 
 import (
-	"log"
-
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 )
@@ -14,7 +12,6 @@ func ContentTypeValidationMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.Method == "POST" || c.Request.Method == "PUT" || c.Request.Method == "PATCH" {
 			contentType := c.Request.Header.Get("Content-Type")
-			log.Println(contentType)
 			if contentType != "application/json" {
 				response.UnSupportMediaTypeError(c, response.ErrCodeContentType)
 				return
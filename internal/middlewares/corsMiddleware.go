@@ -4,15 +4,15 @@ import (
 	"net/http"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
 	"github.com/gin-gonic/gin"
 )
 
 // CORSMiddleware is a middleware function that adds Cross-Origin Resource Sharing (CORS) headers to the response.
 // It allows requests from different origins to access the resources of the server.
-func CORSMiddleware() gin.HandlerFunc {
+func CORSMiddleware(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		allowedOrigins := global.Cfg.Cors.AllowedOrigins
+		allowedOrigins := a.Cfg.Cors.AllowedOrigins
 
 		origin := c.Request.Header.Get("Origin")
 
@@ -40,5 +40,7 @@ func setCORSHeaders(w http.ResponseWriter, origin string) {
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
 	w.Header().Add("Access-Control-Allow-Headers", "X-Device-Id")
 	w.Header().Add("Access-Control-Allow-Headers", "X-CSRF-Token")
+	w.Header().Add("Access-Control-Allow-Headers", constants.HeaderRequestID)
+	w.Header().Set("Access-Control-Expose-Headers", constants.HeaderRequestID)
 
 }
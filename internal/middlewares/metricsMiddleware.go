@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/metrics"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsMiddleware observes the duration of every request by method, route template and status.
+// The route template, e.g. /v1/user/profile/:id, keeps one series per route whatever its parameters;
+// requests that match no route share the "unmatched" route.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = constants.MetricRouteUnmatched
+		}
+
+		metrics.RequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// OutcomeMetrics counts the requests of a route in counter, labelled with their result and, for a failure,
+// the error code of the response. It goes before the middlewares of the route, so a request they refuse is counted too.
+func OutcomeMetrics(counter *prometheus.CounterVec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if code, failed := response.ErrorCode(c); failed {
+			counter.WithLabelValues(constants.MetricResultFailure, strconv.Itoa(code)).Inc()
+			return
+		}
+		counter.WithLabelValues(constants.MetricResultSuccess, "").Inc()
+	}
+}
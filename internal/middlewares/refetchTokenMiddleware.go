@@ -2,10 +2,10 @@ package middlewares
 
 import (
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/logger"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
@@ -15,7 +15,7 @@ import (
 // It checks if the refetch token is valid and associated with the correct device and user.
 // If the refetch token is invalid or the device/user is unauthorized, it aborts the request with an unauthorized status.
 // Otherwise, it sets the refetch token in the context and proceeds to the next middleware or handler.
-func RefetchTokenMiddleware() gin.HandlerFunc {
+func RefetchTokenMiddleware(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		refetchToken, err := c.Cookie("user_login")
 		if err != nil {
@@ -30,7 +30,7 @@ func RefetchTokenMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		resultDevice, errDevice := repo.GetDeviceId(global.DB, models.GetDeviceIdParams{
+		resultDevice, errDevice := a.Repos.Devices.GetDeviceId(c, models.GetDeviceIdParams{
 			DeviceId: deviceID.(string),
 			IsActive: true,
 		})
@@ -58,7 +58,7 @@ func RefetchTokenMiddleware() gin.HandlerFunc {
 		userId := userInfo["id"].(float64)
 		email := userInfo["email"].(string)
 
-		resultCheckUser := CheckUser(email)
+		resultCheckUser := CheckUser(c, a.Repos.Users, email)
 
 		if !resultCheckUser {
 			response.UnauthorizedError(c, response.ErrUserNotExit)
@@ -70,6 +70,7 @@ func RefetchTokenMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		logger.SetUserID(c, int(userId))
 		c.Set(constants.InfoRefetch, models.PayloadRefetchResponse{
 			ID:    int(userId),
 			Email: email,
@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"regexp"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// requestIDPattern is what a request ID sent by the caller may contain, so it can be logged as is.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+
+// RequestIDMiddleware gives every request an ID: the X-Request-ID header sent by the caller, such as a proxy,
+// or a new UUID when it is missing or invalid. The ID is returned in the X-Request-ID header of the response
+// and in the body of an error response, and added to the records logged with the context of the request.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(constants.HeaderRequestID)
+		if len(requestID) > constants.RequestIDMaxLength || !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(constants.RequestIDKey, requestID)
+		c.Header(constants.HeaderRequestID, requestID)
+		c.Request = c.Request.WithContext(logger.WithRequest(c.Request.Context(), requestID))
+
+		c.Next()
+	}
+}
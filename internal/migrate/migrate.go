@@ -0,0 +1,401 @@
+// Package migrate applies and reverts the schema migrations and records the applied versions
+// in the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+)
+
+var (
+	ErrInvalidName = errors.New("invalid migration name")
+	ErrNoDown      = errors.New("migration has no down file")
+	ErrMissingFile = errors.New("applied migration has no file")
+	ErrNoVersion   = errors.New("no migration has this version")
+)
+
+var (
+	// fileName matches the migration files: the version, an underscore and the name.
+	fileName = regexp.MustCompile(`^([0-9]+)_([a-z0-9_]+)\.sql$`)
+	// nameSeparators are replaced by an underscore in the name of a new migration.
+	nameSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Migration is one migration file and the down file that reverts it.
+// Down is empty when the migration cannot be reverted.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	HasDown bool
+}
+
+// String returns the file name of the migration without its extension.
+func (m Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+// Status is a migration and whether it has been applied.
+// Missing is set for a version recorded as applied whose file no longer exists.
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Missing   bool       `json:"missing,omitempty"`
+}
+
+// Migrator applies the migrations of a file system to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	settings   map[string]string
+}
+
+// New loads the migrations of fsys, usually the embedded migrations.FS or os.DirFS of the migrations directory.
+// The settings are set for the transaction of every migration, so a migration that depends on the configuration
+// reads them with current_setting(name, true); their names need a dot, such as app.phone_region.
+func New(db *sql.DB, fsys fs.FS, settings map[string]string) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, settings: settings}, nil
+}
+
+// Load reads the migrations N_name.sql at the root of fsys and their down files down/N_name.sql,
+// sorted by version. Two migrations with the same version are an error.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	versions := make(map[int64]string)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidName, entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidName, entry.Name())
+		}
+		if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("%w: %s and %s have the same version", ErrInvalidName, other, entry.Name())
+		}
+		versions[version] = entry.Name()
+
+		up, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration := Migration{Version: version, Name: match[2], Up: string(up)}
+		down, err := fs.ReadFile(fsys, path.Join("down", entry.Name()))
+		switch {
+		case err == nil:
+			migration.Down = string(down)
+			migration.HasDown = true
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, err
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every migration that has not been applied yet, in order, and returns those it applied.
+// Each migration runs in its own transaction with the insert of its version,
+// so a failed migration leaves no trace and the ones before it stay applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if err := m.setSettings(ctx, tx); err != nil {
+					return err
+				}
+				if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO `+constants.MigrationTable+` (version, name) VALUES ($1, $2)`,
+					migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns those it reverted.
+// It stops at a migration that has no down file or whose file no longer exists.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for i := 0; i < steps && i < len(versions); i++ {
+			migration, ok := m.find(versions[i])
+			if !ok {
+				return fmt.Errorf("version %d: %w", versions[i], ErrMissingFile)
+			}
+			if !migration.HasDown {
+				return fmt.Errorf("migration %s: %w", migration, ErrNoDown)
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if err := m.setSettings(ctx, tx); err != nil {
+					return err
+				}
+				if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`DELETE FROM `+constants.MigrationTable+` WHERE version = $1`, migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Baseline records every migration up to version as applied without running it, and returns those it recorded.
+// It adopts a database whose tables were created outside the migrator, such as by the SQL files
+// the Postgres image used to run from docker-entrypoint-initdb.d, so Up only applies the migrations after version.
+// The versions already recorded are left as they are.
+func (m *Migrator) Baseline(ctx context.Context, version int64) ([]Migration, error) {
+	if _, ok := m.find(version); !ok {
+		return nil, fmt.Errorf("version %d: %w", version, ErrNoVersion)
+	}
+
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		return inTx(ctx, conn, func(tx *sql.Tx) error {
+			for _, migration := range m.migrations {
+				if migration.Version > version {
+					break
+				}
+				if _, ok := applied[migration.Version]; ok {
+					continue
+				}
+
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO `+constants.MigrationTable+` (version, name) VALUES ($1, $2)`,
+					migration.Version, migration.Name)
+				if err != nil {
+					return fmt.Errorf("migration %s: %w", migration, err)
+				}
+				done = append(done, migration)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// Status lists every migration and whether it has been applied, sorted by version,
+// including the applied versions whose file no longer exists.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(_ *sql.Conn, applied map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		for version, appliedAt := range applied {
+			if _, ok := m.find(version); !ok {
+				appliedAt := appliedAt
+				statuses = append(statuses, Status{Version: version, Applied: true, AppliedAt: &appliedAt, Missing: true})
+			}
+		}
+		return nil
+	})
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, err
+}
+
+// setSettings sets the settings of the migrator for the rest of the transaction tx, in the order of their names.
+func (m *Migrator) setSettings(ctx context.Context, tx *sql.Tx) error {
+	names := make([]string, 0, len(m.settings))
+	for name := range m.settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := tx.ExecContext(ctx, `SELECT set_config($1, $2, true)`, name, m.settings[name]); err != nil {
+			return fmt.Errorf("error setting %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// find returns the migration with the given version.
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// locked runs fn on a single connection that holds the migration advisory lock,
+// with the versions already applied. The schema_migrations table is created if it does not exist.
+// Another process applying migrations at the same time waits for the lock,
+// and then finds the migrations applied by the first one.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, applied map[int64]time.Time) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, constants.MigrationLockKey); err != nil {
+		return fmt.Errorf("error locking migrations: %w", err)
+	}
+	// The lock is released even when ctx was cancelled
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, constants.MigrationLockKey)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+constants.MigrationTable+` (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", constants.MigrationTable, err)
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM `+constants.MigrationTable)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	return fn(conn, applied)
+}
+
+// inTx runs fn in a transaction on conn, committed when fn returns nil and rolled back otherwise.
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Create writes an empty migration and its down file to dir, numbered after the last migration there,
+// and returns their paths. The name is lower-cased and every other character than letters and digits
+// becomes an underscore, so "Add users bio" is written as N_add_users_bio.sql.
+func Create(dir, name string) ([]string, error) {
+	name = strings.Trim(nameSeparators.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	file := fmt.Sprintf("%d_%s.sql", version, name)
+	paths := []string{filepath.Join(dir, file), filepath.Join(dir, "down", file)}
+	contents := []string{
+		"-- Migration " + file + "\n",
+		"-- Reverts " + file + "; leave only comments if it cannot be reverted\n",
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "down"), 0o755); err != nil {
+		return nil, err
+	}
+	for i, p := range paths {
+		// O_EXCL so an existing file is never overwritten
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.WriteString(contents[i])
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"10_alter_table_users.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN bio TEXT;")},
+		"2_create_table_users.sql":      {Data: []byte("CREATE TABLE users (id SERIAL);")},
+		"down/2_create_table_users.sql": {Data: []byte("DROP TABLE users;")},
+		"migrations.go":                 {Data: []byte("package migrations")},
+	}
+
+	loaded, err := Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+
+	//* Sorted by version, not by file name
+	assert.Equal(t, "2_create_table_users", loaded[0].String())
+	assert.True(t, loaded[0].HasDown)
+	assert.Equal(t, "DROP TABLE users;", loaded[0].Down)
+	assert.Equal(t, "10_alter_table_users", loaded[1].String())
+	assert.False(t, loaded[1].HasDown)
+
+	_, err = Load(fstest.MapFS{"create_table_users.sql": {}})
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	_, err = Load(fstest.MapFS{"1_create_table_users.sql": {}, "01_create_table_devices.sql": {}})
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+// Every embedded migration can be reverted, and the versions follow each other without gaps.
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, loaded)
+
+	for i, migration := range loaded {
+		assert.Equal(t, int64(i+1), migration.Version, migration.String())
+		assert.True(t, migration.HasDown, migration.String())
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	paths, err := Create(dir, "Create table users")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "1_create_table_users.sql"),
+		filepath.Join(dir, "down", "1_create_table_users.sql"),
+	}, paths)
+
+	paths, err = Create(dir, "add-users-bio")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "2_add_users_bio.sql"), paths[0])
+
+	loaded, err := Load(os.DirFS(dir))
+	require.NoError(t, err)
+	assert.Len(t, loaded, 2)
+
+	_, err = Create(dir, "  ")
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+// A baseline must name a migration, and is refused before the database is touched.
+func TestBaselineUnknownVersion(t *testing.T) {
+	migrator, err := New(nil, migrations.FS, nil)
+	require.NoError(t, err)
+
+	_, err = migrator.Baseline(context.Background(), 0)
+	assert.ErrorIs(t, err, ErrNoVersion)
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type AuditEvent struct {
+	ID        int64           `json:"id"`
+	ActorID   sql.NullInt32   `json:"actor_id"`
+	SubjectID sql.NullInt32   `json:"subject_id"`
+	EventType string          `json:"event_type"`
+	IP        sql.NullString  `json:"ip"`
+	DeviceID  sql.NullString  `json:"device_id"`
+	UserAgent sql.NullString  `json:"user_agent"`
+	Metadata  json.RawMessage `json:"metadata"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type CreateAuditEventParams struct {
+	ActorID   sql.NullInt32   `json:"actor_id"`
+	SubjectID sql.NullInt32   `json:"subject_id"`
+	EventType string          `json:"event_type"`
+	IP        sql.NullString  `json:"ip"`
+	DeviceID  sql.NullString  `json:"device_id"`
+	UserAgent sql.NullString  `json:"user_agent"`
+	Metadata  json.RawMessage `json:"metadata"`
+}
+
+type ListAuditEventsParams struct {
+	UserID    sql.NullInt32  `json:"user_id"`
+	EventType sql.NullString `json:"event_type"`
+	Before    sql.NullInt64  `json:"before"`
+	Limit     int            `json:"limit"`
+}
+
+// AuditEntry describes a security-relevant event before the request details are added to it.
+// ActorID is the user who performed the action and SubjectID the account it affected;
+// 0 means unknown (e.g. a failed login) or not applicable.
+type AuditEntry struct {
+	ActorID   int
+	SubjectID int
+	EventType string
+	Metadata  map[string]interface{}
+}
+
+type QueryAuditEventsRequest struct {
+	UserID    int    `form:"user_id"`
+	EventType string `form:"event_type"`
+	Before    int64  `form:"before"`
+	Limit     int    `form:"limit"`
+}
+
+type AuditEventJSON struct {
+	ID        int64           `json:"id"`
+	ActorID   int             `json:"actor_id,omitempty"`
+	SubjectID int             `json:"subject_id,omitempty"`
+	EventType string          `json:"event_type"`
+	IP        string          `json:"ip"`
+	DeviceID  string          `json:"device_id"`
+	UserAgent string          `json:"user_agent"`
+	Metadata  json.RawMessage `json:"metadata"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type AuditEventsResponse struct {
+	Events     []AuditEventJSON `json:"events"`
+	NextBefore int64            `json:"next_before,omitempty"`
+}
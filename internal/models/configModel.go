@@ -1,13 +1,27 @@
 package models
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Cache    CacheConfig
-	Gmail    GmailConfig
-	Telegram TelegramConfig
-	RabbitMQ RabbitMQConfig
-	Cors     CorsConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Cache     CacheConfig
+	Gmail     GmailConfig
+	Telegram  TelegramConfig
+	RabbitMQ  RabbitMQConfig
+	Cors      CorsConfig
+	SMS       SMSConfig
+	Phone     PhoneConfig
+	Mail      MailConfig
+	Admin     AdminConfig
+	GeoIP     GeoIPConfig
+	Risk      RiskConfig
+	Export    ExportConfig
+	Account   AccountConfig
+	Retention RetentionConfig
+	Social    SocialConfig
+	Timeout   TimeoutConfig
+	Metrics   MetricsConfig
+	Tracing   TracingConfig
+	Log       LogConfig
 }
 
 type CorsConfig struct {
@@ -19,14 +33,19 @@ type ServerConfig struct {
 	Port         string
 	PortFrontend string
 	KeyPassword  string
+	RateLimit    float64
+	RateBurst    int
 }
 
+// DatabaseConfig holds the PostgreSQL connection settings.
+// MigrateOnStart applies the pending migrations when the server starts.
 type DatabaseConfig struct {
-	Username string
-	Password string
-	Name     string
-	Host     string
-	Port     string
+	Username       string
+	Password       string
+	Name           string
+	Host           string
+	Port           string
+	MigrateOnStart bool
 }
 
 type CacheConfig struct {
@@ -54,3 +73,116 @@ type RabbitMQConfig struct {
 	Password string
 	URL      string
 }
+
+type SMSConfig struct {
+	Driver     string
+	LogPath    string
+	GatewayURL string
+	APIKey     string
+	Sender     string
+	Timeout    int
+}
+
+type PhoneConfig struct {
+	DefaultRegion string
+}
+
+type MailConfig struct {
+	Driver        string
+	CapturePath   string
+	IdleTimeout   int
+	WebhookSecret string
+}
+
+type AdminConfig struct {
+	Emails []string
+}
+
+type GeoIPConfig struct {
+	CityPath string
+	ASNPath  string
+}
+
+// RiskConfig holds the rules of the login risk score.
+// Each weight is added to the score when its signal is present; a weight of 0 turns the rule off.
+type RiskConfig struct {
+	Enabled              bool
+	MediumScore          int
+	HighScore            int
+	NewDevice            int
+	NewNetwork           int
+	IPChange             int
+	FailedAttempts       int
+	FailedAttemptsMin    int
+	BlacklistedNeighbour int
+	ImpossibleTravel     int
+	MaxTravelSpeed       float64
+}
+
+// ExportConfig holds where data exports are written and how long their download links work.
+// BaseURL is the public address of the API used in the links; it defaults to http://host:port.
+type ExportConfig struct {
+	Dir     string
+	TTL     int
+	BaseURL string
+}
+
+// AccountConfig holds the account lifecycle settings.
+// DeletionGraceDays is how long a deleted account can still be restored before its data is erased.
+type AccountConfig struct {
+	DeletionGraceDays int
+}
+
+// RetentionConfig holds how long the cleanup jobs keep expired data, in days after it expired
+// or was logged out, and how many rows they delete per statement.
+type RetentionConfig struct {
+	VerificationDays int
+	OtpDays          int
+	DeviceDays       int
+	OutboxDays       int
+	BatchSize        int
+}
+
+// SocialConfig holds who social login ID tokens must be issued for.
+// FirebaseProjectID is the audience of Firebase ID tokens and GoogleClientIDs those of Google Sign-In ID tokens;
+// a provider with no audience is not accepted. JWKSPath switches to offline verification
+// against the keys of a local JWKS file instead of those published by Google.
+type SocialConfig struct {
+	FirebaseProjectID string
+	GoogleClientIDs   []string
+	JWKSPath          string
+}
+
+// TimeoutConfig holds how long each kind of operation of a request may run, in milliseconds:
+// a database query, a whole database transaction, a Redis command and a Firebase Authentication call.
+// A timeout of 0 leaves the operation bounded by the request only, which is cancelled when the client goes away.
+type TimeoutConfig struct {
+	Query       int
+	Transaction int
+	Cache       int
+	Firebase    int
+}
+
+// MetricsConfig holds how the Prometheus metrics are served.
+// Token, when set, must be sent by the scraper as a bearer token. QueuePort is the port
+// the queue consumer serves its metrics on; it has no HTTP server otherwise, and an empty port serves none.
+type MetricsConfig struct {
+	Token     string
+	QueuePort string
+}
+
+// TracingConfig holds where the OpenTelemetry traces are exported.
+// Exporter is "otlp" to send them to the OTLP/HTTP collector at Endpoint (host:port, without TLS when Insecure is set),
+// "stdout" to print them for local use, or empty to disable tracing.
+// SampleRatio is the share of new traces recorded; 0 records them all.
+type TracingConfig struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+// LogConfig holds the level of the logs: debug, info, warn or error.
+type LogConfig struct {
+	Level string
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type ScheduleAccountDeletionParams struct {
+	ID          int       `json:"id"`
+	CancelToken string    `json:"cancel_token"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+}
+
+type ScheduleAccountDeletionRow struct {
+	ID          int            `json:"id"`
+	Email       string         `json:"email"`
+	Locale      sql.NullString `json:"locale"`
+	ScheduledAt time.Time      `json:"scheduled_at"`
+}
+
+type AccountDueForDeletion struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+}
+
+type BodyCancelDeletionRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type CancelDeletionResponse struct {
+	Id    int    `json:"id"`
+	Email string `json:"email"`
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"database/sql"
+	"io"
+	"time"
+)
+
+type DataExport struct {
+	ID          int            `json:"id"`
+	UserID      int            `json:"user_id"`
+	Status      int            `json:"status"`
+	Token       string         `json:"token"`
+	FilePath    sql.NullString `json:"file_path"`
+	Error       sql.NullString `json:"error"`
+	Attempts    int            `json:"attempts"`
+	ExpiresAt   sql.NullTime   `json:"expires_at"`
+	CompletedAt sql.NullTime   `json:"completed_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+type CreateDataExportParams struct {
+	UserID int    `json:"user_id"`
+	Status int    `json:"status"`
+	Token  string `json:"token"`
+}
+
+type UpdateDataExportStatusParams struct {
+	ID     int            `json:"id"`
+	Status int            `json:"status"`
+	Error  sql.NullString `json:"error"`
+}
+
+type MarkDataExportReadyParams struct {
+	ID        int       `json:"id"`
+	Status    int       `json:"status"`
+	FilePath  string    `json:"file_path"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DataExportDownload is the open archive of a data export, sent by the download endpoint.
+type DataExportDownload struct {
+	ID      int
+	File    io.ReadSeekCloser
+	ModTime time.Time
+}
+
+type ExpiredDataExport struct {
+	ID       int            `json:"id"`
+	FilePath sql.NullString `json:"file_path"`
+}
+
+type DataExportRequestedEvent struct {
+	ExportID int    `json:"export_id"`
+	UserID   int    `json:"user_id"`
+	Locale   string `json:"locale"`
+}
+
+type DataExportResponse struct {
+	Id       int    `json:"id"`
+	ExportID int    `json:"export_id"`
+	Status   int    `json:"status"`
+	Message  string `json:"message"`
+}
+
+type ParamsDataExportRequest struct {
+	Token string `uri:"token" binding:"required"`
+}
+
+// * --- Export file
+// The rows below are what a user gets in their data export. They leave out secrets on purpose:
+// password hashes, OTP codes, verification and revoke tokens and device public keys are never exported.
+
+type ExportDevice struct {
+	DeviceID    string     `json:"device_id"`
+	DeviceType  string     `json:"device_type"`
+	IP          string     `json:"ip"`
+	IsActive    bool       `json:"is_active"`
+	LoggedInAt  *time.Time `json:"logged_in_at"`
+	LoggedOutAt *time.Time `json:"logged_out_at"`
+	CreatedAt   *time.Time `json:"created_at"`
+}
+
+type ExportPasswordChange struct {
+	Reason    int        `json:"reason"`
+	ChangedAt *time.Time `json:"changed_at"`
+}
+
+type ExportSocialLogin struct {
+	Provider  int        `json:"provider"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+type ExportOtp struct {
+	Channel   int        `json:"channel"`
+	IsActive  bool       `json:"is_active"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+type ExportVerification struct {
+	IsVerified bool       `json:"is_verified"`
+	IsActive   bool       `json:"is_active"`
+	VerifiedAt *time.Time `json:"verified_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  *time.Time `json:"created_at"`
+}
+
+type ExportSignIn struct {
+	DeviceID   string     `json:"device_id"`
+	DeviceType string     `json:"device_type"`
+	IP         string     `json:"ip"`
+	Network    string     `json:"network"`
+	NewDevice  bool       `json:"new_device"`
+	NewNetwork bool       `json:"new_network"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ExportMail struct {
+	Template  string    `json:"template"`
+	Recipient string    `json:"recipient"`
+	Status    int       `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserDataExport is everything held about a user, written as data.json in the export archive.
+type UserDataExport struct {
+	GeneratedAt     time.Time              `json:"generated_at"`
+	Profile         ProfileResponseJSON    `json:"profile"`
+	Devices         []ExportDevice         `json:"devices"`
+	PasswordChanges []ExportPasswordChange `json:"password_changes"`
+	SocialLogins    []ExportSocialLogin    `json:"social_logins"`
+	Otps            []ExportOtp            `json:"otps"`
+	Verifications   []ExportVerification   `json:"verifications"`
+	SignIns         []ExportSignIn         `json:"sign_ins"`
+	Emails          []ExportMail           `json:"emails"`
+	AuditEvents     []AuditEventJSON       `json:"audit_events"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type JobRun struct {
+	ID          int64          `json:"id"`
+	JobName     string         `json:"job_name"`
+	FenceToken  int64          `json:"fence_token"`
+	Trigger     string         `json:"trigger"`
+	Status      int            `json:"status"`
+	Rows        int64          `json:"rows"`
+	Error       sql.NullString `json:"error"`
+	Owner       string         `json:"owner"`
+	TriggeredBy sql.NullInt32  `json:"triggered_by"`
+	StartedAt   time.Time      `json:"started_at"`
+	FinishedAt  sql.NullTime   `json:"finished_at"`
+}
+
+type StartJobRunParams struct {
+	JobName     string        `json:"job_name"`
+	FenceToken  int64         `json:"fence_token"`
+	Trigger     string        `json:"trigger"`
+	Status      int           `json:"status"`
+	Owner       string        `json:"owner"`
+	TriggeredBy sql.NullInt32 `json:"triggered_by"`
+}
+
+type FinishJobRunParams struct {
+	ID         int64          `json:"id"`
+	FenceToken int64          `json:"fence_token"`
+	Status     int            `json:"status"`
+	Rows       int64          `json:"rows"`
+	Error      sql.NullString `json:"error"`
+}
+
+type AbandonJobRunsParams struct {
+	JobName string `json:"job_name"`
+	Status  int    `json:"status"`
+	Error   string `json:"error"`
+}
+
+type ListJobRunsParams struct {
+	JobName string        `json:"job_name"`
+	Before  sql.NullInt64 `json:"before"`
+	Limit   int           `json:"limit"`
+}
+
+type ParamsJobRequest struct {
+	Name string `uri:"name" binding:"required"`
+}
+
+type QueryJobRunsRequest struct {
+	Before int64 `form:"before"`
+	Limit  int   `form:"limit"`
+}
+
+type JobRunJSON struct {
+	ID          int64      `json:"id"`
+	JobName     string     `json:"job_name"`
+	FenceToken  int64      `json:"fence_token"`
+	Trigger     string     `json:"trigger"`
+	Status      int        `json:"status"`
+	Rows        int64      `json:"rows"`
+	Error       string     `json:"error,omitempty"`
+	Owner       string     `json:"owner"`
+	TriggeredBy int        `json:"triggered_by,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+type JobJSON struct {
+	Name    string      `json:"name"`
+	Spec    string      `json:"spec"`
+	LastRun *JobRunJSON `json:"last_run"`
+}
+
+type JobsResponse struct {
+	Jobs []JobJSON `json:"jobs"`
+}
+
+type JobRunsResponse struct {
+	Runs       []JobRunJSON `json:"runs"`
+	NextBefore int64        `json:"next_before,omitempty"`
+}
+
+type TriggerJobResponse struct {
+	RunID   int64  `json:"run_id"`
+	JobName string `json:"job_name"`
+	Status  int    `json:"status"`
+}
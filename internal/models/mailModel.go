@@ -1,7 +1,70 @@
 package models
 
+import (
+	"database/sql"
+	"time"
+)
+
+// EmailData holds the data for an email template.
+// Template is the name of a template in templates/email, Locale the language it is rendered in
+// and Body the value inserted in it (a link, a code or a password).
+// Details holds extra named values for templates that need more than one (e.g. .Details.ip).
 type EmailData struct {
-	Title    string
-	Body     string
 	Template string
+	Locale   string
+	Body     string
+	Details  map[string]string
+}
+
+type MailLog struct {
+	ID                int            `json:"id"`
+	UserID            sql.NullInt32  `json:"user_id"`
+	Template          string         `json:"template"`
+	Recipient         string         `json:"recipient"`
+	Status            int            `json:"status"`
+	ProviderMessageID sql.NullString `json:"provider_message_id"`
+	Error             sql.NullString `json:"error"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+}
+
+type CreateMailLogParams struct {
+	UserID            sql.NullInt32  `json:"user_id"`
+	Template          string         `json:"template"`
+	Recipient         string         `json:"recipient"`
+	Status            int            `json:"status"`
+	ProviderMessageID sql.NullString `json:"provider_message_id"`
+	Error             sql.NullString `json:"error"`
+}
+
+type UpdateMailLogStatusParams struct {
+	ProviderMessageID string         `json:"provider_message_id"`
+	Status            int            `json:"status"`
+	Error             sql.NullString `json:"error"`
+}
+
+type UpdateMailLogStatusRow struct {
+	ID        int           `json:"id"`
+	UserID    sql.NullInt32 `json:"user_id"`
+	Recipient string        `json:"recipient"`
+}
+
+// MailWebhookEvent is a bounce or complaint notification from the mail provider.
+// Type is "bounce" or "complaint", BounceType "hard" or "soft" for bounces.
+// At least one of Email and MessageID identifies the email.
+type MailWebhookEvent struct {
+	Type       string `json:"type"`
+	BounceType string `json:"bounce_type"`
+	Email      string `json:"email"`
+	MessageID  string `json:"message_id"`
+	Reason     string `json:"reason"`
+}
+
+type MailWebhookRequest struct {
+	Events []MailWebhookEvent `json:"events"`
+}
+
+type MailWebhookResponse struct {
+	Processed  int `json:"processed"`
+	Suppressed int `json:"suppressed"`
 }
@@ -6,41 +6,76 @@ import (
 )
 
 type Otp struct {
-	ID        int          `json:"id"`
-	UserID    int          `json:"user_id"`
-	OtpCode   string       `json:"otp_code"`
-	CreatedAt sql.NullTime `json:"created_at"`
-	IsActive  bool         `json:"is_active"`
-	ExpiresAt time.Time    `json:"expires_at"`
+	ID        int            `json:"id"`
+	UserID    int            `json:"user_id"`
+	OtpCode   string         `json:"otp_code"`
+	Channel   int            `json:"channel"`
+	Purpose   string         `json:"purpose"`
+	Phone     sql.NullString `json:"phone"`
+	CreatedAt sql.NullTime   `json:"created_at"`
+	IsActive  bool           `json:"is_active"`
+	ExpiresAt time.Time      `json:"expires_at"`
 }
 
 type SendOtpResponse struct {
 	Id        int    `json:"id"`
 	Code      string `json:"code"`
+	Channel   int    `json:"channel"`
 	ExpiredAt string `json:"expired_at"`
 }
 
+// CreateOtpParams describes an OTP to create.
+// Purpose is what the OTP can be used for and Phone, for an SMS OTP, the number it is sent to.
 type CreateOtpParams struct {
-	UserID    int       `json:"user_id"`
-	OtpCode   string    `json:"otp_code"`
-	ExpiresAt time.Time `json:"expires_at"`
+	UserID    int            `json:"user_id"`
+	OtpCode   string         `json:"otp_code"`
+	Channel   int            `json:"channel"`
+	Purpose   string         `json:"purpose"`
+	Phone     sql.NullString `json:"phone"`
+	ExpiresAt time.Time      `json:"expires_at"`
 }
 
 type OtpRequest struct {
-	Otp string `json:"otp" binding:"required"`
+	Otp       string `json:"otp" binding:"required"`
+	Challenge string `json:"challenge" binding:"required"`
+	Channel   int    `json:"channel"`
 }
 
 type UpdateOtpIsActiveParams struct {
-	IsActive bool   `json:"is_active"`
-	OtpCode  string `json:"otp_code"`
+	IsActive bool `json:"is_active"`
+	ID       int  `json:"id"`
+}
+
+// GetNewOtpsParams selects the usable OTPs with a code, sent for Purpose.
+// UserID limits them to the OTPs of one user and Channel to those delivered through one channel.
+type GetNewOtpsParams struct {
+	OtpCode string        `json:"otp_code"`
+	Purpose string        `json:"purpose"`
+	UserID  sql.NullInt32 `json:"user_id"`
+	Channel sql.NullInt32 `json:"channel"`
 }
 
 type GetNewOtpsRow struct {
-	ID        int          `json:"id"`
-	UserID    int          `json:"user_id"`
-	OtpCode   string       `json:"otp_code"`
-	CreatedAt sql.NullTime `json:"created_at"`
-	IsActive  bool         `json:"is_active"`
-	ExpiresAt time.Time    `json:"expires_at"`
-	Email     string       `json:"email"`
+	ID        int            `json:"id"`
+	UserID    int            `json:"user_id"`
+	OtpCode   string         `json:"otp_code"`
+	Channel   int            `json:"channel"`
+	Purpose   string         `json:"purpose"`
+	Phone     sql.NullString `json:"phone"`
+	CreatedAt sql.NullTime   `json:"created_at"`
+	IsActive  bool           `json:"is_active"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	Email     string         `json:"email"`
+	Locale    sql.NullString `json:"locale"`
+}
+
+// * --- Verify Phone
+type BodyVerifyPhoneRequest struct {
+	Otp string `json:"otp" binding:"required"`
+}
+
+type VerifyPhoneResponse struct {
+	Id                int    `json:"id"`
+	HiddenPhoneNumber string `json:"hidden_phone_number"`
+	PhoneVerified     bool   `json:"phone_verified"`
 }
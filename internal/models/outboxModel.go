@@ -0,0 +1,82 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type Outbox struct {
+	ID            int               `json:"id"`
+	AggregateType string            `json:"aggregate_type"`
+	AggregateID   int               `json:"aggregate_id"`
+	EventType     string            `json:"event_type"`
+	Payload       json.RawMessage   `json:"payload"`
+	Status        int               `json:"status"`
+	Attempts      int               `json:"attempts"`
+	LastError     sql.NullString    `json:"last_error"`
+	CreatedAt     time.Time         `json:"created_at"`
+	PublishedAt   sql.NullTime      `json:"published_at"`
+	TraceContext  map[string]string `json:"trace_context"`
+}
+
+type CreateOutboxParams struct {
+	AggregateType string            `json:"aggregate_type"`
+	AggregateID   int               `json:"aggregate_id"`
+	EventType     string            `json:"event_type"`
+	Payload       json.RawMessage   `json:"payload"`
+	TraceContext  map[string]string `json:"trace_context"`
+}
+
+type MarkOutboxFailedParams struct {
+	ID          int    `json:"id"`
+	LastError   string `json:"last_error"`
+	MaxAttempts int    `json:"max_attempts"`
+}
+
+// OutboxMessage is the body published to RabbitMQ for every outbox row.
+type OutboxMessage struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// EmailMessage is the payload of an email.send message.
+// The body of the email is stored in outbox_secrets and referenced by SecretID;
+// Body is only set in messages written before the secrets were kept out of the outbox.
+type EmailMessage struct {
+	UserID   int               `json:"user_id"`
+	To       string            `json:"to"`
+	Template string            `json:"template"`
+	Locale   string            `json:"locale"`
+	SecretID int               `json:"secret_id,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	Details  map[string]string `json:"details,omitempty"`
+}
+
+type CreateOutboxSecretParams struct {
+	UserID    int       `json:"user_id"`
+	Secret    string    `json:"secret"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type UserRegisteredEvent struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+type SessionRevokedEvent struct {
+	UserID   int    `json:"user_id"`
+	DeviceID string `json:"device_id"`
+}
+
+// DeadLetter describes a message parked in the dead-letter queue.
+type DeadLetter struct {
+	MessageID  string    `json:"message_id"`
+	Type       string    `json:"type"`
+	RetryCount int       `json:"retry_count"`
+	LastError  string    `json:"last_error"`
+	Timestamp  time.Time `json:"timestamp"`
+	Body       string    `json:"body"`
+}
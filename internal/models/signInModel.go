@@ -0,0 +1,89 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SignInCheck describes how a login compares to the user's previous sign-ins.
+// HasSignIns is false on the first sign-in ever recorded, which is never reported as new.
+type SignInCheck struct {
+	DeviceID   string
+	DeviceType string
+	IP         string
+	Network    string
+	HasSignIns bool
+	NewDevice  bool
+	NewNetwork bool
+}
+
+// Unknown reports whether the login comes from a device or network the user has not signed in from before.
+func (s SignInCheck) Unknown() bool {
+	return s.NewDevice || s.NewNetwork
+}
+
+type GetSignInHistoryParams struct {
+	UserID   int    `json:"user_id"`
+	DeviceID string `json:"device_id"`
+	Network  string `json:"network"`
+}
+
+type SignInHistory struct {
+	HasSignIns   bool `json:"has_sign_ins"`
+	KnownDevice  bool `json:"known_device"`
+	KnownNetwork bool `json:"known_network"`
+}
+
+type CreateSignInParams struct {
+	UserID          int            `json:"user_id"`
+	DeviceID        string         `json:"device_id"`
+	DeviceType      sql.NullString `json:"device_type"`
+	Ip              sql.NullString `json:"ip"`
+	Network         sql.NullString `json:"network"`
+	NewDevice       bool           `json:"new_device"`
+	NewNetwork      bool           `json:"new_network"`
+	RevokeToken     string         `json:"revoke_token"`
+	RevokeExpiresAt time.Time      `json:"revoke_expires_at"`
+}
+
+type RevokeSignInRow struct {
+	UserID   int    `json:"user_id"`
+	DeviceID string `json:"device_id"`
+}
+
+type DeviceUserParams struct {
+	UserID   int    `json:"user_id"`
+	DeviceID string `json:"device_id"`
+}
+
+type UpdateOtpNewDeviceParams struct {
+	ID           int  `json:"id"`
+	OtpNewDevice bool `json:"otp_new_device"`
+}
+
+// * --- Revoke Device
+type BodyRevokeDeviceRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type RevokeDeviceResponse struct {
+	Id       int    `json:"id"`
+	DeviceID string `json:"device_id"`
+}
+
+// * --- Otp New Device
+type BodyOtpNewDeviceRequest struct {
+	OtpNewDevice bool `json:"otp_new_device"`
+}
+
+type LastSignInRow struct {
+	Ip        sql.NullString `json:"ip"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// RiskAssessment is the result of scoring a login: the signals found, their total and what to do about it.
+type RiskAssessment struct {
+	Score    int      `json:"score"`
+	Signals  []string `json:"signals"`
+	Decision string   `json:"decision"`
+}
@@ -17,6 +17,10 @@ type User struct {
 	Gender            sql.NullInt16  `json:"gender"`
 	PasswordHash      sql.NullString `json:"password_hash"`
 	TwoFactorEnabled  bool           `json:"two_factor_enabled"`
+	TwoFactorChannel  int            `json:"two_factor_channel"`
+	PhoneVerified     bool           `json:"phone_verified"`
+	Locale            sql.NullString `json:"locale"`
+	OtpNewDevice      bool           `json:"otp_new_device"`
 	IsActive          bool           `json:"is_active"`
 	CreatedAt         time.Time      `json:"created_at"`
 	UpdatedAt         time.Time      `json:"updated_at"`
@@ -42,6 +46,7 @@ type TokenVerificationLink struct {
 type BodyLoginRequest struct {
 	Identifier string `json:"identifier" binding:"required"`
 	Password   string `json:"password" binding:"required,min=6"`
+	Channel    int    `json:"channel"`
 }
 
 type LoginResponse struct {
@@ -56,13 +61,18 @@ type LoginTwoFactor struct {
 	DeviceID  string    `json:"device_id"`
 	Email     string    `json:"email"`
 	Code      int       `json:"code"`
+	Channel   int       `json:"channel"`
+	Challenge string    `json:"challenge"`
 	ExpiredAt time.Time `json:"expired_at"`
+	NewDevice bool      `json:"new_device,omitempty"`
+	Risk      bool      `json:"risk,omitempty"`
 }
 
 // * ---Login Social
 type BodyLoginSocialRequest struct {
-	Uid  string `json:"uid" binding:"required"`
-	Type int    `json:"type" binding:"required"`
+	IdToken string `json:"id_token" binding:"required"`
+	Type    int    `json:"type" binding:"required"`
+	Channel int    `json:"channel"`
 }
 
 type SocialResponse struct {
@@ -82,10 +92,11 @@ type UpdatePasswordParams struct {
 }
 
 type UpdateUserResponse struct {
-	Id          int    `json:"id"`
-	Email       string `json:"email"`
-	HiddenEmail string `json:"hidden_email"`
-	IsActive    bool   `json:"is_active"`
+	Id          int            `json:"id"`
+	Email       string         `json:"email"`
+	HiddenEmail string         `json:"hidden_email"`
+	IsActive    bool           `json:"is_active"`
+	Locale      sql.NullString `json:"locale"`
 }
 
 type UpdateUserRow struct {
@@ -104,6 +115,7 @@ type UpdateUserParams struct {
 	Avatar            sql.NullString `json:"avatar"`
 	Gender            sql.NullInt64  `json:"gender"`
 	HiddenPhoneNumber sql.NullString `json:"hidden_phone_number"`
+	Locale            sql.NullString `json:"locale"`
 	ID                int            `json:"id"`
 }
 
@@ -113,6 +125,7 @@ type BodyUpdateRequest struct {
 	FullName string `json:"fullname"`
 	Avatar   string `json:"avatar"`
 	Gender   int    `json:"gender"`
+	Locale   string `json:"locale"`
 }
 
 // *  --- Payload Token
@@ -172,6 +185,10 @@ type ProfileResponse struct {
 	Avatar            sql.NullString `json:"avatar"`
 	Gender            sql.NullInt16  `json:"gender"`
 	TwoFactorEnabled  bool           `json:"two_factor_enabled"`
+	TwoFactorChannel  int            `json:"two_factor_channel"`
+	PhoneVerified     bool           `json:"phone_verified"`
+	Locale            sql.NullString `json:"locale"`
+	OtpNewDevice      bool           `json:"otp_new_device"`
 	IsActive          bool           `json:"is_active"`
 	CreatedAt         time.Time      `json:"created_at"`
 }
@@ -187,6 +204,10 @@ type ProfileResponseJSON struct {
 	Avatar            string `json:"avatar"`
 	Gender            int    `json:"gender"`
 	TwoFactorEnabled  bool   `json:"two_factor_enabled"`
+	TwoFactorChannel  int    `json:"two_factor_channel"`
+	PhoneVerified     bool   `json:"phone_verified"`
+	Locale            string `json:"locale"`
+	OtpNewDevice      bool   `json:"otp_new_device"`
 	IsActive          bool   `json:"is_active"`
 	CreatedAt         string `json:"created_at"`
 }
@@ -227,11 +248,19 @@ type BodyChangePasswordRequest struct {
 // * Update Two Factor Enable
 type UpdateTwoFactorEnableParams struct {
 	TwoFactorEnabled bool `json:"two_factor_enabled"`
+	TwoFactorChannel int  `json:"two_factor_channel"`
 	ID               int  `json:"id"`
 }
 
 type BodyTwoFactorEnableRequest struct {
 	TwoFactorEnabled bool `json:"two_factor_enabled"`
+	Channel          int  `json:"channel"`
+}
+
+// * Update Phone Verified
+type UpdatePhoneVerifiedParams struct {
+	PhoneVerified bool `json:"phone_verified"`
+	ID            int  `json:"id"`
 }
 
 // * Update Email
@@ -252,5 +281,6 @@ type BodyUpdateEmailRequest struct {
 }
 
 type DestroyAccountResponse struct {
-	Id int `json:"id"`
+	Id          int       `json:"id"`
+	ScheduledAt time.Time `json:"scheduled_at"`
 }
@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :exec
+WITH event AS (
+    INSERT INTO audit_events (
+        actor_id,
+        subject_id,
+        event_type,
+        metadata
+    ) VALUES (
+        $1,
+        $2,
+        $3,
+        $7
+    ) RETURNING id
+)
+INSERT INTO audit_event_context (
+    event_id,
+    ip,
+    device_id,
+    user_agent
+)
+SELECT id, $4::VARCHAR, $5::VARCHAR, $6::TEXT
+FROM event
+WHERE $4::VARCHAR IS NOT NULL OR $5::VARCHAR IS NOT NULL OR $6::TEXT IS NOT NULL
+`
+
+// CreateAuditEvent appends an event to the audit log.
+// The table is append-only: a trigger rejects every UPDATE and DELETE on it.
+// The IP, device ID and user agent are stored in audit_event_context, which is erased with the account.
+func CreateAuditEvent(ctx context.Context, db DBTX, arg models.CreateAuditEventParams) error {
+	ctx, end := startQuery(ctx, db, "CreateAuditEvent")
+	defer end()
+
+	metadata := []byte(arg.Metadata)
+	if len(metadata) == 0 {
+		metadata = []byte("{}")
+	}
+
+	_, err := db.ExecContext(ctx, createAuditEvent,
+		arg.ActorID,
+		arg.SubjectID,
+		arg.EventType,
+		arg.IP,
+		arg.DeviceID,
+		arg.UserAgent,
+		metadata,
+	)
+	return err
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT e.id, e.actor_id, e.subject_id, e.event_type, c.ip, c.device_id, c.user_agent, e.metadata, e.created_at
+FROM audit_events e
+LEFT JOIN audit_event_context c ON c.event_id = e.id
+WHERE ($1::INT IS NULL OR e.subject_id = $1 OR e.actor_id = $1)
+AND ($2::VARCHAR IS NULL OR e.event_type = $2)
+AND ($3::BIGINT IS NULL OR e.id < $3)
+ORDER BY e.id DESC
+LIMIT $4
+`
+
+// ListAuditEvents retrieves audit events, newest first.
+// UserID limits the events to those a user performed or was affected by, EventType to one type of event,
+// and Before to events older than the given ID, which is how the next page is requested.
+func ListAuditEvents(ctx context.Context, db DBTX, arg models.ListAuditEventsParams) ([]models.AuditEvent, error) {
+	ctx, end := startQuery(ctx, db, "ListAuditEvents")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listAuditEvents, arg.UserID, arg.EventType, arg.Before, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.AuditEvent{}
+	for rows.Next() {
+		var i models.AuditEvent
+		var metadata []byte
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorID,
+			&i.SubjectID,
+			&i.EventType,
+			&i.IP,
+			&i.DeviceID,
+			&i.UserAgent,
+			&metadata,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		i.Metadata = metadata
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DBTX is implemented by both *sql.DB and *sql.Tx,
+// so every repository function can run on its own or inside a transaction.
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Timeouts bound how long a single query and a whole transaction of the Postgres repositories may run
+// before they are cancelled. A zero timeout leaves them bounded by the caller's context only.
+// A query cancelled by its timeout returns context.DeadlineExceeded or the query_canceled error of Postgres.
+type Timeouts struct {
+	Query       time.Duration
+	Transaction time.Duration
+}
+
+// timedDB is a database or a transaction whose queries are cancelled when the query timeout elapses.
+type timedDB struct {
+	DBTX
+	timeout time.Duration
+}
+
+// startQuery starts the span repo.<name> of a query and returns a copy of ctx that carries it
+// and is cancelled when the query timeout of db elapses. The returned func cancels ctx and ends the span.
+func startQuery(ctx context.Context, db DBTX, name string) (context.Context, func()) {
+	ctx, span := tracing.Start(ctx, "repo."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperation(name)))
+
+	var timeout time.Duration
+	if timed, ok := db.(timedDB); ok {
+		timeout = timed.timeout
+	}
+
+	var cancel context.CancelFunc
+	if timeout <= 0 {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {
+		cancel()
+		span.End()
+	}
+}
+
+// WithTx runs fn inside a database transaction.
+// The transaction is committed when fn returns nil and rolled back when fn returns an error or panics,
+// or when ctx is cancelled or the timeout elapses before it is committed. A zero timeout leaves it bounded by ctx only.
+func WithTx(ctx context.Context, db *sql.DB, timeout time.Duration, fn func(tx *sql.Tx) error) (err error) {
+	ctx, span := tracing.Start(ctx, "repo.WithTx",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL))
+	defer func() { tracing.End(span, err) }()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
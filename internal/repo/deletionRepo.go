@@ -0,0 +1,260 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+const scheduleAccountDeletion = `-- name: ScheduleAccountDeletion :one
+UPDATE users
+SET is_active = FALSE, deletion_requested_at = NOW(), deletion_scheduled_at = $3, deletion_cancel_token = $2
+WHERE id = $1 AND is_active = TRUE AND deleted_at IS NULL
+RETURNING id, email, locale, deletion_scheduled_at
+`
+
+// ScheduleAccountDeletion deactivates an account and schedules its deletion at the end of the grace period.
+// The cancel token reactivates the account until then.
+// It returns sql.ErrNoRows if the account is not active.
+func ScheduleAccountDeletion(ctx context.Context, db DBTX, arg models.ScheduleAccountDeletionParams) (models.ScheduleAccountDeletionRow, error) {
+	ctx, end := startQuery(ctx, db, "ScheduleAccountDeletion")
+	defer end()
+
+	row := db.QueryRowContext(ctx, scheduleAccountDeletion, arg.ID, arg.CancelToken, arg.ScheduledAt)
+	var i models.ScheduleAccountDeletionRow
+	err := row.Scan(&i.ID, &i.Email, &i.Locale, &i.ScheduledAt)
+	return i, err
+}
+
+const cancelAccountDeletion = `-- name: CancelAccountDeletion :one
+UPDATE users
+SET is_active = TRUE, deletion_requested_at = NULL, deletion_scheduled_at = NULL, deletion_cancel_token = NULL
+WHERE deletion_cancel_token = $1 AND deletion_scheduled_at > NOW() AND deleted_at IS NULL
+RETURNING id, email
+`
+
+// CancelAccountDeletion reactivates the account whose deletion was scheduled with the given cancel token.
+// It returns sql.ErrNoRows if the token is unknown or the grace period is over.
+func CancelAccountDeletion(ctx context.Context, db DBTX, token string) (models.CancelDeletionResponse, error) {
+	ctx, end := startQuery(ctx, db, "CancelAccountDeletion")
+	defer end()
+
+	row := db.QueryRowContext(ctx, cancelAccountDeletion, token)
+	var i models.CancelDeletionResponse
+	err := row.Scan(&i.Id, &i.Email)
+	return i, err
+}
+
+const isAccountPendingDeletion = `-- name: IsAccountPendingDeletion :one
+SELECT EXISTS (
+    SELECT 1 FROM users WHERE id = $1 AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL
+)
+`
+
+// IsAccountPendingDeletion reports whether the account is in the grace period of a deletion.
+func IsAccountPendingDeletion(ctx context.Context, db DBTX, id int) (bool, error) {
+	ctx, end := startQuery(ctx, db, "IsAccountPendingDeletion")
+	defer end()
+
+	row := db.QueryRowContext(ctx, isAccountPendingDeletion, id)
+	var pending bool
+	err := row.Scan(&pending)
+	return pending, err
+}
+
+const deactivateUserDevices = `-- name: DeactivateUserDevices :exec
+UPDATE devices
+SET is_active = FALSE, logged_out_at = NOW()
+WHERE user_id = $1 AND is_active = TRUE
+`
+
+// DeactivateUserDevices logs every device out of the user's account.
+func DeactivateUserDevices(ctx context.Context, db DBTX, userId int) error {
+	ctx, end := startQuery(ctx, db, "DeactivateUserDevices")
+	defer end()
+
+	_, err := db.ExecContext(ctx, deactivateUserDevices, userId)
+	return err
+}
+
+const listAccountsDueForDeletion = `-- name: ListAccountsDueForDeletion :many
+SELECT id, email
+FROM users
+WHERE deletion_scheduled_at <= NOW() AND deleted_at IS NULL AND is_active = FALSE
+ORDER BY deletion_scheduled_at
+LIMIT $1
+`
+
+// ListAccountsDueForDeletion retrieves the accounts whose grace period is over, oldest first.
+func ListAccountsDueForDeletion(ctx context.Context, db DBTX, limit int) ([]models.AccountDueForDeletion, error) {
+	ctx, end := startQuery(ctx, db, "ListAccountsDueForDeletion")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listAccountsDueForDeletion, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.AccountDueForDeletion{}
+	for rows.Next() {
+		var i models.AccountDueForDeletion
+		if err := rows.Scan(&i.ID, &i.Email); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserDataExportFiles = `-- name: ListUserDataExportFiles :many
+SELECT file_path
+FROM data_exports
+WHERE user_id = $1 AND file_path IS NOT NULL
+`
+
+// ListUserDataExportFiles retrieves the keys of the export archives of a user in the export storage.
+func ListUserDataExportFiles(ctx context.Context, db DBTX, userId int) ([]string, error) {
+	ctx, end := startQuery(ctx, db, "ListUserDataExportFiles")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listUserDataExportFiles, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		items = append(items, path)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteUserDevices = `-- name: DeleteUserDevices :exec
+DELETE FROM devices WHERE user_id = $1
+`
+
+const deleteUserOtps = `-- name: DeleteUserOtps :exec
+DELETE FROM otps WHERE user_id = $1
+`
+
+const deleteUserVerifications = `-- name: DeleteUserVerifications :exec
+DELETE FROM verification WHERE user_id = $1
+`
+
+const deleteUserPasswordHistory = `-- name: DeleteUserPasswordHistory :exec
+DELETE FROM password_history WHERE user_id = $1
+`
+
+const deleteUserSocialLogins = `-- name: DeleteUserSocialLogins :exec
+DELETE FROM social_logins WHERE user_id = $1
+`
+
+const deleteUserSignIns = `-- name: DeleteUserSignIns :exec
+DELETE FROM sign_ins WHERE user_id = $1
+`
+
+const deleteUserDataExports = `-- name: DeleteUserDataExports :exec
+DELETE FROM data_exports WHERE user_id = $1
+`
+
+const deleteUserOutbox = `-- name: DeleteUserOutbox :exec
+DELETE FROM outbox WHERE aggregate_type = 'user' AND aggregate_id = $1
+`
+
+const deleteUserOutboxSecrets = `-- name: DeleteUserOutboxSecrets :exec
+DELETE FROM outbox_secrets WHERE user_id = $1
+`
+
+const deleteUserAuditContext = `-- name: DeleteUserAuditContext :exec
+DELETE FROM audit_event_context
+WHERE event_id IN (
+    SELECT id FROM audit_events
+    WHERE actor_id = $1 OR (actor_id IS NULL AND subject_id = $1)
+)
+`
+
+// DeleteUserPersonalData hard-deletes the rows holding a user's personal data outside the users table:
+// devices, OTPs, verification links, password history, social logins, sign-ins, data exports,
+// the outbox messages about the user with the secrets of their emails, and the IP, device ID and user agent
+// of the audit events the user performed (or that had no actor, such as failed logins to the account).
+// The audit events themselves are append-only and kept.
+// It should be called in the transaction that anonymises the user.
+func DeleteUserPersonalData(ctx context.Context, db DBTX, userId int) error {
+	ctx, end := startQuery(ctx, db, "DeleteUserPersonalData")
+	defer end()
+
+	for _, query := range []string{
+		deleteUserDevices,
+		deleteUserOtps,
+		deleteUserVerifications,
+		deleteUserPasswordHistory,
+		deleteUserSocialLogins,
+		deleteUserSignIns,
+		deleteUserDataExports,
+		deleteUserOutbox,
+		deleteUserOutboxSecrets,
+		deleteUserAuditContext,
+	} {
+		if _, err := db.ExecContext(ctx, query, userId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const anonymiseUser = `-- name: AnonymiseUser :exec
+UPDATE users
+SET email = $2,
+    username = NULL,
+    phone = NULL,
+    hidden_phone_number = NULL,
+    fullname = NULL,
+    hidden_email = NULL,
+    avatar = NULL,
+    gender = NULL,
+    password_hash = NULL,
+    locale = NULL,
+    two_factor_enabled = FALSE,
+    phone_verified = FALSE,
+    otp_new_device = FALSE,
+    email_undeliverable = FALSE,
+    email_undeliverable_at = NULL,
+    deletion_cancel_token = NULL,
+    deleted_at = NOW()
+WHERE id = $1
+`
+
+// AnonymiseUser replaces the personal data of a deleted user with a placeholder email.
+// The row itself is kept so the audit log and mail log still point to an account.
+func AnonymiseUser(ctx context.Context, db DBTX, id int, email string) error {
+	ctx, end := startQuery(ctx, db, "AnonymiseUser")
+	defer end()
+
+	_, err := db.ExecContext(ctx, anonymiseUser, id, email)
+	return err
+}
+
+const anonymiseUserMailLog = `-- name: AnonymiseUserMailLog :exec
+UPDATE mail_log
+SET recipient = $2, error = NULL
+WHERE user_id = $1
+`
+
+// AnonymiseUserMailLog replaces the recipient of the emails sent to a deleted user.
+func AnonymiseUserMailLog(ctx context.Context, db DBTX, userId int, email string) error {
+	ctx, end := startQuery(ctx, db, "AnonymiseUserMailLog")
+	defer end()
+
+	_, err := db.ExecContext(ctx, anonymiseUserMailLog, userId, email)
+	return err
+}
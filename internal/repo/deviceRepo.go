@@ -2,7 +2,6 @@ package repo
 
 import (
 	"context"
-	"database/sql"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 )
@@ -38,8 +37,11 @@ ON CONFLICT (device_id) DO UPDATE SET
 RETURNING id, user_id, device_id, device_type, logged_in_at, logged_out_at, ip, public_key, is_active, created_at, updated_at
 `
 
-func UpsetDevice(db *sql.DB, arg models.UpsetDeviceParams) (models.Device, error) {
-	row := db.QueryRowContext(context.Background(), upsetDevice,
+func UpsetDevice(ctx context.Context, db DBTX, arg models.UpsetDeviceParams) (models.Device, error) {
+	ctx, end := startQuery(ctx, db, "UpsetDevice")
+	defer end()
+
+	row := db.QueryRowContext(ctx, upsetDevice,
 		arg.UserID,
 		arg.DeviceID,
 		arg.DeviceType,
@@ -68,8 +70,11 @@ SELECT id, user_id, device_id, device_type, logged_in_at, logged_out_at, ip, pub
 WHERE device_id = $1 AND is_active = $2 LIMIT 1
 `
 
-func GetDeviceId(db *sql.DB, arg models.GetDeviceIdParams) (models.Device, error) {
-	row := db.QueryRowContext(context.Background(), getDeviceId, arg.DeviceId, arg.IsActive)
+func GetDeviceId(ctx context.Context, db DBTX, arg models.GetDeviceIdParams) (models.Device, error) {
+	ctx, end := startQuery(ctx, db, "GetDeviceId")
+	defer end()
+
+	row := db.QueryRowContext(ctx, getDeviceId, arg.DeviceId, arg.IsActive)
 	var i models.Device
 	err := row.Scan(
 		&i.ID,
@@ -93,7 +98,10 @@ SET logged_out_at = $1
 WHERE device_id = $2
 `
 
-func UpdateTimeLogout(db *sql.DB, arg models.UpdateTimeLogoutParams) error {
-	_, err := db.ExecContext(context.Background(), updateTimeLogout, arg.LoggedOutAt, arg.DeviceId)
+func UpdateTimeLogout(ctx context.Context, db DBTX, arg models.UpdateTimeLogoutParams) error {
+	ctx, end := startQuery(ctx, db, "UpdateTimeLogout")
+	defer end()
+
+	_, err := db.ExecContext(ctx, updateTimeLogout, arg.LoggedOutAt, arg.DeviceId)
 	return err
 }
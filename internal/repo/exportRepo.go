@@ -0,0 +1,447 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+	"github.com/lib/pq"
+)
+
+const createDataExport = `-- name: CreateDataExport :one
+INSERT INTO data_exports (
+    user_id,
+    status,
+    token
+) VALUES (
+    $1,
+    $2,
+    $3
+) RETURNING id
+`
+
+// CreateDataExport records a data export request.
+// It returns the ID of the created row and an error, if any.
+func CreateDataExport(ctx context.Context, db DBTX, arg models.CreateDataExportParams) (int, error) {
+	ctx, end := startQuery(ctx, db, "CreateDataExport")
+	defer end()
+
+	row := db.QueryRowContext(ctx, createDataExport, arg.UserID, arg.Status, arg.Token)
+	var id int
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getDataExport = `-- name: GetDataExport :one
+SELECT id, user_id, status, token, file_path, error, attempts, expires_at, completed_at, created_at
+FROM data_exports
+WHERE id = $1
+`
+
+// GetDataExport retrieves a data export by its ID.
+func GetDataExport(ctx context.Context, db DBTX, id int) (models.DataExport, error) {
+	ctx, end := startQuery(ctx, db, "GetDataExport")
+	defer end()
+
+	row := db.QueryRowContext(ctx, getDataExport, id)
+	return scanDataExport(row)
+}
+
+const getDataExportByToken = `-- name: GetDataExportByToken :one
+SELECT id, user_id, status, token, file_path, error, attempts, expires_at, completed_at, created_at
+FROM data_exports
+WHERE token = $1
+`
+
+// GetDataExportByToken retrieves a data export by the token of its download link.
+func GetDataExportByToken(ctx context.Context, db DBTX, token string) (models.DataExport, error) {
+	ctx, end := startQuery(ctx, db, "GetDataExportByToken")
+	defer end()
+
+	row := db.QueryRowContext(ctx, getDataExportByToken, token)
+	return scanDataExport(row)
+}
+
+func scanDataExport(row *sql.Row) (models.DataExport, error) {
+	var i models.DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.Token,
+		&i.FilePath,
+		&i.Error,
+		&i.Attempts,
+		&i.ExpiresAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const hasDataExportInProgress = `-- name: HasDataExportInProgress :one
+SELECT EXISTS (
+    SELECT 1 FROM data_exports WHERE user_id = $1 AND status = ANY($2::SMALLINT[])
+)
+`
+
+// HasDataExportInProgress reports whether the user has an export that is not built yet.
+// Statuses lists the statuses that count as in progress.
+func HasDataExportInProgress(ctx context.Context, db DBTX, userId int, statuses []int) (bool, error) {
+	ctx, end := startQuery(ctx, db, "HasDataExportInProgress")
+	defer end()
+
+	row := db.QueryRowContext(ctx, hasDataExportInProgress, userId, pq.Array(statuses))
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const startDataExport = `-- name: StartDataExport :one
+UPDATE data_exports
+SET status = $2, attempts = attempts + 1
+WHERE id = $1
+RETURNING attempts
+`
+
+// StartDataExport marks a data export as being built and counts the attempt.
+// It returns the number of attempts made so far, including this one.
+func StartDataExport(ctx context.Context, db DBTX, id int, status int) (int, error) {
+	ctx, end := startQuery(ctx, db, "StartDataExport")
+	defer end()
+
+	row := db.QueryRowContext(ctx, startDataExport, id, status)
+	var attempts int
+	err := row.Scan(&attempts)
+	return attempts, err
+}
+
+const updateDataExportStatus = `-- name: UpdateDataExportStatus :exec
+UPDATE data_exports
+SET status = $2, error = $3
+WHERE id = $1
+`
+
+// UpdateDataExportStatus sets the status of a data export and the error of its last build, if any.
+func UpdateDataExportStatus(ctx context.Context, db DBTX, arg models.UpdateDataExportStatusParams) error {
+	ctx, end := startQuery(ctx, db, "UpdateDataExportStatus")
+	defer end()
+
+	_, err := db.ExecContext(ctx, updateDataExportStatus, arg.ID, arg.Status, arg.Error)
+	return err
+}
+
+const markDataExportReady = `-- name: MarkDataExportReady :exec
+UPDATE data_exports
+SET status = $2, file_path = $3, expires_at = $4, error = NULL, completed_at = NOW()
+WHERE id = $1
+`
+
+// MarkDataExportReady records the archive of a data export and the time its download link expires.
+func MarkDataExportReady(ctx context.Context, db DBTX, arg models.MarkDataExportReadyParams) error {
+	ctx, end := startQuery(ctx, db, "MarkDataExportReady")
+	defer end()
+
+	_, err := db.ExecContext(ctx, markDataExportReady, arg.ID, arg.Status, arg.FilePath, arg.ExpiresAt)
+	return err
+}
+
+const listExpiredDataExports = `-- name: ListExpiredDataExports :many
+SELECT id, file_path
+FROM data_exports
+WHERE status = $1 AND expires_at < $2
+ORDER BY id
+LIMIT $3
+`
+
+// ListExpiredDataExports retrieves up to limit ready data exports whose download link expired before the given time.
+func ListExpiredDataExports(ctx context.Context, db DBTX, before time.Time, limit int) ([]models.ExpiredDataExport, error) {
+	ctx, end := startQuery(ctx, db, "ListExpiredDataExports")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listExpiredDataExports, constants.DataExportStatusReady, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.ExpiredDataExport{}
+	for rows.Next() {
+		var i models.ExpiredDataExport
+		if err := rows.Scan(&i.ID, &i.FilePath); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const expireDataExport = `-- name: ExpireDataExport :exec
+UPDATE data_exports
+SET status = $2, file_path = NULL
+WHERE id = $1
+`
+
+// ExpireDataExport marks a data export as expired once its archive has been removed.
+func ExpireDataExport(ctx context.Context, db DBTX, id int) error {
+	ctx, end := startQuery(ctx, db, "ExpireDataExport")
+	defer end()
+
+	_, err := db.ExecContext(ctx, expireDataExport, id, constants.DataExportStatusExpired)
+	return err
+}
+
+const listExportDevices = `-- name: ListExportDevices :many
+SELECT device_id, COALESCE(device_type, ''), COALESCE(ip, ''), is_active, logged_in_at, logged_out_at, created_at
+FROM devices
+WHERE user_id = $1
+ORDER BY id
+`
+
+// ListExportDevices retrieves the devices of a user for a data export, without their public keys.
+func ListExportDevices(ctx context.Context, db DBTX, userId int) ([]models.ExportDevice, error) {
+	ctx, end := startQuery(ctx, db, "ListExportDevices")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listExportDevices, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.ExportDevice{}
+	for rows.Next() {
+		var i models.ExportDevice
+		var loggedInAt, loggedOutAt, createdAt sql.NullTime
+		if err := rows.Scan(
+			&i.DeviceID,
+			&i.DeviceType,
+			&i.IP,
+			&i.IsActive,
+			&loggedInAt,
+			&loggedOutAt,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+		i.LoggedInAt = helpers.NullTimeToPointer(loggedInAt)
+		i.LoggedOutAt = helpers.NullTimeToPointer(loggedOutAt)
+		i.CreatedAt = helpers.NullTimeToPointer(createdAt)
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExportPasswordChanges = `-- name: ListExportPasswordChanges :many
+SELECT COALESCE(reason_status, 0), created_at
+FROM password_history
+WHERE user_id = $1
+ORDER BY id
+`
+
+// ListExportPasswordChanges retrieves when and why a user's password was changed.
+// The old password hashes are never selected.
+func ListExportPasswordChanges(ctx context.Context, db DBTX, userId int) ([]models.ExportPasswordChange, error) {
+	ctx, end := startQuery(ctx, db, "ListExportPasswordChanges")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listExportPasswordChanges, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.ExportPasswordChange{}
+	for rows.Next() {
+		var i models.ExportPasswordChange
+		var changedAt sql.NullTime
+		if err := rows.Scan(&i.Reason, &changedAt); err != nil {
+			return nil, err
+		}
+		i.ChangedAt = helpers.NullTimeToPointer(changedAt)
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExportSocialLogins = `-- name: ListExportSocialLogins :many
+SELECT provider, created_at
+FROM social_logins
+WHERE user_id = $1
+ORDER BY id
+`
+
+// ListExportSocialLogins retrieves the social providers a user signed in with.
+func ListExportSocialLogins(ctx context.Context, db DBTX, userId int) ([]models.ExportSocialLogin, error) {
+	ctx, end := startQuery(ctx, db, "ListExportSocialLogins")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listExportSocialLogins, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.ExportSocialLogin{}
+	for rows.Next() {
+		var i models.ExportSocialLogin
+		var createdAt sql.NullTime
+		if err := rows.Scan(&i.Provider, &createdAt); err != nil {
+			return nil, err
+		}
+		i.CreatedAt = helpers.NullTimeToPointer(createdAt)
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExportOtps = `-- name: ListExportOtps :many
+SELECT channel, COALESCE(is_active, FALSE), expires_at, created_at
+FROM otps
+WHERE user_id = $1
+ORDER BY id
+`
+
+// ListExportOtps retrieves the OTPs sent to a user, without the codes.
+func ListExportOtps(ctx context.Context, db DBTX, userId int) ([]models.ExportOtp, error) {
+	ctx, end := startQuery(ctx, db, "ListExportOtps")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listExportOtps, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.ExportOtp{}
+	for rows.Next() {
+		var i models.ExportOtp
+		var createdAt sql.NullTime
+		if err := rows.Scan(&i.Channel, &i.IsActive, &i.ExpiresAt, &createdAt); err != nil {
+			return nil, err
+		}
+		i.CreatedAt = helpers.NullTimeToPointer(createdAt)
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExportVerifications = `-- name: ListExportVerifications :many
+SELECT is_verified, is_active, verified_at, expires_at, created_at
+FROM verification
+WHERE user_id = $1
+ORDER BY id
+`
+
+// ListExportVerifications retrieves the verification links sent to a user, without the tokens.
+func ListExportVerifications(ctx context.Context, db DBTX, userId int) ([]models.ExportVerification, error) {
+	ctx, end := startQuery(ctx, db, "ListExportVerifications")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listExportVerifications, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.ExportVerification{}
+	for rows.Next() {
+		var i models.ExportVerification
+		var verifiedAt, createdAt sql.NullTime
+		if err := rows.Scan(&i.IsVerified, &i.IsActive, &verifiedAt, &i.ExpiresAt, &createdAt); err != nil {
+			return nil, err
+		}
+		i.VerifiedAt = helpers.NullTimeToPointer(verifiedAt)
+		i.CreatedAt = helpers.NullTimeToPointer(createdAt)
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExportSignIns = `-- name: ListExportSignIns :many
+SELECT device_id, COALESCE(device_type, ''), COALESCE(ip, ''), COALESCE(network, ''), new_device, new_network, revoked_at, created_at
+FROM sign_ins
+WHERE user_id = $1
+ORDER BY id
+`
+
+// ListExportSignIns retrieves the sign-ins of a user, without their revoke tokens.
+func ListExportSignIns(ctx context.Context, db DBTX, userId int) ([]models.ExportSignIn, error) {
+	ctx, end := startQuery(ctx, db, "ListExportSignIns")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listExportSignIns, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.ExportSignIn{}
+	for rows.Next() {
+		var i models.ExportSignIn
+		var revokedAt sql.NullTime
+		if err := rows.Scan(
+			&i.DeviceID,
+			&i.DeviceType,
+			&i.IP,
+			&i.Network,
+			&i.NewDevice,
+			&i.NewNetwork,
+			&revokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		i.RevokedAt = helpers.NullTimeToPointer(revokedAt)
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExportMail = `-- name: ListExportMail :many
+SELECT template, recipient, status, created_at
+FROM mail_log
+WHERE user_id = $1
+ORDER BY id
+`
+
+// ListExportMail retrieves the emails sent to a user.
+func ListExportMail(ctx context.Context, db DBTX, userId int) ([]models.ExportMail, error) {
+	ctx, end := startQuery(ctx, db, "ListExportMail")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listExportMail, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.ExportMail{}
+	for rows.Next() {
+		var i models.ExportMail
+		if err := rows.Scan(&i.Template, &i.Recipient, &i.Status, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
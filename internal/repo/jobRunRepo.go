@@ -0,0 +1,161 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+const startJobRun = `-- name: StartJobRun :one
+INSERT INTO job_runs (
+    job_name,
+    fence_token,
+    trigger,
+    status,
+    owner,
+    triggered_by
+)
+SELECT $1, $2, $3, $4, $5, $6
+WHERE NOT EXISTS (
+    SELECT 1 FROM job_runs WHERE job_name = $1 AND fence_token >= $2
+)
+RETURNING id
+`
+
+// StartJobRun records the start of a job run under the fencing token of its lease.
+// The fencing token must be greater than that of every earlier run of the job: a process whose lease
+// expired while it was paused gets sql.ErrNoRows instead of starting a run next to the new holder.
+func StartJobRun(ctx context.Context, db DBTX, arg models.StartJobRunParams) (int64, error) {
+	ctx, end := startQuery(ctx, db, "StartJobRun")
+	defer end()
+
+	row := db.QueryRowContext(ctx, startJobRun,
+		arg.JobName,
+		arg.FenceToken,
+		arg.Trigger,
+		arg.Status,
+		arg.Owner,
+		arg.TriggeredBy,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const abandonJobRuns = `-- name: AbandonJobRuns :execrows
+UPDATE job_runs
+SET status = $2, error = $3, finished_at = NOW()
+WHERE job_name = $1 AND finished_at IS NULL
+`
+
+// AbandonJobRuns closes the unfinished runs of a job, left by a process that stopped while running it.
+// It must only be called by the holder of the job's lease. It returns the number of runs closed.
+func AbandonJobRuns(ctx context.Context, db DBTX, arg models.AbandonJobRunsParams) (int64, error) {
+	ctx, end := startQuery(ctx, db, "AbandonJobRuns")
+	defer end()
+
+	result, err := db.ExecContext(ctx, abandonJobRuns, arg.JobName, arg.Status, arg.Error)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const finishJobRun = `-- name: FinishJobRun :execrows
+UPDATE job_runs
+SET status = $2, rows = $3, error = $4, finished_at = NOW()
+WHERE id = $1 AND finished_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM job_runs newer WHERE newer.job_name = job_runs.job_name AND newer.fence_token > $5
+)
+`
+
+// FinishJobRun records the end of a job run with its status, the number of rows it processed and its error, if any.
+// Like StartJobRun it is fenced: a run that a newer run of the job has closed, or that has a newer run,
+// gets sql.ErrNoRows, so a process whose lease expired while it was paused cannot overwrite how its run ended.
+func FinishJobRun(ctx context.Context, db DBTX, arg models.FinishJobRunParams) error {
+	ctx, end := startQuery(ctx, db, "FinishJobRun")
+	defer end()
+
+	result, err := db.ExecContext(ctx, finishJobRun, arg.ID, arg.Status, arg.Rows, arg.Error, arg.FenceToken)
+	if err != nil {
+		return err
+	}
+	finished, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if finished == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+const listJobRuns = `-- name: ListJobRuns :many
+SELECT id, job_name, fence_token, trigger, status, rows, error, owner, triggered_by, started_at, finished_at
+FROM job_runs
+WHERE job_name = $1
+AND ($2::BIGINT IS NULL OR id < $2)
+ORDER BY id DESC
+LIMIT $3
+`
+
+// ListJobRuns retrieves the runs of a job, newest first.
+// Before limits the runs to those older than the given ID, which is how the next page is requested.
+func ListJobRuns(ctx context.Context, db DBTX, arg models.ListJobRunsParams) ([]models.JobRun, error) {
+	ctx, end := startQuery(ctx, db, "ListJobRuns")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listJobRuns, arg.JobName, arg.Before, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobRuns(rows)
+}
+
+const listLastJobRuns = `-- name: ListLastJobRuns :many
+SELECT DISTINCT ON (job_name) id, job_name, fence_token, trigger, status, rows, error, owner, triggered_by, started_at, finished_at
+FROM job_runs
+ORDER BY job_name, id DESC
+`
+
+// ListLastJobRuns retrieves the latest run of every job.
+func ListLastJobRuns(ctx context.Context, db DBTX) ([]models.JobRun, error) {
+	ctx, end := startQuery(ctx, db, "ListLastJobRuns")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, listLastJobRuns)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobRuns(rows)
+}
+
+func scanJobRuns(rows *sql.Rows) ([]models.JobRun, error) {
+	defer rows.Close()
+	items := []models.JobRun{}
+	for rows.Next() {
+		var i models.JobRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.JobName,
+			&i.FenceToken,
+			&i.Trigger,
+			&i.Status,
+			&i.Rows,
+			&i.Error,
+			&i.Owner,
+			&i.TriggeredBy,
+			&i.StartedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+const createMailLog = `-- name: CreateMailLog :one
+INSERT INTO mail_log (
+    user_id,
+    template,
+    recipient,
+    status,
+    provider_message_id,
+    error
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+) RETURNING id
+`
+
+// CreateMailLog records an attempt to send an email.
+// It returns the ID of the created mail_log row and an error, if any.
+func CreateMailLog(ctx context.Context, db DBTX, arg models.CreateMailLogParams) (int, error) {
+	ctx, end := startQuery(ctx, db, "CreateMailLog")
+	defer end()
+
+	row := db.QueryRowContext(ctx, createMailLog,
+		arg.UserID,
+		arg.Template,
+		arg.Recipient,
+		arg.Status,
+		arg.ProviderMessageID,
+		arg.Error,
+	)
+	var id int
+	err := row.Scan(&id)
+	return id, err
+}
+
+const updateMailLogStatus = `-- name: UpdateMailLogStatus :one
+UPDATE mail_log
+SET status = $1, error = $2, updated_at = NOW()
+WHERE provider_message_id = $3
+RETURNING id, user_id, recipient
+`
+
+// UpdateMailLogStatus updates the status of the email with the given provider message ID.
+// It returns sql.ErrNoRows if no email was sent with that ID.
+func UpdateMailLogStatus(ctx context.Context, db DBTX, arg models.UpdateMailLogStatusParams) (models.UpdateMailLogStatusRow, error) {
+	ctx, end := startQuery(ctx, db, "UpdateMailLogStatus")
+	defer end()
+
+	row := db.QueryRowContext(ctx, updateMailLogStatus, arg.Status, arg.Error, arg.ProviderMessageID)
+	var i models.UpdateMailLogStatusRow
+	err := row.Scan(&i.ID, &i.UserID, &i.Recipient)
+	return i, err
+}
+
+const isEmailUndeliverable = `-- name: IsEmailUndeliverable :one
+SELECT EXISTS (
+    SELECT 1
+    FROM users
+    WHERE email = $1
+    AND email_undeliverable = TRUE
+) AS undeliverable
+`
+
+// IsEmailUndeliverable reports whether the email belongs to a user whose address hard-bounced or complained.
+// Emails to such addresses are suppressed.
+func IsEmailUndeliverable(ctx context.Context, db DBTX, email string) (bool, error) {
+	ctx, end := startQuery(ctx, db, "IsEmailUndeliverable")
+	defer end()
+
+	row := db.QueryRowContext(ctx, isEmailUndeliverable, email)
+	var undeliverable bool
+	err := row.Scan(&undeliverable)
+	return undeliverable, err
+}
+
+const markEmailUndeliverable = `-- name: MarkEmailUndeliverable :execrows
+UPDATE users
+SET email_undeliverable = TRUE, email_undeliverable_at = NOW()
+WHERE email = $1 AND email_undeliverable = FALSE
+`
+
+// MarkEmailUndeliverable marks the user with the given email as undeliverable.
+// It returns the number of users that were marked, 0 if the address was unknown or already marked.
+func MarkEmailUndeliverable(ctx context.Context, db DBTX, email string) (int64, error) {
+	ctx, end := startQuery(ctx, db, "MarkEmailUndeliverable")
+	defer end()
+
+	result, err := db.ExecContext(ctx, markEmailUndeliverable, email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,1392 @@
+// Package memory implements the repositories of the auth flow in memory, for unit tests that run without Postgres.
+//
+// The repositories of one Store share its tables, so joins such as the user of an OTP or the
+// verification of a user behave as they do in Postgres. Missing rows return sql.ErrNoRows and
+// duplicate unique values return a *pq.Error with the unique violation code, so the services
+// handle both the same way as with the Postgres repositories.
+//
+// Transactions are serialised and rolled back by restoring a copy of the tables taken when they began,
+// so a write made outside a transaction while one is running is rolled back with it.
+// Tests drive the repositories one request at a time, which is enough.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/lib/pq"
+)
+
+// Store holds the tables of the in-memory repositories. It is safe for concurrent use.
+type Store struct {
+	mu   sync.Mutex
+	txMu sync.Mutex
+	now  func() time.Time
+
+	tables
+}
+
+// tables are the rows of the store, copied as a whole when a transaction begins.
+type tables struct {
+	lastID          int
+	users           map[int]models.User
+	devices         map[string]models.Device
+	otps            []models.Otp
+	verifications   []models.Verification
+	passwordHistory []models.PasswordHistory
+	signIns         []signIn
+	deletions       map[int]deletion
+	auditEvents     []models.AuditEvent
+	outbox          []models.Outbox
+	outboxSecrets   []outboxSecret
+	dataExports     []models.DataExport
+	jobRuns         []models.JobRun
+}
+
+// signIn is a row of the sign_ins table.
+type signIn struct {
+	models.CreateSignInParams
+	ID        int
+	CreatedAt time.Time
+	RevokedAt sql.NullTime
+}
+
+// outboxSecret is a row of the outbox_secrets table.
+type outboxSecret struct {
+	models.CreateOutboxSecretParams
+	ID int
+}
+
+// deletion holds the deletion columns of a user whose deletion is scheduled.
+type deletion struct {
+	CancelToken string
+	ScheduledAt time.Time
+	DeletedAt   sql.NullTime
+}
+
+// New creates an empty Store.
+func New() *Store {
+	return &Store{
+		now: time.Now,
+		tables: tables{
+			users:     map[int]models.User{},
+			devices:   map[string]models.Device{},
+			deletions: map[int]deletion{},
+		},
+	}
+}
+
+// clone returns a copy of the tables that later writes do not change.
+func (t tables) clone() tables {
+	c := t
+	c.users = make(map[int]models.User, len(t.users))
+	for id, user := range t.users {
+		c.users[id] = user
+	}
+	c.devices = make(map[string]models.Device, len(t.devices))
+	for id, device := range t.devices {
+		c.devices[id] = device
+	}
+	c.deletions = make(map[int]deletion, len(t.deletions))
+	for id, d := range t.deletions {
+		c.deletions[id] = d
+	}
+	c.otps = append([]models.Otp(nil), t.otps...)
+	c.verifications = append([]models.Verification(nil), t.verifications...)
+	c.passwordHistory = append([]models.PasswordHistory(nil), t.passwordHistory...)
+	c.signIns = append([]signIn(nil), t.signIns...)
+	c.auditEvents = append([]models.AuditEvent(nil), t.auditEvents...)
+	c.outbox = append([]models.Outbox(nil), t.outbox...)
+	c.outboxSecrets = append([]outboxSecret(nil), t.outboxSecrets...)
+	c.dataExports = append([]models.DataExport(nil), t.dataExports...)
+	c.jobRuns = append([]models.JobRun(nil), t.jobRuns...)
+	return c
+}
+
+// SetClock replaces the clock used for timestamps and OTP expiry, to test expiry without waiting.
+func (s *Store) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Repositories returns the repositories backed by the store.
+func (s *Store) Repositories() repo.Repositories {
+	return repo.Repositories{
+		Users:           users{s},
+		Devices:         devices{s},
+		OTPs:            otps{s},
+		Verifications:   verifications{s},
+		PasswordHistory: passwordHistory{s},
+		SignIns:         signIns{s},
+		Deletions:       deletions{s},
+		Audit:           audit{s},
+		Outbox:          outbox{s},
+		DataExports:     dataExports{s},
+		JobRuns:         jobRuns{s},
+		Transactor:      transactor{s},
+	}
+}
+
+// transactor runs transactions on the store.
+type transactor struct{ s *Store }
+
+func (t transactor) WithTx(_ context.Context, fn func(tx repo.Repositories) error) (err error) {
+	t.s.txMu.Lock()
+	defer t.s.txMu.Unlock()
+
+	t.s.mu.Lock()
+	saved := t.s.tables.clone()
+	t.s.mu.Unlock()
+
+	defer func() {
+		p := recover()
+		if p != nil || err != nil {
+			t.s.mu.Lock()
+			t.s.tables = saved
+			t.s.mu.Unlock()
+		}
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	repos := t.s.Repositories()
+	repos.Transactor = joined{repos: repos}
+	return fn(repos)
+}
+
+// joined runs nested transactions in the transaction already running.
+type joined struct{ repos repo.Repositories }
+
+func (t joined) WithTx(_ context.Context, fn func(tx repo.Repositories) error) error {
+	return fn(t.repos)
+}
+
+// nextID returns the next row ID. IDs are shared by all tables, which is enough for tests.
+func (s *Store) nextID() int {
+	s.lastID++
+	return s.lastID
+}
+
+// uniqueViolation is the error Postgres returns for a duplicate value of a unique column.
+func uniqueViolation(constraint string) error {
+	return &pq.Error{Code: response.UniqueViolation, Constraint: constraint}
+}
+
+// sortedUsers returns the users ordered by ID, as a sequential scan returns them.
+func (s *Store) sortedUsers() []models.User {
+	result := make([]models.User, 0, len(s.users))
+	for _, user := range s.users {
+		result = append(result, user)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+type users struct{ s *Store }
+
+func (r users) GetUserDetail(_ context.Context, email string) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, user := range r.s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return models.User{}, sql.ErrNoRows
+}
+
+func (r users) CreateUser(_ context.Context, email string) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, user := range r.s.users {
+		if user.Email == email {
+			return models.User{}, uniqueViolation("users_email_key")
+		}
+	}
+
+	now := r.s.now()
+	user := models.User{
+		ID:               r.s.nextID(),
+		Email:            email,
+		TwoFactorChannel: constants.OtpChannelEmail,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	r.s.users[user.ID] = user
+	return models.User{ID: user.ID}, nil
+}
+
+func (r users) UpdatePassword(_ context.Context, arg models.UpdatePasswordParams) (models.UpdateUserResponse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[arg.ID]
+	if !ok {
+		return models.UpdateUserResponse{}, sql.ErrNoRows
+	}
+	user.PasswordHash = sql.NullString{String: arg.PasswordHash, Valid: true}
+	user.HiddenEmail = sql.NullString{String: arg.HiddenEmail, Valid: true}
+	user.IsActive = true
+	user.UpdatedAt = r.s.now()
+	r.s.users[user.ID] = user
+
+	return models.UpdateUserResponse{
+		Id:          user.ID,
+		Email:       user.Email,
+		HiddenEmail: user.HiddenEmail.String,
+		IsActive:    user.IsActive,
+		Locale:      user.Locale,
+	}, nil
+}
+
+func (r users) JoinUsersWithVerificationByEmail(_ context.Context, email string) ([]models.User, error) {
+	return r.joinVerified(func(user models.User) bool { return user.Email == email })
+}
+
+func (r users) JoinUsersWithVerificationByPhone(_ context.Context, phone string) ([]models.User, error) {
+	return r.joinVerified(func(user models.User) bool { return user.Phone.Valid && user.Phone.String == phone })
+}
+
+func (r users) JoinUsersWithVerificationByUsername(_ context.Context, username string) ([]models.User, error) {
+	return r.joinVerified(func(user models.User) bool { return user.Username.Valid && user.Username.String == username })
+}
+
+// joinVerified returns the matching users that have a verified verification row, once per such row.
+func (r users) joinVerified(match func(user models.User) bool) ([]models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	items := []models.User{}
+	for _, user := range r.s.sortedUsers() {
+		if !match(user) {
+			continue
+		}
+		for _, v := range r.s.verifications {
+			if v.UserID == user.ID && v.IsVerified {
+				items = append(items, user)
+			}
+		}
+	}
+	return items, nil
+}
+
+func (r users) UpdateOnlyPassword(_ context.Context, arg models.UpdateOnlyPasswordParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if user, ok := r.s.users[arg.ID]; ok {
+		user.PasswordHash = sql.NullString{String: arg.PasswordHash, Valid: true}
+		user.UpdatedAt = r.s.now()
+		r.s.users[user.ID] = user
+	}
+	return nil
+}
+
+func (r users) GetUserId(_ context.Context, arg models.GetUserIdParams) (models.ProfileResponse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[arg.ID]
+	if !ok || user.IsActive != arg.IsActive {
+		return models.ProfileResponse{}, sql.ErrNoRows
+	}
+	return models.ProfileResponse{
+		ID:                user.ID,
+		Username:          user.Username,
+		Email:             user.Email,
+		Phone:             user.Phone,
+		HiddenPhoneNumber: user.HiddenPhoneNumber,
+		FullName:          user.FullName,
+		HiddenEmail:       user.HiddenEmail,
+		Avatar:            user.Avatar,
+		Gender:            user.Gender,
+		TwoFactorEnabled:  user.TwoFactorEnabled,
+		TwoFactorChannel:  user.TwoFactorChannel,
+		PhoneVerified:     user.PhoneVerified,
+		Locale:            user.Locale,
+		OtpNewDevice:      user.OtpNewDevice,
+		IsActive:          user.IsActive,
+		CreatedAt:         user.CreatedAt,
+	}, nil
+}
+
+func (r users) UpdateUser(_ context.Context, arg models.UpdateUserParams) (models.UpdateUserRow, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[arg.ID]
+	if !ok {
+		return models.UpdateUserRow{}, sql.ErrNoRows
+	}
+
+	for _, other := range r.s.users {
+		if other.ID == user.ID {
+			continue
+		}
+		if arg.Username.Valid && other.Username == arg.Username {
+			return models.UpdateUserRow{}, uniqueViolation("users_username_key")
+		}
+		if arg.Phone.Valid && other.Phone == arg.Phone {
+			return models.UpdateUserRow{}, uniqueViolation("users_phone_key")
+		}
+	}
+
+	if arg.Username.Valid {
+		user.Username = arg.Username
+	}
+	if arg.Phone.Valid {
+		user.Phone = arg.Phone
+		user.PhoneVerified = false
+	}
+	if arg.Fullname.Valid {
+		user.FullName = arg.Fullname
+	}
+	if arg.Avatar.Valid {
+		user.Avatar = arg.Avatar
+	}
+	if arg.Gender.Valid {
+		user.Gender = sql.NullInt16{Int16: int16(arg.Gender.Int64), Valid: true}
+	}
+	if arg.Locale.Valid {
+		user.Locale = arg.Locale
+	}
+	user.UpdatedAt = r.s.now()
+	r.s.users[user.ID] = user
+
+	return models.UpdateUserRow{
+		ID:                int32(user.ID),
+		Username:          user.Username,
+		HiddenPhoneNumber: user.HiddenPhoneNumber,
+		Fullname:          user.FullName,
+		Avatar:            user.Avatar,
+		Gender:            sql.NullInt32{Int32: int32(user.Gender.Int16), Valid: user.Gender.Valid},
+	}, nil
+}
+
+func (r users) UpdateTwoFactorEnable(_ context.Context, arg models.UpdateTwoFactorEnableParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if user, ok := r.s.users[arg.ID]; ok {
+		user.TwoFactorEnabled = arg.TwoFactorEnabled
+		user.TwoFactorChannel = arg.TwoFactorChannel
+		user.UpdatedAt = r.s.now()
+		r.s.users[user.ID] = user
+	}
+	return nil
+}
+
+func (r users) UpdatePhoneVerified(_ context.Context, arg models.UpdatePhoneVerifiedParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if user, ok := r.s.users[arg.ID]; ok {
+		user.PhoneVerified = arg.PhoneVerified
+		user.UpdatedAt = r.s.now()
+		r.s.users[user.ID] = user
+	}
+	return nil
+}
+
+func (r users) CheckEmailExists(_ context.Context, arg models.CheckEmailExistsParams) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, user := range r.s.users {
+		if user.Email == arg.Email && user.ID != arg.ID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r users) UpdateEmail(_ context.Context, arg models.UpdateEmailParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, other := range r.s.users {
+		if other.Email == arg.Email && other.ID != arg.ID {
+			return uniqueViolation("users_email_key")
+		}
+	}
+	if user, ok := r.s.users[arg.ID]; ok {
+		user.Email = arg.Email
+		user.HiddenEmail = sql.NullString{String: arg.HiddenEmail, Valid: true}
+		user.UpdatedAt = r.s.now()
+		r.s.users[user.ID] = user
+	}
+	return nil
+}
+
+func (r users) UpdateOtpNewDevice(_ context.Context, arg models.UpdateOtpNewDeviceParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if user, ok := r.s.users[arg.ID]; ok {
+		user.OtpNewDevice = arg.OtpNewDevice
+		user.UpdatedAt = r.s.now()
+		r.s.users[user.ID] = user
+	}
+	return nil
+}
+
+type devices struct{ s *Store }
+
+func (r devices) UpsetDevice(_ context.Context, arg models.UpsetDeviceParams) (models.Device, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	device, ok := r.s.devices[arg.DeviceID]
+	if !ok {
+		device = models.Device{ID: r.s.nextID(), DeviceID: arg.DeviceID, CreatedAt: now}
+	}
+	device.UserID = arg.UserID
+	device.DeviceType = arg.DeviceType
+	device.LoggedInAt = now
+	device.LoggedOutAt = sql.NullTime{}
+	device.Ip = arg.Ip
+	device.PublicKey = sql.NullString{String: arg.PublicKey, Valid: true}
+	device.IsActive = true
+	device.UpdatedAt = now
+	r.s.devices[device.DeviceID] = device
+	return device, nil
+}
+
+func (r devices) GetDeviceId(_ context.Context, arg models.GetDeviceIdParams) (models.Device, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	device, ok := r.s.devices[arg.DeviceId]
+	if !ok || device.IsActive != arg.IsActive {
+		return models.Device{}, sql.ErrNoRows
+	}
+	return device, nil
+}
+
+func (r devices) UpdateTimeLogout(_ context.Context, arg models.UpdateTimeLogoutParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if device, ok := r.s.devices[arg.DeviceId]; ok {
+		device.LoggedOutAt = arg.LoggedOutAt
+		device.UpdatedAt = r.s.now()
+		r.s.devices[device.DeviceID] = device
+	}
+	return nil
+}
+
+func (r devices) DeactivateDevice(_ context.Context, arg models.DeviceUserParams) error {
+	return r.deactivate(func(device models.Device) bool {
+		return device.UserID == arg.UserID && device.DeviceID == arg.DeviceID
+	})
+}
+
+func (r devices) DeactivateUserDevices(_ context.Context, userID int) error {
+	return r.deactivate(func(device models.Device) bool {
+		return device.UserID == userID && device.IsActive
+	})
+}
+
+// deactivate logs out the matching devices.
+func (r devices) deactivate(match func(device models.Device) bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	for id, device := range r.s.devices {
+		if !match(device) {
+			continue
+		}
+		device.IsActive = false
+		device.LoggedOutAt = sql.NullTime{Time: now, Valid: true}
+		device.UpdatedAt = now
+		r.s.devices[id] = device
+	}
+	return nil
+}
+
+type otps struct{ s *Store }
+
+func (r otps) CreateOtp(_ context.Context, arg models.CreateOtpParams) (models.Otp, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	otp := models.Otp{
+		ID:        r.s.nextID(),
+		UserID:    arg.UserID,
+		OtpCode:   arg.OtpCode,
+		Channel:   arg.Channel,
+		Purpose:   arg.Purpose,
+		Phone:     arg.Phone,
+		CreatedAt: sql.NullTime{Time: r.s.now(), Valid: true},
+		IsActive:  true,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	r.s.otps = append(r.s.otps, otp)
+	return otp, nil
+}
+
+func (r otps) GetNewOtps(_ context.Context, arg models.GetNewOtpsParams) ([]models.GetNewOtpsRow, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	items := []models.GetNewOtpsRow{}
+	for _, otp := range r.s.otps {
+		user, ok := r.s.users[otp.UserID]
+		if !ok || otp.OtpCode != arg.OtpCode || otp.Purpose != arg.Purpose || !otp.IsActive || !otp.ExpiresAt.After(now) {
+			continue
+		}
+		if (arg.UserID.Valid && otp.UserID != int(arg.UserID.Int32)) || (arg.Channel.Valid && otp.Channel != int(arg.Channel.Int32)) {
+			continue
+		}
+		items = append(items, models.GetNewOtpsRow{
+			ID:        otp.ID,
+			UserID:    otp.UserID,
+			OtpCode:   otp.OtpCode,
+			Channel:   otp.Channel,
+			Purpose:   otp.Purpose,
+			Phone:     otp.Phone,
+			CreatedAt: otp.CreatedAt,
+			IsActive:  otp.IsActive,
+			ExpiresAt: otp.ExpiresAt,
+			Email:     user.Email,
+			Locale:    user.Locale,
+		})
+	}
+	return items, nil
+}
+
+func (r otps) UpdateOtpIsActive(_ context.Context, arg models.UpdateOtpIsActiveParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i := range r.s.otps {
+		if r.s.otps[i].ID == arg.ID {
+			r.s.otps[i].IsActive = arg.IsActive
+		}
+	}
+	return nil
+}
+
+type verifications struct{ s *Store }
+
+func (r verifications) CreateVerification(_ context.Context, data models.BodyVerificationRequest) (models.Verification, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, v := range r.s.verifications {
+		if v.VerifiedToken == data.VerifiedToken {
+			return models.Verification{}, uniqueViolation("verification_verified_token_key")
+		}
+	}
+
+	now := r.s.now()
+	v := models.Verification{
+		ID:            r.s.nextID(),
+		UserID:        data.UserId,
+		VerifiedToken: data.VerifiedToken,
+		VerifiedAt:    now,
+		ExpiresAt:     data.ExpiresAt,
+		IsActive:      true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	r.s.verifications = append(r.s.verifications, v)
+	return models.Verification{ID: v.ID}, nil
+}
+
+func (r verifications) GetVerification(_ context.Context, arg models.QueryVerificationRequest) (models.Verification, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, v := range r.s.verifications {
+		if v.VerifiedToken == arg.Token && v.UserID == arg.UserId && !v.IsVerified {
+			return v, nil
+		}
+	}
+	return models.Verification{}, sql.ErrNoRows
+}
+
+func (r verifications) UpdateVerification(_ context.Context, arg models.UpdateVerificationParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	for i := range r.s.verifications {
+		if r.s.verifications[i].UserID == arg.UserID {
+			r.s.verifications[i].IsVerified = arg.IsVerified
+			r.s.verifications[i].IsActive = arg.IsActive
+			r.s.verifications[i].UpdatedAt = now
+		}
+	}
+	return nil
+}
+
+func (r verifications) GetVerificationByUserId(_ context.Context, userID int) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	count := 0
+	for _, v := range r.s.verifications {
+		if v.UserID == userID && !v.IsVerified {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type passwordHistory struct{ s *Store }
+
+func (r passwordHistory) InsertPasswordHistory(_ context.Context, arg models.InsertPasswordHistoryParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.passwordHistory = append(r.s.passwordHistory, models.PasswordHistory{
+		ID:           r.s.nextID(),
+		UserID:       arg.UserID,
+		OldPassword:  arg.OldPassword,
+		ReasonStatus: arg.ReasonStatus,
+		CreatedAt:    sql.NullTime{Time: r.s.now(), Valid: true},
+	})
+	return nil
+}
+
+func (r passwordHistory) CheckPreviousPasswords(_ context.Context, userID int, limit int) ([]models.PasswordHistory, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var items []models.PasswordHistory
+	for i := len(r.s.passwordHistory) - 1; i >= 0 && len(items) < limit; i-- {
+		if r.s.passwordHistory[i].UserID == userID {
+			items = append(items, r.s.passwordHistory[i])
+		}
+	}
+	return items, nil
+}
+
+type signIns struct{ s *Store }
+
+func (r signIns) GetSignInHistory(_ context.Context, arg models.GetSignInHistoryParams) (models.SignInHistory, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var history models.SignInHistory
+	for _, row := range r.s.signIns {
+		if row.UserID != arg.UserID {
+			continue
+		}
+		history.HasSignIns = true
+		if row.RevokedAt.Valid {
+			continue
+		}
+		if row.DeviceID == arg.DeviceID {
+			history.KnownDevice = true
+		}
+		if row.Network.Valid && row.Network.String == arg.Network {
+			history.KnownNetwork = true
+		}
+	}
+	if device, ok := r.s.devices[arg.DeviceID]; ok &&
+		device.UserID == arg.UserID && device.IsActive && device.PublicKey.String != "" {
+		history.KnownDevice = true
+	}
+	return history, nil
+}
+
+func (r signIns) CreateSignIn(_ context.Context, arg models.CreateSignInParams) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, row := range r.s.signIns {
+		if row.RevokeToken == arg.RevokeToken {
+			return 0, uniqueViolation("sign_ins_revoke_token_key")
+		}
+	}
+
+	row := signIn{CreateSignInParams: arg, ID: r.s.nextID(), CreatedAt: r.s.now()}
+	r.s.signIns = append(r.s.signIns, row)
+	return row.ID, nil
+}
+
+func (r signIns) GetLastSignIn(_ context.Context, userID int) (models.LastSignInRow, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i := len(r.s.signIns) - 1; i >= 0; i-- {
+		row := r.s.signIns[i]
+		if row.UserID == userID && !row.RevokedAt.Valid {
+			return models.LastSignInRow{Ip: row.Ip, CreatedAt: row.CreatedAt}, nil
+		}
+	}
+	return models.LastSignInRow{}, sql.ErrNoRows
+}
+
+func (r signIns) RevokeSignInByToken(_ context.Context, token string) (models.RevokeSignInRow, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	for i, row := range r.s.signIns {
+		if row.RevokeToken == token && !row.RevokedAt.Valid && row.RevokeExpiresAt.After(now) {
+			r.s.signIns[i].RevokedAt = sql.NullTime{Time: now, Valid: true}
+			return models.RevokeSignInRow{UserID: row.UserID, DeviceID: row.DeviceID}, nil
+		}
+	}
+	return models.RevokeSignInRow{}, sql.ErrNoRows
+}
+
+func (r signIns) RevokeDeviceSignIns(_ context.Context, arg models.DeviceUserParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	for i, row := range r.s.signIns {
+		if row.UserID == arg.UserID && row.DeviceID == arg.DeviceID && !row.RevokedAt.Valid {
+			r.s.signIns[i].RevokedAt = sql.NullTime{Time: now, Valid: true}
+		}
+	}
+	return nil
+}
+
+type deletions struct{ s *Store }
+
+func (r deletions) ScheduleAccountDeletion(_ context.Context, arg models.ScheduleAccountDeletionParams) (models.ScheduleAccountDeletionRow, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[arg.ID]
+	if !ok || !user.IsActive {
+		return models.ScheduleAccountDeletionRow{}, sql.ErrNoRows
+	}
+	user.IsActive = false
+	user.UpdatedAt = r.s.now()
+	r.s.users[user.ID] = user
+	r.s.deletions[user.ID] = deletion{CancelToken: arg.CancelToken, ScheduledAt: arg.ScheduledAt}
+
+	return models.ScheduleAccountDeletionRow{
+		ID:          user.ID,
+		Email:       user.Email,
+		Locale:      user.Locale,
+		ScheduledAt: arg.ScheduledAt,
+	}, nil
+}
+
+func (r deletions) CancelAccountDeletion(_ context.Context, token string) (models.CancelDeletionResponse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, d := range r.s.deletions {
+		if d.CancelToken != token || !d.ScheduledAt.After(r.s.now()) {
+			continue
+		}
+		user := r.s.users[id]
+		user.IsActive = true
+		user.UpdatedAt = r.s.now()
+		r.s.users[id] = user
+		delete(r.s.deletions, id)
+		return models.CancelDeletionResponse{Id: user.ID, Email: user.Email}, nil
+	}
+	return models.CancelDeletionResponse{}, sql.ErrNoRows
+}
+
+func (r deletions) IsAccountPendingDeletion(_ context.Context, userID int) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.deletions[userID]
+	return ok && !d.DeletedAt.Valid, nil
+}
+
+func (r deletions) ListAccountsDueForDeletion(_ context.Context, limit int) ([]models.AccountDueForDeletion, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	type due struct {
+		account     models.AccountDueForDeletion
+		scheduledAt time.Time
+	}
+	var accounts []due
+	for id, d := range r.s.deletions {
+		user := r.s.users[id]
+		if d.DeletedAt.Valid || user.IsActive || d.ScheduledAt.After(r.s.now()) {
+			continue
+		}
+		accounts = append(accounts, due{models.AccountDueForDeletion{ID: id, Email: user.Email}, d.ScheduledAt})
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].scheduledAt.Before(accounts[j].scheduledAt) })
+
+	items := []models.AccountDueForDeletion{}
+	for _, account := range accounts {
+		if len(items) == limit {
+			break
+		}
+		items = append(items, account.account)
+	}
+	return items, nil
+}
+
+func (r deletions) DeleteUserPersonalData(_ context.Context, userID int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, device := range r.s.devices {
+		if device.UserID == userID {
+			delete(r.s.devices, id)
+		}
+	}
+	r.s.otps = removeRows(r.s.otps, func(otp models.Otp) bool { return otp.UserID == userID })
+	r.s.verifications = removeRows(r.s.verifications, func(v models.Verification) bool { return v.UserID == userID })
+	r.s.passwordHistory = removeRows(r.s.passwordHistory, func(p models.PasswordHistory) bool { return p.UserID == userID })
+	r.s.signIns = removeRows(r.s.signIns, func(s signIn) bool { return s.UserID == userID })
+	r.s.dataExports = removeRows(r.s.dataExports, func(e models.DataExport) bool { return e.UserID == userID })
+	r.s.outbox = removeRows(r.s.outbox, func(m models.Outbox) bool {
+		return m.AggregateType == constants.AggregateUser && m.AggregateID == userID
+	})
+	r.s.outboxSecrets = removeRows(r.s.outboxSecrets, func(s outboxSecret) bool { return s.UserID == userID })
+
+	//* The audit events are kept without their request context
+	for i, event := range r.s.auditEvents {
+		actor := event.ActorID.Valid && int(event.ActorID.Int32) == userID
+		subject := !event.ActorID.Valid && event.SubjectID.Valid && int(event.SubjectID.Int32) == userID
+		if actor || subject {
+			r.s.auditEvents[i].IP = sql.NullString{}
+			r.s.auditEvents[i].DeviceID = sql.NullString{}
+			r.s.auditEvents[i].UserAgent = sql.NullString{}
+		}
+	}
+	return nil
+}
+
+// AnonymiseUserMailLog does nothing: the store has no mail log.
+func (r deletions) AnonymiseUserMailLog(_ context.Context, _ int, _ string) error {
+	return nil
+}
+
+func (r deletions) AnonymiseUser(_ context.Context, id int, email string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok {
+		return nil
+	}
+	r.s.users[id] = models.User{
+		ID:        user.ID,
+		Email:     email,
+		IsActive:  user.IsActive,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: r.s.now(),
+	}
+	d := r.s.deletions[id]
+	d.CancelToken = ""
+	d.DeletedAt = sql.NullTime{Time: r.s.now(), Valid: true}
+	r.s.deletions[id] = d
+	return nil
+}
+
+// removeRows returns rows without those matching remove.
+func removeRows[T any](rows []T, remove func(row T) bool) []T {
+	var kept []T
+	for _, row := range rows {
+		if !remove(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+type audit struct{ s *Store }
+
+func (r audit) CreateAuditEvent(_ context.Context, arg models.CreateAuditEventParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	metadata := arg.Metadata
+	if len(metadata) == 0 {
+		metadata = []byte("{}")
+	}
+	r.s.auditEvents = append(r.s.auditEvents, models.AuditEvent{
+		ID:        int64(r.s.nextID()),
+		ActorID:   arg.ActorID,
+		SubjectID: arg.SubjectID,
+		EventType: arg.EventType,
+		IP:        arg.IP,
+		DeviceID:  arg.DeviceID,
+		UserAgent: arg.UserAgent,
+		Metadata:  metadata,
+		CreatedAt: r.s.now(),
+	})
+	return nil
+}
+
+func (r audit) ListAuditEvents(_ context.Context, arg models.ListAuditEventsParams) ([]models.AuditEvent, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	items := []models.AuditEvent{}
+	for i := len(r.s.auditEvents) - 1; i >= 0 && len(items) < arg.Limit; i-- {
+		event := r.s.auditEvents[i]
+		if arg.UserID.Valid && event.SubjectID != arg.UserID && event.ActorID != arg.UserID {
+			continue
+		}
+		if arg.EventType.Valid && event.EventType != arg.EventType.String {
+			continue
+		}
+		if arg.Before.Valid && event.ID >= arg.Before.Int64 {
+			continue
+		}
+		items = append(items, event)
+	}
+	return items, nil
+}
+
+type outbox struct{ s *Store }
+
+func (r outbox) CreateOutbox(_ context.Context, arg models.CreateOutboxParams) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row := models.Outbox{
+		ID:            r.s.nextID(),
+		AggregateType: arg.AggregateType,
+		AggregateID:   arg.AggregateID,
+		EventType:     arg.EventType,
+		Payload:       arg.Payload,
+		TraceContext:  arg.TraceContext,
+		Status:        constants.OutboxStatusPending,
+		CreatedAt:     r.s.now(),
+	}
+	r.s.outbox = append(r.s.outbox, row)
+	return row.ID, nil
+}
+
+func (r outbox) GetPendingOutbox(_ context.Context, limit int) ([]models.Outbox, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	items := []models.Outbox{}
+	for _, row := range r.s.outbox {
+		if len(items) == limit {
+			break
+		}
+		if row.Status == constants.OutboxStatusPending {
+			items = append(items, row)
+		}
+	}
+	return items, nil
+}
+
+func (r outbox) MarkOutboxPublished(_ context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i := range r.s.outbox {
+		if r.s.outbox[i].ID == id {
+			r.s.outbox[i].Status = constants.OutboxStatusPublished
+			r.s.outbox[i].Attempts++
+			r.s.outbox[i].LastError = sql.NullString{}
+			r.s.outbox[i].PublishedAt = sql.NullTime{Time: r.s.now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (r outbox) MarkOutboxFailed(_ context.Context, arg models.MarkOutboxFailedParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i := range r.s.outbox {
+		if r.s.outbox[i].ID == arg.ID {
+			r.s.outbox[i].Attempts++
+			r.s.outbox[i].LastError = sql.NullString{String: arg.LastError, Valid: true}
+			if r.s.outbox[i].Attempts >= arg.MaxAttempts {
+				r.s.outbox[i].Status = constants.OutboxStatusFailed
+			}
+		}
+	}
+	return nil
+}
+
+func (r outbox) CreateOutboxSecret(_ context.Context, arg models.CreateOutboxSecretParams) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row := outboxSecret{CreateOutboxSecretParams: arg, ID: r.s.nextID()}
+	r.s.outboxSecrets = append(r.s.outboxSecrets, row)
+	return row.ID, nil
+}
+
+func (r outbox) GetOutboxSecret(_ context.Context, id int) (string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, row := range r.s.outboxSecrets {
+		if row.ID == id && row.ExpiresAt.After(r.s.now()) {
+			return row.Secret, nil
+		}
+	}
+	return "", sql.ErrNoRows
+}
+
+// dataExports reads the rows of a user's export from the tables of the store.
+// The store has no social_logins or mail_log table, so those sections are always empty.
+type dataExports struct{ s *Store }
+
+func (r dataExports) CreateDataExport(_ context.Context, arg models.CreateDataExportParams) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, export := range r.s.dataExports {
+		if export.Token == arg.Token {
+			return 0, uniqueViolation("data_exports_token_key")
+		}
+	}
+	row := models.DataExport{
+		ID:        r.s.nextID(),
+		UserID:    arg.UserID,
+		Status:    arg.Status,
+		Token:     arg.Token,
+		CreatedAt: r.s.now(),
+	}
+	r.s.dataExports = append(r.s.dataExports, row)
+	return row.ID, nil
+}
+
+func (r dataExports) GetDataExport(_ context.Context, id int) (models.DataExport, error) {
+	return r.find(func(export models.DataExport) bool { return export.ID == id })
+}
+
+func (r dataExports) GetDataExportByToken(_ context.Context, token string) (models.DataExport, error) {
+	return r.find(func(export models.DataExport) bool { return export.Token == token })
+}
+
+func (r dataExports) find(match func(export models.DataExport) bool) (models.DataExport, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, export := range r.s.dataExports {
+		if match(export) {
+			return export, nil
+		}
+	}
+	return models.DataExport{}, sql.ErrNoRows
+}
+
+func (r dataExports) HasDataExportInProgress(_ context.Context, userID int, statuses []int) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, export := range r.s.dataExports {
+		if export.UserID != userID {
+			continue
+		}
+		for _, status := range statuses {
+			if export.Status == status {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (r dataExports) StartDataExport(_ context.Context, id int, status int) (int, error) {
+	attempts := 0
+	err := r.update(id, func(export *models.DataExport) {
+		export.Status = status
+		export.Attempts++
+		attempts = export.Attempts
+	})
+	return attempts, err
+}
+
+func (r dataExports) UpdateDataExportStatus(_ context.Context, arg models.UpdateDataExportStatusParams) error {
+	r.update(arg.ID, func(export *models.DataExport) {
+		export.Status = arg.Status
+		export.Error = arg.Error
+	})
+	return nil
+}
+
+func (r dataExports) MarkDataExportReady(_ context.Context, arg models.MarkDataExportReadyParams) error {
+	r.update(arg.ID, func(export *models.DataExport) {
+		export.Status = arg.Status
+		export.FilePath = sql.NullString{String: arg.FilePath, Valid: true}
+		export.ExpiresAt = sql.NullTime{Time: arg.ExpiresAt, Valid: true}
+		export.Error = sql.NullString{}
+		export.CompletedAt = sql.NullTime{Time: r.s.now(), Valid: true}
+	})
+	return nil
+}
+
+func (r dataExports) ListExpiredDataExports(_ context.Context, before time.Time, limit int) ([]models.ExpiredDataExport, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	items := []models.ExpiredDataExport{}
+	for _, export := range r.s.dataExports {
+		if len(items) == limit {
+			break
+		}
+		if export.Status == constants.DataExportStatusReady && export.ExpiresAt.Valid && export.ExpiresAt.Time.Before(before) {
+			items = append(items, models.ExpiredDataExport{ID: export.ID, FilePath: export.FilePath})
+		}
+	}
+	return items, nil
+}
+
+func (r dataExports) ExpireDataExport(_ context.Context, id int) error {
+	r.update(id, func(export *models.DataExport) {
+		export.Status = constants.DataExportStatusExpired
+		export.FilePath = sql.NullString{}
+	})
+	return nil
+}
+
+func (r dataExports) ListUserDataExportFiles(_ context.Context, userID int) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	items := []string{}
+	for _, export := range r.s.dataExports {
+		if export.UserID == userID && export.FilePath.Valid {
+			items = append(items, export.FilePath.String)
+		}
+	}
+	return items, nil
+}
+
+// update applies fn to the export with the given ID, or returns sql.ErrNoRows.
+func (r dataExports) update(id int, fn func(export *models.DataExport)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i := range r.s.dataExports {
+		if r.s.dataExports[i].ID == id {
+			fn(&r.s.dataExports[i])
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r dataExports) ListExportDevices(_ context.Context, userID int) ([]models.ExportDevice, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	devices := []models.Device{}
+	for _, device := range r.s.devices {
+		if device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+
+	items := []models.ExportDevice{}
+	for _, device := range devices {
+		loggedInAt, createdAt := device.LoggedInAt, device.CreatedAt
+		items = append(items, models.ExportDevice{
+			DeviceID:    device.DeviceID,
+			DeviceType:  device.DeviceType,
+			IP:          device.Ip.String,
+			IsActive:    device.IsActive,
+			LoggedInAt:  &loggedInAt,
+			LoggedOutAt: helpers.NullTimeToPointer(device.LoggedOutAt),
+			CreatedAt:   &createdAt,
+		})
+	}
+	return items, nil
+}
+
+func (r dataExports) ListExportPasswordChanges(_ context.Context, userID int) ([]models.ExportPasswordChange, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	items := []models.ExportPasswordChange{}
+	for _, row := range r.s.passwordHistory {
+		if row.UserID == userID {
+			items = append(items, models.ExportPasswordChange{
+				Reason:    row.ReasonStatus,
+				ChangedAt: helpers.NullTimeToPointer(row.CreatedAt),
+			})
+		}
+	}
+	return items, nil
+}
+
+func (r dataExports) ListExportSocialLogins(_ context.Context, _ int) ([]models.ExportSocialLogin, error) {
+	return []models.ExportSocialLogin{}, nil
+}
+
+func (r dataExports) ListExportOtps(_ context.Context, userID int) ([]models.ExportOtp, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	items := []models.ExportOtp{}
+	for _, otp := range r.s.otps {
+		if otp.UserID == userID {
+			items = append(items, models.ExportOtp{
+				Channel:   otp.Channel,
+				IsActive:  otp.IsActive,
+				ExpiresAt: otp.ExpiresAt,
+				CreatedAt: helpers.NullTimeToPointer(otp.CreatedAt),
+			})
+		}
+	}
+	return items, nil
+}
+
+func (r dataExports) ListExportVerifications(_ context.Context, userID int) ([]models.ExportVerification, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	items := []models.ExportVerification{}
+	for _, verification := range r.s.verifications {
+		if verification.UserID == userID {
+			verifiedAt, createdAt := verification.VerifiedAt, verification.CreatedAt
+			items = append(items, models.ExportVerification{
+				IsVerified: verification.IsVerified,
+				IsActive:   verification.IsActive,
+				VerifiedAt: &verifiedAt,
+				ExpiresAt:  verification.ExpiresAt,
+				CreatedAt:  &createdAt,
+			})
+		}
+	}
+	return items, nil
+}
+
+func (r dataExports) ListExportSignIns(_ context.Context, userID int) ([]models.ExportSignIn, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	items := []models.ExportSignIn{}
+	for _, row := range r.s.signIns {
+		if row.UserID == userID {
+			items = append(items, models.ExportSignIn{
+				DeviceID:   row.DeviceID,
+				DeviceType: row.DeviceType.String,
+				IP:         row.Ip.String,
+				Network:    row.Network.String,
+				NewDevice:  row.NewDevice,
+				NewNetwork: row.NewNetwork,
+				RevokedAt:  helpers.NullTimeToPointer(row.RevokedAt),
+				CreatedAt:  row.CreatedAt,
+			})
+		}
+	}
+	return items, nil
+}
+
+func (r dataExports) ListExportMail(_ context.Context, _ int) ([]models.ExportMail, error) {
+	return []models.ExportMail{}, nil
+}
+
+// jobRuns records job runs under their fencing tokens, as the job_runs queries do.
+type jobRuns struct{ s *Store }
+
+// hasNewerRun reports whether a run of the job has a fencing token greater than token, or equal when orEqual is set.
+func (r jobRuns) hasNewerRun(jobName string, token int64, orEqual bool) bool {
+	for _, run := range r.s.jobRuns {
+		if run.JobName == jobName && (run.FenceToken > token || (orEqual && run.FenceToken == token)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r jobRuns) StartJobRun(_ context.Context, arg models.StartJobRunParams) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.hasNewerRun(arg.JobName, arg.FenceToken, true) {
+		return 0, sql.ErrNoRows
+	}
+	run := models.JobRun{
+		ID:          int64(r.s.nextID()),
+		JobName:     arg.JobName,
+		FenceToken:  arg.FenceToken,
+		Trigger:     arg.Trigger,
+		Status:      arg.Status,
+		Owner:       arg.Owner,
+		TriggeredBy: arg.TriggeredBy,
+		StartedAt:   r.s.now(),
+	}
+	r.s.jobRuns = append(r.s.jobRuns, run)
+	return run.ID, nil
+}
+
+func (r jobRuns) AbandonJobRuns(_ context.Context, arg models.AbandonJobRunsParams) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var abandoned int64
+	for i, run := range r.s.jobRuns {
+		if run.JobName == arg.JobName && !run.FinishedAt.Valid {
+			r.s.jobRuns[i].Status = arg.Status
+			r.s.jobRuns[i].Error = sql.NullString{String: arg.Error, Valid: true}
+			r.s.jobRuns[i].FinishedAt = sql.NullTime{Time: r.s.now(), Valid: true}
+			abandoned++
+		}
+	}
+	return abandoned, nil
+}
+
+func (r jobRuns) FinishJobRun(_ context.Context, arg models.FinishJobRunParams) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i, run := range r.s.jobRuns {
+		if run.ID == arg.ID && !run.FinishedAt.Valid && !r.hasNewerRun(run.JobName, arg.FenceToken, false) {
+			r.s.jobRuns[i].Status = arg.Status
+			r.s.jobRuns[i].Rows = arg.Rows
+			r.s.jobRuns[i].Error = arg.Error
+			r.s.jobRuns[i].FinishedAt = sql.NullTime{Time: r.s.now(), Valid: true}
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r jobRuns) ListJobRuns(_ context.Context, arg models.ListJobRunsParams) ([]models.JobRun, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	runs := []models.JobRun{}
+	for i := len(r.s.jobRuns) - 1; i >= 0 && len(runs) < arg.Limit; i-- {
+		run := r.s.jobRuns[i]
+		if run.JobName == arg.JobName && (!arg.Before.Valid || run.ID < arg.Before.Int64) {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+func (r jobRuns) ListLastJobRuns(_ context.Context) ([]models.JobRun, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	last := map[string]models.JobRun{}
+	for _, run := range r.s.jobRuns {
+		last[run.JobName] = run
+	}
+	runs := make([]models.JobRun, 0, len(last))
+	for _, run := range last {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].JobName < runs[j].JobName })
+	return runs, nil
+}
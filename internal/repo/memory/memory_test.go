@@ -0,0 +1,330 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/utils"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ctx is the context of the repository calls; the in-memory repositories ignore it.
+var ctx = context.Background()
+
+// newVerifiedUser registers a user and verifies its account, as VerificationAccount does.
+func newVerifiedUser(t *testing.T, repos repo.Repositories, email string) models.User {
+	t.Helper()
+
+	user, err := repos.Users.CreateUser(ctx, email)
+	require.NoError(t, err)
+	_, err = repos.Verifications.CreateVerification(ctx, models.BodyVerificationRequest{
+		UserId:        user.ID,
+		VerifiedToken: "token-" + email,
+		ExpiresAt:     time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	_, err = repos.Users.UpdatePassword(ctx, models.UpdatePasswordParams{ID: user.ID, PasswordHash: "hash", HiddenEmail: "h***@example.com"})
+	require.NoError(t, err)
+	require.NoError(t, repos.Verifications.UpdateVerification(ctx, models.UpdateVerificationParams{UserID: user.ID, IsVerified: true}))
+
+	user, err = repos.Users.GetUserDetail(ctx, email)
+	require.NoError(t, err)
+	return user
+}
+
+func TestUsers(t *testing.T) {
+	repos := New().Repositories()
+
+	_, err := repos.Users.GetUserDetail(ctx, "nobody@example.com")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	created, err := repos.Users.CreateUser(ctx, "alice@example.com")
+	require.NoError(t, err)
+	assert.NotZero(t, created.ID)
+
+	_, err = repos.Users.CreateUser(ctx, "alice@example.com")
+	require.Error(t, err)
+	assert.Equal(t, "Unique violation", utils.HandleDBError(err))
+
+	//* Inactive until the account is verified
+	_, err = repos.Users.GetUserId(ctx, models.GetUserIdParams{ID: created.ID, IsActive: true})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	updated, err := repos.Users.UpdatePassword(ctx, models.UpdatePasswordParams{ID: created.ID, PasswordHash: "hash", HiddenEmail: "a***@example.com"})
+	require.NoError(t, err)
+	assert.True(t, updated.IsActive)
+	assert.Equal(t, "alice@example.com", updated.Email)
+
+	profile, err := repos.Users.GetUserId(ctx, models.GetUserIdParams{ID: created.ID, IsActive: true})
+	require.NoError(t, err)
+	assert.Equal(t, "a***@example.com", profile.HiddenEmail.String)
+}
+
+func TestJoinUsersWithVerification(t *testing.T) {
+	repos := New().Repositories()
+
+	unverified, err := repos.Users.CreateUser(ctx, "bob@example.com")
+	require.NoError(t, err)
+	users, err := repos.Users.JoinUsersWithVerificationByEmail(ctx, "bob@example.com")
+	require.NoError(t, err)
+	assert.Empty(t, users, "a user without a verified link must not be able to sign in")
+
+	verified := newVerifiedUser(t, repos, "carol@example.com")
+	_, err = repos.Users.UpdateUser(ctx, models.UpdateUserParams{
+		ID:       verified.ID,
+		Username: sql.NullString{String: "carol", Valid: true},
+		Phone:    sql.NullString{String: "+84901234567", Valid: true},
+	})
+	require.NoError(t, err)
+
+	for name, join := range map[string]func(context.Context, string) ([]models.User, error){
+		"carol@example.com": repos.Users.JoinUsersWithVerificationByEmail,
+		"+84901234567":      repos.Users.JoinUsersWithVerificationByPhone,
+		"carol":             repos.Users.JoinUsersWithVerificationByUsername,
+	} {
+		users, err := join(ctx, name)
+		require.NoError(t, err, name)
+		require.Len(t, users, 1, name)
+		assert.Equal(t, verified.ID, users[0].ID, name)
+	}
+
+	//* The username is unique
+	_, err = repos.Users.UpdateUser(ctx, models.UpdateUserParams{ID: unverified.ID, Username: sql.NullString{String: "carol", Valid: true}})
+	assert.Equal(t, "Unique violation", utils.HandleDBError(err))
+}
+
+func TestUpdateUserResetsPhoneVerification(t *testing.T) {
+	repos := New().Repositories()
+	user := newVerifiedUser(t, repos, "dave@example.com")
+
+	require.NoError(t, repos.Users.UpdatePhoneVerified(ctx, models.UpdatePhoneVerifiedParams{ID: user.ID, PhoneVerified: true}))
+	_, err := repos.Users.UpdateUser(ctx, models.UpdateUserParams{ID: user.ID, Phone: sql.NullString{String: "+84907654321", Valid: true}})
+	require.NoError(t, err)
+
+	profile, err := repos.Users.GetUserId(ctx, models.GetUserIdParams{ID: user.ID, IsActive: true})
+	require.NoError(t, err)
+	assert.False(t, profile.PhoneVerified)
+}
+
+func TestUpdateEmail(t *testing.T) {
+	repos := New().Repositories()
+	erin := newVerifiedUser(t, repos, "erin@example.com")
+	newVerifiedUser(t, repos, "frank@example.com")
+
+	exists, err := repos.Users.CheckEmailExists(ctx, models.CheckEmailExistsParams{ID: erin.ID, Email: "frank@example.com"})
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = repos.Users.CheckEmailExists(ctx, models.CheckEmailExistsParams{ID: erin.ID, Email: "erin@example.com"})
+	require.NoError(t, err)
+	assert.False(t, exists, "the user's own email does not count")
+
+	require.NoError(t, repos.Users.UpdateEmail(ctx, models.UpdateEmailParams{ID: erin.ID, Email: "erin@example.org", HiddenEmail: "e***@example.org"}))
+	_, err = repos.Users.GetUserDetail(ctx, "erin@example.com")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = repos.Users.GetUserDetail(ctx, "erin@example.org")
+	assert.NoError(t, err)
+}
+
+func TestDevices(t *testing.T) {
+	repos := New().Repositories()
+	user := newVerifiedUser(t, repos, "grace@example.com")
+
+	first, err := repos.Devices.UpsetDevice(ctx, models.UpsetDeviceParams{UserID: user.ID, DeviceID: "device-1", DeviceType: "ios"})
+	require.NoError(t, err)
+	require.NoError(t, repos.Devices.UpdateTimeLogout(ctx, models.UpdateTimeLogoutParams{
+		DeviceId:    "device-1",
+		LoggedOutAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}))
+
+	//* Signing in again on the same device updates the row instead of adding one
+	again, err := repos.Devices.UpsetDevice(ctx, models.UpsetDeviceParams{UserID: user.ID, DeviceID: "device-1", DeviceType: "android"})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID)
+	assert.False(t, again.LoggedOutAt.Valid)
+
+	device, err := repos.Devices.GetDeviceId(ctx, models.GetDeviceIdParams{DeviceId: "device-1", IsActive: true})
+	require.NoError(t, err)
+	assert.Equal(t, "android", device.DeviceType)
+
+	_, err = repos.Devices.GetDeviceId(ctx, models.GetDeviceIdParams{DeviceId: "device-2", IsActive: true})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestOtps(t *testing.T) {
+	store := New()
+	repos := store.Repositories()
+	user := newVerifiedUser(t, repos, "heidi@example.com")
+	other := newVerifiedUser(t, repos, "ivan@example.com")
+
+	now := time.Now()
+	store.SetClock(func() time.Time { return now })
+
+	otp, err := repos.OTPs.CreateOtp(ctx, models.CreateOtpParams{UserID: user.ID, OtpCode: "123456", Channel: 10, Purpose: "login", ExpiresAt: now.Add(time.Minute)})
+	require.NoError(t, err)
+
+	login := models.GetNewOtpsParams{OtpCode: "123456", Purpose: "login"}
+	otps, err := repos.OTPs.GetNewOtps(ctx, login)
+	require.NoError(t, err)
+	require.Len(t, otps, 1)
+	assert.Equal(t, "heidi@example.com", otps[0].Email)
+
+	otps, err = repos.OTPs.GetNewOtps(ctx, models.GetNewOtpsParams{OtpCode: "654321", Purpose: "login"})
+	require.NoError(t, err)
+	assert.Empty(t, otps)
+
+	//* Codes are only returned for their purpose, user and channel
+	otps, err = repos.OTPs.GetNewOtps(ctx, models.GetNewOtpsParams{OtpCode: "123456", Purpose: "verify_phone"})
+	require.NoError(t, err)
+	assert.Empty(t, otps)
+
+	otps, err = repos.OTPs.GetNewOtps(ctx, models.GetNewOtpsParams{OtpCode: "123456", Purpose: "login", UserID: sql.NullInt32{Int32: int32(other.ID), Valid: true}})
+	require.NoError(t, err)
+	assert.Empty(t, otps)
+
+	otps, err = repos.OTPs.GetNewOtps(ctx, models.GetNewOtpsParams{OtpCode: "123456", Purpose: "login", Channel: sql.NullInt32{Int32: 20, Valid: true}})
+	require.NoError(t, err)
+	assert.Empty(t, otps)
+
+	//* Expired codes are not returned
+	store.SetClock(func() time.Time { return now.Add(2 * time.Minute) })
+	otps, err = repos.OTPs.GetNewOtps(ctx, login)
+	require.NoError(t, err)
+	assert.Empty(t, otps)
+
+	//* Nor are codes already used, which only uses up that OTP
+	store.SetClock(func() time.Time { return now })
+	_, err = repos.OTPs.CreateOtp(ctx, models.CreateOtpParams{UserID: other.ID, OtpCode: "123456", Channel: 10, Purpose: "login", ExpiresAt: now.Add(time.Minute)})
+	require.NoError(t, err)
+	require.NoError(t, repos.OTPs.UpdateOtpIsActive(ctx, models.UpdateOtpIsActiveParams{ID: otp.ID, IsActive: false}))
+	otps, err = repos.OTPs.GetNewOtps(ctx, login)
+	require.NoError(t, err)
+	require.Len(t, otps, 1)
+	assert.Equal(t, other.ID, otps[0].UserID)
+}
+
+func TestVerifications(t *testing.T) {
+	repos := New().Repositories()
+	user, err := repos.Users.CreateUser(ctx, "ivan@example.com")
+	require.NoError(t, err)
+
+	for _, token := range []string{"first", "second"} {
+		_, err := repos.Verifications.CreateVerification(ctx, models.BodyVerificationRequest{UserId: user.ID, VerifiedToken: token, ExpiresAt: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+	}
+	_, err = repos.Verifications.CreateVerification(ctx, models.BodyVerificationRequest{UserId: user.ID, VerifiedToken: "first"})
+	assert.Equal(t, "Unique violation", utils.HandleDBError(err))
+
+	count, err := repos.Verifications.GetVerificationByUserId(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	_, err = repos.Verifications.GetVerification(ctx, models.QueryVerificationRequest{UserId: user.ID, Token: "second"})
+	require.NoError(t, err)
+	_, err = repos.Verifications.GetVerification(ctx, models.QueryVerificationRequest{UserId: user.ID + 1, Token: "second"})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	//* Verifying the account uses up every link of the user
+	require.NoError(t, repos.Verifications.UpdateVerification(ctx, models.UpdateVerificationParams{UserID: user.ID, IsVerified: true}))
+	_, err = repos.Verifications.GetVerification(ctx, models.QueryVerificationRequest{UserId: user.ID, Token: "first"})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	count, err = repos.Verifications.GetVerificationByUserId(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestPasswordHistory(t *testing.T) {
+	repos := New().Repositories()
+
+	for _, password := range []string{"one", "two", "three"} {
+		require.NoError(t, repos.PasswordHistory.InsertPasswordHistory(ctx, models.InsertPasswordHistoryParams{UserID: 1, OldPassword: password}))
+	}
+	require.NoError(t, repos.PasswordHistory.InsertPasswordHistory(ctx, models.InsertPasswordHistoryParams{UserID: 2, OldPassword: "other"}))
+
+	history, err := repos.PasswordHistory.CheckPreviousPasswords(ctx, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "three", history[0].OldPassword, "newest first")
+	assert.Equal(t, "two", history[1].OldPassword)
+
+	history, err = repos.PasswordHistory.CheckPreviousPasswords(ctx, 3, 5)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestWithTx(t *testing.T) {
+	repos := New().Repositories()
+	user := newVerifiedUser(t, repos, "judy@example.com")
+
+	//* A failed transaction leaves nothing behind
+	failed := errors.New("failed")
+	err := repos.WithTx(ctx, func(tx repo.Repositories) error {
+		_, err := tx.Deletions.ScheduleAccountDeletion(ctx, models.ScheduleAccountDeletionParams{ID: user.ID, CancelToken: "cancel", ScheduledAt: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+		_, err = tx.Outbox.CreateOutbox(ctx, models.CreateOutboxParams{AggregateType: "user", AggregateID: user.ID, EventType: "email.send"})
+		require.NoError(t, err)
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+
+	_, err = repos.Users.GetUserId(ctx, models.GetUserIdParams{ID: user.ID, IsActive: true})
+	assert.NoError(t, err)
+	pending, err := repos.Outbox.GetPendingOutbox(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	//* A committed one keeps its writes, including those of nested calls
+	err = repos.WithTx(ctx, func(tx repo.Repositories) error {
+		_, err := tx.Deletions.ScheduleAccountDeletion(ctx, models.ScheduleAccountDeletionParams{ID: user.ID, CancelToken: "cancel", ScheduledAt: time.Now().Add(time.Hour)})
+		if err != nil {
+			return err
+		}
+		return tx.WithTx(ctx, func(tx repo.Repositories) error {
+			_, err := tx.Outbox.CreateOutbox(ctx, models.CreateOutboxParams{AggregateType: "user", AggregateID: user.ID, EventType: "email.send"})
+			return err
+		})
+	})
+	require.NoError(t, err)
+
+	pendingDeletion, err := repos.Deletions.IsAccountPendingDeletion(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, pendingDeletion)
+	pending, err = repos.Outbox.GetPendingOutbox(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+}
+
+func TestAccountDeletion(t *testing.T) {
+	store := New()
+	repos := store.Repositories()
+	user := newVerifiedUser(t, repos, "kim@example.com")
+
+	now := time.Now()
+	store.SetClock(func() time.Time { return now })
+
+	_, err := repos.Deletions.ScheduleAccountDeletion(ctx, models.ScheduleAccountDeletionParams{ID: user.ID, CancelToken: "cancel", ScheduledAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = repos.Users.GetUserId(ctx, models.GetUserIdParams{ID: user.ID, IsActive: true})
+	assert.ErrorIs(t, err, sql.ErrNoRows, "the account is inactive until the deletion is cancelled")
+
+	_, err = repos.Deletions.CancelAccountDeletion(ctx, "unknown")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	//* The link expires when the account is deleted
+	store.SetClock(func() time.Time { return now.Add(2 * time.Hour) })
+	_, err = repos.Deletions.CancelAccountDeletion(ctx, "cancel")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	store.SetClock(func() time.Time { return now })
+	cancelled, err := repos.Deletions.CancelAccountDeletion(ctx, "cancel")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, cancelled.Id)
+	_, err = repos.Users.GetUserId(ctx, models.GetUserIdParams{ID: user.ID, IsActive: true})
+	assert.NoError(t, err)
+}
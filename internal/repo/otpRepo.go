@@ -2,7 +2,6 @@ package repo
 
 import (
 	"context"
-	"database/sql"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 )
@@ -11,24 +10,36 @@ const createOtp = `-- name: CreateOtp :one
 INSERT INTO otps (
     user_id,
     otp_code,
+    channel,
+    purpose,
+    phone,
     expires_at
 ) VALUES (
     $1,
     $2,
-    $3
-) RETURNING id, user_id, otp_code, created_at, expires_at
+    $3,
+    $4,
+    $5,
+    $6
+) RETURNING id, user_id, otp_code, channel, purpose, phone, created_at, expires_at
 `
 
 // CreateOtp creates a new OTP (One-Time Password) record in the database.
 // It takes a database connection `db` and the OTP parameters `arg` as input.
 // It returns the created OTP record and an error (if any).
-func CreateOtp(db *sql.DB, arg models.CreateOtpParams) (models.Otp, error) {
-	row := db.QueryRowContext(context.Background(), createOtp, arg.UserID, arg.OtpCode, arg.ExpiresAt)
+func CreateOtp(ctx context.Context, db DBTX, arg models.CreateOtpParams) (models.Otp, error) {
+	ctx, end := startQuery(ctx, db, "CreateOtp")
+	defer end()
+
+	row := db.QueryRowContext(ctx, createOtp, arg.UserID, arg.OtpCode, arg.Channel, arg.Purpose, arg.Phone, arg.ExpiresAt)
 	var i models.Otp
 	err := row.Scan(
 		&i.ID,
 		&i.UserID,
 		&i.OtpCode,
+		&i.Channel,
+		&i.Purpose,
+		&i.Phone,
 		&i.CreatedAt,
 		&i.ExpiresAt,
 	)
@@ -36,19 +47,26 @@ func CreateOtp(db *sql.DB, arg models.CreateOtpParams) (models.Otp, error) {
 }
 
 const getNewOtps = `-- name: GetNewOtps :many
-SELECT otps.id, otps.user_id, otps.otp_code, otps.created_at, otps.is_active, otps.expires_at, users.email
+SELECT otps.id, otps.user_id, otps.otp_code, otps.channel, otps.purpose, otps.phone, otps.created_at, otps.is_active, otps.expires_at, users.email, users.locale
 FROM otps
 JOIN users ON otps.user_id = users.id
 WHERE otps.expires_at > NOW()
     AND otps.otp_code = $1
     AND otps.is_active = TRUE
+    AND otps.purpose = $2
+    AND ($3::INT IS NULL OR otps.user_id = $3)
+    AND ($4::SMALLINT IS NULL OR otps.channel = $4)
 `
 
 // GetNewOtps retrieves a list of new OTPs from the database based on the provided OTP code.
-// It takes a database connection (`db`) and an OTP code (`otpCode`) as parameters.
+// It takes a database connection (`db`) and the OTP code, the purpose it was sent for and,
+// optionally, the user and channel it must belong to (`arg`) as parameters.
 // It returns a slice of `models.GetNewOtpsRow` and an error, if any.
-func GetNewOtps(db *sql.DB, otpCode string) ([]models.GetNewOtpsRow, error) {
-	rows, err := db.QueryContext(context.Background(), getNewOtps, otpCode)
+func GetNewOtps(ctx context.Context, db DBTX, arg models.GetNewOtpsParams) ([]models.GetNewOtpsRow, error) {
+	ctx, end := startQuery(ctx, db, "GetNewOtps")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, getNewOtps, arg.OtpCode, arg.Purpose, arg.UserID, arg.Channel)
 	if err != nil {
 		return nil, err
 	}
@@ -60,10 +78,14 @@ func GetNewOtps(db *sql.DB, otpCode string) ([]models.GetNewOtpsRow, error) {
 			&i.ID,
 			&i.UserID,
 			&i.OtpCode,
+			&i.Channel,
+			&i.Purpose,
+			&i.Phone,
 			&i.CreatedAt,
 			&i.IsActive,
 			&i.ExpiresAt,
 			&i.Email,
+			&i.Locale,
 		); err != nil {
 			return nil, err
 		}
@@ -81,14 +103,17 @@ func GetNewOtps(db *sql.DB, otpCode string) ([]models.GetNewOtpsRow, error) {
 const updateOtpIsActive = `-- name: UpdateOtpIsActive :exec
 UPDATE otps
 SET is_active = $1
-WHERE otp_code = $2
+WHERE id = $2
 `
 
-// UpdateOtpIsActive updates the isActive status of an OTP code in the database.
+// UpdateOtpIsActive updates the isActive status of an OTP in the database.
 // It takes a database connection `db` and an `arg` parameter of type `models.UpdateOtpIsActiveParams`.
-// It executes a SQL query to update the isActive status of the OTP code in the database.
+// It executes a SQL query to update the isActive status of the OTP with the given ID in the database.
 // Returns an error if the database query fails.
-func UpdateOtpIsActive(db *sql.DB, arg models.UpdateOtpIsActiveParams) error {
-	_, err := db.ExecContext(context.Background(), updateOtpIsActive, arg.IsActive, arg.OtpCode)
+func UpdateOtpIsActive(ctx context.Context, db DBTX, arg models.UpdateOtpIsActiveParams) error {
+	ctx, end := startQuery(ctx, db, "UpdateOtpIsActive")
+	defer end()
+
+	_, err := db.ExecContext(ctx, updateOtpIsActive, arg.IsActive, arg.ID)
 	return err
 }
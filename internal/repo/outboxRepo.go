@@ -0,0 +1,189 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+const createOutbox = `-- name: CreateOutbox :one
+INSERT INTO outbox (
+    aggregate_type,
+    aggregate_id,
+    event_type,
+    payload,
+    trace_context
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+) RETURNING id
+`
+
+// CreateOutbox stores a message in the outbox table.
+// It must be called with the same transaction as the state change the message belongs to,
+// so the message is only published if the change is committed.
+// It returns the ID of the created outbox row and an error, if any.
+func CreateOutbox(ctx context.Context, db DBTX, arg models.CreateOutboxParams) (int, error) {
+	ctx, end := startQuery(ctx, db, "CreateOutbox")
+	defer end()
+
+	traceContext, err := json.Marshal(arg.TraceContext)
+	if err != nil {
+		return 0, err
+	}
+	if arg.TraceContext == nil {
+		traceContext = []byte("{}")
+	}
+
+	row := db.QueryRowContext(ctx, createOutbox, arg.AggregateType, arg.AggregateID, arg.EventType, []byte(arg.Payload), traceContext)
+	var id int
+	err = row.Scan(&id)
+	return id, err
+}
+
+const getPendingOutbox = `-- name: GetPendingOutbox :many
+SELECT id, aggregate_type, aggregate_id, event_type, payload, status, attempts, last_error, created_at, published_at, trace_context
+FROM outbox
+WHERE status = $1
+ORDER BY id
+LIMIT $2
+FOR UPDATE SKIP LOCKED
+`
+
+// GetPendingOutbox retrieves the oldest outbox rows that have not been published yet.
+// The rows are locked until the transaction ends and rows locked by another relay are skipped,
+// so it has to be called inside a transaction.
+func GetPendingOutbox(ctx context.Context, db DBTX, limit int) ([]models.Outbox, error) {
+	ctx, end := startQuery(ctx, db, "GetPendingOutbox")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, getPendingOutbox, constants.OutboxStatusPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.Outbox{}
+	for rows.Next() {
+		var i models.Outbox
+		var payload, traceContext []byte
+		if err := rows.Scan(
+			&i.ID,
+			&i.AggregateType,
+			&i.AggregateID,
+			&i.EventType,
+			&payload,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.PublishedAt,
+			&traceContext,
+		); err != nil {
+			return nil, err
+		}
+		i.Payload = payload
+		if err := json.Unmarshal(traceContext, &i.TraceContext); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markOutboxPublished = `-- name: MarkOutboxPublished :exec
+UPDATE outbox
+SET status = $1, attempts = attempts + 1, last_error = NULL, published_at = NOW(), payload = payload - 'body'
+WHERE id = $2
+`
+
+// MarkOutboxPublished marks an outbox row as published.
+// The body of an email written before the secrets were kept out of the outbox is removed from its payload.
+func MarkOutboxPublished(ctx context.Context, db DBTX, id int) error {
+	ctx, end := startQuery(ctx, db, "MarkOutboxPublished")
+	defer end()
+
+	_, err := db.ExecContext(ctx, markOutboxPublished, constants.OutboxStatusPublished, id)
+	return err
+}
+
+const markOutboxFailed = `-- name: MarkOutboxFailed :exec
+UPDATE outbox
+SET attempts = attempts + 1,
+    last_error = $1,
+    status = CASE WHEN attempts + 1 >= $2 THEN $3 ELSE status END
+WHERE id = $4
+`
+
+// MarkOutboxFailed records a failed publish attempt.
+// Once the number of attempts reaches MaxAttempts the row is marked as failed and no longer retried.
+func MarkOutboxFailed(ctx context.Context, db DBTX, arg models.MarkOutboxFailedParams) error {
+	ctx, end := startQuery(ctx, db, "MarkOutboxFailed")
+	defer end()
+
+	_, err := db.ExecContext(ctx, markOutboxFailed, arg.LastError, arg.MaxAttempts, constants.OutboxStatusFailed, arg.ID)
+	return err
+}
+
+const createOutboxSecret = `-- name: CreateOutboxSecret :one
+INSERT INTO outbox_secrets (
+    user_id,
+    secret,
+    expires_at
+) VALUES (
+    $1,
+    $2,
+    $3
+) RETURNING id
+`
+
+// CreateOutboxSecret stores the secret of an email, which the outbox payload references by its ID.
+// It must be called with the transaction of the outbox row.
+// It returns the ID of the secret and an error, if any.
+func CreateOutboxSecret(ctx context.Context, db DBTX, arg models.CreateOutboxSecretParams) (int, error) {
+	ctx, end := startQuery(ctx, db, "CreateOutboxSecret")
+	defer end()
+
+	row := db.QueryRowContext(ctx, createOutboxSecret, arg.UserID, arg.Secret, arg.ExpiresAt)
+	var id int
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getOutboxSecret = `-- name: GetOutboxSecret :one
+SELECT secret FROM outbox_secrets
+WHERE id = $1 AND expires_at > NOW()
+`
+
+// GetOutboxSecret retrieves the secret of an email.
+// It returns sql.ErrNoRows when the secret expired or was deleted after the email was sent.
+func GetOutboxSecret(ctx context.Context, db DBTX, id int) (string, error) {
+	ctx, end := startQuery(ctx, db, "GetOutboxSecret")
+	defer end()
+
+	row := db.QueryRowContext(ctx, getOutboxSecret, id)
+	var secret string
+	err := row.Scan(&secret)
+	return secret, err
+}
+
+const deleteOutboxSecret = `-- name: DeleteOutboxSecret :exec
+DELETE FROM outbox_secrets
+WHERE id = $1
+`
+
+// DeleteOutboxSecret deletes the secret of an email once it was sent.
+func DeleteOutboxSecret(ctx context.Context, db DBTX, id int) error {
+	ctx, end := startQuery(ctx, db, "DeleteOutboxSecret")
+	defer end()
+
+	_, err := db.ExecContext(ctx, deleteOutboxSecret, id)
+	return err
+}
@@ -2,7 +2,6 @@ package repo
 
 import (
 	"context"
-	"database/sql"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 )
@@ -16,8 +15,11 @@ VALUES ($1, $2, $3)
 // It takes a database connection `db` and an `arg` parameter of type `models.InsertPasswordHistoryParams`.
 // The `arg` parameter contains the necessary information for inserting the password history record.
 // It returns an error if the insertion fails, otherwise it returns nil.
-func InsertPasswordHistory(db *sql.DB, arg models.InsertPasswordHistoryParams) error {
-	_, err := db.ExecContext(context.Background(), insertPasswordHistory, arg.UserID, arg.OldPassword, arg.ReasonStatus)
+func InsertPasswordHistory(ctx context.Context, db DBTX, arg models.InsertPasswordHistoryParams) error {
+	ctx, end := startQuery(ctx, db, "InsertPasswordHistory")
+	defer end()
+
+	_, err := db.ExecContext(ctx, insertPasswordHistory, arg.UserID, arg.OldPassword, arg.ReasonStatus)
 	return err
 }
 
@@ -25,11 +27,15 @@ const checkPreviousPasswords = `-- name: CheckPreviousPasswords :one
 SELECT id, user_id, old_password, reason_status, created_at
 FROM password_history
 WHERE user_id = $1
+ORDER BY id DESC
 LIMIT $2
 `
 
-func CheckPreviousPasswords(db *sql.DB, userID int, limit int) ([]models.PasswordHistory, error) {
-	rows, err := db.QueryContext(context.Background(), checkPreviousPasswords, userID, limit)
+func CheckPreviousPasswords(ctx context.Context, db DBTX, userID int, limit int) ([]models.PasswordHistory, error) {
+	ctx, end := startQuery(ctx, db, "CheckPreviousPasswords")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, checkPreviousPasswords, userID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireLeaseScript takes a lease if it is free and returns its fencing token, or 0 if it is held.
+// Tokens come from a counter that only grows, so a newer holder always has a greater token than an older one.
+var acquireLeaseScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+local token = redis.call("INCR", KEYS[2])
+redis.call("SET", KEYS[1], token, "PX", ARGV[1])
+return token
+`)
+
+// renewLeaseScript extends a lease only if it is still held with the caller's token.
+var renewLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeaseScript deletes a lease only if it is still held with the caller's token,
+// so a lease that expired and was taken by another process is never released by mistake.
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// AcquireLease takes a lease that expires after ttl unless it is renewed or released first.
+// It returns the fencing token of the lease, drawn from the counter at fenceKey, or 0 if the lease is held.
+func AcquireLease(ctx context.Context, rdb redis.UniversalClient, key string, fenceKey string, ttl time.Duration) (int64, error) {
+	return acquireLeaseScript.Run(ctx, rdb, []string{key, fenceKey}, ttl.Milliseconds()).Int64()
+}
+
+// RenewLease extends a lease held with the given fencing token.
+// It returns false if the lease expired or was taken by someone else.
+func RenewLease(ctx context.Context, rdb redis.UniversalClient, key string, token int64, ttl time.Duration) (bool, error) {
+	renewed, err := renewLeaseScript.Run(ctx, rdb, []string{key}, token, ttl.Milliseconds()).Int64()
+	return renewed == 1, err
+}
+
+// ReleaseLease releases a lease, if it is still held with the given fencing token.
+func ReleaseLease(ctx context.Context, rdb redis.UniversalClient, key string, token int64) error {
+	return releaseLeaseScript.Run(ctx, rdb, []string{key}, token).Err()
+}
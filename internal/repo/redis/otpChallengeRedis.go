@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrOtpChallengeNotFound is returned for a login OTP challenge that is unknown, expired or deleted.
+var ErrOtpChallengeNotFound = errors.New("otp challenge not found")
+
+// attemptOtpChallengeScript counts an attempt at a challenge and returns its user ID and attempts,
+// or nil if the challenge does not exist, so an expired challenge is never recreated by the count.
+var attemptOtpChallengeScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return false
+end
+local attempts = redis.call("HINCRBY", KEYS[1], "attempts", 1)
+return {tonumber(redis.call("HGET", KEYS[1], "user_id")), attempts}
+`)
+
+// CreateOtpChallenge stores the login OTP challenge of a user at key; it expires after ttl, with the OTP.
+func CreateOtpChallenge(ctx context.Context, rdb redis.UniversalClient, key string, userID int, ttl time.Duration) error {
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user_id", userID, "attempts", 0)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+// AttemptOtpChallenge counts an attempt at the login OTP challenge at key.
+// It returns the user of the challenge and the number of attempts, this one included,
+// or ErrOtpChallengeNotFound.
+func AttemptOtpChallenge(ctx context.Context, rdb redis.UniversalClient, key string) (int, int64, error) {
+	result, err := attemptOtpChallengeScript.Run(ctx, rdb, []string{key}).Int64Slice()
+	if err == redis.Nil {
+		return 0, 0, ErrOtpChallengeNotFound
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(result[0]), result[1], nil
+}
@@ -2,17 +2,15 @@ package redis
 
 import (
 	"context"
-	"log"
 	"time"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
-	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 )
 
 // SpamUser checks if a user is spamming based on the request threshold and Cuckoo filter.
-func SpamUser(ctx *gin.Context, rdb *redis.Client, key string, requestThreshold int64) *models.SpamUserResponse {
+func SpamUser(ctx context.Context, rdb redis.UniversalClient, key string, requestThreshold int64) *models.SpamUserResponse {
 	numberRequest, err := rdb.Incr(ctx, key).Result()
 	if err != nil {
 		return nil
@@ -48,7 +46,7 @@ func SpamUser(ctx *gin.Context, rdb *redis.Client, key string, requestThreshold
 }
 
 // AddUserToCuckooFilter adds a user to the Cuckoo filter in Redis and sets an expiration time.
-func AddUserToCuckooFilter(ctx context.Context, rdb *redis.Client, key string, expiration time.Duration) error {
+func AddUserToCuckooFilter(ctx context.Context, rdb redis.UniversalClient, key string, expiration time.Duration) error {
 	cuckooKey := "cuckoo:" + key
 	_, err := rdb.Do(ctx, "CF.ADD", cuckooKey, key).Result()
 	if err != nil {
@@ -61,13 +59,12 @@ func AddUserToCuckooFilter(ctx context.Context, rdb *redis.Client, key string, e
 }
 
 // DeleteKeyUser deletes the entire key from Redis.
-func DeleteKeyUser(ctx context.Context, rdb *redis.Client, key string) error {
-	log.Println("Deleting key from Redis: ", key)
+func DeleteKeyUser(ctx context.Context, rdb redis.UniversalClient, key string) error {
 	_, err := rdb.Del(ctx, key).Result()
 	return err
 }
 
-func GetUserToCuckooFilter(ctx context.Context, rdb *redis.Client, key string) (bool, error) {
+func GetUserToCuckooFilter(ctx context.Context, rdb redis.UniversalClient, key string) (bool, error) {
 	cuckooKey := "cuckoo:" + key
 	exists, err := rdb.Do(ctx, "CF.EXISTS", cuckooKey, key).Result()
 	if err != nil {
@@ -75,6 +72,14 @@ func GetUserToCuckooFilter(ctx context.Context, rdb *redis.Client, key string) (
 
 	}
 
-	log.Println("Exists: ", exists)
 	return exists != 0, nil
 }
+
+// GetCounter returns the value of a counter, 0 if it does not exist.
+func GetCounter(ctx context.Context, rdb redis.UniversalClient, key string) (int64, error) {
+	count, err := rdb.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return count, err
+}
@@ -0,0 +1,576 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+// UserRepository reads and updates the users table.
+// Lookups of a missing user return sql.ErrNoRows, and creating a user with an email
+// already in use returns a unique violation, as Postgres does.
+type UserRepository interface {
+	GetUserDetail(ctx context.Context, email string) (models.User, error)
+	CreateUser(ctx context.Context, email string) (models.User, error)
+	UpdatePassword(ctx context.Context, arg models.UpdatePasswordParams) (models.UpdateUserResponse, error)
+	JoinUsersWithVerificationByEmail(ctx context.Context, email string) ([]models.User, error)
+	JoinUsersWithVerificationByPhone(ctx context.Context, phone string) ([]models.User, error)
+	JoinUsersWithVerificationByUsername(ctx context.Context, username string) ([]models.User, error)
+	UpdateOnlyPassword(ctx context.Context, arg models.UpdateOnlyPasswordParams) error
+	GetUserId(ctx context.Context, arg models.GetUserIdParams) (models.ProfileResponse, error)
+	UpdateUser(ctx context.Context, arg models.UpdateUserParams) (models.UpdateUserRow, error)
+	UpdateTwoFactorEnable(ctx context.Context, arg models.UpdateTwoFactorEnableParams) error
+	UpdatePhoneVerified(ctx context.Context, arg models.UpdatePhoneVerifiedParams) error
+	CheckEmailExists(ctx context.Context, arg models.CheckEmailExistsParams) (bool, error)
+	UpdateEmail(ctx context.Context, arg models.UpdateEmailParams) error
+	UpdateOtpNewDevice(ctx context.Context, arg models.UpdateOtpNewDeviceParams) error
+}
+
+// DeviceRepository reads and updates the devices a user signs in from.
+type DeviceRepository interface {
+	UpsetDevice(ctx context.Context, arg models.UpsetDeviceParams) (models.Device, error)
+	GetDeviceId(ctx context.Context, arg models.GetDeviceIdParams) (models.Device, error)
+	UpdateTimeLogout(ctx context.Context, arg models.UpdateTimeLogoutParams) error
+	DeactivateDevice(ctx context.Context, arg models.DeviceUserParams) error
+	DeactivateUserDevices(ctx context.Context, userID int) error
+}
+
+// OTPRepository creates and consumes one-time passwords.
+type OTPRepository interface {
+	CreateOtp(ctx context.Context, arg models.CreateOtpParams) (models.Otp, error)
+	GetNewOtps(ctx context.Context, arg models.GetNewOtpsParams) ([]models.GetNewOtpsRow, error)
+	UpdateOtpIsActive(ctx context.Context, arg models.UpdateOtpIsActiveParams) error
+}
+
+// VerificationRepository creates and checks the account verification links.
+type VerificationRepository interface {
+	CreateVerification(ctx context.Context, data models.BodyVerificationRequest) (models.Verification, error)
+	GetVerification(ctx context.Context, arg models.QueryVerificationRequest) (models.Verification, error)
+	UpdateVerification(ctx context.Context, arg models.UpdateVerificationParams) error
+	GetVerificationByUserId(ctx context.Context, userID int) (int, error)
+}
+
+// PasswordHistoryRepository records the previous passwords of a user, so they cannot be reused.
+type PasswordHistoryRepository interface {
+	InsertPasswordHistory(ctx context.Context, arg models.InsertPasswordHistoryParams) error
+	CheckPreviousPasswords(ctx context.Context, userID int, limit int) ([]models.PasswordHistory, error)
+}
+
+// SignInRepository records sign-ins and revokes them with the token of the "new sign-in" email.
+type SignInRepository interface {
+	GetSignInHistory(ctx context.Context, arg models.GetSignInHistoryParams) (models.SignInHistory, error)
+	CreateSignIn(ctx context.Context, arg models.CreateSignInParams) (int, error)
+	GetLastSignIn(ctx context.Context, userID int) (models.LastSignInRow, error)
+	RevokeSignInByToken(ctx context.Context, token string) (models.RevokeSignInRow, error)
+	RevokeDeviceSignIns(ctx context.Context, arg models.DeviceUserParams) error
+}
+
+// DeletionRepository schedules and cancels the deletion of an account.
+type DeletionRepository interface {
+	ScheduleAccountDeletion(ctx context.Context, arg models.ScheduleAccountDeletionParams) (models.ScheduleAccountDeletionRow, error)
+	CancelAccountDeletion(ctx context.Context, token string) (models.CancelDeletionResponse, error)
+	IsAccountPendingDeletion(ctx context.Context, userID int) (bool, error)
+	ListAccountsDueForDeletion(ctx context.Context, limit int) ([]models.AccountDueForDeletion, error)
+	DeleteUserPersonalData(ctx context.Context, userID int) error
+	AnonymiseUserMailLog(ctx context.Context, userID int, email string) error
+	AnonymiseUser(ctx context.Context, id int, email string) error
+}
+
+// AuditRepository appends to and reads the audit log.
+type AuditRepository interface {
+	CreateAuditEvent(ctx context.Context, arg models.CreateAuditEventParams) error
+	ListAuditEvents(ctx context.Context, arg models.ListAuditEventsParams) ([]models.AuditEvent, error)
+}
+
+// OutboxRepository stores the messages published by the outbox relay,
+// and the secrets of the emails among them, which the messages only reference.
+type OutboxRepository interface {
+	CreateOutbox(ctx context.Context, arg models.CreateOutboxParams) (int, error)
+	GetPendingOutbox(ctx context.Context, limit int) ([]models.Outbox, error)
+	MarkOutboxPublished(ctx context.Context, id int) error
+	MarkOutboxFailed(ctx context.Context, arg models.MarkOutboxFailedParams) error
+	CreateOutboxSecret(ctx context.Context, arg models.CreateOutboxSecretParams) (int, error)
+	GetOutboxSecret(ctx context.Context, id int) (string, error)
+}
+
+// DataExportRepository records the data exports of users and reads the rows a user gets in theirs.
+// The file path of an export is the key of its archive in the export storage.
+type DataExportRepository interface {
+	CreateDataExport(ctx context.Context, arg models.CreateDataExportParams) (int, error)
+	GetDataExport(ctx context.Context, id int) (models.DataExport, error)
+	GetDataExportByToken(ctx context.Context, token string) (models.DataExport, error)
+	HasDataExportInProgress(ctx context.Context, userID int, statuses []int) (bool, error)
+	StartDataExport(ctx context.Context, id int, status int) (int, error)
+	UpdateDataExportStatus(ctx context.Context, arg models.UpdateDataExportStatusParams) error
+	MarkDataExportReady(ctx context.Context, arg models.MarkDataExportReadyParams) error
+	ListExpiredDataExports(ctx context.Context, before time.Time, limit int) ([]models.ExpiredDataExport, error)
+	ExpireDataExport(ctx context.Context, id int) error
+	ListUserDataExportFiles(ctx context.Context, userID int) ([]string, error)
+
+	ListExportDevices(ctx context.Context, userID int) ([]models.ExportDevice, error)
+	ListExportPasswordChanges(ctx context.Context, userID int) ([]models.ExportPasswordChange, error)
+	ListExportSocialLogins(ctx context.Context, userID int) ([]models.ExportSocialLogin, error)
+	ListExportOtps(ctx context.Context, userID int) ([]models.ExportOtp, error)
+	ListExportVerifications(ctx context.Context, userID int) ([]models.ExportVerification, error)
+	ListExportSignIns(ctx context.Context, userID int) ([]models.ExportSignIn, error)
+	ListExportMail(ctx context.Context, userID int) ([]models.ExportMail, error)
+}
+
+// JobRunRepository records the runs of the background jobs under the fencing tokens of their leases.
+// StartJobRun and FinishJobRun return sql.ErrNoRows for a run whose token is older than that of a later run of its job.
+type JobRunRepository interface {
+	StartJobRun(ctx context.Context, arg models.StartJobRunParams) (int64, error)
+	AbandonJobRuns(ctx context.Context, arg models.AbandonJobRunsParams) (int64, error)
+	FinishJobRun(ctx context.Context, arg models.FinishJobRunParams) error
+	ListJobRuns(ctx context.Context, arg models.ListJobRunsParams) ([]models.JobRun, error)
+	ListLastJobRuns(ctx context.Context) ([]models.JobRun, error)
+}
+
+// Transactor runs a function in a transaction, with repositories bound to that transaction.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(tx Repositories) error) error
+}
+
+// Repositories groups the repositories of the auth flow.
+// NewRepositories backs them with Postgres; the memory package provides an in-memory set for tests.
+type Repositories struct {
+	Users           UserRepository
+	Devices         DeviceRepository
+	OTPs            OTPRepository
+	Verifications   VerificationRepository
+	PasswordHistory PasswordHistoryRepository
+	SignIns         SignInRepository
+	Deletions       DeletionRepository
+	Audit           AuditRepository
+	Outbox          OutboxRepository
+	DataExports     DataExportRepository
+	JobRuns         JobRunRepository
+	Transactor      Transactor
+
+	// DB is the database or transaction the Postgres repositories query, bounded by their query timeout,
+	// for the queries that have no repository. It is nil in the in-memory set.
+	DB DBTX
+}
+
+// WithTx runs fn in a transaction, as the package function WithTx does, bounded by the transaction timeout.
+// The repositories passed to fn write in the transaction; calling WithTx on them runs fn in the same transaction.
+func (r Repositories) WithTx(ctx context.Context, fn func(tx Repositories) error) error {
+	return r.Transactor.WithTx(ctx, fn)
+}
+
+// NewRepositories creates the Postgres repositories on top of a database, with their queries and transactions
+// bounded by the timeouts.
+func NewRepositories(db *sql.DB, timeouts Timeouts) Repositories {
+	repos := newRepositories(timedDB{DBTX: db, timeout: timeouts.Query})
+	repos.Transactor = pgTransactor{db: db, timeouts: timeouts}
+	return repos
+}
+
+// newRepositories creates the Postgres repositories on top of a database or a transaction, without a Transactor.
+func newRepositories(db DBTX) Repositories {
+	return Repositories{
+		DB:              db,
+		Users:           NewUserRepository(db),
+		Devices:         NewDeviceRepository(db),
+		OTPs:            NewOTPRepository(db),
+		Verifications:   NewVerificationRepository(db),
+		PasswordHistory: NewPasswordHistoryRepository(db),
+		SignIns:         NewSignInRepository(db),
+		Deletions:       NewDeletionRepository(db),
+		Audit:           NewAuditRepository(db),
+		Outbox:          NewOutboxRepository(db),
+		DataExports:     NewDataExportRepository(db),
+		JobRuns:         NewJobRunRepository(db),
+	}
+}
+
+// pgTransactor begins Postgres transactions.
+type pgTransactor struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+func (t pgTransactor) WithTx(ctx context.Context, fn func(tx Repositories) error) error {
+	return WithTx(ctx, t.db, t.timeouts.Transaction, func(tx *sql.Tx) error {
+		repos := newRepositories(timedDB{DBTX: tx, timeout: t.timeouts.Query})
+		repos.Transactor = joinedTransactor{repos: repos}
+		return fn(repos)
+	})
+}
+
+// joinedTransactor runs nested transactions in the transaction already open.
+type joinedTransactor struct{ repos Repositories }
+
+func (t joinedTransactor) WithTx(_ context.Context, fn func(tx Repositories) error) error {
+	return fn(t.repos)
+}
+
+// pgUsers is the Postgres UserRepository.
+type pgUsers struct{ db DBTX }
+
+// NewUserRepository creates a UserRepository on top of a database or a transaction.
+func NewUserRepository(db DBTX) UserRepository {
+	return pgUsers{db: db}
+}
+
+func (r pgUsers) GetUserDetail(ctx context.Context, email string) (models.User, error) {
+	return GetUserDetail(ctx, r.db, email)
+}
+
+func (r pgUsers) CreateUser(ctx context.Context, email string) (models.User, error) {
+	return CreateUser(ctx, r.db, email)
+}
+
+func (r pgUsers) UpdatePassword(ctx context.Context, arg models.UpdatePasswordParams) (models.UpdateUserResponse, error) {
+	return UpdatePassword(ctx, r.db, arg)
+}
+
+func (r pgUsers) JoinUsersWithVerificationByEmail(ctx context.Context, email string) ([]models.User, error) {
+	return JoinUsersWithVerificationByEmail(ctx, r.db, email)
+}
+
+func (r pgUsers) JoinUsersWithVerificationByPhone(ctx context.Context, phone string) ([]models.User, error) {
+	return JoinUsersWithVerificationByPhone(ctx, r.db, phone)
+}
+
+func (r pgUsers) JoinUsersWithVerificationByUsername(ctx context.Context, username string) ([]models.User, error) {
+	return JoinUsersWithVerificationByUsername(ctx, r.db, username)
+}
+
+func (r pgUsers) UpdateOnlyPassword(ctx context.Context, arg models.UpdateOnlyPasswordParams) error {
+	return UpdateOnlyPassword(ctx, r.db, arg)
+}
+
+func (r pgUsers) GetUserId(ctx context.Context, arg models.GetUserIdParams) (models.ProfileResponse, error) {
+	return GetUserId(ctx, r.db, arg)
+}
+
+func (r pgUsers) UpdateUser(ctx context.Context, arg models.UpdateUserParams) (models.UpdateUserRow, error) {
+	return UpdateUser(ctx, r.db, arg)
+}
+
+func (r pgUsers) UpdateTwoFactorEnable(ctx context.Context, arg models.UpdateTwoFactorEnableParams) error {
+	return UpdateTwoFactorEnable(ctx, r.db, arg)
+}
+
+func (r pgUsers) UpdatePhoneVerified(ctx context.Context, arg models.UpdatePhoneVerifiedParams) error {
+	return UpdatePhoneVerified(ctx, r.db, arg)
+}
+
+func (r pgUsers) CheckEmailExists(ctx context.Context, arg models.CheckEmailExistsParams) (bool, error) {
+	return CheckEmailExists(ctx, r.db, arg)
+}
+
+func (r pgUsers) UpdateEmail(ctx context.Context, arg models.UpdateEmailParams) error {
+	return UpdateEmail(ctx, r.db, arg)
+}
+
+func (r pgUsers) UpdateOtpNewDevice(ctx context.Context, arg models.UpdateOtpNewDeviceParams) error {
+	return UpdateOtpNewDevice(ctx, r.db, arg)
+}
+
+// pgDevices is the Postgres DeviceRepository.
+type pgDevices struct{ db DBTX }
+
+// NewDeviceRepository creates a DeviceRepository on top of a database or a transaction.
+func NewDeviceRepository(db DBTX) DeviceRepository {
+	return pgDevices{db: db}
+}
+
+func (r pgDevices) UpsetDevice(ctx context.Context, arg models.UpsetDeviceParams) (models.Device, error) {
+	return UpsetDevice(ctx, r.db, arg)
+}
+
+func (r pgDevices) GetDeviceId(ctx context.Context, arg models.GetDeviceIdParams) (models.Device, error) {
+	return GetDeviceId(ctx, r.db, arg)
+}
+
+func (r pgDevices) UpdateTimeLogout(ctx context.Context, arg models.UpdateTimeLogoutParams) error {
+	return UpdateTimeLogout(ctx, r.db, arg)
+}
+
+func (r pgDevices) DeactivateDevice(ctx context.Context, arg models.DeviceUserParams) error {
+	return DeactivateDevice(ctx, r.db, arg)
+}
+
+func (r pgDevices) DeactivateUserDevices(ctx context.Context, userID int) error {
+	return DeactivateUserDevices(ctx, r.db, userID)
+}
+
+// pgOTPs is the Postgres OTPRepository.
+type pgOTPs struct{ db DBTX }
+
+// NewOTPRepository creates an OTPRepository on top of a database or a transaction.
+func NewOTPRepository(db DBTX) OTPRepository {
+	return pgOTPs{db: db}
+}
+
+func (r pgOTPs) CreateOtp(ctx context.Context, arg models.CreateOtpParams) (models.Otp, error) {
+	return CreateOtp(ctx, r.db, arg)
+}
+
+func (r pgOTPs) GetNewOtps(ctx context.Context, arg models.GetNewOtpsParams) ([]models.GetNewOtpsRow, error) {
+	return GetNewOtps(ctx, r.db, arg)
+}
+
+func (r pgOTPs) UpdateOtpIsActive(ctx context.Context, arg models.UpdateOtpIsActiveParams) error {
+	return UpdateOtpIsActive(ctx, r.db, arg)
+}
+
+// pgVerifications is the Postgres VerificationRepository.
+type pgVerifications struct{ db DBTX }
+
+// NewVerificationRepository creates a VerificationRepository on top of a database or a transaction.
+func NewVerificationRepository(db DBTX) VerificationRepository {
+	return pgVerifications{db: db}
+}
+
+func (r pgVerifications) CreateVerification(ctx context.Context, data models.BodyVerificationRequest) (models.Verification, error) {
+	return CreateVerification(ctx, r.db, data)
+}
+
+func (r pgVerifications) GetVerification(ctx context.Context, arg models.QueryVerificationRequest) (models.Verification, error) {
+	return GetVerification(ctx, r.db, arg)
+}
+
+func (r pgVerifications) UpdateVerification(ctx context.Context, arg models.UpdateVerificationParams) error {
+	return UpdateVerification(ctx, r.db, arg)
+}
+
+func (r pgVerifications) GetVerificationByUserId(ctx context.Context, userID int) (int, error) {
+	return GetVerificationByUserId(ctx, r.db, userID)
+}
+
+// pgPasswordHistory is the Postgres PasswordHistoryRepository.
+type pgPasswordHistory struct{ db DBTX }
+
+// NewPasswordHistoryRepository creates a PasswordHistoryRepository on top of a database or a transaction.
+func NewPasswordHistoryRepository(db DBTX) PasswordHistoryRepository {
+	return pgPasswordHistory{db: db}
+}
+
+func (r pgPasswordHistory) InsertPasswordHistory(ctx context.Context, arg models.InsertPasswordHistoryParams) error {
+	return InsertPasswordHistory(ctx, r.db, arg)
+}
+
+func (r pgPasswordHistory) CheckPreviousPasswords(ctx context.Context, userID int, limit int) ([]models.PasswordHistory, error) {
+	return CheckPreviousPasswords(ctx, r.db, userID, limit)
+}
+
+// pgSignIns is the Postgres SignInRepository.
+type pgSignIns struct{ db DBTX }
+
+// NewSignInRepository creates a SignInRepository on top of a database or a transaction.
+func NewSignInRepository(db DBTX) SignInRepository {
+	return pgSignIns{db: db}
+}
+
+func (r pgSignIns) GetSignInHistory(ctx context.Context, arg models.GetSignInHistoryParams) (models.SignInHistory, error) {
+	return GetSignInHistory(ctx, r.db, arg)
+}
+
+func (r pgSignIns) CreateSignIn(ctx context.Context, arg models.CreateSignInParams) (int, error) {
+	return CreateSignIn(ctx, r.db, arg)
+}
+
+func (r pgSignIns) GetLastSignIn(ctx context.Context, userID int) (models.LastSignInRow, error) {
+	return GetLastSignIn(ctx, r.db, userID)
+}
+
+func (r pgSignIns) RevokeSignInByToken(ctx context.Context, token string) (models.RevokeSignInRow, error) {
+	return RevokeSignInByToken(ctx, r.db, token)
+}
+
+func (r pgSignIns) RevokeDeviceSignIns(ctx context.Context, arg models.DeviceUserParams) error {
+	return RevokeDeviceSignIns(ctx, r.db, arg)
+}
+
+// pgDeletions is the Postgres DeletionRepository.
+type pgDeletions struct{ db DBTX }
+
+// NewDeletionRepository creates a DeletionRepository on top of a database or a transaction.
+func NewDeletionRepository(db DBTX) DeletionRepository {
+	return pgDeletions{db: db}
+}
+
+func (r pgDeletions) ScheduleAccountDeletion(ctx context.Context, arg models.ScheduleAccountDeletionParams) (models.ScheduleAccountDeletionRow, error) {
+	return ScheduleAccountDeletion(ctx, r.db, arg)
+}
+
+func (r pgDeletions) CancelAccountDeletion(ctx context.Context, token string) (models.CancelDeletionResponse, error) {
+	return CancelAccountDeletion(ctx, r.db, token)
+}
+
+func (r pgDeletions) IsAccountPendingDeletion(ctx context.Context, userID int) (bool, error) {
+	return IsAccountPendingDeletion(ctx, r.db, userID)
+}
+
+func (r pgDeletions) ListAccountsDueForDeletion(ctx context.Context, limit int) ([]models.AccountDueForDeletion, error) {
+	return ListAccountsDueForDeletion(ctx, r.db, limit)
+}
+
+func (r pgDeletions) DeleteUserPersonalData(ctx context.Context, userID int) error {
+	return DeleteUserPersonalData(ctx, r.db, userID)
+}
+
+func (r pgDeletions) AnonymiseUserMailLog(ctx context.Context, userID int, email string) error {
+	return AnonymiseUserMailLog(ctx, r.db, userID, email)
+}
+
+func (r pgDeletions) AnonymiseUser(ctx context.Context, id int, email string) error {
+	return AnonymiseUser(ctx, r.db, id, email)
+}
+
+// pgAudit is the Postgres AuditRepository.
+type pgAudit struct{ db DBTX }
+
+// NewAuditRepository creates an AuditRepository on top of a database or a transaction.
+func NewAuditRepository(db DBTX) AuditRepository {
+	return pgAudit{db: db}
+}
+
+func (r pgAudit) CreateAuditEvent(ctx context.Context, arg models.CreateAuditEventParams) error {
+	return CreateAuditEvent(ctx, r.db, arg)
+}
+
+func (r pgAudit) ListAuditEvents(ctx context.Context, arg models.ListAuditEventsParams) ([]models.AuditEvent, error) {
+	return ListAuditEvents(ctx, r.db, arg)
+}
+
+// pgOutbox is the Postgres OutboxRepository.
+type pgOutbox struct{ db DBTX }
+
+// NewOutboxRepository creates an OutboxRepository on top of a database or a transaction.
+func NewOutboxRepository(db DBTX) OutboxRepository {
+	return pgOutbox{db: db}
+}
+
+func (r pgOutbox) CreateOutbox(ctx context.Context, arg models.CreateOutboxParams) (int, error) {
+	return CreateOutbox(ctx, r.db, arg)
+}
+
+func (r pgOutbox) GetPendingOutbox(ctx context.Context, limit int) ([]models.Outbox, error) {
+	return GetPendingOutbox(ctx, r.db, limit)
+}
+
+func (r pgOutbox) MarkOutboxPublished(ctx context.Context, id int) error {
+	return MarkOutboxPublished(ctx, r.db, id)
+}
+
+func (r pgOutbox) MarkOutboxFailed(ctx context.Context, arg models.MarkOutboxFailedParams) error {
+	return MarkOutboxFailed(ctx, r.db, arg)
+}
+
+func (r pgOutbox) CreateOutboxSecret(ctx context.Context, arg models.CreateOutboxSecretParams) (int, error) {
+	return CreateOutboxSecret(ctx, r.db, arg)
+}
+
+func (r pgOutbox) GetOutboxSecret(ctx context.Context, id int) (string, error) {
+	return GetOutboxSecret(ctx, r.db, id)
+}
+
+// pgDataExports is the Postgres DataExportRepository.
+type pgDataExports struct{ db DBTX }
+
+// NewDataExportRepository creates a DataExportRepository on top of a database or a transaction.
+func NewDataExportRepository(db DBTX) DataExportRepository {
+	return pgDataExports{db: db}
+}
+
+func (r pgDataExports) CreateDataExport(ctx context.Context, arg models.CreateDataExportParams) (int, error) {
+	return CreateDataExport(ctx, r.db, arg)
+}
+
+func (r pgDataExports) GetDataExport(ctx context.Context, id int) (models.DataExport, error) {
+	return GetDataExport(ctx, r.db, id)
+}
+
+func (r pgDataExports) GetDataExportByToken(ctx context.Context, token string) (models.DataExport, error) {
+	return GetDataExportByToken(ctx, r.db, token)
+}
+
+func (r pgDataExports) HasDataExportInProgress(ctx context.Context, userID int, statuses []int) (bool, error) {
+	return HasDataExportInProgress(ctx, r.db, userID, statuses)
+}
+
+func (r pgDataExports) StartDataExport(ctx context.Context, id int, status int) (int, error) {
+	return StartDataExport(ctx, r.db, id, status)
+}
+
+func (r pgDataExports) UpdateDataExportStatus(ctx context.Context, arg models.UpdateDataExportStatusParams) error {
+	return UpdateDataExportStatus(ctx, r.db, arg)
+}
+
+func (r pgDataExports) MarkDataExportReady(ctx context.Context, arg models.MarkDataExportReadyParams) error {
+	return MarkDataExportReady(ctx, r.db, arg)
+}
+
+func (r pgDataExports) ListExpiredDataExports(ctx context.Context, before time.Time, limit int) ([]models.ExpiredDataExport, error) {
+	return ListExpiredDataExports(ctx, r.db, before, limit)
+}
+
+func (r pgDataExports) ExpireDataExport(ctx context.Context, id int) error {
+	return ExpireDataExport(ctx, r.db, id)
+}
+
+func (r pgDataExports) ListUserDataExportFiles(ctx context.Context, userID int) ([]string, error) {
+	return ListUserDataExportFiles(ctx, r.db, userID)
+}
+
+func (r pgDataExports) ListExportDevices(ctx context.Context, userID int) ([]models.ExportDevice, error) {
+	return ListExportDevices(ctx, r.db, userID)
+}
+
+func (r pgDataExports) ListExportPasswordChanges(ctx context.Context, userID int) ([]models.ExportPasswordChange, error) {
+	return ListExportPasswordChanges(ctx, r.db, userID)
+}
+
+func (r pgDataExports) ListExportSocialLogins(ctx context.Context, userID int) ([]models.ExportSocialLogin, error) {
+	return ListExportSocialLogins(ctx, r.db, userID)
+}
+
+func (r pgDataExports) ListExportOtps(ctx context.Context, userID int) ([]models.ExportOtp, error) {
+	return ListExportOtps(ctx, r.db, userID)
+}
+
+func (r pgDataExports) ListExportVerifications(ctx context.Context, userID int) ([]models.ExportVerification, error) {
+	return ListExportVerifications(ctx, r.db, userID)
+}
+
+func (r pgDataExports) ListExportSignIns(ctx context.Context, userID int) ([]models.ExportSignIn, error) {
+	return ListExportSignIns(ctx, r.db, userID)
+}
+
+func (r pgDataExports) ListExportMail(ctx context.Context, userID int) ([]models.ExportMail, error) {
+	return ListExportMail(ctx, r.db, userID)
+}
+
+// pgJobRuns is the Postgres JobRunRepository.
+type pgJobRuns struct{ db DBTX }
+
+// NewJobRunRepository creates a JobRunRepository on top of a database or a transaction.
+func NewJobRunRepository(db DBTX) JobRunRepository {
+	return pgJobRuns{db: db}
+}
+
+func (r pgJobRuns) StartJobRun(ctx context.Context, arg models.StartJobRunParams) (int64, error) {
+	return StartJobRun(ctx, r.db, arg)
+}
+
+func (r pgJobRuns) AbandonJobRuns(ctx context.Context, arg models.AbandonJobRunsParams) (int64, error) {
+	return AbandonJobRuns(ctx, r.db, arg)
+}
+
+func (r pgJobRuns) FinishJobRun(ctx context.Context, arg models.FinishJobRunParams) error {
+	return FinishJobRun(ctx, r.db, arg)
+}
+
+func (r pgJobRuns) ListJobRuns(ctx context.Context, arg models.ListJobRunsParams) ([]models.JobRun, error) {
+	return ListJobRuns(ctx, r.db, arg)
+}
+
+func (r pgJobRuns) ListLastJobRuns(ctx context.Context) ([]models.JobRun, error) {
+	return ListLastJobRuns(ctx, r.db)
+}
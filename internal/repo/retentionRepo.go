@@ -0,0 +1,146 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+)
+
+const deleteExpiredVerifications = `-- name: DeleteExpiredVerifications :execrows
+DELETE FROM verification
+WHERE id IN (
+    SELECT id FROM verification
+    WHERE expires_at < $1
+    LIMIT $2
+)
+`
+
+// DeleteExpiredVerifications deletes up to limit verification links that expired before the given time.
+// It returns the number of rows deleted.
+func DeleteExpiredVerifications(ctx context.Context, db DBTX, before time.Time, limit int) (int64, error) {
+	ctx, end := startQuery(ctx, db, "DeleteExpiredVerifications")
+	defer end()
+
+	result, err := db.ExecContext(ctx, deleteExpiredVerifications, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteExpiredOtps = `-- name: DeleteExpiredOtps :execrows
+DELETE FROM otps
+WHERE id IN (
+    SELECT id FROM otps
+    WHERE expires_at < $1 OR (is_active = FALSE AND created_at < $1)
+    LIMIT $2
+)
+`
+
+// DeleteExpiredOtps deletes up to limit OTPs that expired, or were used, before the given time.
+// It returns the number of rows deleted.
+func DeleteExpiredOtps(ctx context.Context, db DBTX, before time.Time, limit int) (int64, error) {
+	ctx, end := startQuery(ctx, db, "DeleteExpiredOtps")
+	defer end()
+
+	result, err := db.ExecContext(ctx, deleteExpiredOtps, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteStaleDevices = `-- name: DeleteStaleDevices :execrows
+DELETE FROM devices
+WHERE id IN (
+    SELECT id FROM devices
+    WHERE is_active = FALSE AND COALESCE(logged_out_at, updated_at, created_at) < $1
+    LIMIT $2
+)
+`
+
+// DeleteStaleDevices deletes up to limit devices that were logged out before the given time.
+// It returns the number of rows deleted.
+func DeleteStaleDevices(ctx context.Context, db DBTX, before time.Time, limit int) (int64, error) {
+	ctx, end := startQuery(ctx, db, "DeleteStaleDevices")
+	defer end()
+
+	result, err := db.ExecContext(ctx, deleteStaleDevices, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const prunePasswordHistory = `-- name: PrunePasswordHistory :execrows
+DELETE FROM password_history
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY id DESC) AS position
+        FROM password_history
+    ) AS ranked
+    WHERE position > $1
+    LIMIT $2
+)
+`
+
+// PrunePasswordHistory deletes up to limit old passwords beyond the newest depth of each user,
+// which are the only ones checked when a password is reused.
+// It returns the number of rows deleted.
+func PrunePasswordHistory(ctx context.Context, db DBTX, depth int, limit int) (int64, error) {
+	ctx, end := startQuery(ctx, db, "PrunePasswordHistory")
+	defer end()
+
+	result, err := db.ExecContext(ctx, prunePasswordHistory, depth, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOldOutbox = `-- name: DeleteOldOutbox :execrows
+DELETE FROM outbox
+WHERE id IN (
+    SELECT id FROM outbox
+    WHERE status <> $1 AND COALESCE(published_at, created_at) < $2
+    LIMIT $3
+)
+`
+
+// DeleteOldOutbox deletes up to limit outbox rows that were published, or gave up, before the given time.
+// Pending rows are kept until the relay publishes them.
+// It returns the number of rows deleted.
+func DeleteOldOutbox(ctx context.Context, db DBTX, before time.Time, limit int) (int64, error) {
+	ctx, end := startQuery(ctx, db, "DeleteOldOutbox")
+	defer end()
+
+	result, err := db.ExecContext(ctx, deleteOldOutbox, constants.OutboxStatusPending, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteExpiredOutboxSecrets = `-- name: DeleteExpiredOutboxSecrets :execrows
+DELETE FROM outbox_secrets
+WHERE id IN (
+    SELECT id FROM outbox_secrets
+    WHERE expires_at < $1
+    LIMIT $2
+)
+`
+
+// DeleteExpiredOutboxSecrets deletes up to limit secrets of emails that expired before the given time
+// without the email having been sent.
+// It returns the number of rows deleted.
+func DeleteExpiredOutboxSecrets(ctx context.Context, db DBTX, before time.Time, limit int) (int64, error) {
+	ctx, end := startQuery(ctx, db, "DeleteExpiredOutboxSecrets")
+	defer end()
+
+	result, err := db.ExecContext(ctx, deleteExpiredOutboxSecrets, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+const getSignInHistory = `-- name: GetSignInHistory :one
+SELECT
+    EXISTS (
+        SELECT 1 FROM sign_ins WHERE user_id = $1
+    ) AS has_sign_ins,
+    EXISTS (
+        SELECT 1 FROM sign_ins WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL
+    ) OR EXISTS (
+        SELECT 1 FROM devices WHERE user_id = $1 AND device_id = $2 AND is_active = TRUE AND public_key <> ''
+    ) AS known_device,
+    EXISTS (
+        SELECT 1 FROM sign_ins WHERE user_id = $1 AND network = $3 AND revoked_at IS NULL
+    ) AS known_network
+`
+
+// GetSignInHistory reports whether the user has signed in before, from this device and from this network.
+// Devices that signed in before sign-ins were recorded are known through the devices table.
+// Revoked devices are no longer known.
+func GetSignInHistory(ctx context.Context, db DBTX, arg models.GetSignInHistoryParams) (models.SignInHistory, error) {
+	ctx, end := startQuery(ctx, db, "GetSignInHistory")
+	defer end()
+
+	row := db.QueryRowContext(ctx, getSignInHistory, arg.UserID, arg.DeviceID, arg.Network)
+	var i models.SignInHistory
+	err := row.Scan(&i.HasSignIns, &i.KnownDevice, &i.KnownNetwork)
+	return i, err
+}
+
+const createSignIn = `-- name: CreateSignIn :one
+INSERT INTO sign_ins (
+    user_id,
+    device_id,
+    device_type,
+    ip,
+    network,
+    new_device,
+    new_network,
+    revoke_token,
+    revoke_expires_at
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+) RETURNING id
+`
+
+// CreateSignIn records a successful sign-in together with the token that revokes its device.
+// It returns the ID of the created row and an error, if any.
+func CreateSignIn(ctx context.Context, db DBTX, arg models.CreateSignInParams) (int, error) {
+	ctx, end := startQuery(ctx, db, "CreateSignIn")
+	defer end()
+
+	row := db.QueryRowContext(ctx, createSignIn,
+		arg.UserID,
+		arg.DeviceID,
+		arg.DeviceType,
+		arg.Ip,
+		arg.Network,
+		arg.NewDevice,
+		arg.NewNetwork,
+		arg.RevokeToken,
+		arg.RevokeExpiresAt,
+	)
+	var id int
+	err := row.Scan(&id)
+	return id, err
+}
+
+const revokeSignInByToken = `-- name: RevokeSignInByToken :one
+UPDATE sign_ins
+SET revoked_at = NOW()
+WHERE revoke_token = $1
+AND revoked_at IS NULL
+AND revoke_expires_at > NOW()
+RETURNING user_id, device_id
+`
+
+// RevokeSignInByToken marks the sign-in with the given revoke token as revoked.
+// It returns sql.ErrNoRows if the token is unknown, expired or already used.
+func RevokeSignInByToken(ctx context.Context, db DBTX, token string) (models.RevokeSignInRow, error) {
+	ctx, end := startQuery(ctx, db, "RevokeSignInByToken")
+	defer end()
+
+	row := db.QueryRowContext(ctx, revokeSignInByToken, token)
+	var i models.RevokeSignInRow
+	err := row.Scan(&i.UserID, &i.DeviceID)
+	return i, err
+}
+
+const revokeDeviceSignIns = `-- name: RevokeDeviceSignIns :exec
+UPDATE sign_ins
+SET revoked_at = NOW()
+WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL
+`
+
+// RevokeDeviceSignIns revokes every sign-in of a user from a device, so the device is unknown again.
+func RevokeDeviceSignIns(ctx context.Context, db DBTX, arg models.DeviceUserParams) error {
+	ctx, end := startQuery(ctx, db, "RevokeDeviceSignIns")
+	defer end()
+
+	_, err := db.ExecContext(ctx, revokeDeviceSignIns, arg.UserID, arg.DeviceID)
+	return err
+}
+
+const deactivateDevice = `-- name: DeactivateDevice :exec
+UPDATE devices
+SET is_active = FALSE, logged_out_at = NOW()
+WHERE user_id = $1 AND device_id = $2
+`
+
+// DeactivateDevice logs a device out of the user's account: its tokens are rejected from then on.
+func DeactivateDevice(ctx context.Context, db DBTX, arg models.DeviceUserParams) error {
+	ctx, end := startQuery(ctx, db, "DeactivateDevice")
+	defer end()
+
+	_, err := db.ExecContext(ctx, deactivateDevice, arg.UserID, arg.DeviceID)
+	return err
+}
+
+const updateOtpNewDevice = `-- name: UpdateOtpNewDevice :exec
+UPDATE users
+SET otp_new_device = $1
+WHERE id = $2
+`
+
+// UpdateOtpNewDevice sets whether the user must confirm sign-ins from unknown devices with an OTP.
+func UpdateOtpNewDevice(ctx context.Context, db DBTX, arg models.UpdateOtpNewDeviceParams) error {
+	ctx, end := startQuery(ctx, db, "UpdateOtpNewDevice")
+	defer end()
+
+	_, err := db.ExecContext(ctx, updateOtpNewDevice, arg.OtpNewDevice, arg.ID)
+	return err
+}
+
+const getLastSignIn = `-- name: GetLastSignIn :one
+SELECT ip, created_at
+FROM sign_ins
+WHERE user_id = $1 AND revoked_at IS NULL
+ORDER BY id DESC
+LIMIT 1
+`
+
+// GetLastSignIn returns the IP and time of the user's most recent sign-in.
+// It returns sql.ErrNoRows if the user never signed in.
+func GetLastSignIn(ctx context.Context, db DBTX, userId int) (models.LastSignInRow, error) {
+	ctx, end := startQuery(ctx, db, "GetLastSignIn")
+	defer end()
+
+	row := db.QueryRowContext(ctx, getLastSignIn, userId)
+	var i models.LastSignInRow
+	err := row.Scan(&i.Ip, &i.CreatedAt)
+	return i, err
+}
@@ -2,7 +2,6 @@ package repo
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
@@ -14,8 +13,11 @@ import (
 // The function queries the database for the user with the specified email and scans the result into a models.User object.
 // If the query is successful, it returns the user object and nil error.
 // If the query fails or no user is found, it returns an empty user object and the corresponding error.
-func GetUserDetail(db *sql.DB, email string) (models.User, error) {
-	row := db.QueryRow("SELECT id, username, email, phone, hidden_phone_number, fullname, hidden_email, avatar, gender, password_hash, two_factor_enabled, is_active, created_at, updated_at FROM users "+
+func GetUserDetail(ctx context.Context, db DBTX, email string) (models.User, error) {
+	ctx, end := startQuery(ctx, db, "GetUserDetail")
+	defer end()
+
+	row := db.QueryRowContext(ctx, "SELECT id, username, email, phone, hidden_phone_number, fullname, hidden_email, avatar, gender, password_hash, two_factor_enabled, two_factor_channel, phone_verified, locale, otp_new_device, is_active, created_at, updated_at FROM users "+
 		"WHERE email = $1 LIMIT 1", email)
 
 	var i models.User
@@ -32,6 +34,10 @@ func GetUserDetail(db *sql.DB, email string) (models.User, error) {
 		&i.Gender,
 		&i.PasswordHash,
 		&i.TwoFactorEnabled,
+		&i.TwoFactorChannel,
+		&i.PhoneVerified,
+		&i.Locale,
+		&i.OtpNewDevice,
 		&i.IsActive,
 		&i.CreatedAt,
 		&i.UpdatedAt,
@@ -41,8 +47,11 @@ func GetUserDetail(db *sql.DB, email string) (models.User, error) {
 
 // CreateUser creates a new user in the database with the given email.
 // It returns the created user and any error encountered.
-func CreateUser(db *sql.DB, email string) (models.User, error) {
-	row := db.QueryRow("INSERT INTO users (email) VALUES ($1) RETURNING id", email)
+func CreateUser(ctx context.Context, db DBTX, email string) (models.User, error) {
+	ctx, end := startQuery(ctx, db, "CreateUser")
+	defer end()
+
+	row := db.QueryRowContext(ctx, "INSERT INTO users (email) VALUES ($1) RETURNING id", email)
 	var i models.User
 	err := row.Scan(
 		&i.ID,
@@ -57,17 +66,20 @@ const updatePassword = `
 UPDATE users
 SET password_hash = $1, hidden_email = $2, is_active = true
 WHERE id = $3
-RETURNING id, email, hidden_email, is_active
+RETURNING id, email, hidden_email, is_active, locale
 `
 
-func UpdatePassword(db *sql.DB, arg models.UpdatePasswordParams) (models.UpdateUserResponse, error) {
+func UpdatePassword(ctx context.Context, db DBTX, arg models.UpdatePasswordParams) (models.UpdateUserResponse, error) {
+	ctx, end := startQuery(ctx, db, "UpdatePassword")
+	defer end()
+
 	var i models.UpdateUserResponse
-	err := db.QueryRowContext(context.Background(), updatePassword, arg.PasswordHash, arg.HiddenEmail, arg.ID).Scan(&i.Id, &i.Email, &i.HiddenEmail, &i.IsActive)
+	err := db.QueryRowContext(ctx, updatePassword, arg.PasswordHash, arg.HiddenEmail, arg.ID).Scan(&i.Id, &i.Email, &i.HiddenEmail, &i.IsActive, &i.Locale)
 	return i, err
 }
 
 const joinUsersWithVerificationByEmail = `-- name: JoinUsersWithVerificationByEmail :many
-SELECT users.id, users.username, users.email, users.phone, users.hidden_phone_number, users.fullname, users.hidden_email, users.avatar, users.gender, users.password_hash, users.two_factor_enabled, users.is_active, users.created_at, users.updated_at
+SELECT users.id, users.username, users.email, users.phone, users.hidden_phone_number, users.fullname, users.hidden_email, users.avatar, users.gender, users.password_hash, users.two_factor_enabled, users.two_factor_channel, users.phone_verified, users.locale, users.otp_new_device, users.is_active, users.created_at, users.updated_at
 FROM users
 JOIN verification ON users.id = verification.user_id
 WHERE verification.is_verified = true
@@ -76,8 +88,11 @@ AND users.email = $1
 
 // JoinUsersWithVerificationByEmail joins the user table with the verification table based on the provided email.
 // It returns a slice of User models and an error if any occurred.
-func JoinUsersWithVerificationByEmail(db *sql.DB, email string) ([]models.User, error) {
-	rows, err := db.QueryContext(context.Background(), joinUsersWithVerificationByEmail, email)
+func JoinUsersWithVerificationByEmail(ctx context.Context, db DBTX, email string) ([]models.User, error) {
+	ctx, end := startQuery(ctx, db, "JoinUsersWithVerificationByEmail")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, joinUsersWithVerificationByEmail, email)
 	if err != nil {
 		return nil, err
 	}
@@ -97,6 +112,10 @@ func JoinUsersWithVerificationByEmail(db *sql.DB, email string) ([]models.User,
 			&i.Gender,
 			&i.PasswordHash,
 			&i.TwoFactorEnabled,
+			&i.TwoFactorChannel,
+			&i.PhoneVerified,
+			&i.Locale,
+			&i.OtpNewDevice,
 			&i.IsActive,
 			&i.CreatedAt,
 			&i.UpdatedAt,
@@ -115,7 +134,7 @@ func JoinUsersWithVerificationByEmail(db *sql.DB, email string) ([]models.User,
 }
 
 const joinUsersWithVerificationByPhone = `-- name: JoinUsersWithVerificationByPhone :many
-SELECT users.id, users.username, users.email, users.phone, users.hidden_phone_number, users.fullname, users.hidden_email, users.avatar, users.gender, users.password_hash, users.two_factor_enabled, users.is_active, users.created_at, users.updated_at
+SELECT users.id, users.username, users.email, users.phone, users.hidden_phone_number, users.fullname, users.hidden_email, users.avatar, users.gender, users.password_hash, users.two_factor_enabled, users.two_factor_channel, users.phone_verified, users.locale, users.otp_new_device, users.is_active, users.created_at, users.updated_at
 FROM users
 JOIN verification ON users.id = verification.user_id
 WHERE verification.is_verified = true
@@ -126,8 +145,11 @@ AND users.phone = $1
 // based on the provided phone number.
 // It takes a database connection `db` and a `phone` string as input parameters.
 // It returns a slice of `models.User` and an error if any.
-func JoinUsersWithVerificationByPhone(db *sql.DB, phone string) ([]models.User, error) {
-	rows, err := db.QueryContext(context.Background(), joinUsersWithVerificationByPhone, phone)
+func JoinUsersWithVerificationByPhone(ctx context.Context, db DBTX, phone string) ([]models.User, error) {
+	ctx, end := startQuery(ctx, db, "JoinUsersWithVerificationByPhone")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, joinUsersWithVerificationByPhone, phone)
 	if err != nil {
 		return nil, err
 	}
@@ -147,6 +169,10 @@ func JoinUsersWithVerificationByPhone(db *sql.DB, phone string) ([]models.User,
 			&i.Gender,
 			&i.PasswordHash,
 			&i.TwoFactorEnabled,
+			&i.TwoFactorChannel,
+			&i.PhoneVerified,
+			&i.Locale,
+			&i.OtpNewDevice,
 			&i.IsActive,
 			&i.CreatedAt,
 			&i.UpdatedAt,
@@ -165,7 +191,7 @@ func JoinUsersWithVerificationByPhone(db *sql.DB, phone string) ([]models.User,
 }
 
 const joinUsersWithVerificationByUsername = `-- name: JoinUsersWithVerificationByUsername :many
-SELECT users.id, users.username, users.email, users.phone, users.hidden_phone_number, users.fullname, users.hidden_email, users.avatar, users.gender, users.password_hash, users.two_factor_enabled, users.is_active, users.created_at, users.updated_at
+SELECT users.id, users.username, users.email, users.phone, users.hidden_phone_number, users.fullname, users.hidden_email, users.avatar, users.gender, users.password_hash, users.two_factor_enabled, users.two_factor_channel, users.phone_verified, users.locale, users.otp_new_device, users.is_active, users.created_at, users.updated_at
 FROM users
 JOIN verification ON users.id = verification.user_id
 WHERE verification.is_verified = true
@@ -174,8 +200,11 @@ AND users.username = $1
 
 // JoinUsersWithVerificationByUsername joins the user table with the verification table based on the provided username.
 // It returns a slice of models.User and an error if any.
-func JoinUsersWithVerificationByUsername(db *sql.DB, username string) ([]models.User, error) {
-	rows, err := db.QueryContext(context.Background(), joinUsersWithVerificationByUsername, username)
+func JoinUsersWithVerificationByUsername(ctx context.Context, db DBTX, username string) ([]models.User, error) {
+	ctx, end := startQuery(ctx, db, "JoinUsersWithVerificationByUsername")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, joinUsersWithVerificationByUsername, username)
 	if err != nil {
 		return nil, err
 	}
@@ -195,6 +224,10 @@ func JoinUsersWithVerificationByUsername(db *sql.DB, username string) ([]models.
 			&i.Gender,
 			&i.PasswordHash,
 			&i.TwoFactorEnabled,
+			&i.TwoFactorChannel,
+			&i.PhoneVerified,
+			&i.Locale,
+			&i.OtpNewDevice,
 			&i.IsActive,
 			&i.CreatedAt,
 			&i.UpdatedAt,
@@ -221,18 +254,24 @@ WHERE id = $2
 // UpdateOnlyPassword updates the password hash for a user in the database.
 // It takes a database connection (`db`) and an argument (`arg`) of type `models.UpdateOnlyPasswordParams`.
 // It returns an error if the update operation fails.
-func UpdateOnlyPassword(db *sql.DB, arg models.UpdateOnlyPasswordParams) error {
-	_, err := db.ExecContext(context.Background(), updateOnlyPassword, arg.PasswordHash, arg.ID)
+func UpdateOnlyPassword(ctx context.Context, db DBTX, arg models.UpdateOnlyPasswordParams) error {
+	ctx, end := startQuery(ctx, db, "UpdateOnlyPassword")
+	defer end()
+
+	_, err := db.ExecContext(ctx, updateOnlyPassword, arg.PasswordHash, arg.ID)
 	return err
 }
 
 const getUserId = `-- name: GetUserId :one
-SELECT id, username, email, phone, hidden_phone_number, fullname, hidden_email, avatar, gender, two_factor_enabled, is_active, created_at FROM users
+SELECT id, username, email, phone, hidden_phone_number, fullname, hidden_email, avatar, gender, two_factor_enabled, two_factor_channel, phone_verified, locale, otp_new_device, is_active, created_at FROM users
 WHERE id = $1 AND is_active = $2 LIMIT 1
 `
 
-func GetUserId(db *sql.DB, arg models.GetUserIdParams) (models.ProfileResponse, error) {
-	row := db.QueryRowContext(context.Background(), getUserId, arg.ID, arg.IsActive)
+func GetUserId(ctx context.Context, db DBTX, arg models.GetUserIdParams) (models.ProfileResponse, error) {
+	ctx, end := startQuery(ctx, db, "GetUserId")
+	defer end()
+
+	row := db.QueryRowContext(ctx, getUserId, arg.ID, arg.IsActive)
 	var i models.ProfileResponse
 	err := row.Scan(
 		&i.ID,
@@ -245,6 +284,10 @@ func GetUserId(db *sql.DB, arg models.GetUserIdParams) (models.ProfileResponse,
 		&i.Avatar,
 		&i.Gender,
 		&i.TwoFactorEnabled,
+		&i.TwoFactorChannel,
+		&i.PhoneVerified,
+		&i.Locale,
+		&i.OtpNewDevice,
 		&i.IsActive,
 		&i.CreatedAt,
 	)
@@ -258,7 +301,10 @@ WHERE id = $7
 RETURNING id, username, hidden_phone_number, fullname, avatar, gender
 `
 
-func UpdateUser(db *sql.DB, arg models.UpdateUserParams) (models.UpdateUserRow, error) {
+func UpdateUser(ctx context.Context, db DBTX, arg models.UpdateUserParams) (models.UpdateUserRow, error) {
+	ctx, end := startQuery(ctx, db, "UpdateUser")
+	defer end()
+
 	// Start with the base update statement
 	updateUser := "UPDATE users SET"
 
@@ -279,6 +325,9 @@ func UpdateUser(db *sql.DB, arg models.UpdateUserParams) (models.UpdateUserRow,
 		updateUser += fmt.Sprintf(" phone = $%d,", counter)
 		updateValues = append(updateValues, arg.Phone)
 		counter++
+
+		// A new phone number has to be verified again
+		updateUser += " phone_verified = false,"
 	}
 
 	if arg.Fullname.Valid {
@@ -299,6 +348,12 @@ func UpdateUser(db *sql.DB, arg models.UpdateUserParams) (models.UpdateUserRow,
 		counter++
 	}
 
+	if arg.Locale.Valid {
+		updateUser += fmt.Sprintf(" locale = $%d,", counter)
+		updateValues = append(updateValues, arg.Locale)
+		counter++
+	}
+
 	// Remove the trailing comma
 	updateUser = updateUser[:len(updateUser)-1]
 
@@ -307,7 +362,7 @@ func UpdateUser(db *sql.DB, arg models.UpdateUserParams) (models.UpdateUserRow,
 	updateValues = append(updateValues, arg.ID)
 
 	// Execute the query
-	row := db.QueryRowContext(context.Background(), updateUser, updateValues...)
+	row := db.QueryRowContext(ctx, updateUser, updateValues...)
 
 	var i models.UpdateUserRow
 	err := row.Scan(
@@ -323,12 +378,31 @@ func UpdateUser(db *sql.DB, arg models.UpdateUserParams) (models.UpdateUserRow,
 
 const updateTwoFactorEnable = `-- name: UpdateTwoFactorEnable :exec
 UPDATE users
-SET two_factor_enabled = $1
+SET two_factor_enabled = $1, two_factor_channel = $2
+WHERE id = $3
+`
+
+func UpdateTwoFactorEnable(ctx context.Context, db DBTX, arg models.UpdateTwoFactorEnableParams) error {
+	ctx, end := startQuery(ctx, db, "UpdateTwoFactorEnable")
+	defer end()
+
+	_, err := db.ExecContext(ctx, updateTwoFactorEnable, arg.TwoFactorEnabled, arg.TwoFactorChannel, arg.ID)
+	return err
+}
+
+const updatePhoneVerified = `-- name: UpdatePhoneVerified :exec
+UPDATE users
+SET phone_verified = $1
 WHERE id = $2
 `
 
-func UpdateTwoFactorEnable(db *sql.DB, arg models.UpdateTwoFactorEnableParams) error {
-	_, err := db.ExecContext(context.Background(), updateTwoFactorEnable, arg.TwoFactorEnabled, arg.ID)
+// UpdatePhoneVerified marks the phone number of a user as verified or not.
+// It returns an error if the update operation fails.
+func UpdatePhoneVerified(ctx context.Context, db DBTX, arg models.UpdatePhoneVerifiedParams) error {
+	ctx, end := startQuery(ctx, db, "UpdatePhoneVerified")
+	defer end()
+
+	_, err := db.ExecContext(ctx, updatePhoneVerified, arg.PhoneVerified, arg.ID)
 	return err
 }
 
@@ -344,8 +418,11 @@ SELECT EXISTS (
 ) AS email_exists
 `
 
-func CheckEmailExists(db *sql.DB, arg models.CheckEmailExistsParams) (bool, error) {
-	row := db.QueryRowContext(context.Background(), checkEmailExists, arg.Email, arg.ID)
+func CheckEmailExists(ctx context.Context, db DBTX, arg models.CheckEmailExistsParams) (bool, error) {
+	ctx, end := startQuery(ctx, db, "CheckEmailExists")
+	defer end()
+
+	row := db.QueryRowContext(ctx, checkEmailExists, arg.Email, arg.ID)
 	var email_exists bool
 	err := row.Scan(&email_exists)
 	return email_exists, err
@@ -353,25 +430,14 @@ func CheckEmailExists(db *sql.DB, arg models.CheckEmailExistsParams) (bool, erro
 
 const updateEmail = `-- name: UpdateEmail :exec
 UPDATE users
-SET email = $1, hidden_email = $2
+SET email = $1, hidden_email = $2, email_undeliverable = FALSE, email_undeliverable_at = NULL
 WHERE id = $3
 `
 
-func UpdateEmail(db *sql.DB, arg models.UpdateEmailParams) error {
-	_, err := db.ExecContext(context.Background(), updateEmail, arg.Email, arg.HiddenEmail, arg.ID)
-	return err
-}
-
-const destroyAccount = `-- name: DestroyAccount :exec
-UPDATE users
-SET is_active = false
-WHERE id = $1 AND is_active = true
-`
+func UpdateEmail(ctx context.Context, db DBTX, arg models.UpdateEmailParams) error {
+	ctx, end := startQuery(ctx, db, "UpdateEmail")
+	defer end()
 
-// DestroyAccount deletes a user account from the database based on the provided ID.
-// It takes a database connection and the ID of the user to be deleted as parameters.
-// Returns an error if the deletion operation fails.
-func DestroyAccount(db *sql.DB, id int) error {
-	_, err := db.ExecContext(context.Background(), destroyAccount, id)
+	_, err := db.ExecContext(ctx, updateEmail, arg.Email, arg.HiddenEmail, arg.ID)
 	return err
 }
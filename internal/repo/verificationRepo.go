@@ -2,7 +2,6 @@ package repo
 
 import (
 	"context"
-	"database/sql"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 )
@@ -11,8 +10,11 @@ import (
 // It takes a database connection `db` and a `data` object of type `models.BodyVerificationRequest`
 // containing the necessary information for creating the verification record.
 // It returns a `models.Verification` object representing the created verification record and an error, if any.
-func CreateVerification(db *sql.DB, data models.BodyVerificationRequest) (models.Verification, error) {
-	row := db.QueryRow("INSERT INTO verification (user_id, verified_token, expires_at) "+
+func CreateVerification(ctx context.Context, db DBTX, data models.BodyVerificationRequest) (models.Verification, error) {
+	ctx, end := startQuery(ctx, db, "CreateVerification")
+	defer end()
+
+	row := db.QueryRowContext(ctx, "INSERT INTO verification (user_id, verified_token, expires_at) "+
 		"VALUES ($1, $2, $3) RETURNING id", data.UserId, data.VerifiedToken, data.ExpiresAt)
 	var i models.Verification
 	err := row.Scan(
@@ -29,8 +31,11 @@ SELECT * FROM verification
 WHERE verified_token = $1 AND user_id = $2 AND is_verified = $3 LIMIT 1
 `
 
-func GetVerification(db *sql.DB, arg models.QueryVerificationRequest) (models.Verification, error) {
-	row := db.QueryRowContext(context.Background(), getVerification, arg.Token, arg.UserId, false)
+func GetVerification(ctx context.Context, db DBTX, arg models.QueryVerificationRequest) (models.Verification, error) {
+	ctx, end := startQuery(ctx, db, "GetVerification")
+	defer end()
+
+	row := db.QueryRowContext(ctx, getVerification, arg.Token, arg.UserId, false)
 	var i models.Verification
 	err := row.Scan(
 		&i.ID,
@@ -55,8 +60,11 @@ WHERE user_id = $3
 // UpdateVerification updates the verification status and activity status of a user in the database.
 // It takes a database connection and the necessary parameters as arguments.
 // Returns an error if the database update fails.
-func UpdateVerification(db *sql.DB, arg models.UpdateVerificationParams) error {
-	_, err := db.ExecContext(context.Background(), updateVerification, arg.IsVerified, arg.IsActive, arg.UserID)
+func UpdateVerification(ctx context.Context, db DBTX, arg models.UpdateVerificationParams) error {
+	ctx, end := startQuery(ctx, db, "UpdateVerification")
+	defer end()
+
+	_, err := db.ExecContext(ctx, updateVerification, arg.IsVerified, arg.IsActive, arg.UserID)
 	return err
 }
 
@@ -65,27 +73,12 @@ SELECT COUNT(*) FROM verification
 WHERE user_id = $1 AND is_verified = false
 `
 
-func GetVerificationByUserId(db *sql.DB, userID int) (int, error) {
-	row := db.QueryRowContext(context.Background(), getVerificationByUserId, userID)
+func GetVerificationByUserId(ctx context.Context, db DBTX, userID int) (int, error) {
+	ctx, end := startQuery(ctx, db, "GetVerificationByUserId")
+	defer end()
+
+	row := db.QueryRowContext(ctx, getVerificationByUserId, userID)
 	var count int
 	err := row.Scan(&count)
 	return count, err
 }
-
-const updateVerificationBulk = `-- name: UpdateVerificationBulk :exec
-WITH rows_to_update AS (
-    SELECT id
-    FROM verification
-    WHERE expires_at < NOW() AND is_active = true
-    LIMIT 50
-)
-UPDATE verification AS v
-SET is_active = false
-FROM rows_to_update AS r
-WHERE v.id = r.id
-`
-
-func UpdateVerificationBulk(db *sql.DB) error {
-	_, err := db.ExecContext(context.Background(), updateVerificationBulk)
-	return err
-}
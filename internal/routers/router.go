@@ -1,31 +1,60 @@
 package routers
 
 import (
-	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/utils"
 	_ "github.com/fdhhhdjd/Go_Secure_Auth_Pro/docs/swagger"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/controllers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
 	controller "github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/controllers"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/middlewares"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/metrics"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	third_party "github.com/fdhhhdjd/Go_Secure_Auth_Pro/third_party/telegram"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func NewRouter() *gin.Engine {
+// NewRouter creates the Gin engine with the middlewares and routes of the API,
+// served by svc on top of the given App. The caller closes svc once the server is shut down.
+func NewRouter(a *app.App, svc *service.Service) *gin.Engine {
+	ctl := controller.New(svc)
+
 	nodeEnv := os.Getenv("ENV")
 
 	if nodeEnv != constants.DevEnvironment {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	r := gin.Default()
+	//* Requests and panics are logged by the middlewares below instead of the default logger and recovery of Gin
+	r := gin.New()
+
+	//* The request context is cancelled when the client goes away; services pass c to the repositories, cache and Firebase
+	r.ContextWithFallback = true
+
+	//* The request ID comes first, so every record logged for the request carries it
+	r.Use(middlewares.RequestIDMiddleware())
+	r.Use(middlewares.Recovery())
+
+	//* A span for every request, continuing the trace of the caller; the scrapes of /metrics are not traced
+	r.Use(otelgin.Middleware(constants.ServiceNameServer, otelgin.WithFilter(func(req *http.Request) bool {
+		return req.URL.Path != "/metrics"
+	})))
+
+	//* Metrics of every request, including those refused by the middlewares below
+	r.Use(middlewares.MetricsMiddleware())
+	r.GET("/metrics", gin.WrapH(metrics.Handler(newMetricsRegistry(a), a.Cfg.Metrics.Token)))
+
+	//* One record for every request, with the user and device found by the middlewares below
+	r.Use(middlewares.AccessLogMiddleware())
 
 	//* Swaggers
 	r.GET("/docs/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -34,10 +63,10 @@ func NewRouter() *gin.Engine {
 	r.GET("/ping", controller.Pong)
 
 	//* Test Telegram
-	if err := third_party.PingTelegram(global.Cfg.Telegram.BotToken); err != nil {
-		fmt.Printf("Failed to ping Telegram: %v\n", err)
+	if err := third_party.PingTelegram(a.Cfg.Telegram.BotToken); err != nil {
+		slog.Warn("Failed to ping Telegram", "error", err)
 	} else {
-		fmt.Println("TELEGRAM CONNECTED SUCCESSFULLY 📱")
+		slog.Info("Telegram connected")
 	}
 
 	//* Middleware
@@ -46,18 +75,18 @@ func NewRouter() *gin.Engine {
 	// store := cookie.NewStore([]byte("secret"))
 
 	// Apply middlewares
-	r.Use(middlewares.IPBlackList()) // 1. IP Blacklist
+	r.Use(middlewares.IPBlackList(a)) // 1. IP Blacklist
 	// r.Use(sessions.Sessions(constants.CSRFToken, store)) // 2. Session Handling
-	r.Use(middlewares.CORSMiddleware())            // 3. CORS Middleware
+	r.Use(middlewares.CORSMiddleware(a))           // 3. CORS Middleware
 	r.Use(middlewares.SecurityHeadersMiddleware()) // 4. Security Headers
 	r.Use(middlewares.HeadersMiddlewares())        // 5. Custom Headers
 	// r.Use(middlewares.CSRFMiddleware(secret))            // 6. CSRF Protection
-	r.Use(middlewares.RequestSizeLimiter(1 << 20))       // 7. Request Size Limiter ( 1 MB max )
-	r.Use(middlewares.RateLimiter(5, 10))                // 8. Rate Limiting ( 5 requests per second, with a burst of 10 )
-	r.Use(middlewares.RequestLoggingMiddleware())        // 9. Request Logging
-	r.Use(middlewares.PathTraversalMiddleware())         // 10. Path Traversal
-	r.Use(middlewares.ContentTypeValidationMiddleware()) // 11. Content Type Validation
-	r.Use(middlewares.SanitizeParamsMiddleware())        // 12. Sanitize Params
+	r.Use(middlewares.RequestSizeLimiter(1 << 20))          // 7. Request Size Limiter ( 1 MB max )
+	r.Use(middlewares.RateLimiter(rateLimit(a.Cfg.Server))) // 8. Rate Limiting ( 5 requests per second, with a burst of 10, by default )
+	r.Use(middlewares.RequestLoggingMiddleware(a))          // 9. Request Logging
+	r.Use(middlewares.PathTraversalMiddleware())            // 10. Path Traversal
+	r.Use(middlewares.ContentTypeValidationMiddleware())    // 11. Content Type Validation
+	r.Use(middlewares.SanitizeParamsMiddleware())           // 12. Sanitize Params
 
 	//* Group v1 routes
 	v1 := r.Group("/v1")
@@ -65,32 +94,56 @@ func NewRouter() *gin.Engine {
 		//* Group v1/key routes
 		// key := v1.Group("/key")
 		// {
-		// 	key.GET("/csrf-token", utils.AsyncHandler(controller.GetCsRfToken))
+		// 	key.GET("/csrf-token", utils.AsyncHandler(ctl.GetCsRfToken))
 		// }
 
 		//* Group v1/key routes
 		blacklist := v1.Group("/blacklist")
 		{
-			blacklist.Use(middlewares.AuthorizationMiddleware())
-			blacklist.POST("/ip", utils.AsyncHandler(controller.BlackListIP))
+			blacklist.Use(middlewares.AuthorizationMiddleware(a))
+			blacklist.POST("/ip", utils.AsyncHandler(ctl.BlackListIP))
+		}
+
+		//* Group v1/admin routes
+		admin := v1.Group("/admin")
+		{
+			admin.Use(middlewares.AuthorizationMiddleware(a), middlewares.AdminMiddleware(a))
+			admin.GET("/audit-events", utils.AsyncHandler(ctl.GetAuditEvents))
+			admin.GET("/jobs", utils.AsyncHandler(ctl.ListJobs))
+			admin.GET("/jobs/:name/runs", utils.AsyncHandler(ctl.GetJobRuns))
+			admin.POST("/jobs/:name/run", utils.AsyncHandler(ctl.TriggerJob))
+		}
+
+		//* Group v1/webhooks routes
+		webhooks := v1.Group("/webhooks")
+		{
+			webhooks.POST("/mail", utils.AsyncHandler(ctl.MailWebhook))
+		}
+
+		//* Group v1/exports routes
+		exports := v1.Group("/exports")
+		{
+			exports.GET("/:token", utils.AsyncHandler(ctl.DownloadDataExport))
 		}
 
 		//* Group v1/auth routes
 		auth := v1.Group("/auth")
 		{
-			auth.GET("/veri-account", utils.AsyncHandler(controller.VerificationAccount))
-			auth.POST("/register", utils.AsyncHandler(controller.Register))
-			auth.POST("/resend-link-verification", utils.AsyncHandler(controller.ResendVerificationLink))
-			auth.POST("/login-identifier", utils.AsyncHandler(controller.LoginIdentifier))
-			auth.POST("/login-social", utils.AsyncHandler(controllers.LoginSocial))
-			auth.POST("/forget", utils.AsyncHandler(controllers.ForgetPassword))
-			auth.POST("/reset-password", utils.AsyncHandler(controller.ResetPassword))
-			auth.POST("/verify-otp", utils.AsyncHandler(controller.VerificationOtp))
+			auth.GET("/veri-account", utils.AsyncHandler(ctl.VerificationAccount))
+			auth.POST("/register", utils.AsyncHandler(ctl.Register))
+			auth.POST("/resend-link-verification", utils.AsyncHandler(ctl.ResendVerificationLink))
+			auth.POST("/login-identifier", utils.AsyncHandler(ctl.LoginIdentifier))
+			auth.POST("/login-social", utils.AsyncHandler(ctl.LoginSocial))
+			auth.POST("/forget", utils.AsyncHandler(ctl.ForgetPassword))
+			auth.POST("/reset-password", utils.AsyncHandler(ctl.ResetPassword))
+			auth.POST("/verify-otp", utils.AsyncHandler(ctl.VerificationOtp))
+			auth.POST("/revoke-device", utils.AsyncHandler(ctl.RevokeDevice))
+			auth.POST("/cancel-deletion", utils.AsyncHandler(ctl.CancelAccountDeletion))
 
 			createNewToken := auth.Group("")
-			createNewToken.Use(middlewares.RefetchTokenMiddleware())
+			createNewToken.Use(middlewares.OutcomeMetrics(metrics.TokenRenewals), middlewares.RefetchTokenMiddleware(a))
 			{
-				createNewToken.GET("/renew-token", utils.AsyncHandler(controller.RenewToken))
+				createNewToken.GET("/renew-token", utils.AsyncHandler(ctl.RenewToken))
 
 			}
 
@@ -99,17 +152,22 @@ func NewRouter() *gin.Engine {
 		//* Group v1/user routes (example, you can add more routes here)
 		user := v1.Group("/user")
 		{
-			user.Use(middlewares.AuthorizationMiddleware())
-
-			user.GET("/logout", utils.AsyncHandler(controller.LogoutUser))
-			user.GET("/profile/:id", utils.AsyncHandler(controller.GetProfileUser))
-			user.GET("/destroy-account", utils.AsyncHandler(controller.DestroyAccount))
-
-			user.POST("/update-profile", utils.AsyncHandler(controller.UpdateProfile))
-			user.POST("/change-pass", utils.AsyncHandler(controller.ChangePassword))
-			user.POST("/enable-tow-factor", utils.AsyncHandler(controller.EnableTowFactor))
-			user.POST("/send-otp-update-email", utils.AsyncHandler(controller.SendOtpUpdateEmail))
-			user.POST("/update-email", utils.AsyncHandler(controller.UpdateEmailUser))
+			user.Use(middlewares.AuthorizationMiddleware(a))
+
+			user.GET("/logout", utils.AsyncHandler(ctl.LogoutUser))
+			user.GET("/profile/:id", utils.AsyncHandler(ctl.GetProfileUser))
+			user.GET("/destroy-account", utils.AsyncHandler(ctl.DestroyAccount))
+
+			user.POST("/update-profile", utils.AsyncHandler(ctl.UpdateProfile))
+			user.POST("/change-pass", utils.AsyncHandler(ctl.ChangePassword))
+			user.POST("/enable-tow-factor", utils.AsyncHandler(ctl.EnableTowFactor))
+			user.POST("/send-otp-update-email", utils.AsyncHandler(ctl.SendOtpUpdateEmail))
+			user.POST("/update-email", utils.AsyncHandler(ctl.UpdateEmailUser))
+			user.POST("/send-otp-phone", utils.AsyncHandler(ctl.SendOtpVerifyPhone))
+			user.POST("/verify-phone", utils.AsyncHandler(ctl.VerifyPhone))
+			user.GET("/audit-events", utils.AsyncHandler(ctl.GetMyAuditEvents))
+			user.POST("/otp-new-device", utils.AsyncHandler(ctl.SetOtpNewDevice))
+			user.POST("/export-data", utils.AsyncHandler(ctl.RequestDataExport))
 
 		}
 	}
@@ -141,3 +199,28 @@ func ServiceUnavailable() gin.HandlerFunc {
 	}
 
 }
+
+// rateLimit returns the requests per second and the burst of the rate limiter,
+// from the server configuration or the defaults when they are not set.
+func rateLimit(cfg models.ServerConfig) (float64, int) {
+	rps, burst := cfg.RateLimit, cfg.RateBurst
+	if rps <= 0 {
+		rps = constants.DefaultRateLimit
+	}
+	if burst <= 0 {
+		burst = constants.DefaultRateBurst
+	}
+	return rps, burst
+}
+
+// newMetricsRegistry returns the registry served on /metrics, with the pools of the database and Redis of the App.
+func newMetricsRegistry(a *app.App) *prometheus.Registry {
+	var pools []prometheus.Collector
+	if a.DB != nil {
+		pools = append(pools, metrics.NewDBCollector(a.DB))
+	}
+	if a.Cache != nil {
+		pools = append(pools, metrics.NewRedisCollector(a.Cache))
+	}
+	return metrics.NewRegistry(pools...)
+}
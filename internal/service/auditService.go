@@ -0,0 +1,162 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit appends a security-relevant event to the audit log with the IP, device ID and user agent of the request.
+// It is called once the change has been committed, outside any transaction,
+// so auditing never fails the request: an error is only logged.
+func (s *Service) recordAudit(c *gin.Context, entry models.AuditEntry) {
+	metadata, err := json.Marshal(entry.Metadata)
+	if err != nil || entry.Metadata == nil {
+		metadata = []byte("{}")
+	}
+
+	deviceId := ""
+	if value, exists := c.Get("device_id"); exists {
+		deviceId, _ = value.(string)
+	}
+
+	err = s.app.Repos.Audit.CreateAuditEvent(c, models.CreateAuditEventParams{
+		ActorID:   sql.NullInt32{Int32: int32(entry.ActorID), Valid: entry.ActorID != 0},
+		SubjectID: sql.NullInt32{Int32: int32(entry.SubjectID), Valid: entry.SubjectID != 0},
+		EventType: entry.EventType,
+		IP:        sql.NullString{String: c.ClientIP(), Valid: c.ClientIP() != ""},
+		DeviceID:  sql.NullString{String: deviceId, Valid: deviceId != ""},
+		UserAgent: sql.NullString{String: c.Request.UserAgent(), Valid: c.Request.UserAgent() != ""},
+		Metadata:  metadata,
+	})
+	if err != nil {
+		slog.ErrorContext(c, "Failed to record audit event", "event_type", entry.EventType, "error", err)
+	}
+}
+
+// recordUserAudit records an event a user performed on their own account.
+func (s *Service) recordUserAudit(c *gin.Context, userId int, eventType string, metadata map[string]interface{}) {
+	s.recordAudit(c, models.AuditEntry{
+		ActorID:   userId,
+		SubjectID: userId,
+		EventType: eventType,
+		Metadata:  metadata,
+	})
+}
+
+// GetMyAuditEvents returns the audit events of the authenticated user's account, newest first.
+// The events are paginated with the before query parameter: pass next_before from the previous page
+// to get older events. The event_type parameter filters on one type of event.
+//
+// Swagger documentation for GetMyAuditEvents function
+// @Summary Get my audit events
+// @Description Lists the security events of the authenticated user's account
+// @Tags User
+// @Produce json
+// @Param event_type query string false "Event type"
+// @Param before query int false "Only events older than this ID"
+// @Param limit query int false "Number of events (max 200)"
+// @Success 200 {object} models.AuditEventsResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /user/audit-events [get]
+func (s *Service) GetMyAuditEvents(c *gin.Context) *models.AuditEventsResponse {
+	payload, existsUserInfo := c.Get(constants.InfoAccess)
+	if !existsUserInfo {
+		response.BadRequestError(c, response.ErrorUserEmailInvalid)
+		return nil
+	}
+
+	var reqQuery models.QueryAuditEventsRequest
+	if err := c.ShouldBindQuery(&reqQuery); err != nil {
+		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+
+	// Users only see their own account, whatever user_id they ask for
+	reqQuery.UserID = payload.(models.Payload).ID
+
+	return s.listAuditEvents(c, reqQuery)
+}
+
+// GetAuditEvents returns audit events for administrators, newest first.
+// The user_id query parameter limits them to one account and event_type to one type of event;
+// pagination works as for GetMyAuditEvents.
+//
+// Swagger documentation for GetAuditEvents function
+// @Summary Get audit events
+// @Description Lists security events of every account (administrators only)
+// @Tags Admin
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param event_type query string false "Event type"
+// @Param before query int false "Only events older than this ID"
+// @Param limit query int false "Number of events (max 200)"
+// @Success 200 {object} models.AuditEventsResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/audit-events [get]
+func (s *Service) GetAuditEvents(c *gin.Context) *models.AuditEventsResponse {
+	var reqQuery models.QueryAuditEventsRequest
+	if err := c.ShouldBindQuery(&reqQuery); err != nil {
+		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+
+	return s.listAuditEvents(c, reqQuery)
+}
+
+// listAuditEvents reads one page of audit events matching the query.
+func (s *Service) listAuditEvents(c *gin.Context, reqQuery models.QueryAuditEventsRequest) *models.AuditEventsResponse {
+	limit := reqQuery.Limit
+	if limit <= 0 {
+		limit = constants.AuditDefaultLimit
+	}
+	if limit > constants.AuditMaxLimit {
+		limit = constants.AuditMaxLimit
+	}
+
+	events, err := s.app.Repos.Audit.ListAuditEvents(c, models.ListAuditEventsParams{
+		UserID:    sql.NullInt32{Int32: int32(reqQuery.UserID), Valid: reqQuery.UserID != 0},
+		EventType: sql.NullString{String: reqQuery.EventType, Valid: reqQuery.EventType != ""},
+		Before:    sql.NullInt64{Int64: reqQuery.Before, Valid: reqQuery.Before != 0},
+		Limit:     limit,
+	})
+	if err != nil {
+		slog.ErrorContext(c, "Failed to list audit events", "error", err)
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+
+	result := &models.AuditEventsResponse{Events: make([]models.AuditEventJSON, 0, len(events))}
+	for _, event := range events {
+		result.Events = append(result.Events, auditEventJSON(event))
+	}
+
+	if len(events) == limit {
+		result.NextBefore = events[len(events)-1].ID
+	}
+
+	return result
+}
+
+// auditEventJSON converts an audit event read from the database to its JSON form.
+func auditEventJSON(event models.AuditEvent) models.AuditEventJSON {
+	return models.AuditEventJSON{
+		ID:        event.ID,
+		ActorID:   int(event.ActorID.Int32),
+		SubjectID: int(event.SubjectID.Int32),
+		EventType: event.EventType,
+		IP:        event.IP.String,
+		DeviceID:  event.DeviceID.String,
+		UserAgent: event.UserAgent.String,
+		Metadata:  event.Metadata,
+		CreatedAt: event.CreatedAt,
+	}
+}
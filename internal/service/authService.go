@@ -3,7 +3,7 @@ package service
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
@@ -11,13 +11,12 @@ import (
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/utils"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo/redis"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers/validate"
-	pkg "github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/mail"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/metrics"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -40,12 +39,13 @@ import (
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /auth/register [post]
-func Register(c *gin.Context) *models.RegistrationResponse {
+func (s *Service) Register(c *gin.Context) *models.RegistrationResponse {
 
 	// * Check UserSpam
-	resultSpam := redis.SpamUser(c, global.Cache, constants.SpamKey, constants.RequestThreshold)
+	resultSpam := redis.SpamUser(c, s.app.Cache, constants.SpamKey, constants.RequestThreshold)
 
 	if resultSpam.IsSpam {
+		metrics.SpamBlocks.WithLabelValues(constants.SpamActionRegister).Inc()
 		ttl := fmt.Sprintf("You are blocked for %d seconds", resultSpam.ExpiredSpam)
 		response.BadRequestError(c, response.ErrIpBlackList, ttl)
 		return nil
@@ -63,10 +63,10 @@ func Register(c *gin.Context) *models.RegistrationResponse {
 	cuckooKey := "cuckoo:" + reqBody.Email
 
 	// Delete cache cuckoo if user register success
-	redis.DeleteKeyUser(c, global.Cache, cuckooKey)
+	redis.DeleteKeyUser(c, s.app.Cache, cuckooKey)
 
 	//* Get detail users
-	resultDetailUser, err := repo.GetUserDetail(global.DB, reqBody.Email)
+	resultDetailUser, err := s.app.Repos.Users.GetUserDetail(c, reqBody.Email)
 
 	// * Check account exit into yet
 	if err != nil {
@@ -84,38 +84,64 @@ func Register(c *gin.Context) *models.RegistrationResponse {
 		return nil
 	}
 
-	//* If user not exit create user
-	resultCreateUser, err := repo.CreateUser(global.DB, reqBody.Email)
-	if err != nil {
-		//* Error for database
-		errorCreateUser := utils.HandleDBError(err)
-		if errorCreateUser != "" {
-			response.BadRequestError(c, response.ErrUserDuplicateEmail)
-			return nil
+	ExpiresAtToken := time.Now().Add(24 * time.Hour)
+
+	var resultCreateUser models.User
+	var resultVerificationLink *models.TokenVerificationLink
+
+	//* Create user, verification link and email in one transaction
+	err = s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		var err error
+
+		//* If user not exit create user
+		resultCreateUser, err = tx.Users.CreateUser(c, reqBody.Email)
+		if err != nil {
+			//* Error for database
+			errorCreateUser := utils.HandleDBError(err)
+			if errorCreateUser != "" {
+				response.BadRequestError(c, response.ErrUserDuplicateEmail)
+				return err
+			}
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
 		}
-		response.InternalServerError(c, response.ErrCodeDBQuery)
-		return nil
-	}
 
-	ExpiresAtToken := time.Now().Add(24 * time.Hour)
+		resultVerificationLink = s.createTokenVerificationLink(c, tx.Verifications, models.UserIDEmail{
+			ID:    resultCreateUser.ID,
+			Email: reqBody.Email,
+		}, constants.StatusRegister, ExpiresAtToken)
 
-	resultVerificationLink := createTokenVerificationLink(c, models.UserIDEmail{
-		ID:    resultCreateUser.ID,
-		Email: reqBody.Email,
-	}, constants.StatusRegister, ExpiresAtToken)
+		if resultVerificationLink == nil {
+			return errVerificationLink
+		}
+
+		//* Send email
+		data := models.EmailData{
+			Template: constants.EmailTemplateRegister,
+			Locale:   emailLocale(c, ""),
+			Body:     resultVerificationLink.Link,
+		}
+
+		if err := enqueueEmail(c, tx.Outbox, resultCreateUser.ID, reqBody.Email, data); err != nil {
+			return err
+		}
 
-	//* Send email
-	data := models.EmailData{
-		Title:    "Register User!",
-		Body:     resultVerificationLink.Link,
-		Template: `<h1>{{.Title}}</h1>Verification account: <a href="{{.Body}}">Click here to verify your account</a> </br> <img src="cid:logo" alt="Image" height="200" />`,
+		return enqueueEvent(c, tx.Outbox, resultCreateUser.ID, constants.EventUserRegistered, models.UserRegisteredEvent{
+			UserID: resultCreateUser.ID,
+			Email:  reqBody.Email,
+		})
+	})
+
+	if err != nil {
+		respondTxError(c, err)
+		return nil
 	}
 
-	upsetDevice(c, resultCreateUser.ID, "")
+	s.upsetDevice(c, s.app.Repos.Devices, resultCreateUser.ID, "")
 
-	helpers.CreateUser(c, reqBody.Email, helpers.RandomPassword())
+	helpers.CreateUser(c, s.app.Firebase, reqBody.Email, helpers.RandomPassword())
 
-	go pkg.SendGoEmail(reqBody.Email, data)
+	s.recordUserAudit(c, resultCreateUser.ID, constants.AuditRegister, nil)
 
 	return &models.RegistrationResponse{
 		ID:             resultCreateUser.ID,
@@ -137,8 +163,9 @@ func Register(c *gin.Context) *models.RegistrationResponse {
 // It updates the verification status of the user to true and deactivates the verification token.
 // It updates the user's password with the new hashed password and hidden email.
 // It creates an access token, refetch token, and encodes the public key.
-// It updates the user's device information and sets a cookie with the refetch token.
-// It sends an email to the user with the new password.
+// The password history, the new password, the used verification link, the queued email with the new password
+// and the user's device are written in one transaction, so a failure leaves the account unverified.
+// It then sets a cookie with the refetch token.
 // Finally, it returns a LoginResponse object with the user's ID, device ID, email, and access token.
 // VerificationAccount is a function that handles the verification of user accounts.
 // It verifies the user's account based on the provided query parameters and updates the password.
@@ -161,14 +188,14 @@ func Register(c *gin.Context) *models.RegistrationResponse {
 // @Failure 401 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /auth/veri-account [get]
-func VerificationAccount(c *gin.Context) *models.LoginResponse {
+func (s *Service) VerificationAccount(c *gin.Context) *models.LoginResponse {
 	reqQuery := models.QueryVerificationRequest{}
 	if err := c.ShouldBindQuery(&reqQuery); err != nil {
 		response.BadRequestError(c, response.ErrCodeValidation)
 		return nil
 	}
 
-	GetVerification, err := repo.GetVerification(global.DB, models.QueryVerificationRequest{
+	GetVerification, err := s.app.Repos.Verifications.GetVerification(c, models.QueryVerificationRequest{
 		UserId: reqQuery.UserId,
 		Token:  reqQuery.Token,
 	})
@@ -188,7 +215,7 @@ func VerificationAccount(c *gin.Context) *models.LoginResponse {
 		return nil
 	}
 
-	randomPassword := helpers.GenerateRandomPassword(10)
+	randomPassword := helpers.GenerateRandomPassword(s.app.Cfg.Server.KeyPassword, 10)
 
 	salt, hashedPassword, err := helpers.HashPassword(randomPassword, bcrypt.DefaultCost)
 
@@ -197,62 +224,88 @@ func VerificationAccount(c *gin.Context) *models.LoginResponse {
 		return nil
 	}
 
-	errInsertHistoryPassword := repo.InsertPasswordHistory(global.DB, models.InsertPasswordHistoryParams{
-		UserID:       reqQuery.UserId,
-		OldPassword:  salt,
-		ReasonStatus: constants.Verification,
-	})
+	signIn := s.detectSignIn(c, reqQuery.UserId)
 
-	if errInsertHistoryPassword != nil {
-		response.InternalServerError(c, response.ErrCodeDBQuery)
-		return nil
-	}
+	var resultUpdateUser models.UpdateUserResponse
+	var resultInfoDevice *models.Device
+	var accessToken, refetchToken string
 
-	resultUpdateUser, errUpdatePassword := repo.UpdatePassword(global.DB, models.UpdatePasswordParams{
-		ID:           reqQuery.UserId,
-		PasswordHash: hashedPassword,
-		HiddenEmail:  helpers.HideEmail(reqQuery.Email),
-		IsActive:     true,
-	})
+	//* Password, verification, device and email are written in one transaction
+	err = s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		errInsertHistoryPassword := tx.PasswordHistory.InsertPasswordHistory(c, models.InsertPasswordHistoryParams{
+			UserID:       reqQuery.UserId,
+			OldPassword:  salt,
+			ReasonStatus: constants.Verification,
+		})
 
-	if errUpdatePassword != nil {
-		response.InternalServerError(c, response.ErrCodeDBQuery)
-		return nil
-	}
+		if errInsertHistoryPassword != nil {
+			respondDBError(c, errInsertHistoryPassword, response.ErrCodeDBQuery)
+			return errInsertHistoryPassword
+		}
 
-	err = repo.UpdateVerification(global.DB, models.UpdateVerificationParams{
-		UserID:     reqQuery.UserId,
-		IsVerified: true,
-		IsActive:   false,
-	})
+		var errUpdatePassword error
+		resultUpdateUser, errUpdatePassword = tx.Users.UpdatePassword(c, models.UpdatePasswordParams{
+			ID:           reqQuery.UserId,
+			PasswordHash: hashedPassword,
+			HiddenEmail:  helpers.HideEmail(reqQuery.Email),
+			IsActive:     true,
+		})
+
+		if errUpdatePassword != nil {
+			respondDBError(c, errUpdatePassword, response.ErrCodeDBQuery)
+			return errUpdatePassword
+		}
 
-	if err != nil {
-		response.InternalServerError(c, response.ErrCodeDBQuery)
-		return nil
-	}
+		errUpdateVerification := tx.Verifications.UpdateVerification(c, models.UpdateVerificationParams{
+			UserID:     reqQuery.UserId,
+			IsVerified: true,
+			IsActive:   false,
+		})
 
-	accessToken, refetchToken, resultEncodePublicKey := createKeyAndToken(models.UserIDEmail{
-		ID:    resultUpdateUser.Id,
-		Email: resultUpdateUser.Email,
+		if errUpdateVerification != nil {
+			respondDBError(c, errUpdateVerification, response.ErrCodeDBQuery)
+			return errUpdateVerification
+		}
+
+		//* Send email
+		data := models.EmailData{
+			Template: constants.EmailTemplateVerifySuccess,
+			Locale:   emailLocale(c, helpers.NullStringToString(resultUpdateUser.Locale)),
+			Body:     randomPassword,
+		}
+
+		if err := enqueueEmail(c, tx.Outbox, resultUpdateUser.Id, resultUpdateUser.Email, data); err != nil {
+			return err
+		}
+
+		var resultEncodePublicKey string
+		accessToken, refetchToken, resultEncodePublicKey = createKeyAndToken(models.UserIDEmail{
+			ID:    resultUpdateUser.Id,
+			Email: resultUpdateUser.Email,
+		})
+
+		if accessToken == "" || refetchToken == "" || resultEncodePublicKey == "" {
+			response.BadRequestError(c, response.ErrCodeAuthTokenInvalid)
+			return errDeviceNotSaved
+		}
+
+		resultInfoDevice = s.upsetDevice(c, tx.Devices, resultUpdateUser.Id, resultEncodePublicKey)
+		if resultInfoDevice == nil {
+			return errDeviceNotSaved
+		}
+		return nil
 	})
 
-	if accessToken == "" || refetchToken == "" || resultEncodePublicKey == "" {
-		response.BadRequestError(c, response.ErrCodeAuthTokenInvalid)
+	if err != nil {
+		respondTxError(c, err)
 		return nil
 	}
 
-	resultInfoDevice := upsetDevice(c, resultUpdateUser.Id, resultEncodePublicKey)
-
-	setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
+	s.setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
 
-	//* Send email
-	data := models.EmailData{
-		Title:    "Verification Account Success!",
-		Body:     randomPassword,
-		Template: `<h1>{{.Title}}</h1> <p style="font-size: large;">Thank You, You have verification account success 😊. <br/> Password New: <b>{{.Body}}</b></p>`,
-	}
+	s.recordUserAudit(c, resultUpdateUser.Id, constants.AuditAccountVerified, nil)
 
-	go pkg.SendGoEmail(resultUpdateUser.Email, data)
+	s.trackSignIn(c, models.UserIDEmail{ID: resultUpdateUser.Id, Email: resultUpdateUser.Email}, helpers.NullStringToString(resultUpdateUser.Locale), signIn)
 
 	return &models.LoginResponse{
 		ID:          resultUpdateUser.Id,
@@ -268,16 +321,21 @@ func VerificationAccount(c *gin.Context) *models.LoginResponse {
 // If the user is marked as spam, it returns a BadRequestError response.
 // Otherwise, it retrieves the request body and checks its validity.
 // If the request body is invalid, it returns a BadRequestError response.
-// Next, it identifies the type of identifier (email, phone, or username) and retrieves the user based on the identifier.
+// Next, it identifies the type of identifier (email, phone, or username), normalises phone numbers to E.164 and retrieves the user based on the identifier.
 // If no user is found, it returns a BadRequestError response.
 // It then checks if the user's account is blocked. If the account is blocked, it returns a ForbiddenError response.
 // The function compares the provided password with the user's password hash.
 // If the passwords do not match, it returns a BadRequestError response.
-// If two-factor authentication is enabled for the user, it sends an OTP (one-time password) to the user's email.
+// If two-factor authentication is enabled for the user, it sends an OTP (one-time password) to the user's email,
+// or by SMS to the verified phone number when SMS is the requested or preferred channel.
+// Users who turned on OTP for new devices get the same OTP step when the device or network has not signed in before.
+// Before that, the login is given a risk score (see assessLoginRisk): a medium score also requires the OTP step
+// and a high score blocks the login with a ForbiddenError response and alerts the user and the team.
 // If sending the OTP fails, it returns a BadRequestError response.
 // It creates an access token, a refetch token, and encodes the public key for the user.
 // If any of these values are empty, it returns a BadRequestError response.
 // The function updates the user's device information and sets a cookie for the user's login.
+// The sign-in is recorded, and a sign-in from a new device or network is notified by email with a link that revokes the device.
 // Finally, it returns a LoginResponse object containing the user's ID, device ID, email, and access token.
 //
 // @Summary Login with identifier
@@ -293,10 +351,11 @@ func VerificationAccount(c *gin.Context) *models.LoginResponse {
 // @Failure 403 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /auth/login-identifier [post]
-func LoginIdentifier(c *gin.Context) interface{} {
-	resultSpam := redis.SpamUser(c, global.Cache, constants.SpamKeyLogin, constants.RequestThreshold)
+func (s *Service) LoginIdentifier(c *gin.Context) interface{} {
+	resultSpam := redis.SpamUser(c, s.app.Cache, constants.SpamKeyLogin, constants.RequestThreshold)
 
 	if resultSpam.IsSpam {
+		metrics.SpamBlocks.WithLabelValues(constants.SpamActionLogin).Inc()
 		ttl := fmt.Sprintf("You are blocked for %d seconds", resultSpam.ExpiredSpam)
 		response.BadRequestError(c, response.ErrIpBlackList, ttl)
 		return nil
@@ -311,10 +370,17 @@ func LoginIdentifier(c *gin.Context) interface{} {
 		return nil
 	}
 
+	// Phone numbers are stored in E.164, so the same number typed in any format maps to one identifier
+	identifyType := helpers.IdentifyType(reqBody.Identifier, s.phoneRegion())
+	if identifyType == constants.Phone {
+		reqBody.Identifier, _ = helpers.NormalizePhone(reqBody.Identifier, s.phoneRegion())
+	}
+
 	// Check user exit into cuckoo filter
-	exists, _ := redis.GetUserToCuckooFilter(c, global.Cache, reqBody.Identifier)
+	exists, _ := redis.GetUserToCuckooFilter(c, s.app.Cache, reqBody.Identifier)
 
 	if exists {
+		metrics.CuckooShortCircuits.WithLabelValues(constants.SpamActionLogin).Inc()
 		response.BadRequestError(c, response.ErrUserNotExit)
 		return nil
 	}
@@ -322,13 +388,13 @@ func LoginIdentifier(c *gin.Context) interface{} {
 	var resultUser *models.User
 	var err error
 
-	switch helpers.IdentifyType(reqBody.Identifier) {
+	switch identifyType {
 	case constants.Email:
-		resultUser, err = fetchUserByEmail(c, reqBody.Identifier)
+		resultUser, err = s.fetchUserByEmail(c, reqBody.Identifier)
 	case constants.Phone:
-		resultUser, err = fetchUserByPhone(c, reqBody.Identifier)
+		resultUser, err = s.fetchUserByPhone(c, reqBody.Identifier)
 	case constants.Username:
-		resultUser, err = fetchUserByUsername(c, reqBody.Identifier)
+		resultUser, err = s.fetchUserByUsername(c, reqBody.Identifier)
 	default:
 		response.BadRequestError(c, response.ErrUserNotExit)
 		return nil
@@ -336,50 +402,55 @@ func LoginIdentifier(c *gin.Context) interface{} {
 
 	if err != nil {
 		expiration := 2 * 24 * time.Hour
-		redis.AddUserToCuckooFilter(c, global.Cache, reqBody.Identifier, expiration)
+		redis.AddUserToCuckooFilter(c, s.app.Cache, reqBody.Identifier, expiration)
 		return nil
 	}
 
 	// Check account has been blocked
 	accountBlock := CheckUserIsActive(resultUser.IsActive)
 	if accountBlock == nil {
-		response.ForbiddenError(c, response.ErrUserNotActive)
+		s.recordAudit(c, models.AuditEntry{
+			SubjectID: resultUser.ID,
+			EventType: constants.AuditLoginFailed,
+			Metadata:  map[string]interface{}{"reason": "account_inactive"},
+		})
+		s.inactiveUserError(c, resultUser.ID)
 		return nil
 	}
 
 	errPassword := helpers.ComparePassword(reqBody.Password, resultUser.PasswordHash.String)
 	if errPassword != nil {
+		// The failed passwords of the account are counted like the login attempts, for the risk score
+		redis.SpamUser(c, s.app.Cache, fmt.Sprintf(constants.SpamKeyLoginFailed, resultUser.ID), constants.RequestThreshold)
+		s.recordAudit(c, models.AuditEntry{
+			SubjectID: resultUser.ID,
+			EventType: constants.AuditLoginFailed,
+			Metadata:  map[string]interface{}{"reason": "wrong_password"},
+		})
 		response.BadRequestError(c, response.ErrorPasswordNotMatch)
 		return nil
 	}
 
-	expiredAt := time.Now().Add(time.Minute * 5)
-	if resultUser.TwoFactorEnabled {
-		resultOTP := SendOtp(c, resultUser.ID, expiredAt)
-
-		if resultOTP == nil {
-			response.BadRequestError(c, response.ErrorOTPNotExit)
-			return nil
-		}
-
-		data := models.EmailData{
-			Title:    "OTP Login!",
-			Body:     resultOTP.Code,
-			Template: `<h1>{{.Title}}</h1> <p style="font-size: large;">Thank You, this is code of you 😊. <br/> OTP: <b>{{.Body}}</b></p>`,
-		}
+	signIn := s.detectSignIn(c, resultUser.ID)
 
-		go pkg.SendGoEmail(resultUser.Email, data)
+	risk := s.assessLoginRisk(c, resultUser.ID, signIn)
+	if risk.Decision == constants.RiskDecisionBlock {
+		s.alertBlockedLogin(c, resultUser, signIn, risk)
+		response.ForbiddenError(c, response.ErrCodeLoginRiskBlocked)
+		return nil
+	}
 
-		// Return empty struct for two-factor authentication
-		deviceID, _ := c.Get("device_id")
+	// A sign-in from an unknown device or network needs an OTP when the user asked for it, even without 2FA,
+	// and so does a sign-in with a medium risk score
+	newDeviceOtp := !resultUser.TwoFactorEnabled && resultUser.OtpNewDevice && signIn.Unknown()
+	riskOtp := !resultUser.TwoFactorEnabled && risk.Decision == constants.RiskDecisionStepUp
 
-		return models.LoginTwoFactor{
-			ID:        resultUser.ID,
-			Email:     resultUser.Email,
-			DeviceID:  deviceID.(string),
-			Code:      response.ErrTwoFactorEnabled,
-			ExpiredAt: expiredAt,
+	if resultUser.TwoFactorEnabled || newDeviceOtp || riskOtp {
+		twoFactor := s.sendLoginOtp(c, resultUser, reqBody.Channel, newDeviceOtp, riskOtp)
+		if twoFactor == nil {
+			return nil
 		}
+		return *twoFactor
 	}
 
 	accessToken, refetchToken, resultEncodePublicKey := createKeyAndToken(models.UserIDEmail{
@@ -392,9 +463,15 @@ func LoginIdentifier(c *gin.Context) interface{} {
 		return nil
 	}
 
-	resultInfoDevice := upsetDevice(c, resultUser.ID, resultEncodePublicKey)
+	resultInfoDevice := s.upsetDevice(c, s.app.Repos.Devices, resultUser.ID, resultEncodePublicKey)
+
+	s.setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
 
-	setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
+	redis.DeleteKeyUser(c, s.app.Cache, fmt.Sprintf(constants.SpamKeyLoginFailed, resultUser.ID))
+
+	s.recordUserAudit(c, resultUser.ID, constants.AuditLoginSucceeded, map[string]interface{}{"identifier_type": identifyType})
+
+	s.trackSignIn(c, models.UserIDEmail{ID: resultUser.ID, Email: resultUser.Email}, helpers.NullStringToString(resultUser.Locale), signIn)
 
 	// Return LoginResponse when not using two-factor authentication
 	return &models.LoginResponse{
@@ -405,12 +482,106 @@ func LoginIdentifier(c *gin.Context) interface{} {
 	}
 }
 
+// sendLoginOtp sends the OTP of a login that needs a second step, by email or by SMS to the verified phone number
+// when SMS is the requested or preferred channel, and returns the response that asks for it with the challenge
+// the client sends back with the OTP.
+// NewDevice and risk tell the client why the OTP is needed when two-factor authentication is off.
+// It responds with an error and returns nil when the OTP could not be sent.
+func (s *Service) sendLoginOtp(c *gin.Context, resultUser *models.User, requestedChannel int, newDevice bool, risk bool) *models.LoginTwoFactor {
+	expiredAt := time.Now().Add(constants.LoginOtpTTL)
+
+	channel, errChannel := resolveOtpChannel(resultUser, requestedChannel)
+	if errChannel != 0 {
+		response.BadRequestError(c, errChannel)
+		return nil
+	}
+
+	//* The OTP is only accepted with this challenge, which binds it to the user and counts the attempts
+	challenge, err := helpers.GenerateToken()
+	if err != nil {
+		response.InternalServerError(c, response.ErrCodeInternalServer)
+		return nil
+	}
+	if err := redis.CreateOtpChallenge(c, s.app.Cache, fmt.Sprintf(constants.OtpChallengeKey, challenge), resultUser.ID, constants.LoginOtpTTL); err != nil {
+		slog.ErrorContext(c, "Failed to save OTP challenge", "error", err)
+		response.InternalServerError(c, response.ErrCodeCacheQuery)
+		return nil
+	}
+
+	if channel == constants.OtpChannelSMS {
+		resultOTP := s.SendOtp(c, s.app.Repos.OTPs, models.CreateOtpParams{
+			UserID:    resultUser.ID,
+			Channel:   channel,
+			Purpose:   constants.OtpPurposeLogin,
+			Phone:     resultUser.Phone,
+			ExpiresAt: expiredAt,
+		})
+
+		if resultOTP == nil {
+			response.BadRequestError(c, response.ErrorOTPNotExit)
+			return nil
+		}
+
+		if err := s.sendOtpSMS(resultUser.Phone.String, "OTP Login!", resultOTP.Code); err != nil {
+			slog.ErrorContext(c, "Failed to send OTP SMS", "error", err)
+			response.InternalServerError(c, response.ErrCodeExternalService)
+			return nil
+		}
+	} else {
+		//* OTP and email are written in one transaction
+		err := s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+			resultOTP := s.SendOtp(c, tx.OTPs, models.CreateOtpParams{
+				UserID:    resultUser.ID,
+				Channel:   channel,
+				Purpose:   constants.OtpPurposeLogin,
+				ExpiresAt: expiredAt,
+			})
+
+			if resultOTP == nil {
+				response.BadRequestError(c, response.ErrorOTPNotExit)
+				return errOtpNotCreated
+			}
+
+			data := models.EmailData{
+				Template: constants.EmailTemplateOtpLogin,
+				Locale:   emailLocale(c, helpers.NullStringToString(resultUser.Locale)),
+				Body:     resultOTP.Code,
+			}
+
+			return enqueueEmail(c, tx.Outbox, resultUser.ID, resultUser.Email, data)
+		})
+
+		if err != nil {
+			respondTxError(c, err)
+			return nil
+		}
+	}
+
+	s.recordUserAudit(c, resultUser.ID, constants.AuditOtpSent, map[string]interface{}{"channel": channel, "purpose": "login"})
+	metrics.OtpSent.WithLabelValues(otpChannelName(channel), constants.OtpPurposeLogin).Inc()
+
+	// Return empty struct for two-factor authentication
+	deviceID, _ := c.Get("device_id")
+
+	return &models.LoginTwoFactor{
+		ID:        resultUser.ID,
+		Email:     resultUser.Email,
+		DeviceID:  deviceID.(string),
+		Code:      response.ErrTwoFactorEnabled,
+		Channel:   channel,
+		Challenge: challenge,
+		ExpiredAt: expiredAt,
+		NewDevice: newDevice,
+		Risk:      risk,
+	}
+}
+
 // fetchUserByEmail fetches a user from the database based on the provided email.
 // It returns the user if found, otherwise returns an error.
-func fetchUserByEmail(c *gin.Context, email string) (*models.User, error) {
-	users, err := repo.JoinUsersWithVerificationByEmail(global.DB, email)
+func (s *Service) fetchUserByEmail(c *gin.Context, email string) (*models.User, error) {
+	users, err := s.app.Repos.Users.JoinUsersWithVerificationByEmail(c, email)
 	if err != nil {
-		response.InternalServerError(c, response.ErrCodeDBQuery)
+		respondDBError(c, err, response.ErrCodeDBQuery)
 		return nil, err
 	}
 	if len(users) == 0 {
@@ -421,12 +592,12 @@ func fetchUserByEmail(c *gin.Context, email string) (*models.User, error) {
 }
 
 // fetchUserByPhone fetches a user by their phone number.
-// It queries the database to find a user with the specified phone number.
+// It queries the database to find a user with the specified phone number, which must already be normalised to E.164.
 // If the user is found, it returns the user object. Otherwise, it returns an error.
-func fetchUserByPhone(c *gin.Context, phone string) (*models.User, error) {
-	users, err := repo.JoinUsersWithVerificationByPhone(global.DB, phone)
+func (s *Service) fetchUserByPhone(c *gin.Context, phone string) (*models.User, error) {
+	users, err := s.app.Repos.Users.JoinUsersWithVerificationByPhone(c, phone)
 	if err != nil {
-		response.InternalServerError(c, response.ErrCodeDBQuery)
+		respondDBError(c, err, response.ErrCodeDBQuery)
 		return nil, err
 	}
 	if len(users) == 0 {
@@ -438,10 +609,10 @@ func fetchUserByPhone(c *gin.Context, phone string) (*models.User, error) {
 
 // fetchUserByUsername fetches a user from the database by their username.
 // It returns the user if found, otherwise returns an error.
-func fetchUserByUsername(c *gin.Context, username string) (*models.User, error) {
-	users, err := repo.JoinUsersWithVerificationByUsername(global.DB, username)
+func (s *Service) fetchUserByUsername(c *gin.Context, username string) (*models.User, error) {
+	users, err := s.app.Repos.Users.JoinUsersWithVerificationByUsername(c, username)
 	if err != nil {
-		response.InternalServerError(c, response.ErrCodeDBQuery)
+		respondDBError(c, err, response.ErrCodeDBQuery)
 		return nil, err
 	}
 	if len(users) == 0 {
@@ -478,7 +649,7 @@ func fetchUserByUsername(c *gin.Context, username string) (*models.User, error)
 // @Failure 403 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /auth/resend-link-verification [post]
-func ResendVerificationLink(c *gin.Context) *models.RegistrationResponse {
+func (s *Service) ResendVerificationLink(c *gin.Context) *models.RegistrationResponse {
 	//* Get data for body
 	reqBody := models.BodyRegisterRequest{}
 
@@ -488,23 +659,24 @@ func ResendVerificationLink(c *gin.Context) *models.RegistrationResponse {
 		return nil
 	}
 	// * Check UserSpam
-	resultSpam := redis.SpamUser(c, global.Cache, constants.SpamKeyLinkVerification, constants.RequestThresholdLinkVerification)
+	resultSpam := redis.SpamUser(c, s.app.Cache, constants.SpamKeyLinkVerification, constants.RequestThresholdLinkVerification)
 
 	if resultSpam.IsSpam {
+		metrics.SpamBlocks.WithLabelValues(constants.SpamActionLinkVerification).Inc()
 		ttl := fmt.Sprintf("You are blocked for %d seconds", resultSpam.ExpiredSpam)
 		response.BadRequestError(c, response.ErrIpBlackList, ttl)
 		return nil
 	}
 
 	//* Get detail users
-	resultDetailUser, err := repo.GetUserDetail(global.DB, reqBody.Email)
+	resultDetailUser, err := s.app.Repos.Users.GetUserDetail(c, reqBody.Email)
 
 	// * Check account exit into yet
 	if err != nil {
 		errorDetailUser := utils.HandleDBError(err)
 		//* Error for database
 		if errorDetailUser != "" {
-			response.InternalServerError(c, response.ErrCodeDBQuery)
+			respondDBError(c, err, response.ErrCodeDBQuery)
 			return nil
 		}
 		response.InternalServerError(c, response.ErrUserNotExit)
@@ -512,10 +684,10 @@ func ResendVerificationLink(c *gin.Context) *models.RegistrationResponse {
 	}
 
 	//* Count user had send verification
-	count, err := repo.GetVerificationByUserId(global.DB, resultDetailUser.ID)
+	count, err := s.app.Repos.Verifications.GetVerificationByUserId(c, resultDetailUser.ID)
 
 	if err != nil {
-		response.InternalServerError(c, response.ErrCodeDBQuery)
+		respondDBError(c, err, response.ErrCodeDBQuery)
 		return nil
 	}
 
@@ -531,21 +703,46 @@ func ResendVerificationLink(c *gin.Context) *models.RegistrationResponse {
 		return nil
 	}
 
-	resultVerificationLink := createTokenVerificationLink(c, models.UserIDEmail{
-		ID:    resultDetailUser.ID,
-		Email: reqBody.Email,
-	}, constants.StatusResend, time.Now().Add(24*time.Hour))
-
-	//* Send email
-	data := models.EmailData{
-		Title:    "Resend Verification User!",
-		Body:     resultVerificationLink.Link,
-		Template: `<h1>{{.Title}}</h1>Verification account: <a href="{{.Body}}">Click here to verify your account</a> </br> <img src="cid:logo" alt="Image" height="200" />`,
+	//* A verification link would reactivate an account waiting for deletion
+	pendingDeletion, err := s.app.Repos.Deletions.IsAccountPendingDeletion(c, resultDetailUser.ID)
+	if err != nil {
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+	if pendingDeletion {
+		response.BadRequestError(c, response.ErrorAccountPendingDeletion)
+		return nil
 	}
 
-	upsetDevice(c, resultDetailUser.ID, "")
+	var resultVerificationLink *models.TokenVerificationLink
+
+	//* Verification link and email are written in one transaction
+	err = s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		resultVerificationLink = s.createTokenVerificationLink(c, tx.Verifications, models.UserIDEmail{
+			ID:    resultDetailUser.ID,
+			Email: reqBody.Email,
+		}, constants.StatusResend, time.Now().Add(24*time.Hour))
 
-	go pkg.SendGoEmail(reqBody.Email, data)
+		if resultVerificationLink == nil {
+			return errVerificationLink
+		}
+
+		//* Send email
+		data := models.EmailData{
+			Template: constants.EmailTemplateResendVerification,
+			Locale:   emailLocale(c, helpers.NullStringToString(resultDetailUser.Locale)),
+			Body:     resultVerificationLink.Link,
+		}
+
+		return enqueueEmail(c, tx.Outbox, resultDetailUser.ID, reqBody.Email, data)
+	})
+
+	if err != nil {
+		respondTxError(c, err)
+		return nil
+	}
+
+	s.upsetDevice(c, s.app.Repos.Devices, resultDetailUser.ID, "")
 
 	return &models.RegistrationResponse{
 		ID:    resultDetailUser.ID,
@@ -588,10 +785,11 @@ func ResendVerificationLink(c *gin.Context) *models.RegistrationResponse {
 // Returns:
 // - A pointer to a ForgetResponse struct containing the user ID, email, token, and token expiration time.
 // - If an error occurs, it responds with the appropriate HTTP error and returns nil.
-func ForgetPassword(c *gin.Context) *models.ForgetResponse {
-	resultSpam := redis.SpamUser(c, global.Cache, constants.SpamKeyForget, constants.RequestThresholdForget)
+func (s *Service) ForgetPassword(c *gin.Context) *models.ForgetResponse {
+	resultSpam := redis.SpamUser(c, s.app.Cache, constants.SpamKeyForget, constants.RequestThresholdForget)
 
 	if resultSpam.IsSpam {
+		metrics.SpamBlocks.WithLabelValues(constants.SpamActionForget).Inc()
 		ttl := fmt.Sprintf("You are blocked for %d seconds:", resultSpam.ExpiredSpam)
 		response.BadRequestError(c, response.ErrIpBlackList, ttl)
 		return nil
@@ -605,20 +803,21 @@ func ForgetPassword(c *gin.Context) *models.ForgetResponse {
 	}
 
 	// Check user exit into cuckoo filter
-	exists, _ := redis.GetUserToCuckooFilter(c, global.Cache, reqBody.Email)
+	exists, _ := redis.GetUserToCuckooFilter(c, s.app.Cache, reqBody.Email)
 
 	if exists {
+		metrics.CuckooShortCircuits.WithLabelValues(constants.SpamActionForget).Inc()
 		response.BadRequestError(c, response.ErrUserNotExit)
 		return nil
 	}
 
-	resultDetailUser, err := repo.GetUserDetail(global.DB, reqBody.Email)
+	resultDetailUser, err := s.app.Repos.Users.GetUserDetail(c, reqBody.Email)
 
 	if err != nil {
 		errorDetailUser := utils.HandleDBError(err)
 		//* Error for database
 		if errorDetailUser != "" {
-			response.InternalServerError(c, response.ErrCodeDBQuery)
+			respondDBError(c, err, response.ErrCodeDBQuery)
 			return nil
 		}
 		response.BadRequestError(c, response.ErrUserNotExit)
@@ -632,19 +831,36 @@ func ForgetPassword(c *gin.Context) *models.ForgetResponse {
 	}
 
 	ExpiresAtToken := time.Now().Add(15 * time.Minute)
-	resultForgetLink := createTokenVerificationLink(c, models.UserIDEmail{
-		ID:    resultDetailUser.ID,
-		Email: reqBody.Email,
-	}, constants.StatusForget, ExpiresAtToken)
 
-	//* Send email
-	data := models.EmailData{
-		Title:    "Forget Password!",
-		Body:     resultForgetLink.Link,
-		Template: `<h1>{{.Title}}</h1>Forget account: <a href="{{.Body}}">Click here to reset password your account</a> </br> <img src="cid:logo" alt="Image" height="200" />`,
+	var resultForgetLink *models.TokenVerificationLink
+
+	//* Reset link and email are written in one transaction
+	err = s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		resultForgetLink = s.createTokenVerificationLink(c, tx.Verifications, models.UserIDEmail{
+			ID:    resultDetailUser.ID,
+			Email: reqBody.Email,
+		}, constants.StatusForget, ExpiresAtToken)
+
+		if resultForgetLink == nil {
+			return errVerificationLink
+		}
+
+		//* Send email
+		data := models.EmailData{
+			Template: constants.EmailTemplateForgetPassword,
+			Locale:   emailLocale(c, helpers.NullStringToString(resultDetailUser.Locale)),
+			Body:     resultForgetLink.Link,
+		}
+
+		return enqueueEmail(c, tx.Outbox, resultDetailUser.ID, reqBody.Email, data)
+	})
+
+	if err != nil {
+		respondTxError(c, err)
+		return nil
 	}
 
-	go pkg.SendGoEmail(reqBody.Email, data)
+	s.recordUserAudit(c, resultDetailUser.ID, constants.AuditPasswordResetRequested, nil)
 
 	return &models.ForgetResponse{
 		Id:        resultDetailUser.ID,
@@ -667,9 +883,9 @@ func ForgetPassword(c *gin.Context) *models.ForgetResponse {
 // If the password is weak, it returns a BadRequestError response with a custom message.
 // It checks if the password has been used before using the checkPasswordOld function.
 // If the password has been used before, it returns a BadRequestError response with a custom message.
-// It inserts the old password into the password history table using the InsertPasswordHistory function.
-// It updates the user's password in the database using the UpdateOnlyPassword function.
-// Finally, it updates the verification status in the database using the UpdateVerification function.
+// In one transaction, it inserts the old password into the password history table using the InsertPasswordHistory function,
+// updates the user's password using the UpdateOnlyPassword function and uses up the link using the UpdateVerification function.
+// If any of these writes fails, nothing is changed and it returns an InternalServerError response.
 // The function returns a ResetPasswordResponse object with the user's ID.
 //
 // @Summary Reset password
@@ -684,7 +900,7 @@ func ForgetPassword(c *gin.Context) *models.ForgetResponse {
 // @Failure 403 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /auth/reset-password [post]
-func ResetPassword(c *gin.Context) *models.ResetPasswordResponse {
+func (s *Service) ResetPassword(c *gin.Context) *models.ResetPasswordResponse {
 	reqBody := models.BodyResetPasswordRequest{}
 
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
@@ -692,7 +908,7 @@ func ResetPassword(c *gin.Context) *models.ResetPasswordResponse {
 		return nil
 	}
 
-	GetVerification, err := repo.GetVerification(global.DB, models.QueryVerificationRequest{
+	GetVerification, err := s.app.Repos.Verifications.GetVerification(c, models.QueryVerificationRequest{
 		UserId: reqBody.UserId,
 		Token:  reqBody.Token,
 	})
@@ -717,30 +933,50 @@ func ResetPassword(c *gin.Context) *models.ResetPasswordResponse {
 		return nil
 	}
 
-	hashedPassword := checkPasswordOld(reqBody.Password, reqBody.UserId)
+	hashedPassword := s.checkPasswordOld(c, reqBody.Password, reqBody.UserId)
 
 	if hashedPassword == nil {
 		response.BadRequestError(c, response.ErrorPasswordNotMatch)
 		return nil
 	}
 
-	repo.InsertPasswordHistory(global.DB, models.InsertPasswordHistoryParams{
-		UserID:       reqBody.UserId,
-		OldPassword:  hashedPassword.Salt,
-		ReasonStatus: constants.ResetPassword,
-	})
+	//* Password history, password and verification are written in one transaction
+	err = s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		if err := tx.PasswordHistory.InsertPasswordHistory(c, models.InsertPasswordHistoryParams{
+			UserID:       reqBody.UserId,
+			OldPassword:  hashedPassword.Salt,
+			ReasonStatus: constants.ResetPassword,
+		}); err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
 
-	repo.UpdateOnlyPassword(global.DB, models.UpdateOnlyPasswordParams{
-		ID:           reqBody.UserId,
-		PasswordHash: hashedPassword.HashedPassword,
-	})
+		if err := tx.Users.UpdateOnlyPassword(c, models.UpdateOnlyPasswordParams{
+			ID:           reqBody.UserId,
+			PasswordHash: hashedPassword.HashedPassword,
+		}); err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
 
-	repo.UpdateVerification(global.DB, models.UpdateVerificationParams{
-		UserID:     reqBody.UserId,
-		IsVerified: true,
-		IsActive:   false,
+		if err := tx.Verifications.UpdateVerification(c, models.UpdateVerificationParams{
+			UserID:     reqBody.UserId,
+			IsVerified: true,
+			IsActive:   false,
+		}); err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
+		return nil
 	})
 
+	if err != nil {
+		respondTxError(c, err)
+		return nil
+	}
+
+	s.recordUserAudit(c, reqBody.UserId, constants.AuditPasswordReset, nil)
+
 	return &models.ResetPasswordResponse{
 		Id: reqBody.UserId,
 	}
@@ -761,7 +997,7 @@ func ResetPassword(c *gin.Context) *models.ResetPasswordResponse {
 // @Success 200 {object} models.LoginResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Router /auth/renew-token [get]
-func RenewToken(c *gin.Context) *models.LoginResponse {
+func (s *Service) RenewToken(c *gin.Context) *models.LoginResponse {
 	resultRefetch, exists := c.Get(constants.InfoRefetch)
 
 	if !exists || resultRefetch == nil || resultRefetch == "" {
@@ -782,9 +1018,9 @@ func RenewToken(c *gin.Context) *models.LoginResponse {
 		return nil
 	}
 
-	resultInfoDevice := upsetDevice(c, payloadRefetch.ID, resultEncodePublicKey)
+	resultInfoDevice := s.upsetDevice(c, s.app.Repos.Devices, payloadRefetch.ID, resultEncodePublicKey)
 
-	setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
+	s.setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
 
 	return &models.LoginResponse{
 		ID:          payloadRefetch.ID,
@@ -832,10 +1068,10 @@ func createKeyAndToken(resultUser models.UserIDEmail) (string, string, string) {
 // The domain is determined based on the environment and the request's host.
 // The secure flag is set based on whether the environment is not the development environment.
 // The httpOnly flag is set to true if the environment is not the development environment.
-func setCookie(c *gin.Context, name string, value string, path string, maxAge int) {
+func (s *Service) setCookie(c *gin.Context, name string, value string, path string, maxAge int) {
 	// Set up environment-related variables
 	nodeEnv := os.Getenv("ENV")
-	domain := global.Cfg.Server.Host
+	domain := s.app.Cfg.Server.Host
 	secure := nodeEnv != constants.DevEnvironment
 	httpOnly := false
 
@@ -843,7 +1079,7 @@ func setCookie(c *gin.Context, name string, value string, path string, maxAge in
 		// hostWithPort := c.Request.Host
 		// parts := strings.Split(hostWithPort, ":")
 		// domain = parts[0]
-		domain = global.Cfg.Server.Host
+		domain = s.app.Cfg.Server.Host
 		secure = false
 		httpOnly = false
 	}
@@ -864,12 +1100,12 @@ func setCookie(c *gin.Context, name string, value string, path string, maxAge in
 }
 
 // upsetDevice updates or inserts a new device record in the database for the given user.
-// It takes a gin.Context, user ID, and encoded public key as input parameters.
+// It takes a gin.Context, the device repository, which may be bound to a transaction, user ID, and encoded public key as input parameters.
 // It returns a pointer to the updated device information if successful, otherwise it returns nil.
-func upsetDevice(c *gin.Context, id int, resultEncodePublicKey string) *models.Device {
+func (s *Service) upsetDevice(c *gin.Context, devices repo.DeviceRepository, id int, resultEncodePublicKey string) *models.Device {
 	deviceIDInterface, exists := c.Get("device_id")
 	if !exists {
-		log.Print("device_id not found in context")
+		slog.WarnContext(c, "device_id not found in context")
 		response.BadRequestError(c, response.ErrCodeValidation)
 		return nil
 	}
@@ -887,7 +1123,7 @@ func upsetDevice(c *gin.Context, id int, resultEncodePublicKey string) *models.D
 		publicKey = resultEncodePublicKey
 	}
 
-	resultInfoDevice, err := repo.UpsetDevice(global.DB, models.UpsetDeviceParams{
+	resultInfoDevice, err := devices.UpsetDevice(c, models.UpsetDeviceParams{
 		UserID:     id,
 		DeviceID:   deviceID,
 		DeviceType: c.Request.UserAgent(),
@@ -896,7 +1132,7 @@ func upsetDevice(c *gin.Context, id int, resultEncodePublicKey string) *models.D
 	})
 
 	if err != nil {
-		log.Print("Error in UpsetDevice:", err)
+		slog.ErrorContext(c, "Failed to upsert device", "error", err)
 		response.BadRequestError(c, response.ErrCodeDBQuery)
 		return nil
 	}
@@ -908,7 +1144,7 @@ func upsetDevice(c *gin.Context, id int, resultEncodePublicKey string) *models.D
 // and returns a TokenVerificationLink containing the token and the verification link.
 // If any error occurs during token generation or database operations, it returns nil.
 // The function takes a gin.Context and a user models.UserIDEmail as parameters.
-func createTokenVerificationLink(c *gin.Context, user models.UserIDEmail, status int, expiresToken time.Time) *models.TokenVerificationLink {
+func (s *Service) createTokenVerificationLink(c *gin.Context, verifications repo.VerificationRepository, user models.UserIDEmail, status int, expiresToken time.Time) *models.TokenVerificationLink {
 	//* Random Token for user verification
 	token, err := helpers.GenerateToken()
 	ExpiresAtTokenUnix := expiresToken.Unix()
@@ -921,9 +1157,9 @@ func createTokenVerificationLink(c *gin.Context, user models.UserIDEmail, status
 	//* Link token with user
 	var linkVerification string
 	if status == constants.StatusRegister || status == constants.StatusResend {
-		linkVerification = fmt.Sprintf("%s/auth/verify/account/%s/%s/%s/%s", global.Cfg.Server.PortFrontend, user.Email, strconv.FormatInt(ExpiresAtTokenUnix, 10), strconv.Itoa(user.ID), token)
+		linkVerification = fmt.Sprintf("%s/auth/verify/account/%s/%s/%s/%s", s.app.Cfg.Server.PortFrontend, user.Email, strconv.FormatInt(ExpiresAtTokenUnix, 10), strconv.Itoa(user.ID), token)
 	} else {
-		linkVerification = fmt.Sprintf("%s/auth/reset/password/%s/%s/%s", global.Cfg.Server.PortFrontend, strconv.FormatInt(ExpiresAtTokenUnix, 10), strconv.Itoa(user.ID), token)
+		linkVerification = fmt.Sprintf("%s/auth/reset/password/%s/%s/%s", s.app.Cfg.Server.PortFrontend, strconv.FormatInt(ExpiresAtTokenUnix, 10), strconv.Itoa(user.ID), token)
 	}
 
 	verification := models.BodyVerificationRequest{
@@ -932,7 +1168,7 @@ func createTokenVerificationLink(c *gin.Context, user models.UserIDEmail, status
 		ExpiresAt:     expiresToken,
 	}
 
-	_, err = repo.CreateVerification(global.DB, verification)
+	_, err = verifications.CreateVerification(c, verification)
 
 	if err != nil {
 		//* Error for database
@@ -966,9 +1202,8 @@ func CheckUserIsActive(isActive bool) *bool {
 // It also checks if the password has been used previously by the user.
 // If the password is valid and not found in the previous passwords, it returns the salt and hashed password.
 // If an error occurs during the process, it returns nil.
-func checkPasswordOld(password string, userId int) *models.CheckPreviousResponse {
-	limitPassword := 10
-	resultPasswordOld, err := repo.CheckPreviousPasswords(global.DB, userId, limitPassword)
+func (s *Service) checkPasswordOld(c *gin.Context, password string, userId int) *models.CheckPreviousResponse {
+	resultPasswordOld, err := s.app.Repos.PasswordHistory.CheckPreviousPasswords(c, userId, constants.PasswordHistoryDepth)
 
 	if err != nil {
 		salt, hashedPassword, err := helpers.HashPassword(password, bcrypt.DefaultCost)
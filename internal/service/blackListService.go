@@ -1,10 +1,9 @@
 package service
 
 import (
-	"log"
+	"log/slog"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
@@ -25,7 +24,7 @@ import (
 // @Success 200 {object} models.BodyIpRequest
 // @Failure 400 {object} response.ErrorResponse
 // @Router /blacklist/ip [post]
-func BlackListIP(c *gin.Context) *models.BodyIpRequest {
+func (s *Service) BlackListIP(c *gin.Context) *models.BodyIpRequest {
 	var reqBody models.BodyIpRequest
 
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
@@ -36,9 +35,9 @@ func BlackListIP(c *gin.Context) *models.BodyIpRequest {
 	if len(reqBody.IP) > 0 {
 		// Add IP addresses to the blacklist
 		for _, ip := range reqBody.IP {
-			err := global.Cache.SAdd(c, constants.BlackListIP, ip).Err()
+			err := s.app.Cache.SAdd(c, constants.BlackListIP, ip).Err()
 			if err != nil {
-				log.Println("Failed to add IP to blacklist:", err)
+				slog.ErrorContext(c, "Failed to add IP to blacklist", "ip", ip, "error", err)
 				response.InternalServerError(c, response.ErrCodeCacheQuery)
 				return nil
 			}
@@ -49,5 +48,15 @@ func BlackListIP(c *gin.Context) *models.BodyIpRequest {
 		return nil
 	}
 
+	actorId := 0
+	if payload, exists := c.Get(constants.InfoAccess); exists {
+		actorId = payload.(models.Payload).ID
+	}
+	s.recordAudit(c, models.AuditEntry{
+		ActorID:   actorId,
+		EventType: constants.AuditBlacklistIP,
+		Metadata:  map[string]interface{}{"ips": reqBody.IP},
+	})
+
 	return &reqBody
 }
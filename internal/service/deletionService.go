@@ -0,0 +1,77 @@
+package service
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// CancelAccountDeletion restores an account deleted less than the grace period ago,
+// using the link from the "account deletion" email. The user signs in again afterwards.
+//
+// Swagger documentation for CancelAccountDeletion function
+// @Summary Cancel account deletion
+// @Description Restores an account whose deletion is still in its grace period
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body models.BodyCancelDeletionRequest true "Cancel token from the email"
+// @Success 200 {object} models.CancelDeletionResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /auth/cancel-deletion [post]
+func (s *Service) CancelAccountDeletion(c *gin.Context) *models.CancelDeletionResponse {
+	reqBody := models.BodyCancelDeletionRequest{}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		response.BadRequestError(c, response.ErrCodeValidation)
+		return nil
+	}
+
+	restored, err := s.app.Repos.Deletions.CancelAccountDeletion(c, reqBody.Token)
+	if err == sql.ErrNoRows {
+		response.BadRequestError(c, response.ErrorDeletionTokenInvalid)
+		return nil
+	}
+	if err != nil {
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+
+	s.recordAudit(c, models.AuditEntry{
+		SubjectID: restored.Id,
+		EventType: constants.AuditDeletionCancelled,
+	})
+
+	return &models.CancelDeletionResponse{
+		Id:    restored.Id,
+		Email: restored.Email,
+	}
+}
+
+// inactiveUserError responds to a login of an inactive account:
+// with ErrorAccountPendingDeletion when the account is in the grace period of a deletion,
+// so the user knows it can still be restored, and with ErrUserNotActive otherwise.
+func (s *Service) inactiveUserError(c *gin.Context, userId int) {
+	pending, err := s.app.Repos.Deletions.IsAccountPendingDeletion(c, userId)
+	if err != nil {
+		slog.ErrorContext(c, "Failed to check pending deletion", "user_id", userId, "error", err)
+	}
+	if pending {
+		response.ForbiddenError(c, response.ErrorAccountPendingDeletion)
+		return
+	}
+	response.ForbiddenError(c, response.ErrUserNotActive)
+}
+
+// deletionGracePeriod returns how long a deleted account can be restored.
+func (s *Service) deletionGracePeriod() time.Duration {
+	if days := s.app.Cfg.Account.DeletionGraceDays; days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return constants.DefaultDeletionGracePeriod
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestDataExport queues an export of everything held about the user (GDPR access request).
+// The export is built by the queue consumer, which emails a download link when the archive is ready.
+// A user can only have one export in progress at a time.
+//
+// Swagger documentation for RequestDataExport function
+// @Summary Request data export
+// @Description Queues an export of the user's data and emails a download link when it is ready
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Success 200 {object} models.DataExportResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /user/export-data [post]
+func (s *Service) RequestDataExport(c *gin.Context) *models.DataExportResponse {
+	payload, existsUserInfo := c.Get(constants.InfoAccess)
+	if !existsUserInfo {
+		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+
+	userId := payload.(models.Payload).ID
+
+	user, err := s.app.Repos.Users.GetUserId(c, models.GetUserIdParams{
+		ID:       userId,
+		IsActive: true,
+	})
+	if err != nil {
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+
+	token, err := helpers.GenerateToken()
+	if err != nil {
+		response.InternalServerError(c, response.ErrCodeInternalServer)
+		return nil
+	}
+
+	var exportId int
+
+	//* The export row and its data_export.requested event are written in one transaction
+	err = s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		inProgress, err := tx.DataExports.HasDataExportInProgress(c, userId, []int{
+			constants.DataExportStatusPending,
+			constants.DataExportStatusProcessing,
+		})
+		if err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
+		if inProgress {
+			response.BadRequestError(c, response.ErrorDataExportInProgress)
+			return errDataExportInProgress
+		}
+
+		exportId, err = tx.DataExports.CreateDataExport(c, models.CreateDataExportParams{
+			UserID: userId,
+			Status: constants.DataExportStatusPending,
+			Token:  token,
+		})
+		if err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
+
+		return enqueueEvent(c, tx.Outbox, userId, constants.EventDataExportRequested, models.DataExportRequestedEvent{
+			ExportID: exportId,
+			UserID:   userId,
+			Locale:   emailLocale(c, helpers.NullStringToString(user.Locale)),
+		})
+	})
+
+	if err != nil {
+		respondTxError(c, err)
+		return nil
+	}
+
+	s.recordUserAudit(c, userId, constants.AuditDataExportRequested, map[string]interface{}{"export_id": exportId})
+
+	return &models.DataExportResponse{
+		Id:       userId,
+		ExportID: exportId,
+		Status:   constants.DataExportStatusPending,
+		Message:  "Your export is being prepared, we will email you a download link when it is ready",
+	}
+}
+
+// DownloadDataExport opens the archive of a data export from the token of its download link.
+// The link stops working once it expires; the controller sends the file and closes it.
+//
+// Swagger documentation for DownloadDataExport function
+// @Summary Download data export
+// @Description Downloads the zip archive of a data export
+// @Tags User
+// @Produce application/zip
+// @Param token path string true "Token from the download link"
+// @Success 200 {file} file
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /exports/{token} [get]
+func (s *Service) DownloadDataExport(c *gin.Context) *models.DataExportDownload {
+	reqParams := models.ParamsDataExportRequest{}
+	if err := c.ShouldBindUri(&reqParams); err != nil {
+		response.BadRequestError(c, response.ErrCodeValidation)
+		return nil
+	}
+
+	export, err := s.app.Repos.DataExports.GetDataExportByToken(c, reqParams.Token)
+	if err == sql.ErrNoRows || (err == nil && export.Status != constants.DataExportStatusReady && export.Status != constants.DataExportStatusExpired) {
+		response.NotFoundError(c, response.ErrorDataExportNotFound)
+		return nil
+	}
+	if err != nil {
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+
+	// The archive of an expired export is removed by the data export expiry job
+	if export.Status == constants.DataExportStatusExpired || !export.ExpiresAt.Valid || time.Now().After(export.ExpiresAt.Time) {
+		response.BadRequestError(c, response.ErrorDataExportExpired)
+		return nil
+	}
+
+	file, err := s.app.Exports.Open(c, export.FilePath.String)
+	if err != nil {
+		slog.ErrorContext(c, "Data export archive is missing", "export_id", export.ID, "key", export.FilePath.String, "error", err)
+		response.NotFoundError(c, response.ErrorDataExportNotFound)
+		return nil
+	}
+
+	s.recordAudit(c, models.AuditEntry{
+		SubjectID: export.UserID,
+		EventType: constants.AuditDataExportDownloaded,
+		Metadata:  map[string]interface{}{"export_id": export.ID},
+	})
+
+	return &models.DataExportDownload{
+		ID:      export.ID,
+		File:    file,
+		ModTime: file.ModTime(),
+	}
+}
+
+// BuildDataExport builds the archive of a requested data export and emails its download link.
+// It is called by the queue consumer for every data_export.requested event. An export that is already
+// built is skipped, so a redelivered message does nothing. When building fails, the error is saved on
+// the export and returned so the message is retried; the export is failed once the retries are used up.
+func (s *Service) BuildDataExport(ctx context.Context, event models.DataExportRequestedEvent) error {
+	export, err := s.app.Repos.DataExports.GetDataExport(ctx, event.ExportID)
+	if err != nil {
+		return err
+	}
+	if export.Status != constants.DataExportStatusPending && export.Status != constants.DataExportStatusProcessing {
+		return nil
+	}
+
+	attempts, err := s.app.Repos.DataExports.StartDataExport(ctx, export.ID, constants.DataExportStatusProcessing)
+	if err != nil {
+		return err
+	}
+
+	key, email, err := s.writeDataExport(ctx, export)
+	if err != nil {
+		//* After the last retry the export is failed, so the user can request a new one
+		status := constants.DataExportStatusProcessing
+		if attempts > constants.ConsumerMaxRetries {
+			status = constants.DataExportStatusFailed
+		}
+		if errStatus := s.app.Repos.DataExports.UpdateDataExportStatus(ctx, models.UpdateDataExportStatusParams{
+			ID:     export.ID,
+			Status: status,
+			Error:  sql.NullString{String: err.Error(), Valid: true},
+		}); errStatus != nil {
+			slog.ErrorContext(ctx, "Data export failed to save error", "export_id", export.ID, "error", errStatus)
+		}
+		return err
+	}
+
+	config := s.exportConfig()
+	expiresAt := time.Now().Add(config.ttl)
+
+	//* The export is marked ready and its email queued in one transaction
+	return s.app.Repos.WithTx(ctx, func(tx repo.Repositories) error {
+		if err := tx.DataExports.MarkDataExportReady(ctx, models.MarkDataExportReadyParams{
+			ID:        export.ID,
+			Status:    constants.DataExportStatusReady,
+			FilePath:  key,
+			ExpiresAt: expiresAt,
+		}); err != nil {
+			return err
+		}
+
+		return enqueueEmail(ctx, tx.Outbox, export.UserID, email, models.EmailData{
+			Template: constants.EmailTemplateDataExportReady,
+			Locale:   event.Locale,
+			Body:     fmt.Sprintf("%s/v1/exports/%s", config.baseURL, export.Token),
+			Details: map[string]string{
+				"expires_at": expiresAt.Format(time.RFC1123),
+			},
+		})
+	})
+}
+
+// writeDataExport collects the user's data and writes it to a zip archive named after the export token
+// in the export storage: data.json with everything, and one CSV file per section.
+// It returns the key of the archive in the storage and the email address of the user.
+func (s *Service) writeDataExport(ctx context.Context, export models.DataExport) (string, string, error) {
+	data, err := s.collectUserData(ctx, export.UserID)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := export.Token + ".zip"
+	if err := s.app.Exports.Write(ctx, key, func(w io.Writer) error {
+		return writeExportArchive(w, data)
+	}); err != nil {
+		return "", "", err
+	}
+
+	return key, data.Profile.Email, nil
+}
+
+// collectUserData reads everything held about a user. Secrets are never read:
+// see the Export* models for what each section contains.
+func (s *Service) collectUserData(ctx context.Context, userId int) (*models.UserDataExport, error) {
+	user, err := s.app.Repos.Users.GetUserId(ctx, models.GetUserIdParams{
+		ID:       userId,
+		IsActive: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data := &models.UserDataExport{
+		GeneratedAt: time.Now().UTC(),
+		Profile:     *profileResponseJSON(user),
+	}
+
+	if data.Devices, err = s.app.Repos.DataExports.ListExportDevices(ctx, userId); err != nil {
+		return nil, err
+	}
+	if data.PasswordChanges, err = s.app.Repos.DataExports.ListExportPasswordChanges(ctx, userId); err != nil {
+		return nil, err
+	}
+	if data.SocialLogins, err = s.app.Repos.DataExports.ListExportSocialLogins(ctx, userId); err != nil {
+		return nil, err
+	}
+	if data.Otps, err = s.app.Repos.DataExports.ListExportOtps(ctx, userId); err != nil {
+		return nil, err
+	}
+	if data.Verifications, err = s.app.Repos.DataExports.ListExportVerifications(ctx, userId); err != nil {
+		return nil, err
+	}
+	if data.SignIns, err = s.app.Repos.DataExports.ListExportSignIns(ctx, userId); err != nil {
+		return nil, err
+	}
+	if data.Emails, err = s.app.Repos.DataExports.ListExportMail(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	//* Audit events are paged through, newest first, like the audit endpoints
+	data.AuditEvents = []models.AuditEventJSON{}
+	params := models.ListAuditEventsParams{
+		UserID: sql.NullInt32{Int32: int32(userId), Valid: true},
+		Limit:  constants.AuditMaxLimit,
+	}
+	for {
+		events, err := s.app.Repos.Audit.ListAuditEvents(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			data.AuditEvents = append(data.AuditEvents, auditEventJSON(event))
+		}
+		if len(events) < params.Limit {
+			break
+		}
+		params.Before = sql.NullInt64{Int64: events[len(events)-1].ID, Valid: true}
+	}
+
+	return data, nil
+}
+
+// writeExportArchive writes the zip archive of a data export to w.
+func writeExportArchive(w io.Writer, data *models.UserDataExport) error {
+	archive := zip.NewWriter(w)
+
+	jsonFile, err := archive.Create(constants.ExportDataFile)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(jsonFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return err
+	}
+
+	sections := []struct {
+		name string
+		rows interface{}
+	}{
+		{"profile.csv", []models.ProfileResponseJSON{data.Profile}},
+		{"devices.csv", data.Devices},
+		{"password_changes.csv", data.PasswordChanges},
+		{"social_logins.csv", data.SocialLogins},
+		{"otps.csv", data.Otps},
+		{"verifications.csv", data.Verifications},
+		{"sign_ins.csv", data.SignIns},
+		{"emails.csv", data.Emails},
+		{"audit_events.csv", data.AuditEvents},
+	}
+	for _, section := range sections {
+		csvFile, err := archive.Create(section.name)
+		if err != nil {
+			return err
+		}
+		if err := helpers.WriteCSV(csvFile, section.rows); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+type exportSettings struct {
+	ttl     time.Duration
+	baseURL string
+}
+
+// exportConfig returns the export settings, with the defaults for those left unset.
+func (s *Service) exportConfig() exportSettings {
+	cfg := s.app.Cfg.Export
+	settings := exportSettings{
+		ttl:     time.Duration(cfg.TTL) * time.Hour,
+		baseURL: cfg.BaseURL,
+	}
+	if settings.ttl <= 0 {
+		settings.ttl = constants.DefaultExportTTL
+	}
+	if settings.baseURL == "" {
+		settings.baseURL = fmt.Sprintf("http://%s:%s", s.app.Cfg.Server.Host, s.app.Cfg.Server.Port)
+	}
+	return settings
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"database/sql"
+	"log/slog"
+	"sort"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/jobs"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// ListJobs returns the background jobs with their schedule and their latest run, if any.
+// Runs are read from the job_runs table, so the runs of the cronjob binary and of every replica are listed.
+//
+// Swagger documentation for ListJobs function
+// @Summary List jobs
+// @Description Lists the background jobs and their latest run (administrators only)
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.JobsResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/jobs [get]
+func (s *Service) ListJobs(c *gin.Context) *models.JobsResponse {
+	lastRuns, err := s.app.Repos.JobRuns.ListLastJobRuns(c)
+	if err != nil {
+		slog.ErrorContext(c, "Failed to list last job runs", "error", err)
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+	lastRunByJob := make(map[string]models.JobRun, len(lastRuns))
+	for _, run := range lastRuns {
+		lastRunByJob[run.JobName] = run
+	}
+
+	result := &models.JobsResponse{Jobs: []models.JobJSON{}}
+	for _, job := range defaultJobs(s.app) {
+		item := models.JobJSON{Name: job.Name, Spec: job.Spec}
+		if run, ok := lastRunByJob[job.Name]; ok {
+			lastRun := jobRunJSON(run)
+			item.LastRun = &lastRun
+		}
+		result.Jobs = append(result.Jobs, item)
+	}
+
+	return result
+}
+
+// GetJobRuns returns the run history of a job, newest first.
+// A page holds up to limit runs; when it is full, next_before is the ID to pass as before for the next page.
+//
+// Swagger documentation for GetJobRuns function
+// @Summary Get job runs
+// @Description Lists the runs of a background job (administrators only)
+// @Tags Admin
+// @Produce json
+// @Param name path string true "Job name"
+// @Param before query int false "Only runs older than this ID"
+// @Param limit query int false "Number of runs (max 100)"
+// @Success 200 {object} models.JobRunsResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/jobs/{name}/runs [get]
+func (s *Service) GetJobRuns(c *gin.Context) *models.JobRunsResponse {
+	var reqParams models.ParamsJobRequest
+	var reqQuery models.QueryJobRunsRequest
+	if err := c.ShouldBindUri(&reqParams); err != nil {
+		response.BadRequestError(c, response.ErrCodeValidation)
+		return nil
+	}
+	if err := c.ShouldBindQuery(&reqQuery); err != nil {
+		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+
+	if !hasJob(defaultJobs(s.app), reqParams.Name) {
+		response.NotFoundError(c, response.ErrorJobNotFound)
+		return nil
+	}
+
+	limit := reqQuery.Limit
+	if limit <= 0 {
+		limit = constants.JobRunsDefaultLimit
+	}
+	if limit > constants.JobRunsMaxLimit {
+		limit = constants.JobRunsMaxLimit
+	}
+
+	runs, err := s.app.Repos.JobRuns.ListJobRuns(c, models.ListJobRunsParams{
+		JobName: reqParams.Name,
+		Before:  sql.NullInt64{Int64: reqQuery.Before, Valid: reqQuery.Before != 0},
+		Limit:   limit,
+	})
+	if err != nil {
+		slog.ErrorContext(c, "Failed to list job runs", "job", reqParams.Name, "error", err)
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+
+	result := &models.JobRunsResponse{Runs: make([]models.JobRunJSON, 0, len(runs))}
+	for _, run := range runs {
+		result.Runs = append(result.Runs, jobRunJSON(run))
+	}
+
+	if len(runs) == limit {
+		result.NextBefore = runs[len(runs)-1].ID
+	}
+
+	return result
+}
+
+// TriggerJob runs a background job now, outside its schedule.
+// The run is recorded and the job started before the response is sent; the job itself runs in the background,
+// so its outcome is read from the run history. A job already running, here or on another replica, is not started twice.
+//
+// Swagger documentation for TriggerJob function
+// @Summary Trigger job
+// @Description Runs a background job now (administrators only)
+// @Tags Admin
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 200 {object} models.TriggerJobResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/jobs/{name}/run [post]
+func (s *Service) TriggerJob(c *gin.Context) *models.TriggerJobResponse {
+	var reqParams models.ParamsJobRequest
+	if err := c.ShouldBindUri(&reqParams); err != nil {
+		response.BadRequestError(c, response.ErrCodeValidation)
+		return nil
+	}
+
+	payload, existsUserInfo := c.Get(constants.InfoAccess)
+	if !existsUserInfo {
+		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+	actorId := payload.(models.Payload).ID
+
+	runner, err := s.jobRunner()
+	if err != nil {
+		slog.ErrorContext(c, "Failed to create job runner", "error", err)
+		response.InternalServerError(c, response.ErrCodeInternalServer)
+		return nil
+	}
+
+	runID, err := runner.Trigger(reqParams.Name, actorId)
+	switch {
+	case err == jobs.ErrJobNotFound:
+		response.NotFoundError(c, response.ErrorJobNotFound)
+		return nil
+	case err == jobs.ErrJobRunning:
+		response.BadRequestError(c, response.ErrorJobRunning)
+		return nil
+	case err != nil:
+		slog.ErrorContext(c, "Failed to trigger job", "job", reqParams.Name, "error", err)
+		response.InternalServerError(c, response.ErrCodeInternalServer)
+		return nil
+	}
+
+	s.recordAudit(c, models.AuditEntry{
+		ActorID:   actorId,
+		EventType: constants.AuditJobTriggered,
+		Metadata:  map[string]interface{}{"job": reqParams.Name, "run_id": runID},
+	})
+
+	return &models.TriggerJobResponse{
+		RunID:   runID,
+		JobName: reqParams.Name,
+		Status:  constants.JobRunStatusRunning,
+	}
+}
+
+// defaultJobs returns the jobs run by the cronjob binary, sorted by name, without creating a runner.
+func defaultJobs(a *app.App) []jobs.Job {
+	result := jobs.DefaultJobs(a)
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// hasJob reports whether one of the jobs has the given name.
+func hasJob(list []jobs.Job, name string) bool {
+	for _, job := range list {
+		if job.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jobRunJSON converts a job run read from the database to its JSON form.
+func jobRunJSON(run models.JobRun) models.JobRunJSON {
+	return models.JobRunJSON{
+		ID:          run.ID,
+		JobName:     run.JobName,
+		FenceToken:  run.FenceToken,
+		Trigger:     run.Trigger,
+		Status:      run.Status,
+		Rows:        run.Rows,
+		Error:       run.Error.String,
+		Owner:       run.Owner,
+		TriggeredBy: int(run.TriggeredBy.Int32),
+		StartedAt:   run.StartedAt,
+		FinishedAt:  helpers.NullTimeToPointer(run.FinishedAt),
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"log/slog"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// MailWebhook handles bounce and complaint notifications sent by the mail provider.
+// The provider authenticates with the shared secret from mail.webhooksecret in the X-Webhook-Secret header;
+// the webhook rejects every request while no secret is configured.
+// Every event updates the mail_log row of the email it refers to (matched by Message-ID).
+// Hard bounces and complaints also mark the address as undeliverable on the user,
+// so the queue consumer suppresses further emails to it; soft bounces are only logged.
+// The events are validated before any of them is applied.
+// It returns the number of events processed and of users marked undeliverable.
+//
+// Swagger documentation for MailWebhook function
+// @Summary Mail provider webhook
+// @Description Receives bounce and complaint notifications from the mail provider
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param X-Webhook-Secret header string true "Shared webhook secret"
+// @Param body body models.MailWebhookRequest true "Bounce and complaint events"
+// @Success 200 {object} models.MailWebhookResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /webhooks/mail [post]
+func (s *Service) MailWebhook(c *gin.Context) *models.MailWebhookResponse {
+	secret := s.app.Cfg.Mail.WebhookSecret
+	if secret == "" || subtle.ConstantTimeCompare([]byte(c.GetHeader(constants.WebhookSecret)), []byte(secret)) != 1 {
+		response.UnauthorizedError(c, response.ErrorMailWebhookUnauthorized)
+		return nil
+	}
+
+	var reqBody models.MailWebhookRequest
+	if err := c.ShouldBindJSON(&reqBody); err != nil || len(reqBody.Events) == 0 {
+		response.BadRequestError(c, response.ErrCodeInvalidRequest)
+		return nil
+	}
+
+	for _, event := range reqBody.Events {
+		if !isValidMailEvent(event) {
+			response.BadRequestError(c, response.ErrorMailEventInvalid)
+			return nil
+		}
+	}
+
+	result := &models.MailWebhookResponse{}
+	for _, event := range reqBody.Events {
+		suppressed, err := s.applyMailEvent(c, event)
+		if err != nil {
+			slog.ErrorContext(c, "Failed to apply mail event", "type", event.Type, "error", err)
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return nil
+		}
+
+		result.Processed++
+		if suppressed {
+			result.Suppressed++
+		}
+	}
+
+	return result
+}
+
+// isValidMailEvent checks the event type, the bounce type and that the email can be identified.
+func isValidMailEvent(event models.MailWebhookEvent) bool {
+	if event.Email == "" && event.MessageID == "" {
+		return false
+	}
+
+	switch event.Type {
+	case constants.MailEventComplaint:
+		return true
+	case constants.MailEventBounce:
+		return event.BounceType == constants.BounceTypeHard || event.BounceType == constants.BounceTypeSoft
+	default:
+		return false
+	}
+}
+
+// applyMailEvent records the event on the mail log and marks the address undeliverable when it has to be suppressed.
+// It returns whether a user was marked undeliverable by this event.
+func (s *Service) applyMailEvent(c *gin.Context, event models.MailWebhookEvent) (bool, error) {
+	status := constants.MailStatusBounced
+	if event.Type == constants.MailEventComplaint {
+		status = constants.MailStatusComplained
+	}
+
+	recipient := event.Email
+	if event.MessageID != "" {
+		row, err := repo.UpdateMailLogStatus(c, s.app.Repos.DB, models.UpdateMailLogStatusParams{
+			ProviderMessageID: event.MessageID,
+			Status:            status,
+			Error:             sql.NullString{String: event.Reason, Valid: event.Reason != ""},
+		})
+		switch {
+		case err == sql.ErrNoRows:
+			slog.WarnContext(c, "Mail event for unknown message", "type", event.Type, "message_id", event.MessageID)
+		case err != nil:
+			return false, err
+		case recipient == "":
+			recipient = row.Recipient
+		}
+	}
+
+	if recipient == "" || (event.Type == constants.MailEventBounce && event.BounceType != constants.BounceTypeHard) {
+		return false, nil
+	}
+
+	marked, err := repo.MarkEmailUndeliverable(c, s.app.Repos.DB, recipient)
+	if err != nil {
+		return false, err
+	}
+	if marked > 0 {
+		slog.InfoContext(c, "Email marked undeliverable", "email", recipient, "type", event.Type)
+	}
+	return marked > 0, nil
+}
@@ -1,28 +1,27 @@
 package service
 
 import (
-	"time"
+	"database/sql"
+	"fmt"
+	"log/slog"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo/redis"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/metrics"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 )
 
 // SendOtp generates and sends an OTP (One-Time Password) to the user.
-// It retrieves the user information from the request context, generates an OTP,
-// saves it in the database, and returns a response containing the OTP details.
-func SendOtp(c *gin.Context, userId int, time time.Time) *models.SendOtpResponse {
-	otp := helpers.GenerateOTP(6)
-	timeExpired := time
-	resultOtp, err := repo.CreateOtp(global.DB, models.CreateOtpParams{
-		UserID:    userId,
-		OtpCode:   otp,
-		ExpiresAt: timeExpired,
-	})
+// It generates an OTP, saves it in the database together with the user, delivery channel, purpose and,
+// for an SMS OTP, the phone number it is sent to, and returns a response containing the OTP details.
+// The OTP is written with otps, which may be bound to a transaction shared with the message that delivers it.
+func (s *Service) SendOtp(c *gin.Context, otps repo.OTPRepository, arg models.CreateOtpParams) *models.SendOtpResponse {
+	arg.OtpCode = helpers.GenerateOTP(6)
+	resultOtp, err := otps.CreateOtp(c, arg)
 
 	if err != nil {
 		return nil
@@ -31,7 +30,50 @@ func SendOtp(c *gin.Context, userId int, time time.Time) *models.SendOtpResponse
 	return &models.SendOtpResponse{
 		Id:        resultOtp.UserID,
 		Code:      resultOtp.OtpCode,
-		ExpiredAt: timeExpired.String(),
+		Channel:   resultOtp.Channel,
+		ExpiredAt: arg.ExpiresAt.String(),
+	}
+}
+
+// sendOtpSMS sends the OTP code to the given phone number through the configured SMS gateway.
+// It returns an error if the gateway could not deliver the message.
+func (s *Service) sendOtpSMS(phone string, title string, code string) error {
+	message := fmt.Sprintf("%s Your OTP code is %s. Do not share this code with anyone.", title, code)
+	return s.app.SMS.Send(phone, message)
+}
+
+// otpChannelName returns the name of an OTP channel in the metrics.
+func otpChannelName(channel int) string {
+	if channel == constants.OtpChannelSMS {
+		return "sms"
+	}
+	return "email"
+}
+
+// resolveOtpChannel decides which channel the two-factor OTP is delivered through.
+// The requested channel wins over the channel stored on the user; when neither is set, email is used.
+// SMS is only allowed for a verified phone number: an explicit SMS request without one returns an error code,
+// while a stored SMS preference falls back to email.
+// It returns the channel and 0, or 0 and the error code to respond with.
+func resolveOtpChannel(user *models.User, requested int) (int, int) {
+	channel := requested
+	if channel == 0 {
+		channel = user.TwoFactorChannel
+	}
+
+	switch channel {
+	case 0, constants.OtpChannelEmail:
+		return constants.OtpChannelEmail, 0
+	case constants.OtpChannelSMS:
+		if user.Phone.Valid && user.Phone.String != "" && user.PhoneVerified {
+			return constants.OtpChannelSMS, 0
+		}
+		if requested == constants.OtpChannelSMS {
+			return 0, response.ErrorUserPhoneNotVerified
+		}
+		return constants.OtpChannelEmail, 0
+	default:
+		return 0, response.ErrorOTPChannelInvalid
 	}
 }
 
@@ -39,6 +81,9 @@ func SendOtp(c *gin.Context, userId int, time time.Time) *models.SendOtpResponse
 // It takes a gin.Context object as a parameter and returns a pointer to models.LoginResponse.
 // The function first binds the JSON request body to the models.OtpRequest struct.
 // If there is an error in binding, it returns a bad request error response.
+// Only a login OTP is accepted; an optional channel (email or SMS) restricts it to the one delivered through that channel.
+// The OTP must come with the challenge returned by the login, and belong to the user of that challenge;
+// a challenge is deleted after constants.OtpChallengeMaxAttempts failed attempts, so the user has to log in again.
 // Then, it retrieves the new OTPs from the database using the repo.GetNewOtps function.
 // If there is an error in retrieving the OTPs or if no OTP is found, it returns a bad request error response.
 // Otherwise, it updates the OTP's IsActive field to false using the repo.UpdateOtpIsActive function.
@@ -57,7 +102,7 @@ func SendOtp(c *gin.Context, userId int, time time.Time) *models.SendOtpResponse
 // @Success 200 {object} models.LoginResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Router /auth/verify-otp [post]
-func VerificationOtp(c *gin.Context) *models.LoginResponse {
+func (s *Service) VerificationOtp(c *gin.Context) *models.LoginResponse {
 	var req models.OtpRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -65,11 +110,52 @@ func VerificationOtp(c *gin.Context) *models.LoginResponse {
 		return nil
 	}
 
-	resultInfo := VeriOtp(c, req.Otp)
+	if req.Channel != 0 && req.Channel != constants.OtpChannelEmail && req.Channel != constants.OtpChannelSMS {
+		response.BadRequestError(c, response.ErrorOTPChannelInvalid)
+		return nil
+	}
+
+	challengeKey := fmt.Sprintf(constants.OtpChallengeKey, req.Challenge)
+	userId, attempts, err := redis.AttemptOtpChallenge(c, s.app.Cache, challengeKey)
+	if err == redis.ErrOtpChallengeNotFound {
+		response.BadRequestError(c, response.ErrorOTPChallengeInvalid)
+		return nil
+	}
+	if err != nil {
+		slog.ErrorContext(c, "Failed to read OTP challenge", "error", err)
+		response.InternalServerError(c, response.ErrCodeCacheQuery)
+		return nil
+	}
+
+	if attempts > constants.OtpChallengeMaxAttempts {
+		redis.DeleteKeyUser(c, s.app.Cache, challengeKey)
+		s.recordAudit(c, models.AuditEntry{
+			SubjectID: userId,
+			EventType: constants.AuditOtpFailed,
+			Metadata:  map[string]interface{}{"purpose": "login", "reason": "too_many_attempts"},
+		})
+		metrics.OtpVerified.WithLabelValues(constants.OtpPurposeLogin, constants.MetricResultFailure).Inc()
+		response.BadRequestError(c, response.ErrorOTPChallengeInvalid)
+		return nil
+	}
+
+	resultInfo := s.VeriOtp(c, s.app.Repos.OTPs, models.GetNewOtpsParams{
+		OtpCode: req.Otp,
+		UserID:  sql.NullInt32{Int32: int32(userId), Valid: true},
+		Purpose: constants.OtpPurposeLogin,
+		Channel: sql.NullInt32{Int32: int32(req.Channel), Valid: req.Channel != 0},
+	})
 	if resultInfo == nil {
+		s.recordAudit(c, models.AuditEntry{
+			SubjectID: userId,
+			EventType: constants.AuditOtpFailed,
+			Metadata:  map[string]interface{}{"purpose": "login"},
+		})
+		metrics.OtpVerified.WithLabelValues(constants.OtpPurposeLogin, constants.MetricResultFailure).Inc()
 		response.BadRequestError(c, response.ErrorOTPNotExit)
 		return nil
 	}
+	redis.DeleteKeyUser(c, s.app.Cache, challengeKey)
 
 	accessToken, refetchToken, resultEncodePublicKey := createKeyAndToken(models.UserIDEmail{
 		ID:    resultInfo.UserID,
@@ -81,9 +167,17 @@ func VerificationOtp(c *gin.Context) *models.LoginResponse {
 		return nil
 	}
 
-	resultInfoDevice := upsetDevice(c, resultInfo.UserID, resultEncodePublicKey)
+	signIn := s.detectSignIn(c, resultInfo.UserID)
+
+	resultInfoDevice := s.upsetDevice(c, s.app.Repos.Devices, resultInfo.UserID, resultEncodePublicKey)
+
+	s.setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
 
-	setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
+	s.recordUserAudit(c, resultInfo.UserID, constants.AuditOtpVerified, map[string]interface{}{"channel": resultInfo.Channel, "purpose": "login"})
+	metrics.OtpVerified.WithLabelValues(constants.OtpPurposeLogin, constants.MetricResultSuccess).Inc()
+	s.recordUserAudit(c, resultInfo.UserID, constants.AuditLoginSucceeded, map[string]interface{}{"two_factor": true})
+
+	s.trackSignIn(c, models.UserIDEmail{ID: resultInfo.UserID, Email: resultInfo.Email}, helpers.NullStringToString(resultInfo.Locale), signIn)
 
 	return &models.LoginResponse{
 		ID:          resultInfo.UserID,
@@ -95,17 +189,18 @@ func VerificationOtp(c *gin.Context) *models.LoginResponse {
 
 // VeriOtp verifies the OTP (One-Time Password) provided in the request.
 // It retrieves the OTP from the repository and checks if it exists.
+// The OTP must have been sent for arg.Purpose and, when they are set, to arg.UserID through arg.Channel,
+// so a code of another user or sent for something else is neither accepted nor used up.
 // If the OTP is valid, it updates the OTP's IsActive status to false.
 // Parameters:
-//   - req: The OTP request containing the OTP code.
 //   - c: The Gin context for handling the HTTP request and response.
+//   - otps: The OTP repository, which may be bound to a transaction that uses the OTP.
+//   - arg: The OTP code to verify, its purpose and, optionally, its user and delivery channel.
 //
 // Returns:
-//   - The first OTP information from the repository.
-//   - A boolean indicating if there was an error.
-//   - The login response, which is currently set to nil.
-func VeriOtp(c *gin.Context, otpCode string) *models.GetNewOtpsRow {
-	otp, err := repo.GetNewOtps(global.DB, otpCode)
+//   - The first OTP information from the repository, or nil if the OTP is invalid or could not be used up.
+func (s *Service) VeriOtp(c *gin.Context, otps repo.OTPRepository, arg models.GetNewOtpsParams) *models.GetNewOtpsRow {
+	otp, err := otps.GetNewOtps(c, arg)
 
 	if err != nil {
 		return nil
@@ -117,6 +212,8 @@ func VeriOtp(c *gin.Context, otpCode string) *models.GetNewOtpsRow {
 
 	resultInfo := &otp[0]
 
-	repo.UpdateOtpIsActive(global.DB, models.UpdateOtpIsActiveParams{IsActive: false, OtpCode: otp[0].OtpCode})
+	if err := otps.UpdateOtpIsActive(c, models.UpdateOtpIsActiveParams{IsActive: false, ID: resultInfo.ID}); err != nil {
+		return nil
+	}
 	return resultInfo
 }
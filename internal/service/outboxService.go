@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/utils"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	pkg "github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/mail"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/tracing"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// enqueueEmail writes an email to the outbox.
+// It must be called with the outbox of the transaction of the state change the email belongs to:
+// the relay publishes it to RabbitMQ only after the transaction is committed,
+// and the queue consumer sends it.
+// The body (a password, an OTP or a link with a token) is stored in outbox_secrets and the message
+// only references it, so it is neither kept in the outbox nor published.
+func enqueueEmail(ctx context.Context, outbox repo.OutboxRepository, userId int, email string, data models.EmailData) error {
+	message := models.EmailMessage{
+		UserID:   userId,
+		To:       email,
+		Template: data.Template,
+		Locale:   data.Locale,
+		Details:  data.Details,
+	}
+
+	if data.Body != "" {
+		secretID, err := outbox.CreateOutboxSecret(ctx, models.CreateOutboxSecretParams{
+			UserID:    userId,
+			Secret:    data.Body,
+			ExpiresAt: time.Now().Add(constants.OutboxSecretTTL),
+		})
+		if err != nil {
+			return err
+		}
+		message.SecretID = secretID
+	}
+
+	return enqueueEvent(ctx, outbox, userId, constants.EventEmailSend, message)
+}
+
+// enqueueEvent writes a domain event about a user to the outbox.
+// The payload is stored as JSON and published unchanged by the relay,
+// with the trace context of ctx so the consumer continues the trace of the request.
+func enqueueEvent(ctx context.Context, outbox repo.OutboxRepository, userId int, eventType string, payload interface{}) error {
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = outbox.CreateOutbox(ctx, models.CreateOutboxParams{
+		AggregateType: constants.AggregateUser,
+		AggregateID:   userId,
+		EventType:     eventType,
+		Payload:       jsonPayload,
+		TraceContext:  tracing.Inject(ctx),
+	})
+	return err
+}
+
+// respondTxError responds with ErrCodeDBTransaction when a transaction failed
+// without an error response having been written inside it (e.g. the commit failed).
+func respondTxError(c *gin.Context, err error) {
+	if !c.IsAborted() {
+		respondDBError(c, err, response.ErrCodeDBTransaction)
+	}
+}
+
+// respondDBError responds to a failed query or transaction with code, or with ErrCodeRequestCanceled
+// or ErrCodeRequestTimeout when it failed because the request was cancelled or the query ran out of time.
+func respondDBError(c *gin.Context, err error, code int) {
+	if contextCode := utils.ContextErrorCode(c, err); contextCode != 0 {
+		response.GatewayTimeoutError(c, contextCode)
+		return
+	}
+	response.InternalServerError(c, code)
+}
+
+var (
+	errVerificationLink = errors.New("verification link not created")
+	errOtpNotCreated    = errors.New("otp not created")
+	errOtpInvalid       = errors.New("otp invalid")
+	errDeviceNotSaved   = errors.New("device not saved")
+
+	errDataExportInProgress = errors.New("data export in progress")
+)
+
+// emailLocale returns the locale for an email to a user: the user's saved locale when set,
+// otherwise the request's Accept-Language header.
+func emailLocale(c *gin.Context, userLocale string) string {
+	return pkg.ResolveLocale(userLocale, c.GetHeader("Accept-Language"))
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo/redis"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/geoip"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+	third_party "github.com/fdhhhdjd/Go_Secure_Auth_Pro/third_party/telegram"
+	"github.com/gin-gonic/gin"
+)
+
+// riskConfig returns the risk rules with the thresholds that are not configured set to their defaults.
+func (s *Service) riskConfig() models.RiskConfig {
+	cfg := s.app.Cfg.Risk
+	if cfg.MediumScore <= 0 {
+		cfg.MediumScore = constants.DefaultRiskMediumScore
+	}
+	if cfg.HighScore <= 0 {
+		cfg.HighScore = constants.DefaultRiskHighScore
+	}
+	if cfg.FailedAttemptsMin <= 0 {
+		cfg.FailedAttemptsMin = constants.DefaultRiskFailedAttemptsMin
+	}
+	if cfg.MaxTravelSpeed <= 0 {
+		cfg.MaxTravelSpeed = constants.DefaultRiskMaxTravelSpeed
+	}
+	return cfg
+}
+
+// assessLoginRisk scores a login whose password was correct, from signals the service already has:
+//   - the device or network has not signed in before (not counted on the first sign-in of the account),
+//   - the device is known but signs in from another IP than last time (devices.ip),
+//   - recent failed passwords on the account, read from the spam counter of its failed passwords,
+//   - a blacklisted IP in the same /24 or /48 network,
+//   - impossible travel: the distance from the last sign-in, located with the offline GeoIP database,
+//     could not have been covered at MaxTravelSpeed since then.
+//
+// The weights of the signals found are added up; from MediumScore the login needs an OTP step-up
+// and from HighScore it is blocked. Every scored login is logged, and recorded in the audit log when a signal was found.
+// A signal that cannot be checked (e.g. no GeoIP database) does not count.
+func (s *Service) assessLoginRisk(c *gin.Context, userId int, signIn models.SignInCheck) models.RiskAssessment {
+	assessment := models.RiskAssessment{Signals: []string{}, Decision: constants.RiskDecisionAllow}
+
+	cfg := s.riskConfig()
+	if !cfg.Enabled {
+		return assessment
+	}
+
+	add := func(signal string, weight int) {
+		if weight > 0 {
+			assessment.Score += weight
+			assessment.Signals = append(assessment.Signals, signal)
+		}
+	}
+
+	if signIn.HasSignIns && signIn.NewDevice {
+		add(constants.RiskSignalNewDevice, cfg.NewDevice)
+	}
+
+	if signIn.HasSignIns && signIn.NewNetwork {
+		add(constants.RiskSignalNewNetwork, cfg.NewNetwork)
+	}
+
+	if !signIn.NewDevice && cfg.IPChange > 0 {
+		device, err := s.app.Repos.Devices.GetDeviceId(c, models.GetDeviceIdParams{DeviceId: signIn.DeviceID, IsActive: true})
+		if err == nil && device.UserID == userId && device.Ip.Valid && device.Ip.String != "" && device.Ip.String != signIn.IP {
+			add(constants.RiskSignalIPChange, cfg.IPChange)
+		}
+	}
+
+	if cfg.FailedAttempts > 0 {
+		failed, err := redis.GetCounter(c, s.app.Cache, fmt.Sprintf(constants.SpamKeyLoginFailed, userId))
+		if err == nil && failed >= int64(cfg.FailedAttemptsMin) {
+			add(constants.RiskSignalFailedAttempts, cfg.FailedAttempts)
+		}
+	}
+
+	if cfg.BlacklistedNeighbour > 0 && s.hasBlacklistedNeighbour(c, signIn.IP) {
+		add(constants.RiskSignalBlacklistedNeighbour, cfg.BlacklistedNeighbour)
+	}
+
+	if cfg.ImpossibleTravel > 0 && s.isImpossibleTravel(c, userId, signIn.IP, cfg.MaxTravelSpeed) {
+		add(constants.RiskSignalImpossibleTravel, cfg.ImpossibleTravel)
+	}
+
+	switch {
+	case assessment.Score >= cfg.HighScore:
+		assessment.Decision = constants.RiskDecisionBlock
+	case assessment.Score >= cfg.MediumScore:
+		assessment.Decision = constants.RiskDecisionStepUp
+	}
+
+	slog.InfoContext(c, "Login risk", "user_id", userId, "ip", signIn.IP, "score", assessment.Score, "decision", assessment.Decision, "signals", assessment.Signals)
+	if assessment.Score > 0 {
+		s.recordUserAudit(c, userId, constants.AuditLoginRisk, map[string]interface{}{
+			"score":    assessment.Score,
+			"signals":  assessment.Signals,
+			"decision": assessment.Decision,
+		})
+	}
+
+	return assessment
+}
+
+// hasBlacklistedNeighbour reports whether a blacklisted IP is in the same network as the given IP.
+func (s *Service) hasBlacklistedNeighbour(c *gin.Context, ip string) bool {
+	network := helpers.NetworkKey(ip)
+	if network == "" {
+		return false
+	}
+
+	blacklisted, err := s.app.Cache.SMembers(c, constants.BlackListIP).Result()
+	if err != nil {
+		slog.ErrorContext(c, "Failed to read IP blacklist", "error", err)
+		return false
+	}
+
+	for _, blacklistedIP := range blacklisted {
+		if blacklistedIP != ip && helpers.NetworkKey(blacklistedIP) == network {
+			return true
+		}
+	}
+	return false
+}
+
+// isImpossibleTravel reports whether the user's last sign-in is too far away to have been reached since then.
+func (s *Service) isImpossibleTravel(c *gin.Context, userId int, ip string, maxSpeed float64) bool {
+	last, err := s.app.Repos.SignIns.GetLastSignIn(c, userId)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			slog.ErrorContext(c, "Failed to read last sign-in", "user_id", userId, "error", err)
+		}
+		return false
+	}
+
+	if !last.Ip.Valid || last.Ip.String == ip {
+		return false
+	}
+
+	from, okFrom := s.app.GeoIP.Lookup(last.Ip.String)
+	to, okTo := s.app.GeoIP.Lookup(ip)
+	if !okFrom || !okTo || !from.HasCoordinates || !to.HasCoordinates {
+		return false
+	}
+
+	distance := geoip.Distance(from, to)
+	if distance < constants.RiskMinTravelDistanceKm {
+		return false
+	}
+
+	hours := time.Since(last.CreatedAt).Hours()
+	return hours <= 0 || distance/hours > maxSpeed
+}
+
+// alertBlockedLogin tells the user by email and the team on Telegram that a login was blocked.
+// The email suggests resetting the password, since the password used was correct.
+func (s *Service) alertBlockedLogin(c *gin.Context, user *models.User, signIn models.SignInCheck, assessment models.RiskAssessment) {
+	data := models.EmailData{
+		Template: constants.EmailTemplateSignInBlocked,
+		Locale:   emailLocale(c, helpers.NullStringToString(user.Locale)),
+		Body:     fmt.Sprintf("%s/auth/forget", s.app.Cfg.Server.PortFrontend),
+		Details: map[string]string{
+			"device": signIn.DeviceType,
+			"ip":     signIn.IP,
+			"time":   time.Now().UTC().Format(time.RFC1123),
+		},
+	}
+	if err := enqueueEmail(c, s.app.Repos.Outbox, user.ID, user.Email, data); err != nil {
+		slog.ErrorContext(c, "Failed to queue blocked sign-in email", "user_id", user.ID, "error", err)
+	}
+
+	message := fmt.Sprintf("*Login blocked*\nUser: %d\nIP: %s\nScore: %d\nSignals: %v", user.ID, signIn.IP, assessment.Score, assessment.Signals)
+	go third_party.SendTelegramMessage(s.app.Cfg.Telegram, message, "Markdown", true, false)
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/jobs"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+)
+
+// Service implements the use cases behind the HTTP handlers and the queue consumers.
+// It reaches the database, cache, mailer and other dependencies only through the App it is given.
+type Service struct {
+	app *app.App
+
+	runnerOnce sync.Once
+	runner     *jobs.Runner
+	runnerErr  error
+}
+
+// New creates a Service on top of an App.
+func New(a *app.App) *Service {
+	return &Service{app: a}
+}
+
+// phoneRegion returns the region phone numbers written without a country code are parsed for,
+// from phone.defaultregion in the configuration.
+func (s *Service) phoneRegion() string {
+	return helpers.PhoneRegion(s.app.Cfg.Phone.DefaultRegion)
+}
+
+// errRunnerStopped is returned by jobRunner once the service is closed.
+var errRunnerStopped = errors.New("job runner is stopped")
+
+// jobRunner returns the runner of the default jobs, created on first use.
+// The server uses it to trigger jobs manually; it does not schedule them, the cronjob binary does.
+func (s *Service) jobRunner() (*jobs.Runner, error) {
+	s.runnerOnce.Do(func() {
+		s.runner, s.runnerErr = jobs.NewRunner(s.app, jobs.DefaultJobs(s.app))
+	})
+	return s.runner, s.runnerErr
+}
+
+// Close stops the runner of the jobs triggered manually, if one was created, waiting up to timeout
+// for the running jobs to record the end of their run. No job can be triggered afterwards.
+func (s *Service) Close(timeout time.Duration) {
+	s.runnerOnce.Do(func() {
+		s.runnerErr = errRunnerStopped
+	})
+	if s.runner != nil {
+		s.runner.Stop(timeout)
+	}
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+)
+
+// detectSignIn compares the device and network of the request with the user's previous sign-ins.
+// It has to be called before upsetDevice, which makes the device of the request known.
+// If the history cannot be read the login is treated as unknown, so OTP and the notice err on the safe side.
+func (s *Service) detectSignIn(c *gin.Context, userId int) models.SignInCheck {
+	deviceId, _ := c.Get("device_id")
+	deviceType := c.Request.UserAgent()
+	if len(deviceType) > constants.DeviceTypeMaxLength {
+		deviceType = deviceType[:constants.DeviceTypeMaxLength]
+	}
+
+	check := models.SignInCheck{
+		DeviceType: deviceType,
+		IP:         c.ClientIP(),
+		Network:    s.signInNetwork(c.ClientIP()),
+	}
+	check.DeviceID, _ = deviceId.(string)
+
+	history, err := s.app.Repos.SignIns.GetSignInHistory(c, models.GetSignInHistoryParams{
+		UserID:   userId,
+		DeviceID: check.DeviceID,
+		Network:  check.Network,
+	})
+	if err != nil {
+		slog.ErrorContext(c, "Failed to read sign-in history", "user_id", userId, "error", err)
+		check.HasSignIns = true
+		check.NewDevice = true
+		check.NewNetwork = true
+		return check
+	}
+
+	check.HasSignIns = history.HasSignIns
+	check.NewDevice = !history.KnownDevice
+	check.NewNetwork = check.Network != "" && !history.KnownNetwork
+	return check
+}
+
+// signInNetwork returns the network a sign-in comes from: its autonomous system (e.g. "AS7552")
+// when the GeoIP ASN database knows the IP, otherwise its /24 or /48 block.
+func (s *Service) signInNetwork(ip string) string {
+	if location, ok := s.app.GeoIP.Lookup(ip); ok && location.ASN != 0 {
+		return fmt.Sprintf("AS%d", location.ASN)
+	}
+	return helpers.NetworkKey(ip)
+}
+
+// trackSignIn records a successful sign-in and, when it comes from a new device or network,
+// emails the user a "new sign-in" notice with a link that signs the device out.
+// The first sign-in of an account is recorded without a notice.
+// The sign-in and the email are written in one transaction; a failure is only logged,
+// the user is already signed in at this point.
+func (s *Service) trackSignIn(c *gin.Context, user models.UserIDEmail, locale string, check models.SignInCheck) {
+	token, err := helpers.GenerateToken()
+	if err != nil {
+		slog.ErrorContext(c, "Failed to generate revoke token", "user_id", user.ID, "error", err)
+		return
+	}
+
+	now := time.Now()
+	notify := check.HasSignIns && check.Unknown()
+
+	err = s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		_, err := tx.SignIns.CreateSignIn(c, models.CreateSignInParams{
+			UserID:          user.ID,
+			DeviceID:        check.DeviceID,
+			DeviceType:      sql.NullString{String: check.DeviceType, Valid: check.DeviceType != ""},
+			Ip:              sql.NullString{String: check.IP, Valid: check.IP != ""},
+			Network:         sql.NullString{String: check.Network, Valid: check.Network != ""},
+			NewDevice:       check.NewDevice,
+			NewNetwork:      check.NewNetwork,
+			RevokeToken:     token,
+			RevokeExpiresAt: now.Add(constants.RevokeDeviceTokenTTL),
+		})
+		if err != nil || !notify {
+			return err
+		}
+
+		data := models.EmailData{
+			Template: constants.EmailTemplateNewSignIn,
+			Locale:   emailLocale(c, locale),
+			Body:     fmt.Sprintf("%s/auth/revoke/device/%s", s.app.Cfg.Server.PortFrontend, token),
+			Details: map[string]string{
+				"device": check.DeviceType,
+				"ip":     check.IP,
+				"time":   now.UTC().Format(time.RFC1123),
+			},
+		}
+
+		return enqueueEmail(c, tx.Outbox, user.ID, user.Email, data)
+	})
+	if err != nil {
+		slog.ErrorContext(c, "Failed to record sign-in", "user_id", user.ID, "error", err)
+		return
+	}
+
+	if notify {
+		s.recordUserAudit(c, user.ID, constants.AuditNewSignIn, map[string]interface{}{
+			"new_device":  check.NewDevice,
+			"new_network": check.NewNetwork,
+		})
+	}
+}
+
+// RevokeDevice signs out the device of a sign-in, using the link from the "new sign-in" email.
+// The token can only be used once and expires after seven days.
+// The device is deactivated, so its access and refresh tokens are rejected, and every sign-in of the user
+// from that device is revoked, so the device is unknown again on its next login.
+//
+// Swagger documentation for RevokeDevice function
+// @Summary Revoke device
+// @Description Signs out the device reported in a "new sign-in" email
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body models.BodyRevokeDeviceRequest true "Revoke token from the email"
+// @Success 200 {object} models.RevokeDeviceResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /auth/revoke-device [post]
+func (s *Service) RevokeDevice(c *gin.Context) *models.RevokeDeviceResponse {
+	reqBody := models.BodyRevokeDeviceRequest{}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		response.BadRequestError(c, response.ErrCodeValidation)
+		return nil
+	}
+
+	var revoked models.RevokeSignInRow
+
+	//* Sign-ins, device and session.revoked event are written in one transaction
+	err := s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		var err error
+		revoked, err = tx.SignIns.RevokeSignInByToken(c, reqBody.Token)
+		if err == sql.ErrNoRows {
+			response.BadRequestError(c, response.ErrorRevokeTokenInvalid)
+			return err
+		}
+		if err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
+
+		device := models.DeviceUserParams{UserID: revoked.UserID, DeviceID: revoked.DeviceID}
+		if err := tx.SignIns.RevokeDeviceSignIns(c, device); err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
+		if err := tx.Devices.DeactivateDevice(c, device); err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
+
+		return enqueueEvent(c, tx.Outbox, revoked.UserID, constants.EventSessionRevoked, models.SessionRevokedEvent{
+			UserID:   revoked.UserID,
+			DeviceID: revoked.DeviceID,
+		})
+	})
+
+	if err != nil {
+		respondTxError(c, err)
+		return nil
+	}
+
+	s.recordAudit(c, models.AuditEntry{
+		SubjectID: revoked.UserID,
+		EventType: constants.AuditDeviceRevoked,
+		Metadata:  map[string]interface{}{"device_id": revoked.DeviceID},
+	})
+
+	return &models.RevokeDeviceResponse{
+		Id:       revoked.UserID,
+		DeviceID: revoked.DeviceID,
+	}
+}
+
+// SetOtpNewDevice turns on or off the OTP required for sign-ins from unknown devices or networks.
+// When it is on, such sign-ins are confirmed with an OTP even if two-factor authentication is off.
+//
+// Swagger documentation for SetOtpNewDevice function
+// @Summary Require OTP for new devices
+// @Description Requires an OTP for sign-ins from devices or networks not seen before
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param body body models.BodyOtpNewDeviceRequest true "Setting"
+// @Success 200 {object} models.UpdateOtpNewDeviceParams
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /user/otp-new-device [post]
+func (s *Service) SetOtpNewDevice(c *gin.Context) *models.UpdateOtpNewDeviceParams {
+	reqBody := models.BodyOtpNewDeviceRequest{}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+
+	payload, existsUserInfo := c.Get(constants.InfoAccess)
+	if !existsUserInfo {
+		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+
+	userId := payload.(models.Payload).ID
+
+	if err := s.app.Repos.Users.UpdateOtpNewDevice(c, models.UpdateOtpNewDeviceParams{
+		ID:           userId,
+		OtpNewDevice: reqBody.OtpNewDevice,
+	}); err != nil {
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+
+	keyCache := fmt.Sprintf(constants.CacheProfileUser, strconv.Itoa(userId))
+	if err := s.app.Cache.HSet(c, keyCache, "OtpNewDevice", strconv.FormatBool(reqBody.OtpNewDevice)).Err(); err != nil {
+		slog.ErrorContext(c, "Failed to update cache", "error", err)
+	}
+
+	s.recordUserAudit(c, userId, constants.AuditOtpNewDeviceChanged, map[string]interface{}{"enabled": reqBody.OtpNewDevice})
+
+	return &models.UpdateOtpNewDeviceParams{
+		ID:           userId,
+		OtpNewDevice: reqBody.OtpNewDevice,
+	}
+}
@@ -1,25 +1,30 @@
 package service
 
 import (
+	"log/slog"
+
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 )
 
 // LoginSocial handles the login process for social authentication.
-// It takes a gin.Context object as a parameter and returns a pointer to a models.LoginResponse object.
+// It takes a gin.Context object as a parameter and returns a pointer to a models.LoginResponse object,
+// or a models.LoginTwoFactor when the login needs an OTP.
 // The function first binds the JSON request body to a models.BodyLoginSocialRequest object.
 // If there is an error in binding the JSON, it returns a bad request error response and nil.
-// Then, based on the social authentication type, it calls the corresponding social authentication function.
-// If the social authentication type is not supported, it returns a bad request error response.
+// Then, based on the social authentication type, it calls the corresponding social authentication function,
+// which verifies the provider's ID token; the account is never found from a client-supplied user ID.
+// If the social authentication type is not supported or the token is rejected, it returns an error response and nil.
 // Next, it joins the users table with the verification table using the user's email.
 // If there is an error in joining the tables or no users are found, it returns a bad request error response and nil.
 // Otherwise, it retrieves the first user from the result and checks if the user's account is active.
 // If the account is blocked, it returns a forbidden error response and nil.
+// The login is then given a risk score as LoginIdentifier does: a high score blocks it, and a medium score,
+// or an unknown device or network of a user who turned on OTP for new devices, needs the OTP step,
+// for which a models.LoginTwoFactor is returned instead of tokens.
 // It then creates an access token, a refresh token, and encodes the public key using the user's ID and email.
 // If any of the tokens or the encoded public key is empty, it returns a bad request error response and nil.
 // Next, it updates the user's device information and returns the device ID.
@@ -35,8 +40,9 @@ import (
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 401 {object} response.ErrorResponse
 // @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
 // @Router /login-social [post]
-func LoginSocial(c *gin.Context) *models.LoginResponse {
+func (s *Service) LoginSocial(c *gin.Context) interface{} {
 	reqBody := models.BodyLoginSocialRequest{}
 
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
@@ -48,12 +54,17 @@ func LoginSocial(c *gin.Context) *models.LoginResponse {
 
 	switch reqBody.Type {
 	case constants.SocialGoogle:
-		resultInfoSocial = socialGoogle(c, reqBody.Uid)
+		resultInfoSocial = s.socialGoogle(c, reqBody.IdToken)
 	default:
 		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+
+	if resultInfoSocial == nil {
+		return nil
 	}
 
-	users, err := repo.JoinUsersWithVerificationByEmail(global.DB, resultInfoSocial.Email)
+	users, err := s.app.Repos.Users.JoinUsersWithVerificationByEmail(c, resultInfoSocial.Email)
 
 	if err != nil {
 		response.BadRequestError(c, response.ErrCodeInvalidFormat)
@@ -74,10 +85,31 @@ func LoginSocial(c *gin.Context) *models.LoginResponse {
 
 	accountBlock := CheckUserIsActive(resultUser.IsActive)
 	if accountBlock == nil {
-		response.ForbiddenError(c, response.ErrUserNotActive)
+		s.inactiveUserError(c, resultUser.ID)
+		return nil
+	}
+
+	signIn := s.detectSignIn(c, resultUser.ID)
+
+	risk := s.assessLoginRisk(c, resultUser.ID, signIn)
+	if risk.Decision == constants.RiskDecisionBlock {
+		s.alertBlockedLogin(c, resultUser, signIn, risk)
+		response.ForbiddenError(c, response.ErrCodeLoginRiskBlocked)
 		return nil
 	}
 
+	// The ID token stands for the password: the OTP step is the one of a login from an unknown device
+	// or network, when the user asked for it, or with a medium risk score
+	newDeviceOtp := resultUser.OtpNewDevice && signIn.Unknown()
+	riskOtp := risk.Decision == constants.RiskDecisionStepUp
+	if newDeviceOtp || riskOtp {
+		twoFactor := s.sendLoginOtp(c, resultUser, reqBody.Channel, newDeviceOtp, riskOtp)
+		if twoFactor == nil {
+			return nil
+		}
+		return *twoFactor
+	}
+
 	accessToken, refetchToken, resultEncodePublicKey := createKeyAndToken(models.UserIDEmail{
 		ID:    resultUser.ID,
 		Email: resultUser.Email,
@@ -88,9 +120,13 @@ func LoginSocial(c *gin.Context) *models.LoginResponse {
 		return nil
 	}
 
-	resultInfoDevice := upsetDevice(c, resultUser.ID, resultEncodePublicKey)
+	resultInfoDevice := s.upsetDevice(c, s.app.Repos.Devices, resultUser.ID, resultEncodePublicKey)
+
+	s.setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
+
+	s.recordUserAudit(c, resultUser.ID, constants.AuditSocialLogin, map[string]interface{}{"provider": reqBody.Type})
 
-	setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
+	s.trackSignIn(c, models.UserIDEmail{ID: resultUser.ID, Email: resultUser.Email}, helpers.NullStringToString(resultUser.Locale), signIn)
 
 	return &models.LoginResponse{
 		ID:          resultUser.ID,
@@ -100,19 +136,25 @@ func LoginSocial(c *gin.Context) *models.LoginResponse {
 	}
 }
 
-// socialGoogle retrieves social information from Google for a given user ID.
-// It takes a Gin context and a user ID as input and returns a SocialResponse object.
-// If the user ID token is invalid or missing, it returns a nil response.
-func socialGoogle(c *gin.Context, uid string) *models.SocialResponse {
-	infoUserSocial := helpers.GetUserIDToken(c, uid)
+// socialGoogle retrieves social information from a Firebase or Google Sign-In ID token.
+// It takes a Gin context and the ID token as input and returns a SocialResponse object.
+// The token must be signed by Google for this project, unexpired, and carry a verified email,
+// since the account is found by its email. Otherwise it responds with an error and returns nil.
+func (s *Service) socialGoogle(c *gin.Context, idToken string) *models.SocialResponse {
+	infoUserSocial, err := s.app.IDToken.Verify(c, idToken)
+	if err != nil {
+		slog.WarnContext(c, "Rejected social login ID token", "error", err)
+		response.UnauthorizedError(c, response.ErrorSocialTokenInvalid)
+		return nil
+	}
 
-	if infoUserSocial == nil {
-		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+	if infoUserSocial.Email == "" || !infoUserSocial.EmailVerified {
+		response.ForbiddenError(c, response.ErrorSocialEmailUnverified)
 		return nil
 	}
 
 	return &models.SocialResponse{
-		Fullname: infoUserSocial.Fullname,
+		Fullname: infoUserSocial.Name,
 		Email:    infoUserSocial.Email,
 		Picture:  infoUserSocial.Picture,
 	}
@@ -1,22 +1,25 @@
 package service
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/utils"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo/redis"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers/validate"
-	pkg "github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/mail"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/metrics"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
 	"github.com/gin-gonic/gin"
 )
@@ -37,7 +40,7 @@ import (
 // @Success 200 {object} models.ProfileResponseJSON
 // @Failure 400 {object} response.ErrorResponse
 // @Router /user/profile/{id} [get]
-func GetProfileUser(c *gin.Context) *models.ProfileResponseJSON {
+func (s *Service) GetProfileUser(c *gin.Context) *models.ProfileResponseJSON {
 	var req models.PramsProfileRequest
 
 	if err := c.ShouldBindUri(&req); err != nil {
@@ -47,12 +50,15 @@ func GetProfileUser(c *gin.Context) *models.ProfileResponseJSON {
 
 	keyCache := fmt.Sprintf(constants.CacheProfileUser, strconv.Itoa(req.Id))
 
-	cachedProfileMap := global.Cache.HGetAll(c, keyCache).Val()
+	cachedProfileMap := s.app.Cache.HGetAll(c, keyCache).Val()
 
 	if len(cachedProfileMap) > 0 {
-		log.Printf("Cache hit for key %s", keyCache)
+		slog.DebugContext(c, "Cache hit", "key", keyCache)
 		id, _ := strconv.Atoi(cachedProfileMap["ID"])
 		twoFactorEnabled, _ := strconv.ParseBool(cachedProfileMap["TwoFactorEnabled"])
+		twoFactorChannel, _ := strconv.Atoi(cachedProfileMap["TwoFactorChannel"])
+		phoneVerified, _ := strconv.ParseBool(cachedProfileMap["PhoneVerified"])
+		otpNewDevice, _ := strconv.ParseBool(cachedProfileMap["OtpNewDevice"])
 		isActive, _ := strconv.ParseBool(cachedProfileMap["IsActive"])
 		createdAt := cachedProfileMap["CreatedAt"]
 
@@ -68,6 +74,10 @@ func GetProfileUser(c *gin.Context) *models.ProfileResponseJSON {
 			Avatar:            cachedProfileMap["Avatar"],
 			Gender:            gender,
 			TwoFactorEnabled:  twoFactorEnabled,
+			TwoFactorChannel:  twoFactorChannel,
+			PhoneVerified:     phoneVerified,
+			Locale:            cachedProfileMap["Locale"],
+			OtpNewDevice:      otpNewDevice,
 			IsActive:          isActive,
 			CreatedAt:         createdAt,
 		}
@@ -75,15 +85,15 @@ func GetProfileUser(c *gin.Context) *models.ProfileResponseJSON {
 		return &profileResponse
 	}
 
-	log.Printf("Cache miss for key %s", keyCache)
+	slog.DebugContext(c, "Cache miss", "key", keyCache)
 
-	user, err := repo.GetUserId(global.DB, models.GetUserIdParams{
+	user, err := s.app.Repos.Users.GetUserId(c, models.GetUserIdParams{
 		ID:       req.Id,
 		IsActive: true,
 	})
 
 	if err != nil {
-		response.InternalServerError(c, response.ErrCodeDBQuery)
+		respondDBError(c, err, response.ErrCodeDBQuery)
 		return nil
 	}
 
@@ -98,26 +108,35 @@ func GetProfileUser(c *gin.Context) *models.ProfileResponseJSON {
 		"Avatar":            helpers.NullStringToString(user.Avatar),
 		"Gender":            helpers.NullInt16ToString(user.Gender),
 		"TwoFactorEnabled":  user.TwoFactorEnabled,
+		"TwoFactorChannel":  user.TwoFactorChannel,
+		"PhoneVerified":     user.PhoneVerified,
+		"Locale":            helpers.NullStringToString(user.Locale),
+		"OtpNewDevice":      user.OtpNewDevice,
 		"IsActive":          user.IsActive,
 		"CreatedAt":         user.CreatedAt.Format(time.RFC3339),
 	}
 
-	err = global.Cache.HMSet(c, keyCache, profileMap).Err()
+	err = s.app.Cache.HMSet(c, keyCache, profileMap).Err()
 	if err != nil {
-		log.Printf("Failed to set cache: %v", err)
+		slog.ErrorContext(c, "Failed to set cache", "key", keyCache, "error", err)
 		response.BadRequestError(c, response.ErrCodeCacheQuery)
 		return nil
 	} else {
-		log.Printf("Cache set for key %s: %v", keyCache, profileMap)
+		slog.DebugContext(c, "Cache set", "key", keyCache)
 	}
 
 	expireDuration := helpers.RandomExpireDuration(7)
-	if err := global.Cache.Expire(c, keyCache, expireDuration).Err(); err != nil {
-		log.Printf("Failed to set expiration for key %s: %v", keyCache, err)
+	if err := s.app.Cache.Expire(c, keyCache, expireDuration).Err(); err != nil {
+		slog.ErrorContext(c, "Failed to set cache expiration", "key", keyCache, "error", err)
 	}
 
 	// Trả về response
-	response := &models.ProfileResponseJSON{
+	return profileResponseJSON(user)
+}
+
+// profileResponseJSON converts a profile read from the database to its JSON form.
+func profileResponseJSON(user models.ProfileResponse) *models.ProfileResponseJSON {
+	return &models.ProfileResponseJSON{
 		ID:                user.ID,
 		Username:          helpers.NullStringToString(user.Username),
 		Email:             user.Email,
@@ -128,15 +147,17 @@ func GetProfileUser(c *gin.Context) *models.ProfileResponseJSON {
 		Avatar:            helpers.NullStringToString(user.Avatar),
 		Gender:            int(user.Gender.Int16),
 		TwoFactorEnabled:  user.TwoFactorEnabled,
+		TwoFactorChannel:  user.TwoFactorChannel,
+		PhoneVerified:     user.PhoneVerified,
+		Locale:            helpers.NullStringToString(user.Locale),
+		OtpNewDevice:      user.OtpNewDevice,
 		IsActive:          user.IsActive,
 		CreatedAt:         user.CreatedAt.Format(time.RFC3339),
 	}
-
-	return response
 }
 
 // UpdateProfileUser updates the profile of a user based on the provided request body.
-// It validates the request body fields, checks the user's access information, and updates the user's profile in the database.
+// It validates the request body fields, normalises the phone number to E.164, checks the user's access information, and updates the user's profile in the database.
 // If any validation or database error occurs, it returns an appropriate error response.
 // Otherwise, it returns the updated user profile.
 //
@@ -151,7 +172,7 @@ func GetProfileUser(c *gin.Context) *models.ProfileResponseJSON {
 // @Success 200 {object} models.UpdateUserRow
 // @Failure 400 {object} response.ErrorResponse
 // @Router /user/update-profile [post]
-func UpdateProfileUser(c *gin.Context) *models.UpdateUserRow {
+func (s *Service) UpdateProfileUser(c *gin.Context) *models.UpdateUserRow {
 	reqBody := models.BodyUpdateRequest{}
 
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
@@ -163,11 +184,21 @@ func UpdateProfileUser(c *gin.Context) *models.UpdateUserRow {
 		return nil
 	}
 
-	if !validate.ValidateAndRespond(reqBody.Phone, validate.IsValidatePhone) {
-		response.BadRequestError(c, response.ErrorUserPhoneInvalid)
+	if !validate.ValidateAndRespond(reqBody.Locale, validate.IsValidateLocale) {
+		response.BadRequestError(c, response.ErrorUserLocaleInvalid)
 		return nil
 	}
 
+	// Phone numbers are always stored in E.164
+	if reqBody.Phone != "" {
+		phone, err := helpers.NormalizePhone(reqBody.Phone, s.phoneRegion())
+		if err != nil {
+			response.BadRequestError(c, response.ErrorUserPhoneInvalid)
+			return nil
+		}
+		reqBody.Phone = phone
+	}
+
 	payload, existsUserInfo := c.Get(constants.InfoAccess)
 
 	if !existsUserInfo {
@@ -180,13 +211,14 @@ func UpdateProfileUser(c *gin.Context) *models.UpdateUserRow {
 
 	fieldUpdateKeyCache(reqBody, updatedFields)
 
-	resultUpdateProfile, err := repo.UpdateUser(global.DB, models.UpdateUserParams{
+	resultUpdateProfile, err := s.app.Repos.Users.UpdateUser(c, models.UpdateUserParams{
 		Username:          sql.NullString{String: reqBody.Username, Valid: reqBody.Username != ""},
 		Phone:             sql.NullString{String: reqBody.Phone, Valid: reqBody.Phone != ""},
 		Fullname:          sql.NullString{String: reqBody.FullName, Valid: reqBody.FullName != ""},
 		Avatar:            sql.NullString{String: reqBody.Avatar, Valid: reqBody.Avatar != ""},
 		Gender:            sql.NullInt64{Int64: int64(reqBody.Gender), Valid: true},
 		HiddenPhoneNumber: sql.NullString{String: helpers.HidePhoneNumber(reqBody.Phone), Valid: reqBody.Phone != ""},
+		Locale:            sql.NullString{String: reqBody.Locale, Valid: reqBody.Locale != ""},
 		ID:                payload.(models.Payload).ID,
 	})
 
@@ -194,7 +226,7 @@ func UpdateProfileUser(c *gin.Context) *models.UpdateUserRow {
 		//* Error for database
 		errorCreateUser := utils.HandleDBError(err)
 		if errorCreateUser != "" {
-			response.InternalServerError(c, response.ErrCodeDBQuery)
+			respondDBError(c, err, response.ErrCodeDBQuery)
 			return nil
 		}
 
@@ -204,16 +236,25 @@ func UpdateProfileUser(c *gin.Context) *models.UpdateUserRow {
 
 	// Update only the fields that were updated in Redis
 	keyCache := fmt.Sprintf(constants.CacheProfileUser, strconv.Itoa(payload.(models.Payload).ID))
-	if err := global.Cache.HMSet(c, keyCache, updatedFields).Err(); err != nil {
-		log.Printf("Failed to update cache: %v", err)
+	if err := s.app.Cache.HMSet(c, keyCache, updatedFields).Err(); err != nil {
+		slog.ErrorContext(c, "Failed to update cache", "error", err)
+	}
+
+	// Only the names of the changed fields are audited, not their values
+	fields := make([]string, 0, len(updatedFields))
+	for field := range updatedFields {
+		fields = append(fields, field)
 	}
+	sort.Strings(fields)
+	s.recordUserAudit(c, payload.(models.Payload).ID, constants.AuditProfileUpdated, map[string]interface{}{"fields": fields})
 
 	return &resultUpdateProfile
 }
 
 // FieldUpdateKeyCache updates the fields in the provided map based on the values in the request body.
 // It checks each field in the request body and updates the corresponding field in the map if a non-empty value is found.
-// If the "Phone" field is updated, it also updates the "HiddenPhoneNumber" field with a hidden version of the phone number.
+// If the "Phone" field is updated, it also updates the "HiddenPhoneNumber" field with a hidden version of the phone number
+// and resets "PhoneVerified", because a new number has to be verified again.
 func fieldUpdateKeyCache(reqBody models.BodyUpdateRequest, updatedFields map[string]interface{}) {
 	if reqBody.Username != "" {
 		updatedFields["Username"] = reqBody.Username
@@ -221,6 +262,7 @@ func fieldUpdateKeyCache(reqBody models.BodyUpdateRequest, updatedFields map[str
 	if reqBody.Phone != "" {
 		updatedFields["Phone"] = reqBody.Phone
 		updatedFields["HiddenPhoneNumber"] = helpers.HidePhoneNumber(reqBody.Phone)
+		updatedFields["PhoneVerified"] = false
 	}
 	if reqBody.FullName != "" {
 		updatedFields["Fullname"] = reqBody.FullName
@@ -228,6 +270,9 @@ func fieldUpdateKeyCache(reqBody models.BodyUpdateRequest, updatedFields map[str
 	if reqBody.Avatar != "" {
 		updatedFields["Avatar"] = reqBody.Avatar
 	}
+	if reqBody.Locale != "" {
+		updatedFields["Locale"] = reqBody.Locale
+	}
 	if reqBody.Gender >= 0 {
 		updatedFields["Gender"] = reqBody.Gender
 	}
@@ -236,7 +281,7 @@ func fieldUpdateKeyCache(reqBody models.BodyUpdateRequest, updatedFields map[str
 // Logout logs out the user and clears the session.
 // It takes a gin.Context as input and returns a pointer to models.LogoutResponse.
 // If the user_info or device_id is missing in the context, it returns a BadRequestError.
-// Otherwise, it updates the logout time in the database, clears the user login cookie,
+// Otherwise, it updates the logout time in the database together with a session.revoked event, clears the user login cookie,
 // and returns a pointer to models.LogoutResponse containing the user ID and email.
 //
 // @Summary Logout user
@@ -249,7 +294,7 @@ func fieldUpdateKeyCache(reqBody models.BodyUpdateRequest, updatedFields map[str
 // @Success 200 {object} models.LogoutResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Router /user/logout [get]
-func Logout(c *gin.Context) *models.LogoutResponse {
+func (s *Service) Logout(c *gin.Context) *models.LogoutResponse {
 	payload, existsUserInfo := c.Get(constants.InfoAccess)
 	deviceId, existsDevice := c.Get("device_id")
 
@@ -258,13 +303,30 @@ func Logout(c *gin.Context) *models.LogoutResponse {
 		return nil
 	}
 
-	repo.UpdateTimeLogout(global.DB, models.UpdateTimeLogoutParams{
-		LoggedOutAt: sql.NullTime{Time: time.Now()},
-		DeviceId:    deviceId.(string),
+	//* Logout time and session.revoked event are written in one transaction
+	err := s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		if err := tx.Devices.UpdateTimeLogout(c, models.UpdateTimeLogoutParams{
+			LoggedOutAt: sql.NullTime{Time: time.Now(), Valid: true},
+			DeviceId:    deviceId.(string),
+		}); err != nil {
+			return err
+		}
+
+		return enqueueEvent(c, tx.Outbox, payload.(models.Payload).ID, constants.EventSessionRevoked, models.SessionRevokedEvent{
+			UserID:   payload.(models.Payload).ID,
+			DeviceID: deviceId.(string),
+		})
 	})
 
+	if err != nil {
+		respondTxError(c, err)
+		return nil
+	}
+
 	clearCookie(c, constants.UserLoginKey)
 
+	s.recordUserAudit(c, payload.(models.Payload).ID, constants.AuditLogout, nil)
+
 	return &models.LogoutResponse{
 		Id:    payload.(models.Payload).ID,
 		Email: payload.(models.Payload).Email,
@@ -281,7 +343,8 @@ func Logout(c *gin.Context) *models.LogoutResponse {
 // If the password is weak, it returns a BadRequestError response with the error message "PasswordWeak".
 // It then checks the old password against the hashed password stored in the payload.
 // If the old password is not valid, it returns a BadRequestError response with the error message "PasswordHasUsed".
-// The function inserts the old password into the password history table and updates the password in the user table.
+// The function inserts the old password into the password history table and updates the password in the user table
+// in one transaction; if either write fails, the password is unchanged and it returns an InternalServerError response.
 // Finally, it returns a ChangePassResponse object with the user ID and email.
 //
 // @Summary Change user password
@@ -295,7 +358,7 @@ func Logout(c *gin.Context) *models.LogoutResponse {
 // @Success 200 {object} models.ChangePassResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Router /user/change-pass [post]
-func ChangePassword(c *gin.Context) *models.ChangePassResponse {
+func (s *Service) ChangePassword(c *gin.Context) *models.ChangePassResponse {
 	reqBody := models.BodyChangePasswordRequest{}
 
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
@@ -314,24 +377,41 @@ func ChangePassword(c *gin.Context) *models.ChangePassResponse {
 		return nil
 	}
 
-	hashedPassword := checkPasswordOld(reqBody.Password, payload.(models.Payload).ID)
+	hashedPassword := s.checkPasswordOld(c, reqBody.Password, payload.(models.Payload).ID)
 
 	if hashedPassword == nil {
 		response.BadRequestError(c, response.ErrorPasswordIsOld)
 		return nil
 	}
 
-	repo.InsertPasswordHistory(global.DB, models.InsertPasswordHistoryParams{
-		UserID:       payload.(models.Payload).ID,
-		OldPassword:  hashedPassword.Salt,
-		ReasonStatus: constants.ResetPassword,
-	})
+	//* Password history and password are written in one transaction
+	err := s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		if err := tx.PasswordHistory.InsertPasswordHistory(c, models.InsertPasswordHistoryParams{
+			UserID:       payload.(models.Payload).ID,
+			OldPassword:  hashedPassword.Salt,
+			ReasonStatus: constants.ResetPassword,
+		}); err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
 
-	repo.UpdateOnlyPassword(global.DB, models.UpdateOnlyPasswordParams{
-		ID:           payload.(models.Payload).ID,
-		PasswordHash: hashedPassword.HashedPassword,
+		if err := tx.Users.UpdateOnlyPassword(c, models.UpdateOnlyPasswordParams{
+			ID:           payload.(models.Payload).ID,
+			PasswordHash: hashedPassword.HashedPassword,
+		}); err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
+		return nil
 	})
 
+	if err != nil {
+		respondTxError(c, err)
+		return nil
+	}
+
+	s.recordUserAudit(c, payload.(models.Payload).ID, constants.AuditPasswordChanged, nil)
+
 	return &models.ChangePassResponse{
 		Id:    payload.(models.Payload).ID,
 		Email: payload.(models.Payload).Email,
@@ -344,7 +424,8 @@ func ChangePassword(c *gin.Context) *models.ChangePassResponse {
 // If the JSON parsing fails, it responds with a bad request error and returns nil.
 // Then, it retrieves the user information from the Gin context.
 // If the user information does not exist, it responds with a bad request error and returns nil.
-// Finally, it updates the two-factor authentication status for the user in the database
+// The optional channel selects where the OTP is delivered (email by default, SMS only for a verified phone number).
+// Finally, it updates the two-factor authentication status and channel for the user in the database
 // and returns an `UpdateTwoFactorEnableParams` pointer with the updated information.
 //
 // @Summary Enable two-factor authentication
@@ -359,7 +440,7 @@ func ChangePassword(c *gin.Context) *models.ChangePassResponse {
 // @Success 200 {object} models.UpdateTwoFactorEnableParams
 // @Failure 400 {object} response.ErrorResponse
 // @Router /user/enable-tow-factor [post]
-func EnableTowFactor(c *gin.Context) *models.UpdateTwoFactorEnableParams {
+func (s *Service) EnableTowFactor(c *gin.Context) *models.UpdateTwoFactorEnableParams {
 	reqBody := models.BodyTwoFactorEnableRequest{}
 
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
@@ -374,24 +455,233 @@ func EnableTowFactor(c *gin.Context) *models.UpdateTwoFactorEnableParams {
 		return nil
 	}
 
-	repo.UpdateTwoFactorEnable(global.DB, models.UpdateTwoFactorEnableParams{
+	channel := reqBody.Channel
+	if channel == 0 {
+		channel = constants.OtpChannelEmail
+	}
+
+	switch channel {
+	case constants.OtpChannelEmail:
+	case constants.OtpChannelSMS:
+		user, err := s.app.Repos.Users.GetUserId(c, models.GetUserIdParams{
+			ID:       payload.(models.Payload).ID,
+			IsActive: true,
+		})
+		if err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return nil
+		}
+
+		if !user.PhoneVerified {
+			response.BadRequestError(c, response.ErrorUserPhoneNotVerified)
+			return nil
+		}
+	default:
+		response.BadRequestError(c, response.ErrorOTPChannelInvalid)
+		return nil
+	}
+
+	s.app.Repos.Users.UpdateTwoFactorEnable(c, models.UpdateTwoFactorEnableParams{
 		ID:               payload.(models.Payload).ID,
 		TwoFactorEnabled: reqBody.TwoFactorEnabled,
+		TwoFactorChannel: channel,
 	})
 
 	keyCache := fmt.Sprintf(constants.CacheProfileUser, strconv.Itoa(payload.(models.Payload).ID))
 
 	updatedFields := map[string]interface{}{
 		"TwoFactorEnabled": strconv.FormatBool(reqBody.TwoFactorEnabled),
+		"TwoFactorChannel": channel,
 	}
 
-	if err := global.Cache.HMSet(c, keyCache, updatedFields).Err(); err != nil {
-		log.Printf("Failed to update cache: %v", err)
+	if err := s.app.Cache.HMSet(c, keyCache, updatedFields).Err(); err != nil {
+		slog.ErrorContext(c, "Failed to update cache", "error", err)
 	}
 
+	s.recordUserAudit(c, payload.(models.Payload).ID, constants.AuditTwoFactorChanged, map[string]interface{}{
+		"enabled": reqBody.TwoFactorEnabled,
+		"channel": channel,
+	})
+
 	return &models.UpdateTwoFactorEnableParams{
 		ID:               payload.(models.Payload).ID,
 		TwoFactorEnabled: reqBody.TwoFactorEnabled,
+		TwoFactorChannel: channel,
+	}
+}
+
+// SendOtpVerifyPhone sends an OTP by SMS to the phone number saved on the user's profile.
+// It takes a gin.Context object as a parameter and returns a pointer to a models.SendOtpResponse object.
+// The function rejects users without a phone number or with an already verified phone number,
+// limits how often a user can ask for a code, stores the OTP with the SMS channel
+// and sends it through the configured SMS gateway.
+// The OTP code itself is never returned in the response.
+//
+// @Summary Send OTP to verify phone
+// @Description Sends an OTP by SMS to verify the user's phone number
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param X-Device-Id header string true "Device ID"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Success 200 {object} models.SendOtpResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /user/send-otp-phone [post]
+func (s *Service) SendOtpVerifyPhone(c *gin.Context) *models.SendOtpResponse {
+	payload, existsUserInfo := c.Get(constants.InfoAccess)
+	if !existsUserInfo {
+		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+
+	userId := payload.(models.Payload).ID
+
+	resultSpam := redis.SpamUser(c, s.app.Cache, fmt.Sprintf(constants.SpamKeyOtpPhone, userId), constants.RequestThresholdOtpPhone)
+	if resultSpam != nil && resultSpam.IsSpam {
+		metrics.SpamBlocks.WithLabelValues(constants.SpamActionOtpPhone).Inc()
+		ttl := fmt.Sprintf("You are blocked for %d seconds", resultSpam.ExpiredSpam)
+		response.BadRequestError(c, response.ErrIpBlackList, ttl)
+		return nil
+	}
+
+	user, err := s.app.Repos.Users.GetUserId(c, models.GetUserIdParams{
+		ID:       userId,
+		IsActive: true,
+	})
+	if err != nil {
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+
+	if !user.Phone.Valid || user.Phone.String == "" {
+		response.BadRequestError(c, response.ErrorUserPhoneNotExit)
+		return nil
+	}
+
+	if user.PhoneVerified {
+		response.BadRequestError(c, response.ErrorUserPhoneVerified)
+		return nil
+	}
+
+	expiredAt := time.Now().Add(time.Minute * 5)
+
+	resultOTP := s.SendOtp(c, s.app.Repos.OTPs, models.CreateOtpParams{
+		UserID:    userId,
+		Channel:   constants.OtpChannelSMS,
+		Purpose:   constants.OtpPurposeVerifyPhone,
+		Phone:     user.Phone,
+		ExpiresAt: expiredAt,
+	})
+	if resultOTP == nil {
+		response.BadRequestError(c, response.ErrorOTPNotExit)
+		return nil
+	}
+
+	if err := s.sendOtpSMS(user.Phone.String, "Verify Phone!", resultOTP.Code); err != nil {
+		slog.ErrorContext(c, "Failed to send OTP SMS", "error", err)
+		response.InternalServerError(c, response.ErrCodeExternalService)
+		return nil
+	}
+
+	s.recordUserAudit(c, userId, constants.AuditOtpSent, map[string]interface{}{"channel": constants.OtpChannelSMS, "purpose": "verify_phone"})
+	metrics.OtpSent.WithLabelValues(otpChannelName(constants.OtpChannelSMS), constants.OtpPurposeVerifyPhone).Inc()
+
+	return &models.SendOtpResponse{
+		Id:        userId,
+		Channel:   constants.OtpChannelSMS,
+		ExpiredAt: resultOTP.ExpiredAt,
+	}
+}
+
+// VerifyPhone verifies the user's phone number with the OTP sent by SendOtpVerifyPhone.
+// It takes a gin.Context object as a parameter and returns a pointer to a models.VerifyPhoneResponse object.
+// The OTP must have been delivered by SMS to the user's current phone number and belong to the logged in user.
+// On success it sets the phone verified flag in the database and in the profile cache.
+//
+// @Summary Verify phone
+// @Description Verifies the user's phone number with an SMS OTP
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param X-Device-Id header string true "Device ID"
+// @Param body body models.BodyVerifyPhoneRequest true "Verify phone request body"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Success 200 {object} models.VerifyPhoneResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /user/verify-phone [post]
+func (s *Service) VerifyPhone(c *gin.Context) *models.VerifyPhoneResponse {
+	reqBody := models.BodyVerifyPhoneRequest{}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+
+	payload, existsUserInfo := c.Get(constants.InfoAccess)
+	if !existsUserInfo {
+		response.BadRequestError(c, response.ErrCodeInvalidFormat)
+		return nil
+	}
+
+	userId := payload.(models.Payload).ID
+
+	resultInfo := s.VeriOtp(c, s.app.Repos.OTPs, models.GetNewOtpsParams{
+		OtpCode: reqBody.Otp,
+		Purpose: constants.OtpPurposeVerifyPhone,
+		UserID:  sql.NullInt32{Int32: int32(userId), Valid: true},
+		Channel: sql.NullInt32{Int32: constants.OtpChannelSMS, Valid: true},
+	})
+	if resultInfo == nil {
+		s.recordUserAudit(c, userId, constants.AuditOtpFailed, map[string]interface{}{"purpose": "verify_phone"})
+		metrics.OtpVerified.WithLabelValues(constants.OtpPurposeVerifyPhone, constants.MetricResultFailure).Inc()
+		response.BadRequestError(c, response.ErrorOTPNotExit)
+		return nil
+	}
+
+	user, err := s.app.Repos.Users.GetUserId(c, models.GetUserIdParams{
+		ID:       userId,
+		IsActive: true,
+	})
+	if err != nil {
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+
+	// The OTP only proves the number it was sent to: the phone may have been changed since
+	if !user.Phone.Valid || resultInfo.Phone.String != user.Phone.String {
+		s.recordUserAudit(c, userId, constants.AuditOtpFailed, map[string]interface{}{"purpose": "verify_phone", "reason": "phone_changed"})
+		metrics.OtpVerified.WithLabelValues(constants.OtpPurposeVerifyPhone, constants.MetricResultFailure).Inc()
+		response.BadRequestError(c, response.ErrorOTPInvalid)
+		return nil
+	}
+
+	err = s.app.Repos.Users.UpdatePhoneVerified(c, models.UpdatePhoneVerifiedParams{
+		ID:            userId,
+		PhoneVerified: true,
+	})
+	if err != nil {
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+
+	keyCache := fmt.Sprintf(constants.CacheProfileUser, strconv.Itoa(userId))
+
+	updatedFields := map[string]interface{}{
+		"PhoneVerified": strconv.FormatBool(true),
+	}
+
+	if err := s.app.Cache.HMSet(c, keyCache, updatedFields).Err(); err != nil {
+		slog.ErrorContext(c, "Failed to update cache", "error", err)
+	}
+
+	s.recordUserAudit(c, userId, constants.AuditPhoneVerified, nil)
+	metrics.OtpVerified.WithLabelValues(constants.OtpPurposeVerifyPhone, constants.MetricResultSuccess).Inc()
+
+	return &models.VerifyPhoneResponse{
+		Id:                userId,
+		HiddenPhoneNumber: helpers.NullStringToString(user.HiddenPhoneNumber),
+		PhoneVerified:     true,
 	}
 }
 
@@ -405,7 +695,7 @@ func EnableTowFactor(c *gin.Context) *models.UpdateTwoFactorEnableParams {
 // If the email exists, it returns a bad request error response and nil.
 // It then calls the SendOtp function to generate an OTP for the user.
 // If the OTP generation fails, it returns a bad request error response and nil.
-// The function constructs an email data object with the OTP code and sends it asynchronously using the SendGoEmail function.
+// The OTP and an email data object with the OTP code are written to the database and the outbox in one transaction.
 // Finally, it returns a pointer to a models.SendOtpResponse object containing the user's ID, OTP code, and expiration time.
 
 // @Summary Send OTP to update email
@@ -420,7 +710,7 @@ func EnableTowFactor(c *gin.Context) *models.UpdateTwoFactorEnableParams {
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /user/send-otp-update-email [post]
-func SendOtpUpdateEmail(c *gin.Context) *models.SendOtpResponse {
+func (s *Service) SendOtpUpdateEmail(c *gin.Context) *models.SendOtpResponse {
 	// Parse the request body into a models.UpdateEmailParams object
 	reqBody := models.UpdateEmailParams{}
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
@@ -436,12 +726,12 @@ func SendOtpUpdateEmail(c *gin.Context) *models.SendOtpResponse {
 	}
 
 	// Check if the email already exists in the database for any other user
-	emailExists, err := repo.CheckEmailExists(global.DB, models.CheckEmailExistsParams{
+	emailExists, err := s.app.Repos.Users.CheckEmailExists(c, models.CheckEmailExistsParams{
 		Email: reqBody.Email,
 		ID:    payload.(models.Payload).ID,
 	})
 	if err != nil {
-		response.InternalServerError(c, response.ErrCodeDBQuery)
+		respondDBError(c, err, response.ErrCodeDBQuery)
 		return nil
 	}
 
@@ -450,22 +740,52 @@ func SendOtpUpdateEmail(c *gin.Context) *models.SendOtpResponse {
 		return nil
 	}
 
+	// The email is written in the language the user chose, read from their profile
+	user, err := s.app.Repos.Users.GetUserId(c, models.GetUserIdParams{
+		ID:       payload.(models.Payload).ID,
+		IsActive: true,
+	})
+	if err != nil {
+		respondDBError(c, err, response.ErrCodeDBQuery)
+		return nil
+	}
+
 	expiredAt := time.Now().Add(time.Hour)
 
-	// Generate an OTP for the user
-	resultOTP := SendOtp(c, payload.(models.Payload).ID, expiredAt)
-	if resultOTP == nil {
-		response.BadRequestError(c, response.ErrorOTPNotExit)
+	var resultOTP *models.SendOtpResponse
+
+	// Generate an OTP for the user and queue the email in one transaction
+	err = s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		resultOTP = s.SendOtp(c, tx.OTPs, models.CreateOtpParams{
+			UserID:    payload.(models.Payload).ID,
+			Channel:   constants.OtpChannelEmail,
+			Purpose:   constants.OtpPurposeUpdateEmail,
+			ExpiresAt: expiredAt,
+		})
+		if resultOTP == nil {
+			response.BadRequestError(c, response.ErrorOTPNotExit)
+			return errOtpNotCreated
+		}
+
+		// Construct an email data object with the OTP code
+		data := models.EmailData{
+			Template: constants.EmailTemplateUpdateEmailOtp,
+			Locale:   emailLocale(c, helpers.NullStringToString(user.Locale)),
+			Body:     resultOTP.Code,
+		}
+
+		return enqueueEmail(c, tx.Outbox, payload.(models.Payload).ID, reqBody.Email, data)
+	})
+
+	if err != nil {
+		respondTxError(c, err)
 		return nil
 	}
 
-	// Construct an email data object with the OTP code and send it asynchronously
-	data := models.EmailData{
-		Title:    "Update Email OTP",
-		Body:     resultOTP.Code,
-		Template: `<h1>{{.Title}}</h1> <p style="font-size: large;">Thank you for updating your email. Here is your OTP: <b>{{.Body}}</b></p>`,
-	}
-	go pkg.SendGoEmail(reqBody.Email, data)
+	s.recordUserAudit(c, payload.(models.Payload).ID, constants.AuditEmailChangeRequested, map[string]interface{}{
+		"new_email": helpers.HideEmail(reqBody.Email),
+	})
+	metrics.OtpSent.WithLabelValues(otpChannelName(constants.OtpChannelEmail), constants.OtpPurposeUpdateEmail).Inc()
 
 	// Return a pointer to a models.SendOtpResponse object containing the user's ID, OTP code, and expiration time
 	return &models.SendOtpResponse{
@@ -477,6 +797,8 @@ func SendOtpUpdateEmail(c *gin.Context) *models.SendOtpResponse {
 
 // UpdateEmailUser updates the email of a user based on the provided request body.
 // It validates the request body fields, checks the user's access information, and updates the user's email in the database.
+// The OTP is used up, the email updated and the device given its new key in one transaction,
+// so an email taken by another user leaves the OTP usable and the session unchanged.
 // If any validation or database error occurs, it returns an appropriate error response.
 // Otherwise, it returns the updated user email.
 //
@@ -491,7 +813,7 @@ func SendOtpUpdateEmail(c *gin.Context) *models.SendOtpResponse {
 // @Success 200 {object} models.LoginResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Router /user/update-email [post]
-func UpdateEmailUser(c *gin.Context) *models.LoginResponse {
+func (s *Service) UpdateEmailUser(c *gin.Context) *models.LoginResponse {
 	reqBody := models.BodyUpdateEmailRequest{}
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
 		response.BadRequestError(c, response.ErrCodeInvalidFormat)
@@ -504,18 +826,64 @@ func UpdateEmailUser(c *gin.Context) *models.LoginResponse {
 		return nil
 	}
 
-	resultInfo := VeriOtp(c, reqBody.Otp)
-	if resultInfo == nil {
-		response.BadRequestError(c, response.ErrorOTPNotExit)
+	accessToken, refetchToken, resultEncodePublicKey := createKeyAndToken(models.UserIDEmail{
+		ID:    payload.(models.Payload).ID,
+		Email: reqBody.Email,
+	})
+
+	if accessToken == "" || refetchToken == "" || resultEncodePublicKey == "" {
+		response.BadRequestError(c, response.ErrCodeAuthTokenInvalid)
 		return nil
 	}
 
-	repo.UpdateEmail(global.DB, models.UpdateEmailParams{
-		Email:       reqBody.Email,
-		ID:          payload.(models.Payload).ID,
-		HiddenEmail: helpers.HideEmail(reqBody.Email),
+	var resultInfoDevice *models.Device
+
+	//* OTP, email and device are written in one transaction
+	err := s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		resultInfo := s.VeriOtp(c, tx.OTPs, models.GetNewOtpsParams{
+			OtpCode: reqBody.Otp,
+			Purpose: constants.OtpPurposeUpdateEmail,
+			UserID:  sql.NullInt32{Int32: int32(payload.(models.Payload).ID), Valid: true},
+			Channel: sql.NullInt32{Int32: constants.OtpChannelEmail, Valid: true},
+		})
+		if resultInfo == nil {
+			response.BadRequestError(c, response.ErrorOTPNotExit)
+			return errOtpInvalid
+		}
+
+		if err := tx.Users.UpdateEmail(c, models.UpdateEmailParams{
+			Email:       reqBody.Email,
+			ID:          payload.(models.Payload).ID,
+			HiddenEmail: helpers.HideEmail(reqBody.Email),
+		}); err != nil {
+			//* Error for database
+			errorUpdateEmail := utils.HandleDBError(err)
+			if errorUpdateEmail != "" {
+				response.BadRequestError(c, response.ErrUserDuplicateEmail)
+				return err
+			}
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
+
+		resultInfoDevice = s.upsetDevice(c, tx.Devices, payload.(models.Payload).ID, resultEncodePublicKey)
+		if resultInfoDevice == nil {
+			return errDeviceNotSaved
+		}
+		return nil
 	})
 
+	if errors.Is(err, errOtpInvalid) {
+		s.recordUserAudit(c, payload.(models.Payload).ID, constants.AuditOtpFailed, map[string]interface{}{"purpose": "update_email"})
+		metrics.OtpVerified.WithLabelValues(constants.OtpPurposeUpdateEmail, constants.MetricResultFailure).Inc()
+	}
+
+	if err != nil {
+		respondTxError(c, err)
+		return nil
+	}
+	metrics.OtpVerified.WithLabelValues(constants.OtpPurposeUpdateEmail, constants.MetricResultSuccess).Inc()
+
 	keyCache := fmt.Sprintf(constants.CacheProfileUser, strconv.Itoa(payload.(models.Payload).ID))
 
 	updatedFields := map[string]interface{}{
@@ -523,29 +891,23 @@ func UpdateEmailUser(c *gin.Context) *models.LoginResponse {
 		"HiddenEmail": helpers.HideEmail(reqBody.Email),
 	}
 
-	if err := global.Cache.HMSet(c, keyCache, updatedFields).Err(); err != nil {
-		log.Printf("Failed to update cache: %v", err)
+	if err := s.app.Cache.HMSet(c, keyCache, updatedFields).Err(); err != nil {
+		slog.ErrorContext(c, "Failed to update cache", "error", err)
 	}
 
-	result, err := helpers.GetUserUIDByEmail(c, reqBody.Email)
+	result, err := helpers.GetUserUIDByEmail(c, s.app.Firebase, reqBody.Email)
 
 	if err == nil {
-		go helpers.UpdateUserEmail(c, result, reqBody.Email)
+		//* Detached from the request, which is cancelled once the response is written
+		go helpers.UpdateUserEmail(context.WithoutCancel(c.Request.Context()), s.app.Firebase, result, reqBody.Email)
 	}
 
-	accessToken, refetchToken, resultEncodePublicKey := createKeyAndToken(models.UserIDEmail{
-		ID:    payload.(models.Payload).ID,
-		Email: reqBody.Email,
-	})
+	s.setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
 
-	if accessToken == "" || refetchToken == "" || resultEncodePublicKey == "" {
-		response.BadRequestError(c, response.ErrCodeAuthTokenInvalid)
-		return nil
-	}
-
-	resultInfoDevice := upsetDevice(c, payload.(models.Payload).ID, resultEncodePublicKey)
-
-	setCookie(c, constants.UserLoginKey, refetchToken, "/", constants.AgeCookie)
+	s.recordUserAudit(c, payload.(models.Payload).ID, constants.AuditEmailChanged, map[string]interface{}{
+		"old_email": helpers.HideEmail(payload.(models.Payload).Email),
+		"new_email": helpers.HideEmail(reqBody.Email),
+	})
 
 	return &models.LoginResponse{
 		ID:          payload.(models.Payload).ID,
@@ -581,9 +943,12 @@ func clearCookie(c *gin.Context, cookieName string) {
 
 // DestroyAccount is a handler function that destroys a user account.
 // It takes a Gin context as input and returns a DestroyAccountResponse pointer.
+// The account is deactivated and every device logged out at once, but its data is only erased when the
+// grace period (account.deletiongracedays) is over: until then the link emailed to the user restores it.
+// The account_deletion cron job then anonymises the account and deletes it from Firebase.
 // If the user information is not found in the context, it returns a BadRequestError.
-// If there is an error while destroying the account or deleting the cache, it returns an InternalServerError.
-// Otherwise, it deletes the cache, clears the user login cookie, and returns a DestroyAccountResponse with the user ID.
+// If there is an error while destroying the account, it returns an InternalServerError.
+// Otherwise, it deletes the cache, clears the user login cookie, and returns a DestroyAccountResponse with the deletion date.
 //
 // @Summary Destroy user account
 // @Description Destroys a user account
@@ -596,29 +961,74 @@ func clearCookie(c *gin.Context, cookieName string) {
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /user/destroy-account [get]
-func DestroyAccount(c *gin.Context) *models.DestroyAccountResponse {
+func (s *Service) DestroyAccount(c *gin.Context) *models.DestroyAccountResponse {
 	payload, existsUserInfo := c.Get(constants.InfoAccess)
 	if !existsUserInfo {
 		response.BadRequestError(c, response.ErrCodeInvalidFormat)
 		return nil
 	}
 
-	err := repo.DestroyAccount(global.DB, payload.(models.Payload).ID)
+	userId := payload.(models.Payload).ID
 
+	token, err := helpers.GenerateToken()
 	if err != nil {
-		response.InternalServerError(c, response.ErrCodeDBQuery)
+		response.InternalServerError(c, response.ErrCodeInternalServer)
 		return nil
 	}
 
-	keyCache := fmt.Sprintf(constants.CacheProfileUser, strconv.Itoa(payload.(models.Payload).ID))
+	var scheduled models.ScheduleAccountDeletionRow
+
+	//* Deactivation, devices and cancel email are written in one transaction
+	err = s.app.Repos.WithTx(c, func(tx repo.Repositories) error {
+		var err error
+		scheduled, err = tx.Deletions.ScheduleAccountDeletion(c, models.ScheduleAccountDeletionParams{
+			ID:          userId,
+			CancelToken: token,
+			ScheduledAt: time.Now().Add(s.deletionGracePeriod()),
+		})
+		if err == sql.ErrNoRows {
+			response.BadRequestError(c, response.ErrUserNotActive)
+			return err
+		}
+		if err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
+
+		if err := tx.Devices.DeactivateUserDevices(c, userId); err != nil {
+			respondDBError(c, err, response.ErrCodeDBQuery)
+			return err
+		}
+
+		return enqueueEmail(c, tx.Outbox, userId, scheduled.Email, models.EmailData{
+			Template: constants.EmailTemplateAccountDeletion,
+			Locale:   emailLocale(c, helpers.NullStringToString(scheduled.Locale)),
+			Body:     fmt.Sprintf("%s/auth/cancel-deletion/%s", s.app.Cfg.Server.PortFrontend, token),
+			Details: map[string]string{
+				"scheduled_at": scheduled.ScheduledAt.Format(time.RFC1123),
+			},
+		})
+	})
+
+	if err != nil {
+		respondTxError(c, err)
+		return nil
+	}
 
-	if err := global.Cache.Del(c, keyCache).Err(); err != nil {
-		log.Printf("Failed to Delete cache: %v", err)
+	keyCache := fmt.Sprintf(constants.CacheProfileUser, strconv.Itoa(userId))
+
+	if err := s.app.Cache.Del(c, keyCache).Err(); err != nil {
+		slog.ErrorContext(c, "Failed to delete cache", "error", err)
 	}
 
 	clearCookie(c, constants.UserLoginKey)
 
+	s.recordUserAudit(c, userId, constants.AuditAccountDestroyed, map[string]interface{}{
+		"scheduled_at": scheduled.ScheduledAt,
+	})
+
 	return &models.DestroyAccountResponse{
-		Id: payload.(models.Payload).ID,
+		Id:          userId,
+		ScheduledAt: scheduled.ScheduledAt,
 	}
 }
@@ -0,0 +1,18 @@
+CREATE TABLE mail_log (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    template VARCHAR(100) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    status SMALLINT NOT NULL,
+    provider_message_id VARCHAR(255),
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_mail_log_user_id ON mail_log (user_id);
+CREATE UNIQUE INDEX idx_mail_log_provider_message_id ON mail_log (provider_message_id);
+
+ALTER TABLE users
+    ADD COLUMN email_undeliverable BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN email_undeliverable_at TIMESTAMP;
@@ -0,0 +1,26 @@
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INT,
+    subject_id INT,
+    event_type VARCHAR(100) NOT NULL,
+    ip VARCHAR(45),
+    device_id VARCHAR(255),
+    user_agent TEXT,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_events_subject_id ON audit_events (subject_id, id);
+CREATE INDEX idx_audit_events_actor_id ON audit_events (actor_id, id);
+CREATE INDEX idx_audit_events_event_type ON audit_events (event_type, id);
+
+-- Audit events are append-only: rows can be inserted but never changed or removed
+CREATE OR REPLACE FUNCTION prevent_audit_events_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE
+ON audit_events FOR EACH ROW EXECUTE FUNCTION prevent_audit_events_change();
@@ -0,0 +1,20 @@
+ALTER TABLE users
+    ADD COLUMN otp_new_device BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE sign_ins (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id),
+    device_id VARCHAR(100) NOT NULL,
+    device_type VARCHAR(200),
+    ip VARCHAR(100),
+    network VARCHAR(100),
+    new_device BOOLEAN NOT NULL DEFAULT FALSE,
+    new_network BOOLEAN NOT NULL DEFAULT FALSE,
+    revoke_token VARCHAR(100) UNIQUE NOT NULL,
+    revoke_expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_sign_ins_user_device ON sign_ins (user_id, device_id);
+CREATE INDEX idx_sign_ins_user_network ON sign_ins (user_id, network);
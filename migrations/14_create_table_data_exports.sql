@@ -0,0 +1,14 @@
+CREATE TABLE data_exports (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    status SMALLINT NOT NULL,
+    token VARCHAR(100) UNIQUE NOT NULL,
+    file_path TEXT,
+    error TEXT,
+    attempts SMALLINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_data_exports_user_status ON data_exports (user_id, status);
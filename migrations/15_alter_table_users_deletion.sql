@@ -0,0 +1,8 @@
+ALTER TABLE users
+    ADD COLUMN deletion_requested_at TIMESTAMP,
+    ADD COLUMN deletion_scheduled_at TIMESTAMP,
+    ADD COLUMN deletion_cancel_token VARCHAR(100) UNIQUE,
+    ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_users_deletion_scheduled_at ON users (deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,16 @@
+CREATE TABLE job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job_name VARCHAR(100) NOT NULL,
+    fence_token BIGINT NOT NULL,
+    trigger VARCHAR(20) NOT NULL,
+    status SMALLINT NOT NULL,
+    rows BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    owner VARCHAR(255) NOT NULL,
+    triggered_by INT REFERENCES users(id) ON DELETE SET NULL,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    UNIQUE (job_name, fence_token)
+);
+
+CREATE INDEX idx_job_runs_job_name_id ON job_runs (job_name, id DESC);
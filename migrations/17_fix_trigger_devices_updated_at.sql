@@ -0,0 +1,6 @@
+-- 3_create_table_devices created the trigger of devices on users,
+-- so updated_at of devices never changed and users got a second, redundant trigger
+DROP TRIGGER IF EXISTS update_devices_updated_at ON users;
+
+CREATE TRIGGER update_devices_updated_at BEFORE UPDATE
+ON devices FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
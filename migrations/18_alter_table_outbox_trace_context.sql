@@ -0,0 +1,2 @@
+-- The trace context of the request that wrote the message, so the consumer continues its trace
+ALTER TABLE outbox ADD COLUMN trace_context JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,15 @@
+-- The body of an email (a password, an OTP or a link with a token), kept out of the outbox payload
+-- and so out of the RabbitMQ messages and the dead-letter queue. The consumer reads it when it sends the email.
+CREATE TABLE outbox_secrets (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_secrets_user_id ON outbox_secrets (user_id);
+CREATE INDEX idx_outbox_secrets_expires_at ON outbox_secrets (expires_at);
+
+-- Published rows were sent with their body: remove it
+UPDATE outbox SET payload = payload - 'body' WHERE status <> 10 AND payload ? 'body';
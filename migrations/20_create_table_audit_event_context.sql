@@ -0,0 +1,18 @@
+-- The IP, device ID and user agent of an audit event are personal data, erased with the account.
+-- audit_events is append-only, so they move to this table, whose rows can be deleted.
+CREATE TABLE audit_event_context (
+    event_id BIGINT PRIMARY KEY REFERENCES audit_events(id),
+    ip VARCHAR(45),
+    device_id VARCHAR(255),
+    user_agent TEXT
+);
+
+INSERT INTO audit_event_context (event_id, ip, device_id, user_agent)
+SELECT id, ip, device_id, user_agent
+FROM audit_events
+WHERE ip IS NOT NULL OR device_id IS NOT NULL OR user_agent IS NOT NULL;
+
+ALTER TABLE audit_events
+    DROP COLUMN ip,
+    DROP COLUMN device_id,
+    DROP COLUMN user_agent;
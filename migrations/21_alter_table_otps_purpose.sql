@@ -0,0 +1,4 @@
+-- An OTP is only accepted for what it was sent for, and a phone verification OTP only for the number it was sent to
+ALTER TABLE otps
+    ADD COLUMN purpose VARCHAR(20) NOT NULL DEFAULT 'login',
+    ADD COLUMN phone VARCHAR(20);
@@ -0,0 +1,6 @@
+-- file_path now holds the key of the archive in the export storage, its file name <token>.zip,
+-- instead of a path on the disk of the queue consumer, so every binary finds the archive
+-- wherever the export storage is mounted
+UPDATE data_exports
+SET file_path = regexp_replace(file_path, '^.*/', '')
+WHERE file_path LIKE '%/%';
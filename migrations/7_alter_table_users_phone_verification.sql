@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN phone_verified BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN two_factor_channel SMALLINT NOT NULL DEFAULT 10;
+
+ALTER TABLE otps
+    ADD COLUMN channel SMALLINT NOT NULL DEFAULT 10;
@@ -0,0 +1,53 @@
+-- Normalise existing phone numbers to E.164.
+-- Before this migration only Vietnamese numbers (0xxxxxxxxx) were accepted,
+-- so national numbers are rewritten with the +84 country code: it assumes the region VN.
+-- The migrator sets app.phone_region from phone.defaultregion; with another region the migration
+-- refuses to run while numbers would get the +84 country code. Rewrite those numbers to E.164 by hand
+-- first, or set phone.defaultregion to VN for this migration if they are Vietnamese.
+-- If the same number was stored in two formats the unique constraint on phone stops the
+-- migration; those accounts have to be merged by hand first.
+
+-- Refuse to give +84 to the numbers of another region (unset when run outside the migrator: VN, the default)
+DO $$
+DECLARE
+    region TEXT := upper(coalesce(nullif(current_setting('app.phone_region', true), ''), 'VN'));
+BEGIN
+    IF region <> 'VN' AND EXISTS (
+        SELECT 1 FROM users
+        WHERE regexp_replace(phone, '[^0-9+]', '', 'g') ~ '^(0|84)[0-9]{9,10}$'
+    ) THEN
+        RAISE EXCEPTION 'phone numbers without a country code would be rewritten with +84 but the phone region is %', region
+            USING HINT = 'Rewrite them to E.164 by hand, or set phone.defaultregion to VN if they are Vietnamese';
+    END IF;
+END
+$$;
+
+-- Strip spaces, dots, dashes and brackets
+UPDATE users
+SET phone = regexp_replace(phone, '[^0-9+]', '', 'g')
+WHERE phone IS NOT NULL;
+
+-- 0xxxxxxxxx -> +84xxxxxxxxx
+UPDATE users
+SET phone = '+84' || substring(phone FROM 2)
+WHERE phone ~ '^0[0-9]{9,10}$';
+
+-- 84xxxxxxxxx -> +84xxxxxxxxx
+UPDATE users
+SET phone = '+' || phone
+WHERE phone ~ '^84[0-9]{9,10}$';
+
+-- Empty values become NULL
+UPDATE users
+SET phone = NULL, hidden_phone_number = NULL
+WHERE phone = '';
+
+-- Keep the hidden phone number in sync (only the last four digits are shown)
+UPDATE users
+SET hidden_phone_number = repeat('*', length(phone) - 4) || right(phone, 4)
+WHERE phone IS NOT NULL AND length(phone) >= 10;
+
+-- A removed phone number cannot stay verified
+UPDATE users
+SET phone_verified = false
+WHERE phone IS NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    DROP COLUMN email_undeliverable_at,
+    DROP COLUMN email_undeliverable;
+
+DROP TABLE mail_log;
@@ -0,0 +1,3 @@
+DROP TABLE audit_events;
+
+DROP FUNCTION prevent_audit_events_change();
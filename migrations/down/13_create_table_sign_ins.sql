@@ -0,0 +1,4 @@
+DROP TABLE sign_ins;
+
+ALTER TABLE users
+    DROP COLUMN otp_new_device;
@@ -0,0 +1,7 @@
+DROP INDEX idx_users_deletion_scheduled_at;
+
+ALTER TABLE users
+    DROP COLUMN deleted_at,
+    DROP COLUMN deletion_cancel_token,
+    DROP COLUMN deletion_scheduled_at,
+    DROP COLUMN deletion_requested_at;
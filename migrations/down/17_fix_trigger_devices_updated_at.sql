@@ -0,0 +1,5 @@
+-- Put the trigger back where 3_create_table_devices created it
+DROP TRIGGER update_devices_updated_at ON devices;
+
+CREATE TRIGGER update_devices_updated_at BEFORE UPDATE
+ON users FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
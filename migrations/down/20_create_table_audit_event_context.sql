@@ -0,0 +1,18 @@
+ALTER TABLE audit_events
+    ADD COLUMN ip VARCHAR(45),
+    ADD COLUMN device_id VARCHAR(255),
+    ADD COLUMN user_agent TEXT;
+
+-- The context is copied back into the append-only rows
+ALTER TABLE audit_events DISABLE TRIGGER audit_events_append_only;
+
+UPDATE audit_events
+SET ip = audit_event_context.ip,
+    device_id = audit_event_context.device_id,
+    user_agent = audit_event_context.user_agent
+FROM audit_event_context
+WHERE audit_event_context.event_id = audit_events.id;
+
+ALTER TABLE audit_events ENABLE TRIGGER audit_events_append_only;
+
+DROP TABLE audit_event_context;
@@ -0,0 +1,3 @@
+ALTER TABLE otps
+    DROP COLUMN phone,
+    DROP COLUMN purpose;
@@ -0,0 +1 @@
+-- The directories of the archives are not kept: file_path keeps the keys of the archives.
@@ -0,0 +1,4 @@
+-- The trigger of devices was created on users by mistake, see 17_fix_trigger_devices_updated_at
+DROP TRIGGER IF EXISTS update_devices_updated_at ON users;
+
+DROP TABLE devices;
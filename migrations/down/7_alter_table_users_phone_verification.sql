@@ -0,0 +1,6 @@
+ALTER TABLE otps
+    DROP COLUMN channel;
+
+ALTER TABLE users
+    DROP COLUMN two_factor_channel,
+    DROP COLUMN phone_verified;
@@ -0,0 +1 @@
+-- The backfill cannot be undone: the original formats of the phone numbers are not kept.
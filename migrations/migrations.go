@@ -0,0 +1,12 @@
+// Package migrations embeds the schema migrations, so the binaries can apply them without the source tree.
+//
+// Every migration is a numbered file, N_name.sql, applied in the order of N.
+// The file with the same name in down/ reverts it.
+package migrations
+
+import "embed"
+
+// FS holds the migrations and their down files.
+//
+//go:embed *.sql down/*.sql
+var FS embed.FS
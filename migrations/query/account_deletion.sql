@@ -0,0 +1,93 @@
+-- name: ScheduleAccountDeletion :one
+UPDATE users
+SET is_active = FALSE, deletion_requested_at = NOW(), deletion_scheduled_at = $3, deletion_cancel_token = $2
+WHERE id = $1 AND is_active = TRUE AND deleted_at IS NULL
+RETURNING id, email, locale, deletion_scheduled_at;
+
+-- name: CancelAccountDeletion :one
+UPDATE users
+SET is_active = TRUE, deletion_requested_at = NULL, deletion_scheduled_at = NULL, deletion_cancel_token = NULL
+WHERE deletion_cancel_token = $1 AND deletion_scheduled_at > NOW() AND deleted_at IS NULL
+RETURNING id, email;
+
+-- name: IsAccountPendingDeletion :one
+SELECT EXISTS (
+    SELECT 1 FROM users WHERE id = $1 AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL
+);
+
+-- name: DeactivateUserDevices :exec
+UPDATE devices
+SET is_active = FALSE, logged_out_at = NOW()
+WHERE user_id = $1 AND is_active = TRUE;
+
+-- name: ListAccountsDueForDeletion :many
+SELECT id, email
+FROM users
+WHERE deletion_scheduled_at <= NOW() AND deleted_at IS NULL AND is_active = FALSE
+ORDER BY deletion_scheduled_at
+LIMIT $1;
+
+-- name: ListUserDataExportFiles :many
+SELECT file_path
+FROM data_exports
+WHERE user_id = $1 AND file_path IS NOT NULL;
+
+-- name: DeleteUserDevices :exec
+DELETE FROM devices WHERE user_id = $1;
+
+-- name: DeleteUserOtps :exec
+DELETE FROM otps WHERE user_id = $1;
+
+-- name: DeleteUserVerifications :exec
+DELETE FROM verification WHERE user_id = $1;
+
+-- name: DeleteUserPasswordHistory :exec
+DELETE FROM password_history WHERE user_id = $1;
+
+-- name: DeleteUserSocialLogins :exec
+DELETE FROM social_logins WHERE user_id = $1;
+
+-- name: DeleteUserSignIns :exec
+DELETE FROM sign_ins WHERE user_id = $1;
+
+-- name: DeleteUserDataExports :exec
+DELETE FROM data_exports WHERE user_id = $1;
+
+-- name: DeleteUserOutbox :exec
+DELETE FROM outbox WHERE aggregate_type = 'user' AND aggregate_id = $1;
+
+-- name: DeleteUserOutboxSecrets :exec
+DELETE FROM outbox_secrets WHERE user_id = $1;
+
+-- name: DeleteUserAuditContext :exec
+DELETE FROM audit_event_context
+WHERE event_id IN (
+    SELECT id FROM audit_events
+    WHERE actor_id = $1 OR (actor_id IS NULL AND subject_id = $1)
+);
+
+-- name: AnonymiseUser :exec
+UPDATE users
+SET email = $2,
+    username = NULL,
+    phone = NULL,
+    hidden_phone_number = NULL,
+    fullname = NULL,
+    hidden_email = NULL,
+    avatar = NULL,
+    gender = NULL,
+    password_hash = NULL,
+    locale = NULL,
+    two_factor_enabled = FALSE,
+    phone_verified = FALSE,
+    otp_new_device = FALSE,
+    email_undeliverable = FALSE,
+    email_undeliverable_at = NULL,
+    deletion_cancel_token = NULL,
+    deleted_at = NOW()
+WHERE id = $1;
+
+-- name: AnonymiseUserMailLog :exec
+UPDATE mail_log
+SET recipient = $2, error = NULL
+WHERE user_id = $1;
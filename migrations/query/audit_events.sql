@@ -0,0 +1,33 @@
+-- name: CreateAuditEvent :exec
+WITH event AS (
+    INSERT INTO audit_events (
+        actor_id,
+        subject_id,
+        event_type,
+        metadata
+    ) VALUES (
+        $1,
+        $2,
+        $3,
+        $7
+    ) RETURNING id
+)
+INSERT INTO audit_event_context (
+    event_id,
+    ip,
+    device_id,
+    user_agent
+)
+SELECT id, $4::VARCHAR, $5::VARCHAR, $6::TEXT
+FROM event
+WHERE $4::VARCHAR IS NOT NULL OR $5::VARCHAR IS NOT NULL OR $6::TEXT IS NOT NULL;
+
+-- name: ListAuditEvents :many
+SELECT e.id, e.actor_id, e.subject_id, e.event_type, c.ip, c.device_id, c.user_agent, e.metadata, e.created_at
+FROM audit_events e
+LEFT JOIN audit_event_context c ON c.event_id = e.id
+WHERE ($1::INT IS NULL OR e.subject_id = $1 OR e.actor_id = $1)
+AND ($2::VARCHAR IS NULL OR e.event_type = $2)
+AND ($3::BIGINT IS NULL OR e.id < $3)
+ORDER BY e.id DESC
+LIMIT $4;
@@ -0,0 +1,95 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (
+    user_id,
+    status,
+    token
+) VALUES (
+    $1,
+    $2,
+    $3
+) RETURNING id;
+
+-- name: GetDataExport :one
+SELECT id, user_id, status, token, file_path, error, attempts, expires_at, completed_at, created_at
+FROM data_exports
+WHERE id = $1;
+
+-- name: GetDataExportByToken :one
+SELECT id, user_id, status, token, file_path, error, attempts, expires_at, completed_at, created_at
+FROM data_exports
+WHERE token = $1;
+
+-- name: HasDataExportInProgress :one
+SELECT EXISTS (
+    SELECT 1 FROM data_exports WHERE user_id = $1 AND status = ANY($2::SMALLINT[])
+);
+
+-- name: StartDataExport :one
+UPDATE data_exports
+SET status = $2, attempts = attempts + 1
+WHERE id = $1
+RETURNING attempts;
+
+-- name: UpdateDataExportStatus :exec
+UPDATE data_exports
+SET status = $2, error = $3
+WHERE id = $1;
+
+-- name: MarkDataExportReady :exec
+UPDATE data_exports
+SET status = $2, file_path = $3, expires_at = $4, error = NULL, completed_at = NOW()
+WHERE id = $1;
+
+-- name: ListExpiredDataExports :many
+SELECT id, file_path
+FROM data_exports
+WHERE status = $1 AND expires_at < $2
+ORDER BY id
+LIMIT $3;
+
+-- name: ExpireDataExport :exec
+UPDATE data_exports
+SET status = $2, file_path = NULL
+WHERE id = $1;
+
+-- name: ListExportDevices :many
+SELECT device_id, COALESCE(device_type, ''), COALESCE(ip, ''), is_active, logged_in_at, logged_out_at, created_at
+FROM devices
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ListExportPasswordChanges :many
+SELECT COALESCE(reason_status, 0), created_at
+FROM password_history
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ListExportSocialLogins :many
+SELECT provider, created_at
+FROM social_logins
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ListExportOtps :many
+SELECT channel, COALESCE(is_active, FALSE), expires_at, created_at
+FROM otps
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ListExportVerifications :many
+SELECT is_verified, is_active, verified_at, expires_at, created_at
+FROM verification
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ListExportSignIns :many
+SELECT device_id, COALESCE(device_type, ''), COALESCE(ip, ''), COALESCE(network, ''), new_device, new_network, revoked_at, created_at
+FROM sign_ins
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ListExportMail :many
+SELECT template, recipient, status, created_at
+FROM mail_log
+WHERE user_id = $1
+ORDER BY id;
@@ -0,0 +1,40 @@
+-- name: StartJobRun :one
+INSERT INTO job_runs (
+    job_name,
+    fence_token,
+    trigger,
+    status,
+    owner,
+    triggered_by
+)
+SELECT $1, $2, $3, $4, $5, $6
+WHERE NOT EXISTS (
+    SELECT 1 FROM job_runs WHERE job_name = $1 AND fence_token >= $2
+)
+RETURNING id;
+
+-- name: AbandonJobRuns :execrows
+UPDATE job_runs
+SET status = $2, error = $3, finished_at = NOW()
+WHERE job_name = $1 AND finished_at IS NULL;
+
+-- name: FinishJobRun :execrows
+UPDATE job_runs
+SET status = $2, rows = $3, error = $4, finished_at = NOW()
+WHERE id = $1 AND finished_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM job_runs newer WHERE newer.job_name = job_runs.job_name AND newer.fence_token > $5
+);
+
+-- name: ListJobRuns :many
+SELECT id, job_name, fence_token, trigger, status, rows, error, owner, triggered_by, started_at, finished_at
+FROM job_runs
+WHERE job_name = $1
+AND ($2::BIGINT IS NULL OR id < $2)
+ORDER BY id DESC
+LIMIT $3;
+
+-- name: ListLastJobRuns :many
+SELECT DISTINCT ON (job_name) id, job_name, fence_token, trigger, status, rows, error, owner, triggered_by, started_at, finished_at
+FROM job_runs
+ORDER BY job_name, id DESC;
@@ -0,0 +1,35 @@
+-- name: CreateMailLog :one
+INSERT INTO mail_log (
+    user_id,
+    template,
+    recipient,
+    status,
+    provider_message_id,
+    error
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+) RETURNING id;
+
+-- name: UpdateMailLogStatus :one
+UPDATE mail_log
+SET status = $1, error = $2, updated_at = NOW()
+WHERE provider_message_id = $3
+RETURNING id, user_id, recipient;
+
+-- name: IsEmailUndeliverable :one
+SELECT EXISTS (
+    SELECT 1
+    FROM users
+    WHERE email = $1
+    AND email_undeliverable = TRUE
+) AS undeliverable;
+
+-- name: MarkEmailUndeliverable :execrows
+UPDATE users
+SET email_undeliverable = TRUE, email_undeliverable_at = NOW()
+WHERE email = $1 AND email_undeliverable = FALSE;
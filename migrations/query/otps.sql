@@ -2,22 +2,31 @@
 INSERT INTO otps (
     user_id,
     otp_code,
+    channel,
+    purpose,
+    phone,
     expires_at
 ) VALUES (
     $1,
     $2,
-    $3
+    $3,
+    $4,
+    $5,
+    $6
 ) RETURNING *;
 
 -- name: GetNewOtps :many
-SELECT otps.*, users.email
+SELECT otps.*, users.email, users.locale
 FROM otps
 JOIN users ON otps.user_id = users.id
 WHERE otps.expires_at > NOW()
     AND otps.otp_code = $1
-    AND otps.is_active = TRUE;
+    AND otps.is_active = TRUE
+    AND otps.purpose = $2
+    AND ($3::INT IS NULL OR otps.user_id = $3)
+    AND ($4::SMALLINT IS NULL OR otps.channel = $4);
 
 -- name: UpdateOtpIsActive :exec
 UPDATE otps
 SET is_active = $1
-WHERE otp_code = $2;
\ No newline at end of file
+WHERE id = $2;
\ No newline at end of file
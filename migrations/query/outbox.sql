@@ -0,0 +1,52 @@
+-- name: CreateOutbox :one
+INSERT INTO outbox (
+    aggregate_type,
+    aggregate_id,
+    event_type,
+    payload,
+    trace_context
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+) RETURNING id;
+
+-- name: GetPendingOutbox :many
+SELECT * FROM outbox
+WHERE status = $1
+ORDER BY id
+LIMIT $2
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxPublished :exec
+UPDATE outbox
+SET status = $1, attempts = attempts + 1, last_error = NULL, published_at = NOW(), payload = payload - 'body'
+WHERE id = $2;
+
+-- name: MarkOutboxFailed :exec
+UPDATE outbox
+SET attempts = attempts + 1,
+    last_error = $1,
+    status = CASE WHEN attempts + 1 >= $2 THEN $3 ELSE status END
+WHERE id = $4;
+
+-- name: CreateOutboxSecret :one
+INSERT INTO outbox_secrets (
+    user_id,
+    secret,
+    expires_at
+) VALUES (
+    $1,
+    $2,
+    $3
+) RETURNING id;
+
+-- name: GetOutboxSecret :one
+SELECT secret FROM outbox_secrets
+WHERE id = $1 AND expires_at > NOW();
+
+-- name: DeleteOutboxSecret :exec
+DELETE FROM outbox_secrets
+WHERE id = $1;
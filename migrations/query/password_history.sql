@@ -5,4 +5,6 @@ VALUES ($1, $2, $3);
 -- name: CheckPreviousPasswords :one
 SELECT *
 FROM password_history
-WHERE user_id = $1;
\ No newline at end of file
+WHERE user_id = $1
+ORDER BY id DESC
+LIMIT $2;
\ No newline at end of file
@@ -0,0 +1,50 @@
+-- name: DeleteExpiredVerifications :execrows
+DELETE FROM verification
+WHERE id IN (
+    SELECT id FROM verification
+    WHERE expires_at < $1
+    LIMIT $2
+);
+
+-- name: DeleteExpiredOtps :execrows
+DELETE FROM otps
+WHERE id IN (
+    SELECT id FROM otps
+    WHERE expires_at < $1 OR (is_active = FALSE AND created_at < $1)
+    LIMIT $2
+);
+
+-- name: DeleteStaleDevices :execrows
+DELETE FROM devices
+WHERE id IN (
+    SELECT id FROM devices
+    WHERE is_active = FALSE AND COALESCE(logged_out_at, updated_at, created_at) < $1
+    LIMIT $2
+);
+
+-- name: PrunePasswordHistory :execrows
+DELETE FROM password_history
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY id DESC) AS position
+        FROM password_history
+    ) AS ranked
+    WHERE position > $1
+    LIMIT $2
+);
+
+-- name: DeleteOldOutbox :execrows
+DELETE FROM outbox
+WHERE id IN (
+    SELECT id FROM outbox
+    WHERE status <> $1 AND COALESCE(published_at, created_at) < $2
+    LIMIT $3
+);
+
+-- name: DeleteExpiredOutboxSecrets :execrows
+DELETE FROM outbox_secrets
+WHERE id IN (
+    SELECT id FROM outbox_secrets
+    WHERE expires_at < $1
+    LIMIT $2
+);
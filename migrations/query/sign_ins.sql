@@ -0,0 +1,66 @@
+-- name: GetSignInHistory :one
+SELECT
+    EXISTS (
+        SELECT 1 FROM sign_ins WHERE user_id = $1
+    ) AS has_sign_ins,
+    EXISTS (
+        SELECT 1 FROM sign_ins WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL
+    ) OR EXISTS (
+        SELECT 1 FROM devices WHERE user_id = $1 AND device_id = $2 AND is_active = TRUE AND public_key <> ''
+    ) AS known_device,
+    EXISTS (
+        SELECT 1 FROM sign_ins WHERE user_id = $1 AND network = $3 AND revoked_at IS NULL
+    ) AS known_network;
+
+-- name: CreateSignIn :one
+INSERT INTO sign_ins (
+    user_id,
+    device_id,
+    device_type,
+    ip,
+    network,
+    new_device,
+    new_network,
+    revoke_token,
+    revoke_expires_at
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+) RETURNING id;
+
+-- name: RevokeSignInByToken :one
+UPDATE sign_ins
+SET revoked_at = NOW()
+WHERE revoke_token = $1
+AND revoked_at IS NULL
+AND revoke_expires_at > NOW()
+RETURNING user_id, device_id;
+
+-- name: RevokeDeviceSignIns :exec
+UPDATE sign_ins
+SET revoked_at = NOW()
+WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL;
+
+-- name: DeactivateDevice :exec
+UPDATE devices
+SET is_active = FALSE, logged_out_at = NOW()
+WHERE user_id = $1 AND device_id = $2;
+
+-- name: UpdateOtpNewDevice :exec
+UPDATE users
+SET otp_new_device = $1
+WHERE id = $2;
+
+-- name: GetLastSignIn :one
+SELECT ip, created_at
+FROM sign_ins
+WHERE user_id = $1 AND revoked_at IS NULL
+ORDER BY id DESC
+LIMIT 1;
@@ -21,7 +21,12 @@ WHERE id = $2;
 
 -- name: UpdateTwoFactorEnable :exec
 UPDATE users
-SET two_factor_enabled = $1
+SET two_factor_enabled = $1, two_factor_channel = $2
+WHERE id = $3;
+
+-- name: UpdatePhoneVerified :exec
+UPDATE users
+SET phone_verified = $1
 WHERE id = $2;
 
 -- name: UpdateUser :one
@@ -61,14 +66,8 @@ SELECT EXISTS (
 
 -- name: UpdateEmail :exec
 UPDATE users
-SET email = $1
-WHERE id = $2;
-
-
--- name: DestroyAccount :exec
-UPDATE users
-SET is_active = true
-WHERE id = $1 AND is_active = false;
+SET email = $1, hidden_email = $2, email_undeliverable = FALSE, email_undeliverable_at = NULL
+WHERE id = $3;
 
 
 
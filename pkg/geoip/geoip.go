@@ -0,0 +1,111 @@
+package geoip
+
+import (
+	"fmt"
+	"math"
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Location is what the offline GeoIP databases know about an IP address.
+// HasCoordinates is false when the city database is missing or has no position for the address,
+// ASN is 0 when the ASN database is missing or does not know the address.
+type Location struct {
+	Country        string
+	Latitude       float64
+	Longitude      float64
+	HasCoordinates bool
+	ASN            uint
+	ASOrg          string
+}
+
+// Locator looks IP addresses up in MaxMind (.mmdb) City and ASN database files.
+// Both databases are optional; a nil Locator finds nothing.
+type Locator struct {
+	city *geoip2.Reader
+	asn  *geoip2.Reader
+}
+
+// Open opens the City and ASN databases at the given paths.
+// An empty path skips that database.
+func Open(cityPath string, asnPath string) (*Locator, error) {
+	l := &Locator{}
+
+	if cityPath != "" {
+		city, err := geoip2.Open(cityPath)
+		if err != nil {
+			return nil, fmt.Errorf("error opening geoip city database: %v", err)
+		}
+		l.city = city
+	}
+
+	if asnPath != "" {
+		asn, err := geoip2.Open(asnPath)
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("error opening geoip asn database: %v", err)
+		}
+		l.asn = asn
+	}
+
+	return l, nil
+}
+
+// Lookup returns the location of an IP address and whether anything is known about it.
+func (l *Locator) Lookup(ip string) (Location, bool) {
+	var location Location
+	parsed := net.ParseIP(ip)
+	if l == nil || parsed == nil {
+		return location, false
+	}
+
+	found := false
+
+	if l.city != nil {
+		if city, err := l.city.City(parsed); err == nil && (city.Location.Latitude != 0 || city.Location.Longitude != 0) {
+			location.Country = city.Country.IsoCode
+			location.Latitude = city.Location.Latitude
+			location.Longitude = city.Location.Longitude
+			location.HasCoordinates = true
+			found = true
+		}
+	}
+
+	if l.asn != nil {
+		if asn, err := l.asn.ASN(parsed); err == nil && asn.AutonomousSystemNumber != 0 {
+			location.ASN = asn.AutonomousSystemNumber
+			location.ASOrg = asn.AutonomousSystemOrganization
+			found = true
+		}
+	}
+
+	return location, found
+}
+
+// Close closes the database files.
+func (l *Locator) Close() error {
+	if l == nil {
+		return nil
+	}
+	if l.city != nil {
+		l.city.Close()
+	}
+	if l.asn != nil {
+		l.asn.Close()
+	}
+	return nil
+}
+
+// Distance returns the great-circle distance between two locations in kilometres.
+func Distance(a Location, b Location) float64 {
+	const earthRadiusKm = 6371.0
+
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
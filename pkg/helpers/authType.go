@@ -2,7 +2,6 @@ package helpers
 
 import (
 	"net/mail"
-	"regexp"
 	"strings"
 
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
@@ -11,9 +10,9 @@ import (
 // IdentifyType identifies the type of the given identification string.
 // It checks if the identification string is an email, phone number, or username.
 // If it's an email, it returns constants.Email.
-// If it's a phone number, it returns constants.Phone.
+// If it's a phone number that can be normalised to E.164 for region (see NormalizePhone), it returns constants.Phone.
 // If it's neither an email nor a phone number, it assumes it's a username and returns constants.Username.
-func IdentifyType(identify string) int {
+func IdentifyType(identify string, region string) int {
 	// Check if it's an email
 	_, err := mail.ParseAddress(identify)
 	if err == nil {
@@ -21,9 +20,7 @@ func IdentifyType(identify string) int {
 	}
 
 	// Check if it's a phone number
-	phoneRegex := `^\+?([0-9]{1,3})?[-. ]?([0-9]{1,4})[-. ]?([0-9]{1,4})[-. ]?([0-9]{9,10})$`
-	match, _ := regexp.MatchString(phoneRegex, identify)
-	if match {
+	if _, err := NormalizePhone(identify, region); err == nil {
 		return constants.Phone
 	}
 
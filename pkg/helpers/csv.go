@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// WriteCSV writes a slice of structs as CSV, one row per element.
+// The header is taken from the json tags of the struct fields; fields tagged "-" are skipped.
+// Times are written in RFC 3339, nil pointers as empty cells and JSON values unchanged.
+func WriteCSV(w io.Writer, rows interface{}) error {
+	value := reflect.ValueOf(rows)
+	if value.Kind() != reflect.Slice {
+		return fmt.Errorf("WriteCSV: expected a slice, got %s", value.Kind())
+	}
+
+	elemType := value.Type().Elem()
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("WriteCSV: expected a slice of structs, got a slice of %s", elemType.Kind())
+	}
+
+	var fields []int
+	var header []string
+	for i := 0; i < elemType.NumField(); i++ {
+		field := elemType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, i)
+		header = append(header, name)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(fields))
+	for i := 0; i < value.Len(); i++ {
+		elem := value.Index(i)
+		for j, field := range fields {
+			record[j] = csvCell(elem.Field(field))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell formats one struct field as a CSV cell.
+func csvCell(value reflect.Value) string {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case json.RawMessage:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
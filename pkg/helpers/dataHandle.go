@@ -3,6 +3,7 @@ package helpers
 import (
 	"database/sql"
 	"strconv"
+	"time"
 )
 
 // NullStringToString converts a sql.NullString to a string.
@@ -25,3 +26,13 @@ func NullInt16ToString(ni sql.NullInt16) int {
 	}
 	return 0
 }
+
+// NullTimeToPointer converts a sql.NullTime to a *time.Time.
+// If the sql.NullTime is valid, it returns a pointer to the time.
+// Otherwise, it returns nil, which is written as null in JSON.
+func NullTimeToPointer(nt sql.NullTime) *time.Time {
+	if nt.Valid {
+		return &nt.Time
+	}
+	return nil
+}
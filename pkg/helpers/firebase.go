@@ -2,13 +2,16 @@ package helpers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
+	firebase "firebase.google.com/go"
 	"firebase.google.com/go/auth"
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
-	"github.com/gin-gonic/gin"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type UserTest struct {
@@ -17,29 +20,66 @@ type UserTest struct {
 	Picture  string `json:"picture"`
 }
 
+// ErrFirebaseNotConfigured is returned by the functions below when the App was created without Firebase.
+var ErrFirebaseNotConfigured = errors.New("firebase is not configured")
+
+// FirebaseAuth is the Firebase app the functions below call Firebase Authentication with.
+// Timeout bounds how long a call may run before it is cancelled, from timeout.firebase in the configuration;
+// a zero timeout leaves calls bounded by their context only.
+type FirebaseAuth struct {
+	App     *firebase.App
+	Timeout time.Duration
+}
+
+// startFirebaseCall starts the span firebase.<name> of a call and returns a copy of ctx that carries it
+// and is cancelled when the timeout of fb elapses. The returned func cancels ctx and ends the span.
+func startFirebaseCall(ctx context.Context, fb *FirebaseAuth, name string) (context.Context, func()) {
+	ctx, span := tracing.Start(ctx, "firebase."+name, trace.WithSpanKind(trace.SpanKindClient))
+
+	var cancel context.CancelFunc
+	if fb == nil || fb.Timeout <= 0 {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, fb.Timeout)
+	}
+	return ctx, func() {
+		cancel()
+		span.End()
+	}
+}
+
 // getAuthClient returns an instance of the Firebase Authentication client.
-// It takes a Gin context as a parameter and returns a pointer to the auth.Client.
-// If an error occurs during the creation of the client, it logs the error and returns nil.
-func getAuthClient(c *gin.Context) *auth.Client {
-	authClient, err := global.AdminSdk.Auth(c.Request.Context())
+// It takes a context (a Gin context in handlers) and the Firebase app, and returns a pointer to the auth.Client.
+// It returns ErrFirebaseNotConfigured when fb is nil, or the error of the creation of the client.
+func getAuthClient(ctx context.Context, fb *FirebaseAuth) (*auth.Client, error) {
+	if fb == nil || fb.App == nil {
+		return nil, ErrFirebaseNotConfigured
+	}
+
+	authClient, err := fb.App.Auth(ctx)
 	if err != nil {
-		errMsg := fmt.Errorf("error creating user: %v", err)
-		log.Fatalf(errMsg.Error())
-		return nil
+		return nil, fmt.Errorf("error creating auth client: %w", err)
 	}
 
-	return authClient
+	return authClient, nil
 }
 
-// GetUserIDToken retrieves the user ID token and returns a SocialResponse object containing user information.
-// It takes a gin.Context object and a uid string as parameters.
-// If the user ID token cannot be retrieved or the authClient is nil, it returns nil.
+// GetUserRecord retrieves the Firebase user with the given UID and returns a SocialResponse object containing user information.
+// It takes a context, the Firebase app and a uid string as parameters.
+// If the user cannot be retrieved or the authClient is nil, it returns nil.
 // Otherwise, it returns a SocialResponse object with the user's full name, email, and picture.
-func GetUserIDToken(c *gin.Context, uid string) *models.SocialResponse {
-	authClient := getAuthClient(c)
+// A UID is not a secret: it must never be used to sign a user in, verify an ID token with idtoken.Verifier instead.
+func GetUserRecord(ctx context.Context, fb *FirebaseAuth, uid string) *models.SocialResponse {
+	ctx, end := startFirebaseCall(ctx, fb, "GetUserRecord")
+	defer end()
+
+	authClient, err := getAuthClient(ctx, fb)
+	if err != nil {
+		return nil
+	}
 
-	userRecord, err := authClient.GetUser(context.Background(), uid)
-	if err != nil || authClient == nil {
+	userRecord, err := authClient.GetUser(ctx, uid)
+	if err != nil {
 		return nil
 	}
 
@@ -51,23 +91,36 @@ func GetUserIDToken(c *gin.Context, uid string) *models.SocialResponse {
 }
 
 // GetUserUIDByEmail retrieves a user's UID by their email address.
-// It takes a gin.Context and the user's email address as input.
-// It returns the UID of the user and any error encountered during the retrieval.
-func GetUserUIDByEmail(c *gin.Context, email string) (string, error) {
-	authClient := getAuthClient(c)
+// It takes a context, the Firebase app and the user's email address as input.
+// It returns the UID of the user and any error encountered during the retrieval;
+// IsUserNotFound reports whether the error means there is no such user.
+func GetUserUIDByEmail(ctx context.Context, fb *FirebaseAuth, email string) (string, error) {
+	ctx, end := startFirebaseCall(ctx, fb, "GetUserUIDByEmail")
+	defer end()
+
+	authClient, err := getAuthClient(ctx, fb)
+	if err != nil {
+		return "", err
+	}
 
-	userRecord, err := authClient.GetUserByEmail(context.Background(), email)
+	userRecord, err := authClient.GetUserByEmail(ctx, email)
 	if err != nil {
-		return "", fmt.Errorf("error retrieving user by email: %v", err)
+		return "", fmt.Errorf("error retrieving user by email: %w", err)
 	}
-	fmt.Printf("Successfully retrieved user UID: %v for email: %v\n", userRecord.UID, email)
+	slog.DebugContext(ctx, "Retrieved Firebase user", "uid", userRecord.UID, "email", email)
 	return userRecord.UID, nil
 }
 
 // createUser creates a new user in Firebase Authentication with the provided email and password.
 // It returns the created user record or an error if the user creation fails.
-func CreateUser(c *gin.Context, email, password string) (*auth.UserRecord, error) {
-	authClient := getAuthClient(c)
+func CreateUser(ctx context.Context, fb *FirebaseAuth, email, password string) (*auth.UserRecord, error) {
+	ctx, end := startFirebaseCall(ctx, fb, "CreateUser")
+	defer end()
+
+	authClient, err := getAuthClient(ctx, fb)
+	if err != nil {
+		return nil, err
+	}
 
 	params := (&auth.UserToCreate{}).
 		Email(email).
@@ -76,41 +129,64 @@ func CreateUser(c *gin.Context, email, password string) (*auth.UserRecord, error
 		DisplayName(email).
 		Disabled(false)
 
-	u, err := authClient.CreateUser(context.Background(), params)
+	u, err := authClient.CreateUser(ctx, params)
 	if err != nil {
 		return nil, fmt.Errorf("error creating user: %v", err)
 	}
-	fmt.Printf("Successfully created user: %v\n", u.UID)
+	slog.InfoContext(ctx, "Created Firebase user", "uid", u.UID)
 	return u, nil
 }
 
 // UpdateUserEmail updates the email address of a user in Firebase Authentication.
-// It takes a gin.Context, user ID (uid), and the new email address as input.
+// It takes a context, the Firebase app, user ID (uid), and the new email address as input.
 // It returns the updated UserRecord and any error encountered during the update.
-func UpdateUserEmail(c *gin.Context, uid, newEmail string) (*auth.UserRecord, error) {
-	authClient := getAuthClient(c)
+func UpdateUserEmail(ctx context.Context, fb *FirebaseAuth, uid, newEmail string) (*auth.UserRecord, error) {
+	ctx, end := startFirebaseCall(ctx, fb, "UpdateUserEmail")
+	defer end()
+
+	authClient, err := getAuthClient(ctx, fb)
+	if err != nil {
+		return nil, err
+	}
 
 	params := (&auth.UserToUpdate{}).
 		Email(newEmail).
 		EmailVerified(true)
 
-	u, err := authClient.UpdateUser(context.Background(), uid, params)
+	u, err := authClient.UpdateUser(ctx, uid, params)
 	if err != nil {
 		return nil, fmt.Errorf("error updating user email: %v", err)
 	}
-	log.Printf("Successfully updated user email: %v\n", u.Email)
+	slog.InfoContext(ctx, "Updated Firebase user email", "uid", u.UID, "email", u.Email)
 	return u, nil
 }
 
 // DeleteUser deletes a user from Firebase Authentication using the provided user ID.
 // It returns an error if there was a problem deleting the user.
-func DeleteUser(c *gin.Context, uid string) error {
-	authClient := getAuthClient(c)
+func DeleteUser(ctx context.Context, fb *FirebaseAuth, uid string) error {
+	ctx, end := startFirebaseCall(ctx, fb, "DeleteUser")
+	defer end()
+
+	authClient, err := getAuthClient(ctx, fb)
+	if err != nil {
+		return err
+	}
 
-	err := authClient.DeleteUser(context.Background(), uid)
+	err = authClient.DeleteUser(ctx, uid)
 	if err != nil {
-		return fmt.Errorf("error deleting user: %v", err)
+		return fmt.Errorf("error deleting user: %w", err)
 	}
-	fmt.Printf("Successfully deleted user: %v\n", uid)
+	slog.InfoContext(ctx, "Deleted Firebase user", "uid", uid)
 	return nil
 }
+
+// IsUserNotFound reports whether an error returned by the functions above means
+// the user does not exist in Firebase Authentication.
+func IsUserNotFound(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if auth.IsUserNotFound(err) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package helpers
+
+import "net"
+
+// NetworkKey returns the network an IP address belongs to, used to recognise sign-ins from the same place.
+// IPv4 addresses are grouped by /24 and IPv6 addresses by /48, the smallest blocks usually routed on their own.
+// It returns an empty string for an invalid address.
+func NetworkKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
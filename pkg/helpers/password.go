@@ -4,14 +4,11 @@ import (
 	"math/rand"
 	"time"
 
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var charset = global.Cfg.Server.KeyPassword
-
-// generatePassword generates a random password of the specified length.
-func generatePassword(length int) string {
+// generatePassword generates a random password of the specified length from the characters of charset.
+func generatePassword(charset string, length int) string {
 	seededRand := rand.New(rand.NewSource(time.Now().UnixNano()))
 	b := make([]byte, length)
 	for i := range b {
@@ -20,9 +17,10 @@ func generatePassword(length int) string {
 	return string(b)
 }
 
-// GenerateRandomPassword generates a random password with the specified length.
-func GenerateRandomPassword(character int) string {
-	return generatePassword(character)
+// GenerateRandomPassword generates a random password with the specified length from the characters of charset,
+// server.keypassword in the configuration.
+func GenerateRandomPassword(charset string, character int) string {
+	return generatePassword(charset, character)
 }
 
 // HashPassword generates a salted and hashed password using bcrypt.
@@ -0,0 +1,35 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/nyaruka/phonenumbers"
+)
+
+// PhoneRegion returns the region used to parse phone numbers written without a country code
+// from phone.defaultregion in the configuration. An empty region gives constants.DefaultPhoneRegion.
+func PhoneRegion(configured string) string {
+	region := strings.ToUpper(strings.TrimSpace(configured))
+	if region == "" {
+		return constants.DefaultPhoneRegion
+	}
+	return region
+}
+
+// NormalizePhone parses the given phone number and returns it in E.164 format (e.g. +84912345678).
+// Numbers without a country code are parsed for region (see PhoneRegion).
+// It returns an error if the number cannot be parsed or is not a valid number for its region.
+func NormalizePhone(phone string, region string) (string, error) {
+	number, err := phonenumbers.Parse(strings.TrimSpace(phone), region)
+	if err != nil {
+		return "", err
+	}
+
+	if !phonenumbers.IsValidNumber(number) {
+		return "", fmt.Errorf("invalid phone number: %s", phone)
+	}
+
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
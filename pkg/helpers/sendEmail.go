@@ -1,31 +1,15 @@
 package helpers
 
 import (
-	"fmt"
-	"log"
-	"net/smtp"
-
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/mailer"
 )
 
-// SendEmail sends an email using the configured SMTP settings.
-func SendEmail(email string, data string) {
-	// Set up authentication information.
-	auth := smtp.PlainAuth(
-		"",
-		global.Cfg.Gmail.Mail, // Pass the email address as a string
-		global.Cfg.Gmail.Password,
-		global.Cfg.Gmail.Host,
-	)
-
-	to := []string{email}
-	msg := []byte("To: " + email + "\r\n" +
-		"Subject: Hello!\r\n" +
-		"\r\n" +
-		"This is content:\r\n" +
-		"Data: " + data + "\r\n")
-	err := smtp.SendMail(fmt.Sprintf("%s:%s", global.Cfg.Gmail.Host, global.Cfg.Gmail.Port), auth, "profile-forme.com", to, msg)
-	if err != nil {
-		log.Fatal(err)
-	}
+// SendEmail sends a plain-text email through the given mailer.
+// It returns an error if the message could not be delivered.
+func SendEmail(m mailer.Mailer, email string, data string) error {
+	return m.Send(mailer.Message{
+		To:      email,
+		Subject: "Hello!",
+		Text:    "This is content:\r\nData: " + data + "\r\n",
+	})
 }
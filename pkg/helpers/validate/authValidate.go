@@ -2,6 +2,9 @@ package validate
 
 import (
 	"regexp"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
 )
 
 // ValidateAndRespond validates a value using the provided validation function and returns a boolean indicating whether the value is valid.
@@ -34,10 +37,19 @@ func IsValidateUser(username string) bool {
 	return isValid
 }
 
-// IsValidatePhone checks if a given phone number is a valid Vietnamese phone number.
-// A valid Vietnamese phone number starts with 0 and is 10 or 11 digits long.
-func IsValidatePhone(phone string) bool {
-	// A valid Vietnamese phone number starts with 0 and is 10 or 11 digits long
-	isValid := regexp.MustCompile(`^0[0-9]{9,10}$`).MatchString(phone)
-	return isValid
+// IsValidatePhone checks if a given phone number is valid.
+// Numbers with a country code are checked for their own region, numbers without one for the given region.
+func IsValidatePhone(phone string, region string) bool {
+	_, err := helpers.NormalizePhone(phone, region)
+	return err == nil
+}
+
+// IsValidateLocale checks if a given locale is one of the locales emails are translated to.
+func IsValidateLocale(locale string) bool {
+	for _, supported := range constants.SupportedLocales {
+		if locale == supported {
+			return true
+		}
+	}
+	return false
 }
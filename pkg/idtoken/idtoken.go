@@ -0,0 +1,275 @@
+package idtoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	// FirebaseKeysURL is the JWKS of the keys that sign Firebase Authentication ID tokens
+	FirebaseKeysURL = "https://www.googleapis.com/service_accounts/v1/jwk/securetoken@system.gserviceaccount.com"
+	// FirebaseIssuerPrefix is followed by the Firebase project ID in the issuer of its ID tokens
+	FirebaseIssuerPrefix = "https://securetoken.google.com/"
+	// GoogleKeysURL is the JWKS of the keys that sign Google Sign-In ID tokens
+	GoogleKeysURL = "https://www.googleapis.com/oauth2/v3/certs"
+)
+
+// googleIssuers are the two issuers Google Sign-In ID tokens are issued with.
+var googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+var (
+	ErrMalformed      = errors.New("malformed id token")
+	ErrUnknownIssuer  = errors.New("id token issuer is not accepted")
+	ErrUnknownKey     = errors.New("id token signed with an unknown key")
+	ErrBadSignature   = errors.New("id token signature is invalid")
+	ErrBadAudience    = errors.New("id token audience is not accepted")
+	ErrExpired        = errors.New("id token has expired")
+	ErrIssuedInFuture = errors.New("id token is issued in the future")
+	ErrNoSubject      = errors.New("id token has no subject")
+)
+
+// Claims are the claims of a verified ID token used to sign a user in.
+type Claims struct {
+	Provider      string
+	Issuer        string
+	Audience      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+	IssuedAt      time.Time
+	ExpiresAt     time.Time
+}
+
+// Provider is an issuer of ID tokens: the issuers it signs tokens as,
+// the audiences (project or client IDs) a token must be issued for, and its signing keys.
+type Provider struct {
+	Name      string
+	Issuers   []string
+	Audiences []string
+	Keys      KeySet
+}
+
+// FirebaseProvider accepts the ID tokens of a Firebase project.
+func FirebaseProvider(projectID string, keys KeySet) Provider {
+	return Provider{
+		Name:      "firebase",
+		Issuers:   []string{FirebaseIssuerPrefix + projectID},
+		Audiences: []string{projectID},
+		Keys:      keys,
+	}
+}
+
+// GoogleProvider accepts the Google Sign-In ID tokens issued for one of the OAuth client IDs.
+func GoogleProvider(clientIDs []string, keys KeySet) Provider {
+	return Provider{
+		Name:      "google",
+		Issuers:   googleIssuers,
+		Audiences: clientIDs,
+		Keys:      keys,
+	}
+}
+
+// Verifier verifies RS256 ID tokens against the providers it accepts: the provider is picked
+// by the issuer of the token, then the signature is checked with the key named in its header,
+// and the audience, expiry and issue time are checked with the given clock leeway.
+type Verifier struct {
+	providers []Provider
+	leeway    time.Duration
+	now       func() time.Time
+}
+
+// NewVerifier creates a verifier accepting the tokens of the given providers.
+// Providers without an audience are skipped, so an unconfigured provider accepts nothing.
+func NewVerifier(leeway time.Duration, providers ...Provider) *Verifier {
+	v := &Verifier{leeway: leeway, now: time.Now}
+	for _, provider := range providers {
+		if len(provider.Audiences) > 0 && provider.Keys != nil {
+			v.providers = append(v.providers, provider)
+		}
+	}
+	return v
+}
+
+// New creates the verifier of social login ID tokens from the configuration:
+// Firebase and Google tokens are checked against the keys Google publishes, cached as long as allowed,
+// or against the keys of a local JWKS file in offline mode.
+func New(cfg models.SocialConfig) (*Verifier, error) {
+	var firebaseKeys, googleKeys KeySet
+	if cfg.JWKSPath != "" {
+		keys, err := LoadKeySet(cfg.JWKSPath)
+		if err != nil {
+			return nil, err
+		}
+		firebaseKeys, googleKeys = keys, keys
+	} else {
+		client := &http.Client{Timeout: constants.JWKSFetchTimeout}
+		firebaseKeys = NewRemoteKeySet(FirebaseKeysURL, client)
+		googleKeys = NewRemoteKeySet(GoogleKeysURL, client)
+	}
+
+	var firebase Provider
+	if cfg.FirebaseProjectID != "" {
+		firebase = FirebaseProvider(cfg.FirebaseProjectID, firebaseKeys)
+	}
+
+	return NewVerifier(constants.IDTokenLeeway,
+		firebase,
+		GoogleProvider(cfg.GoogleClientIDs, googleKeys),
+	), nil
+}
+
+// WithClock returns a copy of the verifier that reads the time from now, to verify recorded tokens.
+func (v *Verifier) WithClock(now func() time.Time) *Verifier {
+	clone := *v
+	clone.now = now
+	return &clone
+}
+
+// Verify checks an ID token and returns its claims.
+func (v *Verifier) Verify(ctx context.Context, raw string) (*Claims, error) {
+	var provider *Provider
+	parser := &jwt.Parser{
+		ValidMethods: []string{jwt.SigningMethodRS256.Alg()},
+		//* Time claims are checked below with the verifier's clock and leeway
+		SkipClaimsValidation: true,
+	}
+
+	token, err := parser.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, ErrMalformed
+		}
+		issuer, _ := claims["iss"].(string)
+		provider = v.provider(issuer)
+		if provider == nil {
+			return nil, ErrUnknownIssuer
+		}
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, ErrUnknownKey
+		}
+		key, err := provider.Keys.Key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, verifyError(err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	result := &Claims{Provider: provider.Name}
+	result.Issuer, _ = claims["iss"].(string)
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.EmailVerified, _ = claims["email_verified"].(bool)
+	result.Name, _ = claims["name"].(string)
+	result.Picture, _ = claims["picture"].(string)
+
+	audience, ok := matchAudience(claims["aud"], provider.Audiences)
+	if !ok {
+		return nil, ErrBadAudience
+	}
+	result.Audience = audience
+
+	if result.Subject == "" {
+		return nil, ErrNoSubject
+	}
+
+	now := v.now()
+	exp, ok := numericDate(claims["exp"])
+	if !ok || !now.Before(exp.Add(v.leeway)) {
+		return nil, ErrExpired
+	}
+	iat, ok := numericDate(claims["iat"])
+	if !ok || iat.After(now.Add(v.leeway)) {
+		return nil, ErrIssuedInFuture
+	}
+	if authTime, ok := numericDate(claims["auth_time"]); ok && authTime.After(now.Add(v.leeway)) {
+		return nil, ErrIssuedInFuture
+	}
+	result.ExpiresAt = exp
+	result.IssuedAt = iat
+
+	return result, nil
+}
+
+// provider returns the provider that issues tokens as issuer, or nil.
+func (v *Verifier) provider(issuer string) *Provider {
+	for i := range v.providers {
+		for _, accepted := range v.providers[i].Issuers {
+			if accepted == issuer {
+				return &v.providers[i]
+			}
+		}
+	}
+	return nil
+}
+
+// verifyError unwraps the error returned by the key function from the jwt validation error,
+// and reports any other parse failure as a malformed or badly signed token.
+func verifyError(err error) error {
+	validationErr, ok := err.(*jwt.ValidationError)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if validationErr.Inner != nil {
+		switch validationErr.Inner {
+		case ErrMalformed, ErrUnknownIssuer, ErrUnknownKey:
+			return validationErr.Inner
+		}
+		if validationErr.Errors&jwt.ValidationErrorUnverifiable != 0 {
+			return fmt.Errorf("%w: %v", ErrUnknownKey, validationErr.Inner)
+		}
+	}
+	if validationErr.Errors&jwt.ValidationErrorSignatureInvalid != 0 {
+		return ErrBadSignature
+	}
+	return fmt.Errorf("%w: %v", ErrMalformed, err)
+}
+
+// matchAudience returns the audience of a token if it is one of the accepted ones.
+// The aud claim is a string, or an array of strings in tokens with several audiences.
+func matchAudience(aud interface{}, accepted []string) (string, bool) {
+	var audiences []string
+	switch value := aud.(type) {
+	case string:
+		audiences = []string{value}
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+
+	for _, audience := range audiences {
+		for _, a := range accepted {
+			if audience == a && a != "" {
+				return audience, true
+			}
+		}
+	}
+	return "", false
+}
+
+// numericDate reads a JWT time claim, in seconds since the epoch.
+func numericDate(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case int64:
+		return time.Unix(v, 0), true
+	}
+	return time.Time{}, false
+}
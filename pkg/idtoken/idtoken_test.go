@@ -0,0 +1,296 @@
+package idtoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKeyID     = "key-1"
+	testProjectID = "my-project"
+	testClientID  = "client-id.apps.googleusercontent.com"
+	testLeeway    = time.Minute
+)
+
+// testNow is the time the tokens of the tests are verified at.
+var testNow = time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+// newTestKey generates an RSA key for signing test tokens.
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+// jwksJSON returns the JWKS publishing the public keys under their key IDs.
+func jwksJSON(t *testing.T, keys map[string]*rsa.PrivateKey) []byte {
+	t.Helper()
+	var set jwks
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, jwk{
+			Kid: kid,
+			Kty: "RSA",
+			Alg: "RS256",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	return data
+}
+
+// signToken signs claims with key under the key ID kid, with RS256.
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	require.NoError(t, err)
+	return raw
+}
+
+// firebaseClaims returns the claims of a valid Firebase ID token at testNow.
+func firebaseClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            FirebaseIssuerPrefix + testProjectID,
+		"aud":            testProjectID,
+		"sub":            "firebase-uid",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+		"picture":        "https://example.com/alice.png",
+		"iat":            testNow.Add(-time.Minute).Unix(),
+		"auth_time":      testNow.Add(-time.Minute).Unix(),
+		"exp":            testNow.Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key := newTestKey(t)
+	otherKey := newTestKey(t)
+
+	//* The keys are read from a JWKS file, as in offline mode
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksJSON(t, map[string]*rsa.PrivateKey{testKeyID: key}), 0o600))
+	keys, err := LoadKeySet(path)
+	require.NoError(t, err)
+
+	verifier := NewVerifier(testLeeway,
+		FirebaseProvider(testProjectID, keys),
+		GoogleProvider([]string{testClientID}, keys),
+	).WithClock(func() time.Time { return testNow })
+
+	// with returns the Firebase claims changed by change.
+	with := func(change func(claims jwt.MapClaims)) jwt.MapClaims {
+		claims := firebaseClaims()
+		change(claims)
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+		err   error
+		check func(t *testing.T, claims *Claims)
+	}{
+		{
+			name:  "valid firebase token",
+			token: func() string { return signToken(t, key, testKeyID, firebaseClaims()) },
+			check: func(t *testing.T, claims *Claims) {
+				assert.Equal(t, "firebase", claims.Provider)
+				assert.Equal(t, FirebaseIssuerPrefix+testProjectID, claims.Issuer)
+				assert.Equal(t, testProjectID, claims.Audience)
+				assert.Equal(t, "firebase-uid", claims.Subject)
+				assert.Equal(t, "alice@example.com", claims.Email)
+				assert.True(t, claims.EmailVerified)
+				assert.Equal(t, "Alice", claims.Name)
+				assert.Equal(t, "https://example.com/alice.png", claims.Picture)
+				assert.Equal(t, testNow.Add(-time.Minute).Unix(), claims.IssuedAt.Unix())
+				assert.Equal(t, testNow.Add(time.Hour).Unix(), claims.ExpiresAt.Unix())
+			},
+		},
+		{
+			name: "valid google token with several audiences",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) {
+					claims["iss"] = "accounts.google.com"
+					claims["aud"] = []string{"other-client", testClientID}
+				}))
+			},
+			check: func(t *testing.T, claims *Claims) {
+				assert.Equal(t, "google", claims.Provider)
+				assert.Equal(t, testClientID, claims.Audience)
+			},
+		},
+		{
+			name:  "bad signature",
+			token: func() string { return signToken(t, otherKey, testKeyID, firebaseClaims()) },
+			err:   ErrBadSignature,
+		},
+		{
+			name: "alg none",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, firebaseClaims())
+				token.Header["kid"] = testKeyID
+				raw, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+				require.NoError(t, err)
+				return raw
+			},
+			err: ErrBadSignature,
+		},
+		{
+			name: "HS256 signed with the public key",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, firebaseClaims())
+				token.Header["kid"] = testKeyID
+				raw, err := token.SignedString(key.PublicKey.N.Bytes())
+				require.NoError(t, err)
+				return raw
+			},
+			err: ErrBadSignature,
+		},
+		{
+			name:  "unknown kid",
+			token: func() string { return signToken(t, key, "key-2", firebaseClaims()) },
+			err:   ErrUnknownKey,
+		},
+		{
+			name:  "missing kid",
+			token: func() string { return signToken(t, key, "", firebaseClaims()) },
+			err:   ErrUnknownKey,
+		},
+		{
+			name: "wrong aud",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { claims["aud"] = "other-project" }))
+			},
+			err: ErrBadAudience,
+		},
+		{
+			name: "wrong iss",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { claims["iss"] = FirebaseIssuerPrefix + "other-project" }))
+			},
+			err: ErrUnknownIssuer,
+		},
+		{
+			name: "expired",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { claims["exp"] = testNow.Add(-testLeeway - time.Second).Unix() }))
+			},
+			err: ErrExpired,
+		},
+		{
+			name: "expired within the leeway",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { claims["exp"] = testNow.Add(-testLeeway / 2).Unix() }))
+			},
+		},
+		{
+			name: "no exp",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { delete(claims, "exp") }))
+			},
+			err: ErrExpired,
+		},
+		{
+			name: "iat in the future beyond the leeway",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { claims["iat"] = testNow.Add(testLeeway + time.Second).Unix() }))
+			},
+			err: ErrIssuedInFuture,
+		},
+		{
+			name: "iat in the future within the leeway",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { claims["iat"] = testNow.Add(testLeeway / 2).Unix() }))
+			},
+		},
+		{
+			name: "auth_time in the future beyond the leeway",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { claims["auth_time"] = testNow.Add(testLeeway + time.Second).Unix() }))
+			},
+			err: ErrIssuedInFuture,
+		},
+		{
+			name: "missing sub",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { delete(claims, "sub") }))
+			},
+			err: ErrNoSubject,
+		},
+		{
+			name: "email_verified false",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { claims["email_verified"] = false }))
+			},
+			check: func(t *testing.T, claims *Claims) {
+				assert.Equal(t, "alice@example.com", claims.Email)
+				assert.False(t, claims.EmailVerified)
+			},
+		},
+		{
+			name: "email_verified as a string",
+			token: func() string {
+				return signToken(t, key, testKeyID, with(func(claims jwt.MapClaims) { claims["email_verified"] = "true" }))
+			},
+			check: func(t *testing.T, claims *Claims) {
+				assert.False(t, claims.EmailVerified)
+			},
+		},
+		{
+			name:  "malformed",
+			token: func() string { return "not.a.token" },
+			err:   ErrMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), tt.token())
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Nil(t, claims)
+				return
+			}
+			require.NoError(t, err)
+			if tt.check != nil {
+				tt.check(t, claims)
+			}
+		})
+	}
+}
+
+func TestVerifierSkipsUnconfiguredProviders(t *testing.T) {
+	key := newTestKey(t)
+	keys := &StaticKeySet{keys: map[string]*rsa.PublicKey{testKeyID: &key.PublicKey}}
+
+	//* Without a client ID Google tokens are not accepted
+	verifier := NewVerifier(testLeeway,
+		FirebaseProvider(testProjectID, keys),
+		GoogleProvider(nil, keys),
+	).WithClock(func() time.Time { return testNow })
+
+	claims := firebaseClaims()
+	claims["iss"] = "https://accounts.google.com"
+	_, err := verifier.Verify(context.Background(), signToken(t, key, testKeyID, claims))
+	assert.ErrorIs(t, err, ErrUnknownIssuer)
+}
@@ -0,0 +1,196 @@
+package idtoken
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeySet finds the public key that signed a token from the key ID (kid) in its header.
+type KeySet interface {
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// jwk is one key of a JSON Web Key Set. Only RSA keys are used.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// parseJWKS reads the RSA signing keys of a JSON Web Key Set by key ID.
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error parsing jwks: %v", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding modulus of key %s: %v", key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding exponent of key %s: %v", key.Kid, err)
+		}
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks has no rsa signing key")
+	}
+	return keys, nil
+}
+
+// StaticKeySet is a fixed set of keys, read from a local JWKS file.
+// It lets tokens be verified offline, in tests or on machines without access to Google.
+type StaticKeySet struct {
+	keys map[string]*rsa.PublicKey
+}
+
+// LoadKeySet reads a JWKS file.
+func LoadKeySet(path string) (*StaticKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading jwks file: %v", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeySet{keys: keys}, nil
+}
+
+// Key returns the key with the given ID.
+func (s *StaticKeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+var maxAgePattern = regexp.MustCompile(`max-age=(\d+)`)
+
+// RemoteKeySet downloads a JWKS from a URL and caches it for as long as the Cache-Control header allows.
+// A key ID missing from the cache refreshes it, at most once per minRefresh, so keys rotated
+// by Google are picked up without letting unknown key IDs trigger a download on every request.
+type RemoteKeySet struct {
+	url        string
+	client     *http.Client
+	minRefresh time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expiresAt time.Time
+	fetchedAt time.Time
+}
+
+// NewRemoteKeySet creates a key set downloaded from url. A nil client uses http.DefaultClient.
+func NewRemoteKeySet(url string, client *http.Client) *RemoteKeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &RemoteKeySet{
+		url:        url,
+		client:     client,
+		minRefresh: time.Minute,
+	}
+}
+
+// Key returns the key with the given ID, downloading the key set when the cache is stale.
+func (r *RemoteKeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	key, ok := r.keys[kid]
+	if ok && now.Before(r.expiresAt) {
+		return key, nil
+	}
+
+	if r.keys == nil || now.After(r.expiresAt) || now.Sub(r.fetchedAt) >= r.minRefresh {
+		if err := r.refresh(ctx, now); err != nil {
+			//* Keep serving the cached keys if Google is unreachable: they are only rotated every few days
+			if key, ok := r.keys[kid]; ok {
+				return key, nil
+			}
+			return nil, err
+		}
+	}
+
+	key, ok = r.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refresh downloads the key set. It must be called with the mutex held.
+func (r *RemoteKeySet) refresh(ctx context.Context, now time.Time) error {
+	r.fetchedAt = now
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating jwks request: %v", err)
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error downloading jwks: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("error downloading jwks: status %d", res.StatusCode)
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return fmt.Errorf("error reading jwks: %v", err)
+	}
+	keys, err := parseJWKS(body)
+	if err != nil {
+		return err
+	}
+
+	r.keys = keys
+	r.expiresAt = now.Add(cacheMaxAge(res.Header.Get("Cache-Control")))
+	return nil
+}
+
+// cacheMaxAge returns the max-age of a Cache-Control header, or an hour when it has none.
+func cacheMaxAge(header string) time.Duration {
+	match := maxAgePattern.FindStringSubmatch(header)
+	if match == nil {
+		return time.Hour
+	}
+	seconds, err := strconv.Atoi(match[1])
+	if err != nil {
+		return time.Hour
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,137 @@
+package idtoken
+
+import (
+	"context"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jwksServer serves a JWKS that the test can change, and counts the downloads.
+type jwksServer struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	body         []byte
+	cacheControl string
+	status       int
+	downloads    int
+}
+
+func newJWKSServer(t *testing.T, body []byte, cacheControl string) *jwksServer {
+	t.Helper()
+	s := &jwksServer{body: body, cacheControl: cacheControl, status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.downloads++
+		if s.cacheControl != "" {
+			w.Header().Set("Cache-Control", s.cacheControl)
+		}
+		w.WriteHeader(s.status)
+		w.Write(s.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// serve changes the response of the server.
+func (s *jwksServer) serve(status int, body []byte, cacheControl string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.body, s.cacheControl = status, body, cacheControl
+}
+
+func (s *jwksServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloads
+}
+
+func TestRemoteKeySetCaching(t *testing.T) {
+	ctx := context.Background()
+	key1, key2 := newTestKey(t), newTestKey(t)
+	server := newJWKSServer(t, jwksJSON(t, map[string]*rsa.PrivateKey{"key-1": key1}), "public, max-age=3600")
+	keys := NewRemoteKeySet(server.URL, server.Client())
+
+	//* The first lookup downloads the key set, the next ones are served from the cache
+	key, err := keys.Key(ctx, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, key1.PublicKey.N, key.N)
+	assert.Equal(t, key1.PublicKey.E, key.E)
+	_, err = keys.Key(ctx, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, server.count())
+
+	//* An unknown key ID does not download the key set again within minRefresh
+	server.serve(http.StatusOK, jwksJSON(t, map[string]*rsa.PrivateKey{"key-1": key1, "key-2": key2}), "public, max-age=3600")
+	_, err = keys.Key(ctx, "key-2")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, 1, server.count())
+
+	//* After minRefresh it refreshes the key set, which picks up the rotated key
+	keys.minRefresh = 0
+	key, err = keys.Key(ctx, "key-2")
+	require.NoError(t, err)
+	assert.Equal(t, key2.PublicKey.N, key.N)
+	assert.Equal(t, 2, server.count())
+
+	//* A key ID the refreshed set does not have either stays unknown
+	keys.minRefresh = time.Hour
+	_, err = keys.Key(ctx, "key-3")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, 2, server.count())
+}
+
+func TestRemoteKeySetExpiry(t *testing.T) {
+	ctx := context.Background()
+	key1 := newTestKey(t)
+	server := newJWKSServer(t, jwksJSON(t, map[string]*rsa.PrivateKey{"key-1": key1}), "max-age=0")
+	keys := NewRemoteKeySet(server.URL, server.Client())
+
+	//* max-age=0: every lookup downloads the key set again
+	_, err := keys.Key(ctx, "key-1")
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = keys.Key(ctx, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, server.count())
+
+	//* When the download fails, the expired keys are still served
+	server.serve(http.StatusInternalServerError, nil, "")
+	time.Sleep(time.Millisecond)
+	key, err := keys.Key(ctx, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, key1.PublicKey.N, key.N)
+	assert.Equal(t, 3, server.count())
+
+	//* But a key that was never downloaded is an error
+	_, err = keys.Key(ctx, "key-2")
+	assert.Error(t, err)
+}
+
+func TestRemoteKeySetUnreachable(t *testing.T) {
+	server := newJWKSServer(t, []byte(`{"keys": []}`), "")
+	keys := NewRemoteKeySet(server.URL, server.Client())
+
+	_, err := keys.Key(context.Background(), "key-1")
+	assert.ErrorContains(t, err, "no rsa signing key")
+
+	server.serve(http.StatusServiceUnavailable, nil, "")
+	keys.minRefresh = 0
+	_, err = keys.Key(context.Background(), "key-1")
+	assert.ErrorContains(t, err, "status 503")
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	assert.Equal(t, 19845*time.Second, cacheMaxAge("public, max-age=19845, must-revalidate, no-transform"))
+	assert.Equal(t, time.Duration(0), cacheMaxAge("max-age=0"))
+	assert.Equal(t, time.Hour, cacheMaxAge("no-cache"))
+	assert.Equal(t, time.Hour, cacheMaxAge(""))
+}
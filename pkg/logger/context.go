@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// fieldsKey is the context key of the fields of a request.
+type fieldsKey struct{}
+
+// fields are the identifiers of a request added to its records. The user and device are set by the middlewares
+// that find them, after the fields were put in the context, so they are guarded by a mutex.
+type fields struct {
+	mu        sync.Mutex
+	requestID string
+	userID    int
+	deviceID  string
+}
+
+func (f *fields) attrs() []slog.Attr {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	attrs := []slog.Attr{slog.String("request_id", f.requestID)}
+	if f.userID != 0 {
+		attrs = append(attrs, slog.Int("user_id", f.userID))
+	}
+	if f.deviceID != "" {
+		attrs = append(attrs, slog.String("device_id", f.deviceID))
+	}
+	return attrs
+}
+
+// WithRequest returns a copy of ctx whose records carry requestID, and the user and device set later
+// with SetUserID and SetDeviceID.
+func WithRequest(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, &fields{requestID: requestID})
+}
+
+// RequestID returns the request ID of ctx, or an empty string outside of a request.
+func RequestID(ctx context.Context) string {
+	if f := fieldsFrom(ctx); f != nil {
+		return f.requestID
+	}
+	return ""
+}
+
+// SetUserID adds the ID of the signed in user to the records of the request of ctx.
+func SetUserID(ctx context.Context, userID int) {
+	if f := fieldsFrom(ctx); f != nil {
+		f.mu.Lock()
+		f.userID = userID
+		f.mu.Unlock()
+	}
+}
+
+// SetDeviceID adds the device of the caller to the records of the request of ctx.
+func SetDeviceID(ctx context.Context, deviceID string) {
+	if f := fieldsFrom(ctx); f != nil {
+		f.mu.Lock()
+		f.deviceID = deviceID
+		f.mu.Unlock()
+	}
+}
+
+func fieldsFrom(ctx context.Context) *fields {
+	if ctx == nil {
+		return nil
+	}
+	f, _ := ctx.Value(fieldsKey{}).(*fields)
+	return f
+}
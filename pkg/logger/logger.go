@@ -0,0 +1,82 @@
+// Package logger sets up the structured JSON logger of the binaries.
+// Every record is passed through the redaction of this package, and the records logged with the context
+// of a request carry its request ID, user ID, device ID and trace ID.
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New returns a logger that writes JSON records to w, at the level of cfg (debug, info, warn or error, info by default).
+func New(cfg models.LogConfig, w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(cfg.Level))); err != nil {
+		level = slog.LevelInfo
+	}
+
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})})
+}
+
+// contextHandler adds the fields of the request and the trace ID of the span in the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if f := fieldsFrom(ctx); f != nil {
+		r.AddAttrs(f.attrs()...)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// redactAttr masks the value of an attribute by its key and the personal data found in it, see RedactField.
+// Values that are neither strings nor numbers, such as maps and structs, are logged as their redacted JSON.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(RedactField(a.Key, a.Value.String()))
+	case slog.KindInt64, slog.KindUint64, slog.KindFloat64:
+		if isSecret(a.Key) {
+			a.Value = slog.StringValue(Redacted)
+		}
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			// fmt prints a nil pointer error as <nil> where calling Error could panic
+			a.Value = slog.StringValue(RedactField(a.Key, fmt.Sprint(v)))
+		case []byte:
+			a.Value = slog.StringValue(RedactField(a.Key, string(v)))
+		default:
+			if isSecret(a.Key) {
+				a.Value = slog.StringValue(Redacted)
+				break
+			}
+			if body, err := json.Marshal(v); err == nil {
+				a.Value = slog.AnyValue(json.RawMessage(RedactJSON(body)))
+			}
+		}
+	}
+	return a
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactJSON(t *testing.T) {
+	body := []byte(`{
+		"email": "alice@example.com",
+		"identifier": "+84901234567",
+		"password": "S3cret!pass",
+		"otp_code": 123456,
+		"profile": {"phone": "0901234567", "refetch_token": "abc", "fullname": "Alice"},
+		"note": "write to bob@example.com or +84907654321"
+	}`)
+
+	var redacted map[string]interface{}
+	require.NoError(t, json.Unmarshal(RedactJSON(body), &redacted))
+
+	assert.Equal(t, "a***@example.com", redacted["email"])
+	assert.Equal(t, "***67", redacted["identifier"])
+	assert.Equal(t, Redacted, redacted["password"])
+	assert.Equal(t, Redacted, redacted["otp_code"])
+	profile := redacted["profile"].(map[string]interface{})
+	assert.Equal(t, "***67", profile["phone"])
+	assert.Equal(t, Redacted, profile["refetch_token"])
+	assert.Equal(t, "Alice", profile["fullname"])
+	assert.Equal(t, "write to b***@example.com or ***21", redacted["note"])
+
+	//* A body that is not JSON is redacted as text
+	assert.Equal(t, "email=c***@example.com", string(RedactJSON([]byte("email=carol@example.com"))))
+}
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	log := New(models.LogConfig{Level: "info"}, &out)
+
+	ctx := WithRequest(context.Background(), "req-1")
+	SetUserID(ctx, 42)
+	SetDeviceID(ctx, "device-1")
+	log.InfoContext(ctx, "Signed in dave@example.com",
+		"email", "dave@example.com",
+		"token", "eyJhbGciOiJSUzI1NiJ9.eyJpZCI6NDJ9.c2lnbmF0dXJl",
+		"error", errors.New("no user eve@example.com"),
+		"profile", map[string]string{"Email": "dave@example.com", "Phone": "+84901234567"})
+	log.Debug("not logged below the level")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record), out.String())
+	assert.Equal(t, "Signed in d***@example.com", record[slog.MessageKey])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, float64(42), record["user_id"])
+	assert.Equal(t, "device-1", record["device_id"])
+	assert.Equal(t, "d***@example.com", record["email"])
+	assert.Equal(t, Redacted, record["token"])
+	assert.Equal(t, "no user e***@example.com", record["error"])
+	assert.Equal(t, map[string]interface{}{"Email": "d***@example.com", "Phone": "***67"}, record["profile"])
+}
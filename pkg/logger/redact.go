@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the secrets: passwords, OTPs, tokens, keys and cookies.
+const Redacted = "[REDACTED]"
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// phonePattern matches the phone numbers in E.164 form, the form they are stored in
+	phonePattern = regexp.MustCompile(`\+[0-9]{8,15}`)
+	// jwtPattern matches the access, refetch and ID tokens
+	jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+	// secretKeys are the keys whose value is always redacted, once lower-cased and stripped of _ and -
+	secretKeys = []string{"password", "token", "secret", "otp", "authorization", "cookie", "privatekey", "apikey", "salt"}
+	// secretNames are the short keys that are secrets only when they are the whole key
+	secretNames = map[string]bool{"code": true, "pin": true, "hash": true}
+)
+
+// RedactString masks the emails and phone numbers and redacts the tokens found in free text, such as a log message.
+func RedactString(s string) string {
+	s = jwtPattern.ReplaceAllString(s, Redacted)
+	s = emailPattern.ReplaceAllStringFunc(s, MaskEmail)
+	return phonePattern.ReplaceAllStringFunc(s, MaskPhone)
+}
+
+// RedactField redacts value when its key names a secret, masks it as an email or phone number
+// when its key names one, and otherwise masks the personal data found in it with RedactString.
+func RedactField(key, value string) string {
+	name := normalize(key)
+	switch {
+	case value == "":
+		return value
+	case isSecret(name):
+		return Redacted
+	case strings.Contains(name, "email"):
+		return emailPattern.ReplaceAllStringFunc(value, MaskEmail)
+	case strings.Contains(name, "phone"):
+		return MaskPhone(value)
+	case name == "identifier":
+		// An email, a phone number or a username
+		if strings.Contains(value, "@") {
+			return MaskEmail(value)
+		}
+		return MaskPhone(value)
+	}
+	return RedactString(value)
+}
+
+// RedactJSON redacts a JSON document, such as a request body, with RedactField on every field.
+// A body that is not JSON is redacted as text.
+func RedactJSON(body []byte) []byte {
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return []byte(RedactString(string(body)))
+	}
+
+	redacted, err := json.Marshal(redactValue("", document))
+	if err != nil {
+		return []byte(RedactString(string(body)))
+	}
+	return redacted
+}
+
+// redactValue redacts a decoded JSON value found under key.
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			v[k] = redactValue(k, field)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(key, item)
+		}
+		return v
+	case string:
+		return RedactField(key, v)
+	case nil, bool:
+		return v
+	default:
+		if isSecret(key) {
+			return Redacted
+		}
+		return v
+	}
+}
+
+// MaskEmail keeps the first character of the local part and the domain of an email: j***@example.com.
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return Redacted
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// MaskPhone keeps the last two digits of a phone number: ***42.
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return Redacted
+	}
+	return "***" + phone[len(phone)-2:]
+}
+
+// isSecret reports whether key names a secret.
+func isSecret(key string) bool {
+	name := normalize(key)
+	if secretNames[name] {
+		return true
+	}
+	for _, secret := range secretKeys {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize lower-cases a key and strips its separators, so refetch_token, refetchToken and X-Refetch-Token match.
+func normalize(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
@@ -0,0 +1,158 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+// RenderedEmail is an email template rendered for one recipient.
+type RenderedEmail struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// templateCache holds parsed templates by "<locale>/<name>".
+var templateCache sync.Map
+
+// RenderEmail renders the named template from templates/email with the given data.
+// Each template is a pair of files, <locale>/<name>.html and <locale>/<name>.txt, defining the
+// "subject" and "content" blocks, which are wrapped in the shared layout.html and layout.txt.
+// A template missing for the requested locale falls back to the default locale.
+func RenderEmail(data models.EmailData) (RenderedEmail, error) {
+	if data.Template == "" || strings.ContainsAny(data.Template, `/\.`) {
+		return RenderedEmail{}, fmt.Errorf("invalid email template name %q", data.Template)
+	}
+
+	data.Locale = ResolveLocale(data.Locale, "")
+
+	tmpl, err := loadTemplate(data.Template, data.Locale)
+	if err != nil && data.Locale != constants.DefaultLocale {
+		data.Locale = constants.DefaultLocale
+		tmpl, err = loadTemplate(data.Template, data.Locale)
+	}
+	if err != nil {
+		return RenderedEmail{}, err
+	}
+
+	var subject, html, text bytes.Buffer
+
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("error executing subject of %s: %v", data.Template, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("error executing html of %s: %v", data.Template, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "layout", data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("error executing text of %s: %v", data.Template, err)
+	}
+
+	return RenderedEmail{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+// loadTemplate parses the HTML and text parts of a template once and caches them.
+func loadTemplate(name string, locale string) (*emailTemplate, error) {
+	key := locale + "/" + name
+	if cached, ok := templateCache.Load(key); ok {
+		return cached.(*emailTemplate), nil
+	}
+
+	dir := constants.EmailTemplateDir
+
+	html, err := htmltemplate.ParseFiles(filepath.Join(dir, "layout.html"), filepath.Join(dir, locale, name+".html"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing email template %s: %v", key, err)
+	}
+
+	text, err := texttemplate.ParseFiles(filepath.Join(dir, "layout.txt"), filepath.Join(dir, locale, name+".txt"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing email template %s: %v", key, err)
+	}
+
+	tmpl := &emailTemplate{html: html, text: text}
+	templateCache.Store(key, tmpl)
+
+	return tmpl, nil
+}
+
+// ResolveLocale picks the locale of an email.
+// The preferred locale (usually the user's) wins when it is supported; otherwise the
+// Accept-Language header is read in order of quality, matching full tags (vi-VN) or their
+// primary language (vi). The default locale is used when nothing matches.
+func ResolveLocale(preferred string, acceptLanguage string) string {
+	if locale, ok := supportedLocale(preferred); ok {
+		return locale
+	}
+
+	type weightedTag struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weightedTag
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if fields[0] == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		tags = append(tags, weightedTag{tag: fields[0], q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if locale, ok := supportedLocale(t.tag); ok {
+			return locale
+		}
+	}
+
+	return constants.DefaultLocale
+}
+
+// supportedLocale matches a language tag against constants.SupportedLocales.
+func supportedLocale(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return "", false
+	}
+
+	primary := strings.SplitN(strings.ReplaceAll(tag, "_", "-"), "-", 2)[0]
+
+	for _, locale := range constants.SupportedLocales {
+		if tag == locale || primary == locale {
+			return locale, true
+		}
+	}
+
+	return "", false
+}
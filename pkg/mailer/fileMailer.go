@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileMailer is the capture driver for development.
+// It never talks to a mail server: when Path ends in .mbox every message is appended to that mbox file,
+// otherwise Path is a directory and every message is written to its own .eml file.
+type FileMailer struct {
+	Path string
+	From string
+	mu   sync.Mutex
+}
+
+// NewFileMailer creates a FileMailer writing to the given path.
+// The path defaults to tmp/mail.mbox.
+func NewFileMailer(path string, from string) *FileMailer {
+	if path == "" {
+		path = "tmp/mail.mbox"
+	}
+	return &FileMailer{Path: path, From: from}
+}
+
+// Send captures the message.
+func (m *FileMailer) Send(msg Message) error {
+	var raw bytes.Buffer
+	if err := writeMessage(&raw, msg, m.From); err != nil {
+		return fmt.Errorf("error building email to %s: %v", msg.To, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if strings.HasSuffix(m.Path, ".mbox") {
+		return m.appendMbox(raw.Bytes())
+	}
+	return m.writeEml(msg.To, raw.Bytes())
+}
+
+// Close does nothing; files are closed after every message.
+func (m *FileMailer) Close() error {
+	return nil
+}
+
+// appendMbox appends a message to the mbox file, escaping body lines that start with "From ".
+func (m *FileMailer) appendMbox(raw []byte) error {
+	if err := os.MkdirAll(filepath.Dir(m.Path), 0755); err != nil {
+		return fmt.Errorf("error creating mail capture directory: %v", err)
+	}
+
+	file, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening mail capture file: %v", err)
+	}
+	defer file.Close()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From %s %s\n", mboxSender(m.From), time.Now().UTC().Format(time.ANSIC))
+	for _, line := range strings.Split(strings.ReplaceAll(string(raw), "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(line, "From ") {
+			buf.WriteString(">")
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+
+	_, err = file.Write(buf.Bytes())
+	return err
+}
+
+// writeEml writes a message to its own file in the capture directory.
+func (m *FileMailer) writeEml(to string, raw []byte) error {
+	if err := os.MkdirAll(m.Path, 0755); err != nil {
+		return fmt.Errorf("error creating mail capture directory: %v", err)
+	}
+
+	name := fmt.Sprintf("%s_%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), sanitizeFileName(to))
+	return os.WriteFile(filepath.Join(m.Path, name), raw, 0644)
+}
+
+// mboxSender returns the envelope sender used in the mbox "From " line.
+func mboxSender(from string) string {
+	if from == "" {
+		return "MAILER-DAEMON"
+	}
+	return from
+}
+
+// sanitizeFileName keeps an address usable as part of a file name.
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"strings"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/go-gomail/gomail"
+)
+
+// Message is a rendered email ready to be delivered.
+// ID is sent as the Message-ID header so bounces reported by the provider can be matched to the email.
+// Embeds maps a Content-ID (referenced as cid:<id> in the HTML) to the file embedded under it.
+type Message struct {
+	ID      string
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Embeds  map[string]string
+}
+
+// Mailer is implemented by every mail driver.
+// Send delivers one message and returns an error if it could not be delivered;
+// Close releases any connection kept open between sends.
+type Mailer interface {
+	Send(msg Message) error
+	Close() error
+}
+
+// NewMailer builds the mail driver selected in the configuration.
+// SMTP uses the Gmail settings; it is also the fallback when no driver is configured.
+func NewMailer(cfg models.MailConfig, smtp models.GmailConfig) Mailer {
+	switch cfg.Driver {
+	case constants.MailDriverFile:
+		return NewFileMailer(cfg.CapturePath, smtp.Mail)
+	case constants.MailDriverMemory:
+		return NewMemoryMailer()
+	default:
+		return NewSMTPMailer(smtp, cfg.IdleTimeout)
+	}
+}
+
+// buildMessage converts a Message into a MIME message with a plain-text part,
+// an HTML alternative and the embedded files.
+func buildMessage(msg Message, defaultFrom string) *gomail.Message {
+	from := msg.From
+	if from == "" {
+		from = defaultFrom
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", from)
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	if msg.ID != "" {
+		m.SetHeader("Message-ID", "<"+msg.ID+">")
+	}
+
+	switch {
+	case msg.Text != "" && msg.HTML != "":
+		m.SetBody("text/plain", msg.Text)
+		m.AddAlternative("text/html", msg.HTML)
+	case msg.HTML != "":
+		m.SetBody("text/html", msg.HTML)
+	default:
+		m.SetBody("text/plain", msg.Text)
+	}
+
+	for contentID, path := range msg.Embeds {
+		m.Embed(path, gomail.SetHeader(map[string][]string{
+			"Content-ID": {"<" + contentID + ">"},
+		}))
+	}
+
+	return m
+}
+
+// writeMessage writes the MIME representation of a message.
+func writeMessage(w io.Writer, msg Message, defaultFrom string) error {
+	_, err := buildMessage(msg, defaultFrom).WriteTo(w)
+	return err
+}
+
+// NewMessageID returns a unique message ID in the domain of the sender address.
+func NewMessageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b) + "@" + domain
+}
@@ -0,0 +1,119 @@
+package mailer
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/go-gomail/gomail"
+)
+
+// SMTPMailer delivers messages through an SMTP server.
+// The connection is kept open between sends so bulk sends do not dial per message;
+// it is closed after IdleTimeout without sends and dialled again on the next one.
+type SMTPMailer struct {
+	dialer      *gomail.Dialer
+	from        string
+	idleTimeout time.Duration
+	configErr   error
+
+	mu        sync.Mutex
+	conn      gomail.SendCloser
+	idleTimer *time.Timer
+}
+
+// NewSMTPMailer creates an SMTPMailer from the Gmail configuration.
+// The idle timeout is in seconds and defaults to 30 seconds.
+func NewSMTPMailer(cfg models.GmailConfig, idleTimeout int) *SMTPMailer {
+	timeout := time.Duration(idleTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	m := &SMTPMailer{
+		from:        cfg.Mail,
+		idleTimeout: timeout,
+	}
+
+	port, err := strconv.Atoi(cfg.Port)
+	if err != nil {
+		m.configErr = fmt.Errorf("invalid smtp port %q: %v", cfg.Port, err)
+		return m
+	}
+
+	m.dialer = gomail.NewDialer(cfg.Host, port, cfg.Mail, cfg.Password)
+	return m
+}
+
+// Send delivers the message over the shared connection.
+// If the server dropped the connection, it dials again once before giving up.
+func (m *SMTPMailer) Send(msg Message) error {
+	if m.configErr != nil {
+		return m.configErr
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	gm := buildMessage(msg, m.from)
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if m.conn == nil {
+			conn, errDial := m.dialer.Dial()
+			if errDial != nil {
+				return fmt.Errorf("error dialing smtp server: %v", errDial)
+			}
+			m.conn = conn
+		}
+
+		if err = gomail.Send(m.conn, gm); err == nil {
+			m.resetIdleTimer()
+			return nil
+		}
+
+		// The connection may be broken, so it is never reused after an error
+		m.conn.Close()
+		m.conn = nil
+	}
+
+	return fmt.Errorf("error sending email to %s: %v", msg.To, err)
+}
+
+// Close closes the open connection, if any.
+func (m *SMTPMailer) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.idleTimer != nil {
+		m.idleTimer.Stop()
+	}
+
+	return m.closeConn()
+}
+
+// resetIdleTimer postpones closing the connection. The caller holds mu.
+func (m *SMTPMailer) resetIdleTimer() {
+	if m.idleTimer == nil {
+		m.idleTimer = time.AfterFunc(m.idleTimeout, func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.closeConn()
+		})
+		return
+	}
+	m.idleTimer.Reset(m.idleTimeout)
+}
+
+// closeConn closes the connection. The caller holds mu.
+func (m *SMTPMailer) closeConn() error {
+	if m.conn == nil {
+		return nil
+	}
+
+	err := m.conn.Close()
+	m.conn = nil
+	return err
+}
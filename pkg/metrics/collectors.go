@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"database/sql"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
+)
+
+// NewDBCollector reports the connection pool of db: open, in use and idle connections, and waits for one.
+func NewDBCollector(db *sql.DB) prometheus.Collector {
+	return collectors.NewDBStatsCollector(db, "postgres")
+}
+
+// PoolStatser is implemented by the Redis clients.
+type PoolStatser interface {
+	PoolStats() *redis.PoolStats
+}
+
+// redisCollector reports the connection pool of a Redis client when it is scraped.
+type redisCollector struct {
+	client PoolStatser
+
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+}
+
+// NewRedisCollector reports the connection pool of client: total, idle and stale connections,
+// and how often a connection was found in the pool, had to be created or could not be had in time.
+func NewRedisCollector(client PoolStatser) prometheus.Collector {
+	return &redisCollector{
+		client:     client,
+		totalConns: prometheus.NewDesc("redis_pool_connections", "Connections in the Redis pool.", nil, nil),
+		idleConns:  prometheus.NewDesc("redis_pool_idle_connections", "Idle connections in the Redis pool.", nil, nil),
+		staleConns: prometheus.NewDesc("redis_pool_stale_connections_total", "Stale connections removed from the Redis pool.", nil, nil),
+		hits:       prometheus.NewDesc("redis_pool_hits_total", "Times a free connection was found in the Redis pool.", nil, nil),
+		misses:     prometheus.NewDesc("redis_pool_misses_total", "Times no free connection was found in the Redis pool.", nil, nil),
+		timeouts:   prometheus.NewDesc("redis_pool_timeouts_total", "Times waiting for a connection of the Redis pool timed out.", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (rc *redisCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rc.totalConns
+	ch <- rc.idleConns
+	ch <- rc.staleConns
+	ch <- rc.hits
+	ch <- rc.misses
+	ch <- rc.timeouts
+}
+
+// Collect implements prometheus.Collector.
+func (rc *redisCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := rc.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(rc.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(rc.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(rc.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+	ch <- prometheus.MustNewConstMetric(rc.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(rc.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(rc.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+}
+
+// queueCollector reports the number of messages waiting in each queue when it is scraped.
+type queueCollector struct {
+	depths func() (map[string]int, error)
+	depth  *prometheus.Desc
+}
+
+// NewQueueCollector reports the messages waiting in the queues returned by depths, keyed by queue name.
+// When depths fails the error is logged and no depth is reported for that scrape.
+func NewQueueCollector(depths func() (map[string]int, error)) prometheus.Collector {
+	return &queueCollector{
+		depths: depths,
+		depth:  prometheus.NewDesc("rabbitmq_queue_messages", "Messages ready in the RabbitMQ queue.", []string{"queue"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (qc *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- qc.depth
+}
+
+// Collect implements prometheus.Collector.
+func (qc *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	depths, err := qc.depths()
+	if err != nil {
+		slog.Error("Failed to read the queue depths", "error", err)
+		return
+	}
+	for queue, depth := range depths {
+		ch <- prometheus.MustNewConstMetric(qc.depth, prometheus.GaugeValue, float64(depth), queue)
+	}
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the metrics of the auth outcomes.
+const namespace = "auth"
+
+// The metrics are package variables so the code that counts an outcome does not need to carry them around.
+// They are collected by every registry made with NewRegistry.
+var (
+	// RequestDuration observes the HTTP requests by method, route template and status.
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of the HTTP requests by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// Logins counts the login attempts by method, result and, for a failure, the error code of the response.
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by method, result and the error code of a failure.",
+	}, []string{"method", "result", "reason"})
+
+	// OtpSent counts the OTPs sent by channel and purpose.
+	OtpSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "otp_sent_total",
+		Help:      "OTPs sent by channel and purpose.",
+	}, []string{"channel", "purpose"})
+
+	// OtpVerified counts the OTP verifications by purpose and result.
+	OtpVerified = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "otp_verified_total",
+		Help:      "OTP verifications by purpose and result.",
+	}, []string{"purpose", "result"})
+
+	// TokenRenewals counts the access token renewals by result and, for a failure, the error code of the response.
+	TokenRenewals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_renewals_total",
+		Help:      "Access token renewals by result and the error code of a failure.",
+	}, []string{"result", "reason"})
+
+	// SpamBlocks counts the requests refused because the caller sent too many, by action.
+	SpamBlocks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spam_blocks_total",
+		Help:      "Requests blocked as spam by action.",
+	}, []string{"action"})
+
+	// BlacklistHits counts the requests refused because their IP is blacklisted.
+	BlacklistHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blacklist_hits_total",
+		Help:      "Requests refused because their IP address is blacklisted.",
+	})
+
+	// CuckooShortCircuits counts the lookups answered by the cuckoo filter of unknown users without a query, by action.
+	CuckooShortCircuits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cuckoo_short_circuits_total",
+		Help:      "Unknown users answered by the cuckoo filter without a database query, by action.",
+	}, []string{"action"})
+)
+
+// NewRegistry returns a registry with the Go runtime and process metrics, the metrics of this package
+// and the given collectors, such as the pool and queue collectors of the binary serving it.
+func NewRegistry(extra ...prometheus.Collector) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestDuration,
+		Logins,
+		OtpSent,
+		OtpVerified,
+		TokenRenewals,
+		SpamBlocks,
+		BlacklistHits,
+		CuckooShortCircuits,
+	)
+	reg.MustRegister(extra...)
+	return reg
+}
+
+// Handler serves the metrics of reg in the Prometheus text format.
+// When token is set, the scraper must send it as "Authorization: Bearer <token>".
+func Handler(reg *prometheus.Registry, token string) http.Handler {
+	handler := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	if token == "" {
+		return handler
+	}
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	firebase "firebase.google.com/go"
 	"google.golang.org/api/option"
@@ -22,6 +23,6 @@ func InitializeApp() (*firebase.App, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error initializing app: %v", err)
 	}
-	fmt.Println("CONNECTED TO FIREBASE 🔥")
+	slog.Info("Connected to Firebase")
 	return app, nil
 }
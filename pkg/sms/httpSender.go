@@ -0,0 +1,83 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+// HTTPSender delivers messages through a generic HTTP SMS gateway.
+// The gateway receives a JSON body with the recipient, the sender name and the message,
+// authenticated with a bearer API key.
+type HTTPSender struct {
+	GatewayURL string
+	APIKey     string
+	Sender     string
+	Client     *http.Client
+}
+
+type gatewayMessage struct {
+	To      string `json:"to"`
+	From    string `json:"from,omitempty"`
+	Message string `json:"message"`
+}
+
+// NewHTTPSender creates an HTTPSender from the SMS configuration.
+// The request timeout defaults to 10 seconds.
+func NewHTTPSender(cfg models.SMSConfig) *HTTPSender {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &HTTPSender{
+		GatewayURL: cfg.GatewayURL,
+		APIKey:     cfg.APIKey,
+		Sender:     cfg.Sender,
+		Client:     &http.Client{Timeout: timeout},
+	}
+}
+
+// Send posts the message to the gateway.
+// Any non-2xx status is returned as an error together with the gateway response body.
+func (s *HTTPSender) Send(phone string, message string) error {
+	if s.GatewayURL == "" {
+		return fmt.Errorf("sms gateway url is not configured")
+	}
+
+	jsonData, err := json.Marshal(gatewayMessage{
+		To:      phone,
+		From:    s.Sender,
+		Message: message,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.GatewayURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending sms: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("sms gateway returned status %d: %s", res.StatusCode, string(body))
+	}
+
+	return nil
+}
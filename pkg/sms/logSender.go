@@ -0,0 +1,49 @@
+package sms
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LogSender is the development driver.
+// It never talks to a gateway: messages are appended to a file, or logged at debug level when no path is set.
+// The log is redacted like every other, so the code in a message is only readable in the file.
+type LogSender struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewLogSender creates a LogSender writing to the given file path.
+func NewLogSender(path string) *LogSender {
+	return &LogSender{Path: path}
+}
+
+// Send records the message instead of delivering it.
+func (s *LogSender) Send(phone string, message string) error {
+	line := fmt.Sprintf("%s to=%s message=%q\n", time.Now().Format(time.RFC3339), phone, message)
+
+	if s.Path == "" {
+		slog.Debug("SMS not sent, logged by the log driver", "phone", phone, "message", message)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return fmt.Errorf("error creating sms log directory: %v", err)
+	}
+
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening sms log file: %v", err)
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(line)
+	return err
+}
@@ -0,0 +1,23 @@
+package sms
+
+import (
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+)
+
+// SMSSender is implemented by every SMS driver.
+// Send delivers a plain-text message to a phone number and returns an error if the gateway rejects it.
+type SMSSender interface {
+	Send(phone string, message string) error
+}
+
+// NewSender builds the SMS driver selected in the configuration.
+// It falls back to the log driver when no driver is configured, so development never sends real SMS.
+func NewSender(cfg models.SMSConfig) SMSSender {
+	switch cfg.Driver {
+	case constants.SMSDriverHTTP:
+		return NewHTTPSender(cfg)
+	default:
+		return NewLogSender(cfg.LogPath)
+	}
+}
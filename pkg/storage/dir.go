@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Dir stores the files in a directory. In production the directory is a volume mounted
+// into every container that reads or writes the files, see docker-compose.pro.yml.
+type Dir struct {
+	root string
+}
+
+// NewDir creates a Dir storing its files in root. A relative root is resolved against the working directory
+// once, when the Dir is created. The directory is created on the first write.
+func NewDir(root string) (*Dir, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Dir{root: abs}, nil
+}
+
+// Write writes the file to a temporary file in the directory and renames it to its key.
+func (d *Dir) Write(ctx context.Context, key string, write func(w io.Writer) error) (err error) {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.root, 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(d.root, "."+key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o640); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open opens the file of key.
+func (d *Dir) Open(ctx context.Context, key string) (File, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return dirFile{File: file, modTime: info.ModTime()}, nil
+}
+
+// Remove deletes the file of key.
+func (d *Dir) Remove(ctx context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// path returns the path of the file of key. A key is a file name: anything that could leave the directory is refused.
+func (d *Dir) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return "", &fs.PathError{Op: "storage", Path: key, Err: fmt.Errorf("invalid key")}
+	}
+	return filepath.Join(d.root, key), nil
+}
+
+type dirFile struct {
+	*os.File
+	modTime time.Time
+}
+
+func (f dirFile) ModTime() time.Time {
+	return f.modTime
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Storage keeps the files shared by the binaries, such as the archives of the data exports:
+// the queue consumer writes them, the server sends them and the cron jobs remove them.
+// Files are named by a key, never by a path, so every binary finds them wherever the storage is mounted.
+// A missing file is reported with an error that wraps fs.ErrNotExist.
+type Storage interface {
+	// Write stores the file written by write under key. The file is only visible once write returns
+	// without error, so a reader never sees a half-written file.
+	Write(ctx context.Context, key string, write func(w io.Writer) error) error
+	// Open opens the file stored under key. The caller closes it.
+	Open(ctx context.Context, key string) (File, error)
+	// Remove deletes the file stored under key.
+	Remove(ctx context.Context, key string) error
+}
+
+// File is an open file of a Storage.
+type File interface {
+	io.ReadSeekCloser
+	ModTime() time.Time
+}
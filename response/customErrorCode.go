@@ -32,6 +32,12 @@ const (
 	// ErrorNotRead indicates the request body not read
 	ErrorNotRead = 1010
 
+	// ErrCodeRequestCanceled indicates the request was cancelled before it completed, e.g. the client went away.
+	ErrCodeRequestCanceled = 1011
+
+	// ErrCodeRequestTimeout indicates an operation of the request ran out of time.
+	ErrCodeRequestTimeout = 1012
+
 	//* Database errors
 	// ErrCodeDBConnection indicates a database connection error.
 	ErrCodeDBConnection = 2000
@@ -87,6 +93,9 @@ const (
 	// ErrCodeLoginFailed indicates the login attempt failed.
 	ErrCodeLoginFailed = 4003
 
+	// ErrCodeLoginRiskBlocked indicates the login was blocked because its risk score is too high.
+	ErrCodeLoginRiskBlocked = 4004
+
 	//* Resource errors
 	// ErrCodeResourceExhausted indicates the resource has been exhausted.
 	ErrCodeResourceExhausted = 5000
@@ -3,15 +3,21 @@ package response
 import (
 	"time"
 
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
 	"github.com/gin-gonic/gin"
 )
 
+// ErrorCodeKey is the key of the gin context the code of an error response is stored under,
+// so the middlewares that run after the handler know why the request failed.
+const ErrorCodeKey = "error_code"
+
 // ErrorResponse represents a structured error response
 type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Status  int    `json:"status"`
-	Now     int64  `json:"now"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	Status    int    `json:"status"`
+	Now       int64  `json:"now"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // NewErrorResponse creates a new ErrorResponse
@@ -24,12 +30,24 @@ func NewErrorResponse(message string, status int, code int) *ErrorResponse {
 	}
 }
 
-// Send sends the error response to the client.
+// Send sends the error response to the client, with the ID of the request.
 // It aborts the request and responds with the error response as JSON.
 func (sr *ErrorResponse) Send(c *gin.Context) {
+	sr.RequestID = c.GetString(constants.RequestIDKey)
+	c.Set(ErrorCodeKey, sr.Code)
 	c.AbortWithStatusJSON(sr.Status, sr)
 }
 
+// ErrorCode returns the code of the error response sent for the request, or false when none was sent.
+func ErrorCode(c *gin.Context) (int, bool) {
+	code, exists := c.Get(ErrorCodeKey)
+	if !exists {
+		return 0, false
+	}
+	value, ok := code.(int)
+	return value, ok
+}
+
 // BadRequestError represents a 400 Bad Request error
 func BadRequestError(c *gin.Context, code int, messages ...string) {
 	message := ""
@@ -158,3 +176,17 @@ func ServiceUnavailable(c *gin.Context, code int, messages ...string) {
 	response := NewErrorResponse(message, StatusServiceUnavailable, code)
 	response.Send(c)
 }
+
+// GatewayTimeoutError represents a 504 Gateway Timeout
+func GatewayTimeoutError(c *gin.Context, code int, messages ...string) {
+	message := ""
+	if len(messages) > 0 {
+		message = messages[0]
+	}
+
+	if message == "" {
+		message = GetReasonPhrase(StatusGatewayTimeout)
+	}
+	response := NewErrorResponse(message, StatusGatewayTimeout, code)
+	response.Send(c)
+}
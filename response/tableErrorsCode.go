@@ -44,6 +44,15 @@ const (
 	// ErrTwoFactorEnabled indicates the two factor enabled
 	ErrTwoFactorEnabled = 12012
 
+	// ErrorUserPhoneNotVerified indicates the phone has not been verified
+	ErrorUserPhoneNotVerified = 12014
+
+	// ErrorUserPhoneVerified indicates the phone has already been verified
+	ErrorUserPhoneVerified = 12015
+
+	// ErrorUserLocaleInvalid indicates the locale is not supported
+	ErrorUserLocaleInvalid = 12016
+
 	//* Device Table Errors
 	// ErrCodeDeviceNotExit indicates the device not exits
 	ErrCodeDeviceNotExit = 12002
@@ -89,4 +98,52 @@ const (
 
 	// ErrorOTPInvalid indicates the otp is invalid
 	ErrorOTPInvalid = 16002
+
+	// ErrorOTPChannelInvalid indicates the otp channel is invalid
+	ErrorOTPChannelInvalid = 16003
+
+	// ErrorOTPChallengeInvalid indicates the login OTP challenge is unknown, expired or used up
+	ErrorOTPChallengeInvalid = 16004
+
+	//* Mail Log Table Errors
+	// ErrorMailWebhookUnauthorized indicates the mail webhook secret is missing or wrong
+	ErrorMailWebhookUnauthorized = 17000
+
+	// ErrorMailEventInvalid indicates a mail webhook event is invalid
+	ErrorMailEventInvalid = 17001
+
+	//* Sign-in Table Errors
+	// ErrorRevokeTokenInvalid indicates the device revoke token is unknown, expired or already used
+	ErrorRevokeTokenInvalid = 18000
+
+	//* Data Export Table Errors
+	// ErrorDataExportInProgress indicates the user already has a data export being prepared
+	ErrorDataExportInProgress = 19000
+
+	// ErrorDataExportNotFound indicates the data export link is unknown or the export is not ready
+	ErrorDataExportNotFound = 19001
+
+	// ErrorDataExportExpired indicates the data export download link has expired
+	ErrorDataExportExpired = 19002
+
+	//* Account Deletion Errors
+	// ErrorDeletionTokenInvalid indicates the account deletion cancel token is unknown or the grace period is over
+	ErrorDeletionTokenInvalid = 20000
+
+	// ErrorAccountPendingDeletion indicates the account is deleted and can only be restored with the cancel link
+	ErrorAccountPendingDeletion = 20001
+
+	//* Job Run Errors
+	// ErrorJobNotFound indicates no background job has the given name
+	ErrorJobNotFound = 21000
+
+	// ErrorJobRunning indicates the background job is already running
+	ErrorJobRunning = 21001
+
+	//* Social Login Errors
+	// ErrorSocialTokenInvalid indicates the social login ID token is malformed, expired, or not signed for this project
+	ErrorSocialTokenInvalid = 22000
+
+	// ErrorSocialEmailUnverified indicates the social account has no verified email to sign in with
+	ErrorSocialEmailUnverified = 22001
 )
@@ -0,0 +1,760 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/middlewares"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/logger"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/metrics"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/storage"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/tracing"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newPassword passes validate.IsValidPassword.
+const newPassword = "N3wPassw0rd!"
+
+// register signs an email up and opens the link of the register email, which verifies the account
+// and signs the client in. It returns the user ID and the password sent in the verification email.
+func register(t *testing.T, c *client, email string) (int, string) {
+	t.Helper()
+
+	var registration models.RegistrationResponse
+	c.ok(http.MethodPost, "/v1/auth/register", map[string]string{"email": email}, &registration)
+	assert.Equal(t, email, registration.Email)
+
+	//* The link is <frontend>/auth/verify/account/<email>/<expires at>/<user id>/<token>
+	link := find(t, c.h.lastEmail(email, "Register User"), `/auth/verify/account/(\S+)`)
+	parts := strings.Split(link, "/")
+	require.Len(t, parts, 4, link)
+	assert.Equal(t, fmt.Sprint(registration.ID), parts[2])
+	assert.Equal(t, registration.Token, parts[3])
+
+	var login models.LoginResponse
+	c.ok(http.MethodGet, verifyPath(parts[2], parts[3], email), nil, &login)
+	assert.Equal(t, registration.ID, login.ID)
+	assert.Equal(t, c.deviceID, login.DeviceID)
+	require.NotEmpty(t, login.AccessToken)
+	require.NotEmpty(t, c.refetchToken, "verifying signs the user in")
+	c.accessToken = login.AccessToken
+
+	password := find(t, c.h.lastEmail(email, "Verification Account Success"), `New password: (\S+)`)
+	return registration.ID, password
+}
+
+func verifyPath(userID string, token string, email string) string {
+	return "/v1/auth/veri-account?" + url.Values{
+		"user_id": {userID},
+		"token":   {token},
+		"email":   {email},
+	}.Encode()
+}
+
+// login signs in with a password and, when the account asks for one, the OTP emailed to it.
+// The login spam limit counts the logins of every user, so it is reset first.
+func login(t *testing.T, c *client, email string, password string) models.LoginResponse {
+	t.Helper()
+	c.h.resetLoginLimit()
+
+	res := c.do(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": email, "password": password})
+	require.Equal(t, http.StatusOK, res.Status, "%+v", res)
+
+	var twoFactor models.LoginTwoFactor
+	res.decode(t, &twoFactor)
+
+	var result models.LoginResponse
+	if twoFactor.Code == response.ErrTwoFactorEnabled {
+		otp := find(t, c.h.lastEmail(email, "OTP Login"), `OTP: (\d+)`)
+		c.ok(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": otp, "challenge": twoFactor.Challenge}, &result)
+	} else {
+		res.decode(t, &result)
+	}
+
+	require.NotEmpty(t, result.AccessToken)
+	c.accessToken = result.AccessToken
+	return result
+}
+
+func (h *harness) resetLoginLimit() {
+	h.redis.Del(constants.SpamKeyLogin)
+}
+
+func TestAuthFlow(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+	const email = "alice@example.com"
+
+	//* Register and verify
+	userID, password := register(t, c, email)
+	profilePath := fmt.Sprintf("/v1/user/profile/%d", userID)
+
+	var profile models.ProfileResponseJSON
+	c.ok(http.MethodGet, profilePath, nil, &profile)
+	assert.Equal(t, email, profile.Email)
+
+	//* Login with the password of the verification email
+	result := login(t, c, email, password)
+	assert.Equal(t, userID, result.ID)
+
+	//* Two-factor authentication
+	c.ok(http.MethodPost, "/v1/user/enable-tow-factor", map[string]interface{}{"two_factor_enabled": true}, nil)
+
+	h.resetLoginLimit()
+	var twoFactor models.LoginTwoFactor
+	c.ok(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": email, "password": password}, &twoFactor)
+	assert.Equal(t, response.ErrTwoFactorEnabled, twoFactor.Code)
+	assert.Equal(t, constants.OtpChannelEmail, twoFactor.Channel)
+	require.NotEmpty(t, twoFactor.Challenge)
+
+	//* The OTP is kept out of the outbox message, which only references it
+	pending, err := h.repos.Outbox.GetPendingOutbox(context.Background(), constants.OutboxBatchSize)
+	require.NoError(t, err)
+	require.NotEmpty(t, pending)
+	assert.NotContains(t, string(pending[len(pending)-1].Payload), `"body"`)
+	assert.Contains(t, string(pending[len(pending)-1].Payload), `"secret_id"`)
+
+	otp := find(t, h.lastEmail(email, "OTP Login"), `OTP: (\d+)`)
+	c.fails(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": "000000" + otp, "challenge": twoFactor.Challenge}, http.StatusBadRequest, response.ErrorOTPNotExit)
+	c.ok(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": otp, "challenge": twoFactor.Challenge}, &result)
+	c.accessToken = result.AccessToken
+
+	//* An OTP and its challenge can only be used once
+	c.fails(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": otp, "challenge": twoFactor.Challenge}, http.StatusBadRequest, response.ErrorOTPChallengeInvalid)
+
+	//* Renew: the device gets a new key, so the previous access token is rejected
+	previous := c.accessToken
+	c.ok(http.MethodGet, "/v1/auth/renew-token", nil, &result)
+	assert.NotEqual(t, previous, result.AccessToken)
+	c.accessToken = previous
+	c.fails(http.MethodGet, profilePath, nil, http.StatusUnauthorized, response.ErrCodeAuthTokenInvalid)
+	c.accessToken = result.AccessToken
+	c.ok(http.MethodGet, profilePath, nil, nil)
+
+	//* Change password
+	c.fails(http.MethodPost, "/v1/user/change-pass", map[string]string{"password": "weakpassword"}, http.StatusBadRequest, response.ErrorPassWeak)
+	c.ok(http.MethodPost, "/v1/user/change-pass", map[string]string{"password": newPassword}, nil)
+	c.fails(http.MethodPost, "/v1/user/change-pass", map[string]string{"password": newPassword}, http.StatusBadRequest, response.ErrorPasswordIsOld)
+
+	h.resetLoginLimit()
+	c.fails(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": email, "password": password}, http.StatusBadRequest, response.ErrorPasswordNotMatch)
+	login(t, c, email, newPassword)
+
+	//* Logout clears the cookie, without which the access token is rejected
+	var logout models.LogoutResponse
+	c.ok(http.MethodGet, "/v1/user/logout", nil, &logout)
+	assert.Equal(t, userID, logout.Id)
+	assert.Empty(t, c.refetchToken)
+	c.fails(http.MethodGet, profilePath, nil, http.StatusUnauthorized, response.ErrCodeAuthTokenInvalid)
+	c.fails(http.MethodGet, "/v1/auth/renew-token", nil, http.StatusUnauthorized, response.ErrCookieInvalid)
+
+	//* Destroy: the account is deactivated until the deletion is cancelled with the emailed link
+	login(t, c, email, newPassword)
+	var destroyed models.DestroyAccountResponse
+	c.ok(http.MethodGet, "/v1/user/destroy-account", nil, &destroyed)
+	assert.Equal(t, userID, destroyed.Id)
+	cancelToken := find(t, h.lastEmail(email, "Your account will be deleted"), `/auth/cancel-deletion/(\S+)`)
+
+	c.fails(http.MethodGet, profilePath, nil, http.StatusUnauthorized, response.ErrCodeAuthTokenInvalid)
+	h.resetLoginLimit()
+	c.fails(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": email, "password": newPassword}, http.StatusForbidden, response.ErrorAccountPendingDeletion)
+
+	c.fails(http.MethodPost, "/v1/auth/cancel-deletion", map[string]string{"token": "unknown"}, http.StatusBadRequest, response.ErrorDeletionTokenInvalid)
+	c.ok(http.MethodPost, "/v1/auth/cancel-deletion", map[string]string{"token": cancelToken}, nil)
+	login(t, c, email, newPassword)
+	c.ok(http.MethodGet, profilePath, nil, nil)
+}
+
+func TestRegisterErrors(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+
+	c.fails(http.MethodPost, "/v1/auth/register", map[string]string{"email": "not-an-email"}, http.StatusBadRequest, response.ErrCodeValidation)
+
+	register(t, c, "bob@example.com")
+	c.fails(http.MethodPost, "/v1/auth/register", map[string]string{"email": "bob@example.com"}, http.StatusBadRequest, response.UserExit)
+
+	//* Registrations are limited: the limit is already reached with the requests above
+	for i := 0; i < 2; i++ {
+		c.do(http.MethodPost, "/v1/auth/register", map[string]string{"email": fmt.Sprintf("user%d@example.com", i)})
+	}
+	c.fails(http.MethodPost, "/v1/auth/register", map[string]string{"email": "carol@example.com"}, http.StatusBadRequest, response.ErrIpBlackList)
+}
+
+func TestVerifyErrors(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+
+	var registration models.RegistrationResponse
+	c.ok(http.MethodPost, "/v1/auth/register", map[string]string{"email": "erin@example.com"}, &registration)
+	userID := fmt.Sprint(registration.ID)
+
+	c.fails(http.MethodGet, "/v1/auth/veri-account?user_id="+userID, nil, http.StatusBadRequest, response.ErrCodeValidation)
+	c.fails(http.MethodGet, verifyPath(userID, "wrong-token", "erin@example.com"), nil, http.StatusBadRequest, response.ErrorVerificationCodeNotExit)
+	c.fails(http.MethodGet, verifyPath("999", registration.Token, "erin@example.com"), nil, http.StatusBadRequest, response.ErrorVerificationCodeNotExit)
+
+	//* A link can only be used once
+	c.ok(http.MethodGet, verifyPath(userID, registration.Token, "erin@example.com"), nil, nil)
+	c.fails(http.MethodGet, verifyPath(userID, registration.Token, "erin@example.com"), nil, http.StatusBadRequest, response.ErrorVerificationCodeNotExit)
+}
+
+func TestLoginErrors(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+	_, password := register(t, c, "frank@example.com")
+
+	var unverified models.RegistrationResponse
+	c.ok(http.MethodPost, "/v1/auth/register", map[string]string{"email": "grace@example.com"}, &unverified)
+
+	for _, tc := range []struct {
+		name   string
+		body   map[string]string
+		status int
+		code   int
+	}{
+		{"short password", map[string]string{"identifier": "frank@example.com", "password": "short"}, http.StatusBadRequest, response.ErrCodeValidation},
+		{"unknown email", map[string]string{"identifier": "nobody@example.com", "password": password}, http.StatusBadRequest, response.ErrUserNotExitEmail},
+		{"unverified account", map[string]string{"identifier": "grace@example.com", "password": password}, http.StatusBadRequest, response.ErrUserNotExitEmail},
+		{"unknown username", map[string]string{"identifier": "nobody", "password": password}, http.StatusBadRequest, response.ErrorUserNotExitUsername},
+		{"wrong password", map[string]string{"identifier": "frank@example.com", "password": "Wrong-password1"}, http.StatusBadRequest, response.ErrorPasswordNotMatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h.resetLoginLimit()
+			c.fails(http.MethodPost, "/v1/auth/login-identifier", tc.body, tc.status, tc.code)
+		})
+	}
+
+	//* Logins are limited: after five attempts the next one is blocked, even with the right password
+	h.resetLoginLimit()
+	for i := 0; i < 5; i++ {
+		c.do(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": "frank@example.com", "password": "Wrong-password1"})
+	}
+	c.fails(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": "frank@example.com", "password": password}, http.StatusBadRequest, response.ErrIpBlackList)
+}
+
+func TestOtpErrors(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+
+	c.fails(http.MethodPost, "/v1/auth/verify-otp", map[string]string{}, http.StatusBadRequest, response.ErrCodeCacheInvalidRequest)
+	c.fails(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": "123456"}, http.StatusBadRequest, response.ErrCodeCacheInvalidRequest)
+	c.fails(http.MethodPost, "/v1/auth/verify-otp", map[string]interface{}{"otp": "123456", "challenge": "unknown", "channel": 99}, http.StatusBadRequest, response.ErrorOTPChannelInvalid)
+	c.fails(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": "123456", "challenge": "unknown"}, http.StatusBadRequest, response.ErrorOTPChallengeInvalid)
+
+	//* SMS needs a verified phone number
+	register(t, c, "heidi@example.com")
+	c.fails(http.MethodPost, "/v1/user/enable-tow-factor", map[string]interface{}{"two_factor_enabled": true, "channel": constants.OtpChannelSMS}, http.StatusBadRequest, response.ErrorUserPhoneNotVerified)
+}
+
+// startTwoFactorLogin enables two-factor authentication on the account of c and logs in with the password,
+// which emails an OTP. It returns the challenge of the login and the OTP.
+func startTwoFactorLogin(t *testing.T, c *client, email string, password string) (string, string) {
+	t.Helper()
+	c.ok(http.MethodPost, "/v1/user/enable-tow-factor", map[string]interface{}{"two_factor_enabled": true}, nil)
+
+	c.h.resetLoginLimit()
+	var twoFactor models.LoginTwoFactor
+	c.ok(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": email, "password": password}, &twoFactor)
+	require.Equal(t, response.ErrTwoFactorEnabled, twoFactor.Code)
+	return twoFactor.Challenge, find(t, c.h.lastEmail(email, "OTP Login"), `OTP: (\d+)`)
+}
+
+func TestOtpChallenge(t *testing.T) {
+	h := newHarness(t)
+	alice, bob := h.client("device-alice"), h.client("device-bob")
+	const aliceEmail, bobEmail = "alice@example.com", "bob@example.com"
+	aliceID, alicePassword := register(t, alice, aliceEmail)
+	_, bobPassword := register(t, bob, bobEmail)
+
+	aliceChallenge, aliceOtp := startTwoFactorLogin(t, alice, aliceEmail, alicePassword)
+	bobChallenge, bobOtp := startTwoFactorLogin(t, bob, bobEmail, bobPassword)
+	require.NotEqual(t, aliceChallenge, bobChallenge)
+
+	//* The code of user A is rejected for user B, and is not used up
+	bob.fails(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": aliceOtp, "challenge": bobChallenge}, http.StatusBadRequest, response.ErrorOTPNotExit)
+	var result models.LoginResponse
+	alice.ok(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": aliceOtp, "challenge": aliceChallenge}, &result)
+	assert.Equal(t, aliceID, result.ID)
+
+	//* After the maximum of attempts the challenge is deleted, even for the right code
+	for i := 1; i < constants.OtpChallengeMaxAttempts; i++ {
+		bob.fails(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": "000000", "challenge": bobChallenge}, http.StatusBadRequest, response.ErrorOTPNotExit)
+	}
+	bob.fails(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": bobOtp, "challenge": bobChallenge}, http.StatusBadRequest, response.ErrorOTPChallengeInvalid)
+	bob.fails(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": bobOtp, "challenge": bobChallenge}, http.StatusBadRequest, response.ErrorOTPChallengeInvalid)
+
+	//* A new login gets a new challenge
+	bobChallenge, bobOtp = startTwoFactorLogin(t, bob, bobEmail, bobPassword)
+	bob.ok(http.MethodPost, "/v1/auth/verify-otp", map[string]string{"otp": bobOtp, "challenge": bobChallenge}, nil)
+}
+
+func TestAuthorizationErrors(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+	userID, _ := register(t, c, "ivan@example.com")
+	profilePath := fmt.Sprintf("/v1/user/profile/%d", userID)
+
+	//* Every request needs the device header
+	noDevice := &client{h: h, accessToken: c.accessToken, refetchToken: c.refetchToken}
+	noDevice.fails(http.MethodGet, profilePath, nil, http.StatusBadRequest, response.ErrCodeHeaderNotExit)
+
+	//* The tokens belong to the device they were issued to
+	otherDevice := &client{h: h, deviceID: "device-2", accessToken: c.accessToken, refetchToken: c.refetchToken}
+	otherDevice.fails(http.MethodGet, profilePath, nil, http.StatusUnauthorized, response.ErrCodeAuthTokenInvalid)
+	otherDevice.fails(http.MethodGet, "/v1/auth/renew-token", nil, http.StatusUnauthorized, response.ErrCodeDeviceNotExit)
+
+	forged := &client{h: h, deviceID: c.deviceID, accessToken: "forged", refetchToken: c.refetchToken}
+	forged.fails(http.MethodGet, profilePath, nil, http.StatusUnauthorized, response.ErrCodeAuthTokenInvalid)
+
+	noCookie := &client{h: h, deviceID: c.deviceID, accessToken: c.accessToken}
+	noCookie.fails(http.MethodGet, profilePath, nil, http.StatusUnauthorized, response.ErrCodeAuthTokenInvalid)
+	noCookie.fails(http.MethodGet, "/v1/auth/renew-token", nil, http.StatusUnauthorized, response.ErrCookieInvalid)
+
+	c.ok(http.MethodGet, profilePath, nil, nil)
+}
+
+func TestRevokeNewDevice(t *testing.T) {
+	h := newHarness(t)
+	const email = "judy@example.com"
+	first := h.client("device-1")
+	userID, password := register(t, first, email)
+
+	//* A sign-in from another device is notified by email, with a link that signs that device out
+	second := h.client("device-2")
+	login(t, second, email, password)
+	token := find(t, h.lastEmail(email, "New sign-in to your account"), `/auth/revoke/device/(\S+)`)
+
+	var revoked models.RevokeDeviceResponse
+	second.ok(http.MethodPost, "/v1/auth/revoke-device", map[string]string{"token": token}, &revoked)
+	assert.Equal(t, userID, revoked.Id)
+	assert.Equal(t, "device-2", revoked.DeviceID)
+	second.fails(http.MethodPost, "/v1/auth/revoke-device", map[string]string{"token": token}, http.StatusBadRequest, response.ErrorRevokeTokenInvalid)
+
+	profilePath := fmt.Sprintf("/v1/user/profile/%d", userID)
+	second.fails(http.MethodGet, profilePath, nil, http.StatusUnauthorized, response.ErrCodeAuthTokenInvalid)
+	first.ok(http.MethodGet, profilePath, nil, nil)
+}
+
+func TestRequestErrors(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+
+	c.fails(http.MethodGet, "/v1/unknown", nil, http.StatusNotFound, response.ErrCodeResourceNotFound)
+	c.fails(http.MethodGet, "/v1/auth/veri-account?token=<script>alert(1)</script>", nil, http.StatusBadRequest, response.ErrPotentiallyDangerousInputDetected)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/auth/register", strings.NewReader("email=kim@example.com"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Device-Id", c.deviceID)
+	recorder := httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), fmt.Sprintf(`"code":%d`, response.ErrCodeContentType))
+}
+
+func TestRateLimit(t *testing.T) {
+	h := newHarness(t, func(cfg *models.Config) {
+		cfg.Server.RateLimit = 1
+		cfg.Server.RateBurst = 1
+	})
+	c := h.client("device-1")
+
+	c.fails(http.MethodGet, "/v1/unknown", nil, http.StatusNotFound, response.ErrCodeResourceNotFound)
+	c.fails(http.MethodGet, "/v1/unknown", nil, http.StatusTooManyRequests, response.ErrCodeTooManyRequests)
+}
+
+func TestResetPassword(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+	const email = "liam@example.com"
+	userID, password := register(t, c, email)
+
+	c.ok(http.MethodPost, "/v1/auth/forget", map[string]string{"email": email}, nil)
+
+	//* The link is <frontend>/auth/reset/password/<expires at>/<user id>/<token>
+	link := find(t, h.lastEmail(email, "Forget Password"), `/auth/reset/password/(\S+)`)
+	parts := strings.Split(link, "/")
+	require.Len(t, parts, 3, link)
+	token := parts[2]
+
+	reset := func(password string) map[string]interface{} {
+		return map[string]interface{}{"token": token, "user_id": userID, "password": password}
+	}
+	c.fails(http.MethodPost, "/v1/auth/reset-password", reset("weakpassword"), http.StatusBadRequest, response.ErrorPassWeak)
+	c.ok(http.MethodPost, "/v1/auth/reset-password", reset(newPassword), nil)
+
+	//* The link is used up with the password change
+	c.fails(http.MethodPost, "/v1/auth/reset-password", reset("An0ther-password"), http.StatusBadRequest, response.ErrorVerificationCodeNotExit)
+
+	h.resetLoginLimit()
+	c.fails(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": email, "password": password}, http.StatusBadRequest, response.ErrorPasswordNotMatch)
+	login(t, c, email, newPassword)
+}
+
+func TestVerifyPhone(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+	register(t, c, "nina@example.com")
+
+	c.ok(http.MethodPost, "/v1/user/update-profile", map[string]string{"phone": "+14155550100"}, nil)
+	c.ok(http.MethodPost, "/v1/user/send-otp-phone", map[string]string{}, nil)
+	otp := h.lastSMSCode("+14155550100")
+
+	//* Another user can neither use the code nor use it up
+	other := h.client("device-2")
+	register(t, other, "oscar@example.com")
+	other.fails(http.MethodPost, "/v1/user/verify-phone", map[string]string{"otp": otp}, http.StatusBadRequest, response.ErrorOTPNotExit)
+
+	//* The code only proves the number it was sent to
+	c.ok(http.MethodPost, "/v1/user/update-profile", map[string]string{"phone": "+14155550101"}, nil)
+	c.fails(http.MethodPost, "/v1/user/verify-phone", map[string]string{"otp": otp}, http.StatusBadRequest, response.ErrorOTPInvalid)
+
+	c.ok(http.MethodPost, "/v1/user/send-otp-phone", map[string]string{}, nil)
+	var verified models.VerifyPhoneResponse
+	c.ok(http.MethodPost, "/v1/user/verify-phone", map[string]string{"otp": h.lastSMSCode("+14155550101")}, &verified)
+	assert.True(t, verified.PhoneVerified)
+}
+
+func TestPhoneRegion(t *testing.T) {
+	//* Each App parses numbers without a country code for its own phone.defaultregion
+	us := newHarness(t, func(cfg *models.Config) { cfg.Phone.DefaultRegion = "us" })
+	vn := newHarness(t)
+	usClient, vnClient := us.client("device-1"), vn.client("device-1")
+	register(t, usClient, "paul@example.com")
+	register(t, vnClient, "quinn@example.com")
+
+	usClient.ok(http.MethodPost, "/v1/user/update-profile", map[string]string{"phone": "(415) 555-0100"}, nil)
+	usClient.ok(http.MethodPost, "/v1/user/send-otp-phone", map[string]string{}, nil)
+	assert.NotEmpty(t, us.lastSMSCode("+14155550100"))
+
+	vnClient.fails(http.MethodPost, "/v1/user/update-profile", map[string]string{"phone": "(415) 555-0100"}, http.StatusBadRequest, response.ErrorUserPhoneInvalid)
+	vnClient.ok(http.MethodPost, "/v1/user/update-profile", map[string]string{"phone": "0912 345 678"}, nil)
+	vnClient.ok(http.MethodPost, "/v1/user/send-otp-phone", map[string]string{}, nil)
+	assert.NotEmpty(t, vn.lastSMSCode("+84912345678"))
+}
+
+func TestUpdateEmail(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+	userID, _ := register(t, c, "mia@example.com")
+	profilePath := fmt.Sprintf("/v1/user/profile/%d", userID)
+
+	c.ok(http.MethodPost, "/v1/user/send-otp-update-email", map[string]string{"email": "mia@example.org"}, nil)
+	otp := find(t, h.lastEmail("mia@example.org", "Update Email OTP"), `OTP: (\d+)`)
+
+	//* The address is taken before the change: nothing is written and the OTP can still be used
+	register(t, h.client("device-2"), "mia@example.org")
+	c.fails(http.MethodPost, "/v1/user/update-email", map[string]string{"email": "mia@example.org", "otp": otp}, http.StatusBadRequest, response.ErrUserDuplicateEmail)
+	c.ok(http.MethodGet, profilePath, nil, nil)
+
+	var result models.LoginResponse
+	c.ok(http.MethodPost, "/v1/user/update-email", map[string]string{"email": "mia@example.net", "otp": otp}, &result)
+	assert.Equal(t, "mia@example.net", result.Email)
+	c.accessToken = result.AccessToken
+	c.ok(http.MethodGet, profilePath, nil, nil)
+
+	c.fails(http.MethodPost, "/v1/user/update-email", map[string]string{"email": "mia@example.com", "otp": otp}, http.StatusBadRequest, response.ErrorOTPNotExit)
+
+	//* The OTP email is written in the language saved in the profile
+	c.ok(http.MethodPost, "/v1/user/update-profile", map[string]string{"locale": "vi"}, nil)
+	c.ok(http.MethodPost, "/v1/user/send-otp-update-email", map[string]string{"email": "mia@example.io"}, nil)
+	h.lastEmail("mia@example.io", "Mã OTP cập nhật email")
+}
+
+func TestMetrics(t *testing.T) {
+	h := newHarness(t, func(cfg *models.Config) {
+		cfg.Metrics.Token = "scrape-token"
+	})
+	c := h.client("device-1")
+	const email = "mallory@example.com"
+	_, password := register(t, c, email)
+
+	wrongPassword := metrics.Logins.WithLabelValues(constants.LoginMethodIdentifier, constants.MetricResultFailure, strconv.Itoa(response.ErrorPasswordNotMatch))
+	success := metrics.Logins.WithLabelValues(constants.LoginMethodIdentifier, constants.MetricResultSuccess, "")
+	renewed := metrics.TokenRenewals.WithLabelValues(constants.MetricResultSuccess, "")
+	noCookie := metrics.TokenRenewals.WithLabelValues(constants.MetricResultFailure, strconv.Itoa(response.ErrCookieInvalid))
+	before := []float64{testutil.ToFloat64(wrongPassword), testutil.ToFloat64(success), testutil.ToFloat64(renewed), testutil.ToFloat64(noCookie)}
+
+	h.resetLoginLimit()
+	c.fails(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": email, "password": "Wrong-password1"}, http.StatusBadRequest, response.ErrorPasswordNotMatch)
+	login(t, c, email, password)
+	c.ok(http.MethodGet, "/v1/auth/renew-token", nil, nil)
+	(&client{h: h, deviceID: c.deviceID}).fails(http.MethodGet, "/v1/auth/renew-token", nil, http.StatusUnauthorized, response.ErrCookieInvalid)
+
+	//* A failure is counted with the error code of its response, and a renewal refused by the middleware is counted too
+	assert.Equal(t, before[0]+1, testutil.ToFloat64(wrongPassword))
+	assert.Equal(t, before[1]+1, testutil.ToFloat64(success))
+	assert.Equal(t, before[2]+1, testutil.ToFloat64(renewed))
+	assert.Equal(t, before[3]+1, testutil.ToFloat64(noCookie))
+
+	//* The metrics need the token
+	recorder := httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-token")
+	recorder = httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `http_request_duration_seconds_count{method="POST",route="/v1/auth/login-identifier",status="400"}`)
+	assert.Contains(t, recorder.Body.String(), `auth_logins_total{method="identifier",reason="15001",result="failure"}`)
+	assert.Contains(t, recorder.Body.String(), "redis_pool_connections")
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	h := newHarness(t)
+
+	//* The request continues the trace of the caller
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	h.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	ping := endedSpan(t, recorder, "/ping")
+	assert.Equal(t, traceID, ping.SpanContext().TraceID().String())
+
+	//* The outbox row keeps the trace of the request that wrote it, for the relay to put in the message headers
+	c := h.client("device-1")
+	c.ok(http.MethodPost, "/v1/auth/register", map[string]string{"email": "trent@example.com"}, nil)
+	registration := endedSpan(t, recorder, "/v1/auth/register")
+
+	pending, err := h.repos.Outbox.GetPendingOutbox(context.Background(), constants.OutboxBatchSize)
+	require.NoError(t, err)
+	require.NotEmpty(t, pending)
+	row := pending[len(pending)-1]
+	require.Contains(t, row.TraceContext, "traceparent")
+
+	stored := trace.SpanContextFromContext(tracing.Extract(context.Background(), row.TraceContext))
+	assert.Equal(t, registration.SpanContext().TraceID(), stored.TraceID())
+}
+
+// endedSpan returns the last ended span with the given name.
+func endedSpan(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	spans := recorder.Ended()
+	for i := len(spans) - 1; i >= 0; i-- {
+		if spans[i].Name() == name {
+			return spans[i]
+		}
+	}
+	require.Failf(t, "span not found", "no ended span named %s", name)
+	return nil
+}
+
+func TestRequestLogs(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logger.New(models.LogConfig{Level: "debug"}, &logs))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	h := newHarness(t)
+	c := h.client("device-1")
+	const email = "peggy@example.com"
+	userID, password := register(t, c, email)
+	profilePath := fmt.Sprintf("/v1/user/profile/%d", userID)
+
+	//* The request ID sent by the caller is returned in the header and in the error body
+	req := httptest.NewRequest(http.MethodGet, profilePath, nil)
+	req.Header.Set("X-Device-Id", c.deviceID)
+	req.Header.Set(constants.HeaderRequestID, "req-123")
+	recorder := httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, req)
+	assert.Equal(t, "req-123", recorder.Header().Get(constants.HeaderRequestID))
+	var failure response.ErrorResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &failure))
+	assert.Equal(t, http.StatusUnauthorized, failure.Status)
+	assert.Equal(t, "req-123", failure.RequestID)
+
+	//* An invalid one is replaced
+	req.Header.Set(constants.HeaderRequestID, "bad id\n")
+	recorder = httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, req)
+	assert.Len(t, recorder.Header().Get(constants.HeaderRequestID), 36)
+
+	//* The access record of a signed in request carries the request ID, the user and the device
+	c.ok(http.MethodGet, profilePath, nil, nil)
+	var access map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		if record["msg"] == "request" && record["route"] == "/v1/user/profile/:id" && record["status"] == float64(http.StatusOK) {
+			access = record
+		}
+	}
+	require.NotNil(t, access, logs.String())
+	assert.NotEmpty(t, access["request_id"])
+	assert.Equal(t, float64(userID), access["user_id"])
+	assert.Equal(t, c.deviceID, access["device_id"])
+
+	//* The email, the password and the tokens never reach the logs
+	assert.NotContains(t, logs.String(), email)
+	assert.NotContains(t, logs.String(), password)
+	assert.NotContains(t, logs.String(), c.accessToken)
+
+	//* The token of a download link is redacted from the path
+	const exportToken = "f3b1c9d27a6e4c0b8d5e2a7f9c1b3d4e"
+	router := gin.New()
+	router.Use(middlewares.AccessLogMiddleware())
+	router.GET("/v1/exports/:token", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/exports/"+exportToken, nil))
+	assert.Contains(t, logs.String(), `"path":"/v1/exports/[REDACTED]"`)
+	assert.NotContains(t, logs.String(), exportToken)
+}
+
+func TestDataExport(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+	const email = "nina@example.com"
+	register(t, c, email)
+
+	var requested models.DataExportResponse
+	c.ok(http.MethodPost, "/v1/user/export-data", struct{}{}, &requested)
+	assert.Equal(t, constants.DataExportStatusPending, requested.Status)
+	c.fails(http.MethodPost, "/v1/user/export-data", struct{}{}, http.StatusBadRequest, response.ErrorDataExportInProgress)
+
+	//* The queue consumer builds the archive from the repositories and emails the link
+	h.buildDataExports()
+	token := find(t, h.lastEmail(email, "Your data export is ready"), `/v1/exports/(\w+)`)
+
+	download := c.send(http.MethodGet, "/v1/exports/"+token, nil)
+	require.Equal(t, http.StatusOK, download.Code, download.Body.String())
+	archive, err := zip.NewReader(bytes.NewReader(download.Body.Bytes()), int64(download.Body.Len()))
+	require.NoError(t, err)
+	data, err := archive.Open(constants.ExportDataFile)
+	require.NoError(t, err)
+	var export models.UserDataExport
+	require.NoError(t, json.NewDecoder(data).Decode(&export))
+	assert.Equal(t, email, export.Profile.Email)
+	assert.NotEmpty(t, export.Devices)
+	assert.NotEmpty(t, export.Verifications)
+
+	//* A new export can be requested once the last one is built
+	c.ok(http.MethodPost, "/v1/user/export-data", struct{}{}, nil)
+}
+
+func TestDataExportSharedStorage(t *testing.T) {
+	//* The queue consumer and the server run in their own directory and mount the export volume
+	//* at different paths, as containers do: the server finds the archive by its key in its own mount
+	volume := t.TempDir()
+	queueDir, serverDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.Symlink(volume, filepath.Join(queueDir, "exports")))
+	require.NoError(t, os.Mkdir(filepath.Join(serverDir, "mnt"), 0o750))
+	require.NoError(t, os.Symlink(volume, filepath.Join(serverDir, "mnt", "exports")))
+
+	root, err := os.Getwd()
+	require.NoError(t, err)
+
+	chdir(t, serverDir)
+	h := newHarness(t, func(cfg *models.Config) {
+		cfg.Export.Dir = filepath.Join("mnt", "exports")
+	})
+
+	chdir(t, queueDir)
+	queueExports, err := storage.NewDir("exports")
+	require.NoError(t, err)
+	queueCfg := h.cfg
+	queueCfg.Export.Dir = "exports"
+	h.queue = service.New(&app.App{Cfg: queueCfg, Repos: h.repos, Exports: queueExports})
+
+	//* The email templates are read from the root of the repository
+	chdir(t, root)
+
+	c := h.client("device-1")
+	const email = "omar@example.com"
+	register(t, c, email)
+	c.ok(http.MethodPost, "/v1/user/export-data", struct{}{}, nil)
+	h.buildDataExports()
+	token := find(t, h.lastEmail(email, "Your data export is ready"), `/v1/exports/(\w+)`)
+
+	export, err := h.repos.DataExports.GetDataExportByToken(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, token+".zip", export.FilePath.String, "the key of the archive, not a path of the queue")
+	assert.FileExists(t, filepath.Join(volume, token+".zip"))
+
+	download := c.send(http.MethodGet, "/v1/exports/"+token, nil)
+	require.Equal(t, http.StatusOK, download.Code, download.Body.String())
+	assert.Equal(t, fmt.Sprintf(`attachment; filename="data-export-%d.zip"`, export.ID), download.Header().Get("Content-Disposition"))
+	_, err = zip.NewReader(bytes.NewReader(download.Body.Bytes()), int64(download.Body.Len()))
+	require.NoError(t, err)
+
+	//* An archive missing from the storage is not found
+	require.NoError(t, os.Remove(filepath.Join(volume, token+".zip")))
+	c.fails(http.MethodGet, "/v1/exports/"+token, nil, http.StatusNotFound, response.ErrorDataExportNotFound)
+}
+
+func TestAccountDeletionRemovesExports(t *testing.T) {
+	h := newHarness(t)
+	c := h.client("device-1")
+	const email = "pia@example.com"
+	userID, _ := register(t, c, email)
+
+	//* Two built exports, so the user has two archives in the export storage
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		c.ok(http.MethodPost, "/v1/user/export-data", struct{}{}, nil)
+		h.buildDataExports()
+		tokens = append(tokens, find(t, h.lastEmail(email, "Your data export is ready"), `/v1/exports/(\w+)`))
+	}
+	for _, token := range tokens {
+		require.FileExists(t, filepath.Join(h.cfg.Export.Dir, token+".zip"))
+	}
+
+	c.ok(http.MethodGet, "/v1/user/destroy-account", nil, nil)
+
+	//* Nothing is erased during the grace period
+	purged, err := h.runJob(constants.JobAccountDeletion)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	h.store.SetClock(func() time.Time { return time.Now().AddDate(1, 0, 0) })
+	purged, err = h.runJob(constants.JobAccountDeletion)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, purged)
+
+	for _, token := range tokens {
+		assert.NoFileExists(t, filepath.Join(h.cfg.Export.Dir, token+".zip"))
+		_, err := h.repos.DataExports.GetDataExportByToken(context.Background(), token)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	}
+	user, err := h.repos.Users.GetUserId(context.Background(), models.GetUserIdParams{ID: userID, IsActive: false})
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(constants.AnonymisedEmailFormat, userID), user.Email)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 
+	firebase "firebase.google.com/go"
 	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/helpers"
 	"github.com/gin-gonic/gin"
 )
@@ -16,18 +17,20 @@ type UserTest struct {
 
 // CreateAndGetUidTestFireBase is a function that creates a new user in Firebase
 // and retrieves the ID token for the user.
-func CreateAndGetUidTestFireBase(c *gin.Context) {
+func CreateAndGetUidTestFireBase(c *gin.Context, app *firebase.App) {
+	fb := &helpers.FirebaseAuth{App: app}
+
 	// Create a new user
 	email := helpers.RandomEmail()
 	password := helpers.RandomPassword()
-	u, err := helpers.CreateUser(c, email, password)
+	u, err := helpers.CreateUser(c, fb, email, password)
 	if err != nil {
 		errMsg := fmt.Errorf("error creating user: %v", err)
 		log.Fatalf(errMsg.Error())
 	}
 
 	// Get the ID Token for the user
-	userRecord := helpers.GetUserIDToken(c, u.UID)
+	userRecord := helpers.GetUserRecord(c, fb, u.UID)
 	if userRecord != nil {
 		fmt.Printf("ID userRecord: %s\n", userRecord)
 	} else {
@@ -0,0 +1,329 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/app"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/jobs"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/repo/memory"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/routers"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/service"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/geoip"
+	pkg "github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/mail"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/mailer"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/sms"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain runs the suite from the root of the repository, where the binaries run,
+// so the email templates are found.
+func TestMain(m *testing.M) {
+	if err := os.Chdir(".."); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// harness is the API served by routers.NewRouter on top of the in-memory repositories,
+// miniredis and a mailer that keeps the emails in memory.
+type harness struct {
+	t      *testing.T
+	app    *app.App
+	router *gin.Engine
+	svc    *service.Service
+	queue  *service.Service
+	store  *memory.Store
+	repos  repo.Repositories
+	redis  *miniredis.Miniredis
+	mailer *mailer.MemoryMailer
+	smsLog string
+	cfg    models.Config
+}
+
+// newHarness creates the API with a test configuration, which the options may change.
+func newHarness(t *testing.T, options ...func(cfg *models.Config)) *harness {
+	t.Helper()
+
+	cfg := models.Config{
+		Server: models.ServerConfig{
+			Host:         "localhost",
+			PortFrontend: "http://localhost:5173",
+			KeyPassword:  "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789",
+			RateLimit:    1000,
+			RateBurst:    1000,
+		},
+		Gmail:  models.GmailConfig{Mail: "no-reply@example.com"},
+		Export: models.ExportConfig{Dir: t.TempDir()},
+	}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	store := memory.New()
+	redisServer := miniredis.RunT(t)
+	cache := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { cache.Close() })
+
+	h := &harness{
+		t:      t,
+		store:  store,
+		repos:  store.Repositories(),
+		redis:  redisServer,
+		mailer: mailer.NewMemoryMailer(),
+		smsLog: filepath.Join(t.TempDir(), "sms.log"),
+		cfg:    cfg,
+	}
+
+	exports, err := storage.NewDir(cfg.Export.Dir)
+	require.NoError(t, err)
+
+	locator, err := geoip.Open(cfg.GeoIP.CityPath, cfg.GeoIP.ASNPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { locator.Close() })
+
+	a := &app.App{
+		Cfg:     cfg,
+		Repos:   h.repos,
+		Cache:   cache,
+		Mailer:  h.mailer,
+		SMS:     sms.NewLogSender(h.smsLog),
+		GeoIP:   locator,
+		Exports: exports,
+	}
+	h.app = a
+	h.svc = service.New(a)
+	h.queue = h.svc
+	h.router = routers.NewRouter(a, h.svc)
+	return h
+}
+
+// buildDataExports does what the queue consumer does with the data_export.requested messages of the outbox:
+// it builds their archive with h.queue, which queues the email with the download link.
+// h.queue shares the App of the API unless a test gives the queue consumer its own.
+func (h *harness) buildDataExports() {
+	h.t.Helper()
+
+	pending, err := h.repos.Outbox.GetPendingOutbox(context.Background(), constants.OutboxBatchSize)
+	require.NoError(h.t, err)
+	for _, message := range pending {
+		if message.EventType != constants.EventDataExportRequested {
+			continue
+		}
+		var event models.DataExportRequestedEvent
+		require.NoError(h.t, json.Unmarshal(message.Payload, &event))
+		require.NoError(h.t, h.queue.BuildDataExport(context.Background(), event))
+	}
+}
+
+// deliverEmails does what the outbox relay and the queue consumer do together:
+// it sends the email.send messages of the outbox, with their secret, with the mailer
+// and marks every pending message published.
+func (h *harness) deliverEmails() {
+	h.t.Helper()
+
+	err := h.repos.WithTx(context.Background(), func(tx repo.Repositories) error {
+		pending, err := tx.Outbox.GetPendingOutbox(context.Background(), constants.OutboxBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, message := range pending {
+			if message.EventType == constants.EventEmailSend {
+				var email models.EmailMessage
+				if err := json.Unmarshal(message.Payload, &email); err != nil {
+					return err
+				}
+				body := email.Body
+				if email.SecretID != 0 {
+					if body, err = tx.Outbox.GetOutboxSecret(context.Background(), email.SecretID); err != nil {
+						return err
+					}
+				}
+				if _, err := pkg.SendGoEmail(h.mailer, h.cfg.Gmail.Mail, email.To, models.EmailData{
+					Template: email.Template,
+					Locale:   email.Locale,
+					Body:     body,
+					Details:  email.Details,
+				}); err != nil {
+					return err
+				}
+			}
+			if err := tx.Outbox.MarkOutboxPublished(context.Background(), message.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(h.t, err)
+}
+
+// lastEmail delivers the pending emails and returns the last one sent to the address,
+// which must have the given subject.
+func (h *harness) lastEmail(to string, subject string) mailer.Message {
+	h.t.Helper()
+	h.deliverEmails()
+
+	messages := h.mailer.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To == to {
+			require.Equal(h.t, subject, messages[i].Subject, "last email to %s", to)
+			return messages[i]
+		}
+	}
+	require.FailNow(h.t, "no email sent", "to %s", to)
+	return mailer.Message{}
+}
+
+// lastSMSCode returns the OTP code of the last SMS sent to the phone number.
+func (h *harness) lastSMSCode(phone string) string {
+	h.t.Helper()
+
+	log, err := os.ReadFile(h.smsLog)
+	require.NoError(h.t, err)
+	matches := regexp.MustCompile(`to=`+regexp.QuoteMeta(phone)+` message=".*?code is (\d+)`).FindAllStringSubmatch(string(log), -1)
+	require.NotEmpty(h.t, matches, "no SMS sent to %s", phone)
+	return matches[len(matches)-1][1]
+}
+
+// runJob runs one of the jobs of the cronjob binary against the API's App and returns the number of rows it handled.
+func (h *harness) runJob(name string) (int64, error) {
+	h.t.Helper()
+	for _, job := range jobs.DefaultJobs(h.app) {
+		if job.Name == name {
+			return job.Run(context.Background())
+		}
+	}
+	require.FailNow(h.t, "unknown job", name)
+	return 0, nil
+}
+
+// chdir changes the working directory until the end of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	previous, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+// find returns the first group of the pattern in the text of an email.
+func find(t *testing.T, message mailer.Message, pattern string) string {
+	t.Helper()
+	match := regexp.MustCompile(pattern).FindStringSubmatch(message.Text)
+	require.Len(t, match, 2, "%q not found in email %q", pattern, message.Subject)
+	return match[1]
+}
+
+// client sends requests as one device: it keeps the access token of the last login
+// and the user_login cookie, as a browser does. The requests come from ip when it is set.
+type client struct {
+	h            *harness
+	deviceID     string
+	ip           string
+	accessToken  string
+	refetchToken string
+}
+
+func (h *harness) client(deviceID string) *client {
+	return &client{h: h, deviceID: deviceID}
+}
+
+// result is the JSON body of a response: the error code of an error, the metadata of a success.
+type result struct {
+	Status   int             `json:"status"`
+	Code     int             `json:"code"`
+	Message  string          `json:"message"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// decode unmarshals the metadata of a successful response.
+func (r result) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	require.NoError(t, json.Unmarshal(r.Metadata, v), string(r.Metadata))
+}
+
+// do sends a request with the device header, the access token and the cookie, when the client has them.
+// A body is sent as JSON.
+func (c *client) do(method string, path string, body interface{}) result {
+	c.h.t.Helper()
+	recorder := c.send(method, path, body)
+
+	var res result
+	require.NoError(c.h.t, json.Unmarshal(recorder.Body.Bytes(), &res), recorder.Body.String())
+	require.Equal(c.h.t, recorder.Code, res.Status, recorder.Body.String())
+	return res
+}
+
+// send sends a request like do and returns the raw response, for the routes that do not answer with JSON.
+func (c *client) send(method string, path string, body interface{}) *httptest.ResponseRecorder {
+	c.h.t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		require.NoError(c.h.t, err)
+		reader = bytes.NewReader(payload)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if c.ip != "" {
+		req.RemoteAddr = c.ip + ":40000"
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.deviceID != "" {
+		req.Header.Set("X-Device-Id", c.deviceID)
+	}
+	if c.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	}
+	if c.refetchToken != "" {
+		req.AddCookie(&http.Cookie{Name: constants.UserLoginKey, Value: c.refetchToken})
+	}
+
+	recorder := httptest.NewRecorder()
+	c.h.router.ServeHTTP(recorder, req)
+
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == constants.UserLoginKey {
+			c.refetchToken = cookie.Value
+		}
+	}
+	return recorder
+}
+
+// ok sends a request that must succeed and decodes its metadata into v, unless v is nil.
+func (c *client) ok(method string, path string, body interface{}, v interface{}) {
+	c.h.t.Helper()
+	res := c.do(method, path, body)
+	require.Contains(c.h.t, []int{http.StatusOK, http.StatusCreated}, res.Status, "%s %s: %+v", method, path, res)
+	if v != nil {
+		res.decode(c.h.t, v)
+	}
+}
+
+// fails sends a request that must fail with the status and error code.
+func (c *client) fails(method string, path string, body interface{}, status int, code int) {
+	c.h.t.Helper()
+	res := c.do(method, path, body)
+	require.Equal(c.h.t, status, res.Status, "%s %s: %+v", method, path, res)
+	require.Equal(c.h.t, code, res.Code, "%s %s: %+v", method, path, res)
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/jobs"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingJob returns a job that signals started when it runs and returns rows once release is closed.
+func blockingJob(name string, rows int64) (job jobs.Job, started chan struct{}, release chan struct{}) {
+	started, release = make(chan struct{}), make(chan struct{})
+	job = jobs.Job{
+		Name: name,
+		Spec: "@every 1h",
+		Run: func(ctx context.Context) (int64, error) {
+			close(started)
+			<-release
+			return rows, nil
+		},
+	}
+	return job, started, release
+}
+
+func TestJobRunnerStaleHolder(t *testing.T) {
+	h := newHarness(t)
+	const name = "stale-holder"
+
+	//* A first process starts the job, then pauses longer than its lease
+	pausedJob, pausedStarted, pausedRelease := blockingJob(name, 5)
+	paused, err := jobs.NewRunner(h.app, []jobs.Job{pausedJob})
+	require.NoError(t, err)
+	staleRunID, err := paused.Trigger(name, 1)
+	require.NoError(t, err)
+	<-pausedStarted
+
+	h.redis.FastForward(constants.JobLeaseTTL + time.Second)
+
+	//* A second process takes the lease over: the run of the first one is closed as failed
+	holderJob, holderStarted, holderRelease := blockingJob(name, 7)
+	holder, err := jobs.NewRunner(h.app, []jobs.Job{holderJob})
+	require.NoError(t, err)
+	runID, err := holder.Trigger(name, 1)
+	require.NoError(t, err)
+	<-holderStarted
+
+	//* When the first process resumes, the end of its run is not recorded over the new holder's records
+	close(pausedRelease)
+	paused.Stop(5 * time.Second)
+	metrics := paused.Metrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, int64(1), metrics[0].Failures)
+	assert.Equal(t, "fencing token is older than the last run", metrics[0].LastError)
+
+	close(holderRelease)
+	holder.Stop(5 * time.Second)
+
+	runs, err := h.repos.JobRuns.ListJobRuns(context.Background(), models.ListJobRunsParams{JobName: name, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+
+	assert.Equal(t, runID, runs[0].ID)
+	assert.Equal(t, constants.JobRunStatusSucceeded, runs[0].Status)
+	assert.Equal(t, int64(7), runs[0].Rows)
+
+	assert.Equal(t, staleRunID, runs[1].ID)
+	assert.Equal(t, constants.JobRunStatusFailed, runs[1].Status)
+	assert.Equal(t, sql.NullString{String: "lease lost", Valid: true}, runs[1].Error)
+	assert.Equal(t, int64(0), runs[1].Rows)
+	assert.Less(t, runs[1].FenceToken, runs[0].FenceToken)
+
+	//* A lease whose fencing token is older than the last run cannot start a run, e.g. after the fence key was lost
+	h.redis.Del(fmt.Sprintf(constants.JobFenceKey, name))
+	staleJob, _, _ := blockingJob(name, 0)
+	stale, err := jobs.NewRunner(h.app, []jobs.Job{staleJob})
+	require.NoError(t, err)
+	defer stale.Stop(time.Second)
+	_, err = stale.Trigger(name, 1)
+	assert.EqualError(t, err, "fencing token is older than the last run")
+
+	runs, err = h.repos.JobRuns.ListJobRuns(context.Background(), models.ListJobRunsParams{JobName: name, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+}
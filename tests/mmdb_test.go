@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// city is the location of a network in the fixture GeoIP database.
+type city struct {
+	country   string
+	latitude  float64
+	longitude float64
+}
+
+// writeCityDB writes an IPv4 GeoIP2-City database in the MaxMind DB format with the given networks,
+// e.g. "1.0.0.0/16", which must not overlap, and returns its path.
+// Only the fields geoip.Locator reads are written.
+func writeCityDB(t *testing.T, networks map[string]city) string {
+	t.Helper()
+
+	// A record of the search tree is a node (>= 0), empty (-1) or the data of the network at index -record-2
+	nodes := [][2]int{{-1, -1}}
+	var data bytes.Buffer
+	var offsets []int
+
+	prefixes := make([]string, 0, len(networks))
+	for prefix := range networks {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	for _, prefix := range prefixes {
+		_, network, err := net.ParseCIDR(prefix)
+		require.NoError(t, err)
+		ip := network.IP.To4()
+		require.NotNil(t, ip, "%s is not an IPv4 network", prefix)
+		ones, _ := network.Mask.Size()
+		require.NotZero(t, ones, prefix)
+
+		location := networks[prefix]
+		offsets = append(offsets, data.Len())
+		mmdbEncode(&data, map[string]interface{}{
+			"country":  map[string]interface{}{"iso_code": location.country},
+			"location": map[string]interface{}{"latitude": location.latitude, "longitude": location.longitude},
+		})
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i/8]>>(7-i%8)) & 1
+			if i == ones-1 {
+				require.Equal(t, -1, nodes[node][bit], "%s overlaps another network", prefix)
+				nodes[node][bit] = -len(offsets) - 1
+				break
+			}
+			if nodes[node][bit] == -1 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			require.GreaterOrEqual(t, nodes[node][bit], 0, "%s overlaps another network", prefix)
+			node = nodes[node][bit]
+		}
+	}
+
+	// The tree has 24-bit records: a node number, the node count for an empty record,
+	// or the node count + 16 + the offset of the data
+	var file bytes.Buffer
+	for _, node := range nodes {
+		for _, record := range node {
+			value := len(nodes)
+			if record >= 0 {
+				value = record
+			} else if record < -1 {
+				value = len(nodes) + 16 + offsets[-record-2]
+			}
+			file.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	file.Write(make([]byte, 16))
+	file.Write(data.Bytes())
+	file.WriteString("\xAB\xCD\xEFMaxMind.com")
+	mmdbEncode(&file, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(0),
+		"database_type":               "GeoIP2-City",
+		"description":                 map[string]interface{}{"en": "Test city database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []string{"en"},
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(24),
+	})
+
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	require.NoError(t, os.WriteFile(path, file.Bytes(), 0o600))
+	return path
+}
+
+// mmdbEncode writes a value to the data section of a MaxMind DB.
+func mmdbEncode(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		mmdbControl(buf, 2, len(v))
+		buf.WriteString(v)
+	case float64:
+		mmdbControl(buf, 3, 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case uint16:
+		mmdbUint(buf, 5, uint64(v))
+	case uint32:
+		mmdbUint(buf, 6, uint64(v))
+	case uint64:
+		mmdbUint(buf, 9, v)
+	case []string:
+		mmdbControl(buf, 11, len(v))
+		for _, item := range v {
+			mmdbEncode(buf, item)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		mmdbControl(buf, 7, len(keys))
+		for _, key := range keys {
+			mmdbEncode(buf, key)
+			mmdbEncode(buf, v[key])
+		}
+	default:
+		panic("mmdbEncode: unsupported type")
+	}
+}
+
+// mmdbUint writes an unsigned integer of the given type with as few bytes as possible.
+func mmdbUint(buf *bytes.Buffer, typ int, v uint64) {
+	var raw [8]byte
+	binary.BigEndian.PutUint64(raw[:], v)
+	size := 8
+	for size > 0 && raw[8-size] == 0 {
+		size--
+	}
+	mmdbControl(buf, typ, size)
+	buf.Write(raw[8-size:])
+}
+
+// mmdbControl writes the control byte of a value: its type, extended when above 7, and its size.
+func mmdbControl(buf *bytes.Buffer, typ int, size int) {
+	var sizeBits byte
+	var extra []byte
+	switch {
+	case size < 29:
+		sizeBits = byte(size)
+	case size < 29+256:
+		sizeBits, extra = 29, []byte{byte(size - 29)}
+	default:
+		panic("mmdbControl: size too large")
+	}
+
+	if typ <= 7 {
+		buf.WriteByte(byte(typ)<<5 | sizeBits)
+	} else {
+		buf.WriteByte(sizeBits)
+		buf.WriteByte(byte(typ - 7))
+	}
+	buf.Write(extra)
+}
@@ -0,0 +1,235 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/configs/common/constants"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// riskRules are the rules of config.example.yaml: an OTP from 30 and blocked from 70.
+var riskRules = models.RiskConfig{
+	Enabled:              true,
+	MediumScore:          30,
+	HighScore:            70,
+	NewDevice:            20,
+	NewNetwork:           15,
+	IPChange:             10,
+	FailedAttempts:       25,
+	FailedAttemptsMin:    3,
+	BlacklistedNeighbour: 30,
+	ImpossibleTravel:     50,
+	MaxTravelSpeed:       900,
+}
+
+func TestLoginRisk(t *testing.T) {
+	//* Hanoi and Paris are about 9,200 km apart; 3.0.0.0/8 is not in the database
+	cityDB := writeCityDB(t, map[string]city{
+		"1.0.0.0/16": {country: "VN", latitude: 21.0285, longitude: 105.8542},
+		"5.0.0.0/16": {country: "VN", latitude: 21.0285, longitude: 105.8542},
+		"2.0.0.0/16": {country: "FR", latitude: 48.8566, longitude: 2.3522},
+	})
+
+	const email = "ivan@example.com"
+
+	tests := []struct {
+		name        string
+		disabled    bool
+		noGeoIP     bool
+		lastIP      string        // IP of the sign-in of the registration, from device-1; 1.0.0.1 when empty
+		lastAgo     time.Duration // how long ago that sign-in was
+		device      string
+		ip          string
+		failed      int    // wrong passwords before the login
+		blacklisted string // IP added to the blacklist
+		decision    string
+		signals     []string
+	}{
+		{
+			name:     "known device and network",
+			device:   "device-1",
+			ip:       "1.0.0.1",
+			decision: constants.RiskDecisionAllow,
+		},
+		{
+			name:     "new device",
+			device:   "device-2",
+			ip:       "1.0.0.1",
+			decision: constants.RiskDecisionAllow,
+			signals:  []string{constants.RiskSignalNewDevice},
+		},
+		{
+			name:     "known device from another IP of the network",
+			device:   "device-1",
+			ip:       "1.0.0.2",
+			decision: constants.RiskDecisionAllow,
+			signals:  []string{constants.RiskSignalIPChange},
+		},
+		{
+			name:     "new device and network in the same city",
+			device:   "device-2",
+			ip:       "5.0.0.1",
+			decision: constants.RiskDecisionStepUp,
+			signals:  []string{constants.RiskSignalNewDevice, constants.RiskSignalNewNetwork},
+		},
+		{
+			name:     "failed passwords below the minimum",
+			device:   "device-1",
+			ip:       "1.0.0.1",
+			failed:   2,
+			decision: constants.RiskDecisionAllow,
+		},
+		{
+			name:     "failed passwords",
+			device:   "device-1",
+			ip:       "1.0.0.2",
+			failed:   3,
+			decision: constants.RiskDecisionStepUp,
+			signals:  []string{constants.RiskSignalIPChange, constants.RiskSignalFailedAttempts},
+		},
+		{
+			name:        "blacklisted neighbour at the step-up score",
+			device:      "device-1",
+			ip:          "1.0.0.1",
+			blacklisted: "1.0.0.9",
+			decision:    constants.RiskDecisionStepUp,
+			signals:     []string{constants.RiskSignalBlacklistedNeighbour},
+		},
+		{
+			name:        "every signal but travel",
+			device:      "device-2",
+			ip:          "5.0.0.1",
+			failed:      3,
+			blacklisted: "5.0.0.9",
+			decision:    constants.RiskDecisionBlock,
+			signals: []string{constants.RiskSignalNewDevice, constants.RiskSignalNewNetwork,
+				constants.RiskSignalFailedAttempts, constants.RiskSignalBlacklistedNeighbour},
+		},
+		{
+			name:     "impossible travel",
+			device:   "device-1",
+			ip:       "2.0.0.1",
+			decision: constants.RiskDecisionBlock,
+			signals:  []string{constants.RiskSignalIPChange, constants.RiskSignalNewNetwork, constants.RiskSignalImpossibleTravel},
+		},
+		{
+			name:     "travel possible since the last sign-in",
+			device:   "device-1",
+			ip:       "2.0.0.1",
+			lastAgo:  24 * time.Hour,
+			decision: constants.RiskDecisionAllow,
+			signals:  []string{constants.RiskSignalIPChange, constants.RiskSignalNewNetwork},
+		},
+		{
+			name:     "IP not in the GeoIP database",
+			device:   "device-1",
+			ip:       "3.0.0.1",
+			decision: constants.RiskDecisionAllow,
+			signals:  []string{constants.RiskSignalIPChange, constants.RiskSignalNewNetwork},
+		},
+		{
+			name:     "last sign-in not in the GeoIP database",
+			lastIP:   "3.0.0.1",
+			device:   "device-1",
+			ip:       "2.0.0.1",
+			decision: constants.RiskDecisionAllow,
+			signals:  []string{constants.RiskSignalIPChange, constants.RiskSignalNewNetwork},
+		},
+		{
+			name:     "no GeoIP database",
+			noGeoIP:  true,
+			device:   "device-1",
+			ip:       "2.0.0.1",
+			decision: constants.RiskDecisionAllow,
+			signals:  []string{constants.RiskSignalIPChange, constants.RiskSignalNewNetwork},
+		},
+		{
+			name:     "disabled",
+			disabled: true,
+			device:   "device-2",
+			ip:       "2.0.0.1",
+			failed:   3,
+			decision: constants.RiskDecisionAllow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, func(cfg *models.Config) {
+				cfg.Risk = riskRules
+				cfg.Risk.Enabled = !tt.disabled
+				if !tt.noGeoIP {
+					cfg.GeoIP.CityPath = cityDB
+				}
+			})
+
+			first := h.client("device-1")
+			first.ip = "1.0.0.1"
+			if tt.lastIP != "" {
+				first.ip = tt.lastIP
+			}
+			h.store.SetClock(func() time.Time { return time.Now().Add(-tt.lastAgo) })
+			userID, password := register(t, first, email)
+			h.store.SetClock(time.Now)
+
+			if tt.blacklisted != "" {
+				_, err := h.redis.SAdd(constants.BlackListIP, tt.blacklisted)
+				require.NoError(t, err)
+			}
+
+			c := h.client(tt.device)
+			c.ip = tt.ip
+			for i := 0; i < tt.failed; i++ {
+				h.resetLoginLimit()
+				c.fails(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": email, "password": "Wrong-password1"}, http.StatusBadRequest, response.ErrorPasswordNotMatch)
+			}
+
+			h.resetLoginLimit()
+			res := c.do(http.MethodPost, "/v1/auth/login-identifier", map[string]string{"identifier": email, "password": password})
+			switch tt.decision {
+			case constants.RiskDecisionAllow:
+				require.Equal(t, http.StatusOK, res.Status, "%+v", res)
+				var result models.LoginResponse
+				res.decode(t, &result)
+				assert.NotEmpty(t, result.AccessToken)
+			case constants.RiskDecisionStepUp:
+				require.Equal(t, http.StatusOK, res.Status, "%+v", res)
+				var twoFactor models.LoginTwoFactor
+				res.decode(t, &twoFactor)
+				assert.Equal(t, response.ErrTwoFactorEnabled, twoFactor.Code)
+				assert.True(t, twoFactor.Risk)
+			case constants.RiskDecisionBlock:
+				require.Equal(t, http.StatusForbidden, res.Status, "%+v", res)
+				assert.Equal(t, response.ErrCodeLoginRiskBlocked, res.Code)
+			}
+
+			//* The signals found are recorded in the audit log
+			events, err := h.repos.Audit.ListAuditEvents(context.Background(), models.ListAuditEventsParams{
+				UserID:    sql.NullInt32{Int32: int32(userID), Valid: true},
+				EventType: sql.NullString{String: constants.AuditLoginRisk, Valid: true},
+				Limit:     10,
+			})
+			require.NoError(t, err)
+			if len(tt.signals) == 0 {
+				assert.Empty(t, events)
+				return
+			}
+			require.Len(t, events, 1)
+			var risk struct {
+				Signals  []string `json:"signals"`
+				Decision string   `json:"decision"`
+			}
+			require.NoError(t, json.Unmarshal(events[0].Metadata, &risk))
+			assert.ElementsMatch(t, tt.signals, risk.Signals)
+			assert.Equal(t, tt.decision, risk.Decision)
+		})
+	}
+}
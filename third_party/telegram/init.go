@@ -3,13 +3,18 @@ package third_party
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 
-	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/global"
+	"github.com/fdhhhdjd/Go_Secure_Auth_Pro/internal/models"
 )
 
+// ErrNotConfigured is returned when no bot token is configured; nothing is sent then.
+var ErrNotConfigured = errors.New("telegram bot token is not configured")
+
 type TelegramMessage struct {
 	ChatID                string `json:"chat_id"`
 	Text                  string `json:"text"`
@@ -19,13 +24,17 @@ type TelegramMessage struct {
 }
 
 // SendTelegramMessage sends a message to a Telegram chat using the Telegram Bot API.
-// It takes the message content, parse mode, disable web page preview, and disable notification as parameters.
-// Returns an error if there was a problem sending the message.
-func SendTelegramMessage(message string, parseMode string, disableWebPagePreview bool, disableNotification bool) error {
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", global.Cfg.Telegram.BotToken)
+// It takes the bot configuration, the message content, parse mode, disable web page preview, and disable notification as parameters.
+// Returns an error if there was a problem sending the message, or ErrNotConfigured without a bot token.
+func SendTelegramMessage(cfg models.TelegramConfig, message string, parseMode string, disableWebPagePreview bool, disableNotification bool) error {
+	if cfg.BotToken == "" {
+		return ErrNotConfigured
+	}
+
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", cfg.BotToken)
 
 	telegramMessage := TelegramMessage{
-		ChatID:                global.Cfg.Telegram.ChatID,
+		ChatID:                cfg.ChatID,
 		Text:                  message,
 		ParseMode:             parseMode,
 		DisableWebPagePreview: disableWebPagePreview,
@@ -48,14 +57,18 @@ func SendTelegramMessage(message string, parseMode string, disableWebPagePreview
 		return err
 	}
 
-	fmt.Printf("Response from Telegram API: %s\n", string(body))
+	slog.Debug("Response from Telegram API", "status", response.StatusCode, "body", string(body))
 	return nil
 }
 
 // PingTelegram pings the Telegram API to check if the bot is reachable.
 // It sends a GET request to the Telegram API's getMe endpoint using the provided bot token.
-// If the API returns a non-OK status, it returns an error.
+// If the API returns a non-OK status, it returns an error; without a bot token it returns ErrNotConfigured.
 func PingTelegram(botToken string) error {
+	if botToken == "" {
+		return ErrNotConfigured
+	}
+
 	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/getMe", botToken)
 
 	response, err := http.Get(apiURL)